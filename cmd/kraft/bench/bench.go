@@ -0,0 +1,610 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package bench
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	"kraftkit.sh/config"
+	"kraftkit.sh/iostreams"
+	"kraftkit.sh/log"
+	"kraftkit.sh/machine"
+	machinedriver "kraftkit.sh/machine/driver"
+	machinedriveropts "kraftkit.sh/machine/driveropts"
+	"kraftkit.sh/packmanager"
+	"kraftkit.sh/utils"
+
+	"kraftkit.sh/internal/cmdfactory"
+	"kraftkit.sh/internal/cmdutil"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/dustin/go-humanize"
+	goprocess "github.com/shirou/gopsutil/v3/process"
+	"github.com/spf13/cobra"
+)
+
+// rssSampleInterval is the interval at which the resident set size of the VMM
+// is sampled whilst waiting for the machine to become ready
+const rssSampleInterval = 10 * time.Millisecond
+
+type benchOptions struct {
+	PackageManager func(opts ...packmanager.PackageManagerOption) (packmanager.PackageManager, error)
+	ConfigManager  func() (*config.ConfigManager, error)
+	Logger         func() (log.Logger, error)
+	IO             *iostreams.IOStreams
+
+	// Command-line arguments
+	Architecture string
+	DisableAccel bool
+	Format       string
+	Hypervisor   string
+	Machine      string
+	Memory       int
+	Platform     string
+	Ready        string
+	Runs         int
+	Timeout      time.Duration
+}
+
+func BenchCmd(f *cmdfactory.Factory) *cobra.Command {
+	cmd, err := cmdutil.NewCmd(f, "bench")
+	if err != nil {
+		panic("could not initialize 'kraft bench' command")
+	}
+
+	opts := &benchOptions{
+		PackageManager: f.PackageManager,
+		ConfigManager:  f.ConfigManager,
+		Logger:         f.Logger,
+		IO:             f.IOStreams,
+	}
+
+	cmd.Short = "Measure the boot time and memory footprint of a unikernel"
+	cmd.Use = "bench [FLAGS] KERNEL [ARGS]"
+	cmd.Args = cobra.MinimumNArgs(1)
+	cmd.Long = heredoc.Doc(`
+		Measure the boot time and memory footprint of a unikernel
+
+		The unikernel is repeatedly created, started and destroyed.  For each run
+		the time taken to spawn the VMM, to start (or continue) the machine and
+		until the machine is ready is recorded, along with the peak resident set
+		size (RSS) of the VMM.  A machine is ready when it has written its first
+		byte to the console or, if --ready is set, once its console output matches
+		the provided regular expression.`)
+	cmd.Example = heredoc.Doc(`
+		# Benchmark a unikernel over 10 runs
+		kraft bench --arch x86_64 --plat kvm path/to/kernel-x86_64-kvm
+
+		# Benchmark a unikernel until it reports that it is listening
+		kraft bench --runs 50 --ready 'Listening on' --arch x86_64 --plat kvm path/to/kernel
+
+		# Output the results as JSON
+		kraft bench --format json --arch x86_64 --plat kvm path/to/kernel`)
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		opts.Hypervisor = cmd.Flag("hypervisor").Value.String()
+		opts.Format = cmd.Flag("format").Value.String()
+
+		return runBench(opts, args...)
+	}
+
+	cmd.Flags().IntVarP(
+		&opts.Runs,
+		"runs", "n",
+		10,
+		"Number of times to boot the unikernel.",
+	)
+
+	cmd.Flags().StringVar(
+		&opts.Ready,
+		"ready",
+		"",
+		"Regular expression matching console output which indicates the unikernel is ready (default is the first byte of output).",
+	)
+
+	cmd.Flags().DurationVar(
+		&opts.Timeout,
+		"timeout",
+		30*time.Second,
+		"Maximum time to wait for the unikernel to become ready in each run.",
+	)
+
+	cmd.Flags().VarP(
+		cmdutil.NewEnumFlag([]string{"table", "json"}, "table"),
+		"format",
+		"f",
+		"Set the output format.",
+	)
+
+	cmd.Flags().VarP(
+		cmdutil.NewEnumFlag(append(machinedriver.DriverNames(), "auto"), "auto"),
+		"hypervisor",
+		"H",
+		"Set the hypervisor machine driver, by default selected based on the platform and architecture.",
+	)
+
+	cmd.Flags().StringVar(
+		&opts.Machine,
+		"machine",
+		"",
+		"Set the hypervisor machine type (e.g. pc or microvm for QEMU on x86_64).",
+	)
+
+	cmd.Flags().BoolVarP(
+		&opts.DisableAccel,
+		"disable-acceleration", "W",
+		false,
+		"Disable acceleration of CPU (usually enables TCG).",
+	)
+
+	cmd.Flags().IntVarP(
+		&opts.Memory,
+		"memory", "M",
+		64,
+		"Assign MB memory to the unikernel.",
+	)
+
+	cmd.Flags().StringVar(
+		&opts.Architecture,
+		"arch",
+		"",
+		"Architecture of the unikernel.",
+	)
+
+	cmd.Flags().StringVar(
+		&opts.Platform,
+		"plat",
+		"",
+		"Platform of the unikernel.",
+	)
+
+	return cmd
+}
+
+// benchSample contains the measurements of a single run.
+type benchSample struct {
+	Spawn time.Duration `json:"spawn"`
+	Start time.Duration `json:"start"`
+	Ready time.Duration `json:"ready"`
+	RSS   uint64        `json:"rss"`
+}
+
+// benchStats summarizes a single metric over all runs.
+type benchStats struct {
+	Min float64 `json:"min"`
+	Avg float64 `json:"avg"`
+	P95 float64 `json:"p95"`
+}
+
+// benchResult is the output of `kraft bench --format json`.  Durations are
+// represented in nanoseconds and memory in bytes.
+type benchResult struct {
+	Kernel  string        `json:"kernel"`
+	Driver  string        `json:"driver"`
+	Machine string        `json:"machine,omitempty"`
+	Expr    string        `json:"ready,omitempty"`
+	Runs    int           `json:"runs"`
+	Spawn   benchStats    `json:"spawn"`
+	Start   benchStats    `json:"start"`
+	Ready   benchStats    `json:"time_to_ready"`
+	RSS     benchStats    `json:"rss"`
+	Samples []benchSample `json:"samples"`
+}
+
+// summarize calculates the minimum, mean and 95th percentile (nearest rank) of
+// the provided values.
+func summarize(values []float64) benchStats {
+	if len(values) == 0 {
+		return benchStats{}
+	}
+
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+
+	var sum float64
+	for _, v := range sorted {
+		sum += v
+	}
+
+	rank := int(math.Ceil(0.95*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+
+	return benchStats{
+		Min: sorted[0],
+		Avg: sum / float64(len(sorted)),
+		P95: sorted[rank],
+	}
+}
+
+// readyWriter receives the console output of a machine and signals once the
+// output satisfies the readiness condition.  Without an expression the first
+// byte of output is considered ready.
+type readyWriter struct {
+	mu    sync.Mutex
+	expr  *regexp.Regexp
+	buf   bytes.Buffer
+	once  sync.Once
+	ready chan time.Time
+}
+
+func newReadyWriter(expr *regexp.Regexp) *readyWriter {
+	return &readyWriter{
+		expr:  expr,
+		ready: make(chan time.Time, 1),
+	}
+}
+
+func (w *readyWriter) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	now := time.Now()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.expr != nil {
+		w.buf.Write(p)
+		if !w.expr.Match(w.buf.Bytes()) {
+			return len(p), nil
+		}
+	}
+
+	w.once.Do(func() {
+		w.ready <- now
+	})
+
+	return len(p), nil
+}
+
+func runBench(opts *benchOptions, args ...string) error {
+	plog, err := opts.Logger()
+	if err != nil {
+		return err
+	}
+
+	cfgm, err := opts.ConfigManager()
+	if err != nil {
+		return err
+	}
+
+	if opts.Runs < 1 {
+		return fmt.Errorf("number of runs must be at least 1")
+	}
+
+	if len(opts.Architecture) == 0 || len(opts.Platform) == 0 {
+		return fmt.Errorf("cannot use `kraft bench KERNEL` without specifying --arch and --plat")
+	}
+
+	var expr *regexp.Regexp
+	if len(opts.Ready) > 0 {
+		if expr, err = regexp.Compile(opts.Ready); err != nil {
+			return fmt.Errorf("could not compile readiness expression: %v", err)
+		}
+	}
+
+	kernel := args[0]
+	if f, err := os.Stat(kernel); err != nil {
+		return fmt.Errorf("could not access kernel: %v", err)
+	} else if f.IsDir() {
+		return fmt.Errorf("kernel is a directory: %s", kernel)
+	}
+
+	if opts.Hypervisor == "config" {
+		opts.Hypervisor = cfgm.Config.DefaultPlat
+	}
+
+	var driverType machinedriver.DriverType
+	if opts.Hypervisor == "auto" || len(opts.Hypervisor) == 0 {
+		if driverType, err = machinedriver.SelectDriver(opts.Platform, opts.Architecture); err != nil {
+			return err
+		}
+	} else {
+		if !utils.Contains(machinedriver.DriverNames(), opts.Hypervisor) {
+			return fmt.Errorf("unknown hypervisor driver: %s", opts.Hypervisor)
+		}
+
+		driverType = machinedriver.DriverTypeFromName(opts.Hypervisor)
+		if err := machinedriver.ValidateCompatibility(driverType, opts.Platform, opts.Architecture); err != nil {
+			return err
+		}
+	}
+
+	if len(opts.Machine) > 0 && driverType != machinedriver.QemuDriver {
+		return fmt.Errorf("machine type %s is not supported by the %s driver", opts.Machine, driverType.String())
+	}
+
+	mopts := []machine.MachineOption{
+		machine.WithArchitecture(opts.Architecture),
+		machine.WithPlatform(opts.Platform),
+		machine.WithKernel(kernel),
+		machine.WithSource("kernel://" + filepath.Base(kernel)),
+		machine.WithAcceleration(!opts.DisableAccel),
+		machine.WithDriverName(driverType.String()),
+		machine.WithMemorySize(uint64(opts.Memory)),
+		machine.WithMachineType(opts.Machine),
+		machine.WithArguments(args[1:]),
+	}
+
+	mcfg, err := machine.NewMachineConfig(mopts...)
+	if err != nil {
+		return err
+	}
+
+	if _, err := mcfg.Validate(); err != nil {
+		return err
+	}
+
+	// Machines are kept apart from those listed by `kraft ps` as they only exist
+	// for the duration of a run.  UNIX socket paths are limited in length, so
+	// the directory is kept short.
+	runtimeDir, err := os.MkdirTemp("", "kraft-bench")
+	if err != nil {
+		return fmt.Errorf("could not create runtime directory: %v", err)
+	}
+
+	defer os.RemoveAll(runtimeDir)
+
+	store, err := machine.NewMachineStoreFromPath(runtimeDir)
+	if err != nil {
+		return fmt.Errorf("could not access machine store: %v", err)
+	}
+
+	driver, err := machinedriver.New(driverType,
+		machinedriveropts.WithRuntimeDir(runtimeDir),
+		machinedriveropts.WithMachineStore(store),
+		machinedriveropts.WithLogger(plog),
+	)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	samples := make([]benchSample, 0, opts.Runs)
+
+	for i := 0; i < opts.Runs; i++ {
+		sample, err := benchRun(ctx, driver, expr, opts.Timeout,
+			append(mopts, machine.WithName(machine.MachineName(fmt.Sprintf("bench-%d", i))))...,
+		)
+		if err != nil {
+			return fmt.Errorf("run %d: %v", i+1, err)
+		}
+
+		plog.Debugf("run %d: spawn=%s start=%s ready=%s rss=%s",
+			i+1, sample.Spawn, sample.Start, sample.Ready, humanize.IBytes(sample.RSS),
+		)
+
+		samples = append(samples, *sample)
+	}
+
+	var spawn, start, ready, rss []float64
+	for _, sample := range samples {
+		spawn = append(spawn, float64(sample.Spawn))
+		start = append(start, float64(sample.Start))
+		ready = append(ready, float64(sample.Ready))
+		rss = append(rss, float64(sample.RSS))
+	}
+
+	result := benchResult{
+		Kernel:  kernel,
+		Driver:  driverType.String(),
+		Machine: opts.Machine,
+		Expr:    opts.Ready,
+		Runs:    opts.Runs,
+		Spawn:   summarize(spawn),
+		Start:   summarize(start),
+		Ready:   summarize(ready),
+		RSS:     summarize(rss),
+		Samples: samples,
+	}
+
+	if opts.Format == "json" {
+		encoder := json.NewEncoder(opts.IO.Out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(result)
+	}
+
+	readyMetric := "FIRST OUTPUT"
+	if expr != nil {
+		readyMetric = "READY"
+	}
+
+	cs := opts.IO.ColorScheme()
+	table := utils.NewTablePrinter(opts.IO)
+
+	// Header row
+	table.AddField("METRIC", nil, cs.Bold)
+	table.AddField("MIN", nil, cs.Bold)
+	table.AddField("AVG", nil, cs.Bold)
+	table.AddField("P95", nil, cs.Bold)
+	table.EndRow()
+
+	duration := func(v float64) string {
+		return time.Duration(v).Round(time.Microsecond).String()
+	}
+	size := func(v float64) string {
+		return humanize.IBytes(uint64(v))
+	}
+
+	for _, row := range []struct {
+		name   string
+		stats  benchStats
+		format func(float64) string
+	}{
+		{"SPAWN", result.Spawn, duration},
+		{"START", result.Start, duration},
+		{readyMetric, result.Ready, duration},
+		{"RSS", result.RSS, size},
+	} {
+		table.AddField(row.name, nil, nil)
+		table.AddField(row.format(row.stats.Min), nil, nil)
+		table.AddField(row.format(row.stats.Avg), nil, nil)
+		table.AddField(row.format(row.stats.P95), nil, nil)
+		table.EndRow()
+	}
+
+	return table.Render()
+}
+
+// benchRun creates, starts and destroys a single machine whilst measuring the
+// time taken for each step and sampling the RSS of its VMM.
+func benchRun(ctx context.Context, driver machinedriver.Driver, expr *regexp.Regexp, timeout time.Duration, mopts ...machine.MachineOption) (*benchSample, error) {
+	sample := &benchSample{}
+
+	begin := time.Now()
+	mid, err := driver.Create(ctx, mopts...)
+	if err != nil {
+		return nil, fmt.Errorf("could not create machine: %v", err)
+	}
+
+	sample.Spawn = time.Since(begin)
+
+	// Always remove the machine, even if the benchmark has been interrupted
+	defer driver.Destroy(context.Background(), mid)
+
+	tctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Attach to the console before the machine is started such that no output
+	// is missed.  Some drivers only make the console available once started or
+	// stop tailing when the machine is not running, so keep trying until the
+	// console has been tailed after the machine was started.
+	w := newReadyWriter(expr)
+	started := make(chan struct{})
+	go func() {
+		for {
+			wasStarted := false
+			select {
+			case <-started:
+				wasStarted = true
+			default:
+			}
+
+			if err := driver.TailWriter(tctx, mid, w); err == nil && wasStarted {
+				return
+			}
+
+			select {
+			case <-tctx.Done():
+				return
+			case <-time.After(rssSampleInterval):
+			}
+		}
+	}()
+
+	begin = time.Now()
+	if err := driver.Start(ctx, mid); err != nil {
+		return nil, fmt.Errorf("could not start machine: %v", err)
+	}
+
+	sample.Start = time.Since(begin)
+	close(started)
+
+	var proc *goprocess.Process
+	if pid, err := driver.Pid(ctx, mid); err == nil {
+		proc, _ = goprocess.NewProcess(int32(pid))
+	}
+
+	sampleRSS := func() {
+		if proc == nil {
+			return
+		}
+
+		mem, err := proc.MemoryInfo()
+		if err != nil {
+			return
+		}
+
+		if mem.RSS > sample.RSS {
+			sample.RSS = mem.RSS
+		}
+	}
+
+	ticker := time.NewTicker(rssSampleInterval)
+	defer ticker.Stop()
+
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	for {
+		sampleRSS()
+
+		select {
+		case at := <-w.ready:
+			sample.Ready = at.Sub(begin)
+			sampleRSS()
+			return sample, nil
+
+		case <-ticker.C:
+			state, err := driver.State(ctx, mid)
+			if err != nil {
+				return nil, fmt.Errorf("could not get machine state: %v", err)
+			}
+
+			switch state {
+			case machine.MachineStateExited, machine.MachineStateDead, machine.MachineStateCrashed:
+				// Console output may still be in flight
+				select {
+				case at := <-w.ready:
+					sample.Ready = at.Sub(begin)
+					return sample, nil
+				case <-time.After(100 * time.Millisecond):
+				}
+
+				return nil, fmt.Errorf("machine %s before becoming ready", state)
+			}
+
+		case <-deadline.C:
+			return nil, fmt.Errorf("machine did not become ready within %s", timeout)
+
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
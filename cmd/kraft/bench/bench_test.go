@@ -0,0 +1,114 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package bench
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestSummarize(t *testing.T) {
+	hundred := make([]float64, 100)
+	for i := range hundred {
+		hundred[i] = float64(100 - i)
+	}
+
+	tests := []struct {
+		name     string
+		values   []float64
+		expected benchStats
+	}{
+		{
+			name:     "empty",
+			values:   nil,
+			expected: benchStats{},
+		},
+		{
+			name:     "single sample",
+			values:   []float64{42},
+			expected: benchStats{Min: 42, Avg: 42, P95: 42},
+		},
+		{
+			name:     "unsorted",
+			values:   []float64{30, 10, 20},
+			expected: benchStats{Min: 10, Avg: 20, P95: 30},
+		},
+		{
+			name:     "nearest rank",
+			values:   hundred,
+			expected: benchStats{Min: 1, Avg: 50.5, P95: 95},
+		},
+		{
+			name:     "twenty samples",
+			values:   []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 100},
+			expected: benchStats{Min: 1, Avg: 14.5, P95: 19},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			values := append([]float64(nil), test.values...)
+
+			if stats := summarize(test.values); stats != test.expected {
+				t.Errorf("expected %+v, got %+v", test.expected, stats)
+			}
+
+			for i := range values {
+				if values[i] != test.values[i] {
+					t.Fatalf("expected the samples not to be reordered")
+				}
+			}
+		})
+	}
+}
+
+func TestReadyWriter(t *testing.T) {
+	w := newReadyWriter(regexp.MustCompile(`Listening on port \d+`))
+
+	for _, chunk := range []string{"Booting...\n", "Listening on ", "port 80\n", "more output\n"} {
+		if _, err := w.Write([]byte(chunk)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	select {
+	case <-w.ready:
+	default:
+		t.Fatalf("expected the output to be ready")
+	}
+
+	select {
+	case <-w.ready:
+		t.Errorf("expected readiness to be signalled once")
+	default:
+	}
+}
@@ -34,6 +34,8 @@ package build
 import (
 	"fmt"
 	"os"
+	"strings"
+	"sync"
 
 	"github.com/MakeNowJust/heredoc"
 	"github.com/spf13/cobra"
@@ -46,6 +48,7 @@ import (
 	"kraftkit.sh/internal/cmdfactory"
 	"kraftkit.sh/internal/cmdutil"
 	"kraftkit.sh/internal/logger"
+	"kraftkit.sh/internal/semver"
 
 	"kraftkit.sh/iostreams"
 	"kraftkit.sh/log"
@@ -88,6 +91,7 @@ type buildOptions struct {
 	NoPull       bool
 	NoConfigure  bool
 	SaveBuildLog string
+	Frozen       bool
 }
 
 func BuildCmd(f *cmdfactory.Factory) *cobra.Command {
@@ -122,6 +126,17 @@ func BuildCmd(f *cmdfactory.Factory) *cobra.Command {
 
 		The default behaviour of %[1]skraft build%[1]s is to build a project.  Given no
 		arguments, you will be guided through interactive mode.
+
+		The version of each component is either an exact version, a channel or a
+		semantic version constraint, such as %[1]s^0.11%[1]s, %[1]s~1.2.3%[1]s or
+		%[1]s>=0.10 <0.12%[1]s, which is resolved to the highest matching version.
+
+		Libraries which are required by the components, as declared in their
+		manifests, are added to the project and pulled as well.
+
+		The exact version, Git commit or archive and checksum of each component
+		is recorded in %[1]skraft.lock%[1]s next to the Kraftfile.  Subsequent builds
+		pull the recorded content instead of resolving the components again.
 	`, "`")
 	cmd.Example = heredoc.Doc(`
 		# Build the current project (cwd)
@@ -129,6 +144,9 @@ func BuildCmd(f *cmdfactory.Factory) *cobra.Command {
 
 		# Build path to a Unikraft project
 		$ kraft build path/to/app
+
+		# Build exactly the components recorded in kraft.lock
+		$ kraft build --frozen
 	`)
 	cmd.RunE = func(cmd *cobra.Command, args []string) error {
 		if (len(opts.Architecture) > 0 || len(opts.Platform) > 0) && len(opts.Target) > 0 {
@@ -233,6 +251,13 @@ func BuildCmd(f *cmdfactory.Factory) *cobra.Command {
 		"Do not run Unikraft's prepare step before building",
 	)
 
+	cmd.Flags().BoolVar(
+		&opts.Frozen,
+		"frozen",
+		false,
+		"Fail instead of updating kraft.lock when it does not match the project",
+	)
+
 	return cmd
 }
 
@@ -254,26 +279,12 @@ func buildRun(opts *buildOptions, workdir string) error {
 		return err
 	}
 
-	// Initialize at least the configuration options for a project
-	projectOpts, err := app.NewProjectOptions(
-		nil,
-		app.WithLogger(plog),
-		app.WithWorkingDirectory(workdir),
-		app.WithDefaultConfigPath(),
-		app.WithPackageManager(&pm),
-		app.WithResolvedPaths(true),
-		app.WithDotConfig(false),
-	)
-	if err != nil {
-		return err
-	}
-
 	if !app.IsWorkdirInitialized(workdir) {
 		return fmt.Errorf("cannot build uninitialized project! start with: ukbuild init")
 	}
 
 	// Interpret the application
-	project, err := app.NewApplicationFromOptions(projectOpts)
+	project, err := app.LoadProject(pm, plog, workdir)
 	if err != nil {
 		return err
 	}
@@ -288,8 +299,37 @@ func buildRun(opts *buildOptions, workdir string) error {
 	var processes []*paraprogress.Process
 	var searches []*processtree.ProcessTreeItem
 
+	lock, err := app.NewLockFileFromPath(app.LockFilePath(workdir))
+	if err != nil {
+		return err
+	}
+
+	var pulled []pack.Package
+
+	// resolutions of version constraints and channels to exact versions
+	var resolutions []string
+	var resolutionsMu sync.Mutex
+
+	// lockedPullOptions returns the options which pull the content recorded in
+	// the lockfile for the package, if the package is locked at its version
+	lockedPullOptions := func(p pack.Package) ([]pack.PullPackageOption, error) {
+		if locked, ok := lock.Lookup(p.Options().Type, p.Name()); ok && locked.Version == p.Options().Version {
+			return locked.PullOptions(), nil
+		} else if opts.Frozen {
+			return nil, fmt.Errorf("%s is not locked in %s", p.Options().TypeNameVersion(), lock.Path())
+		}
+
+		return nil, nil
+	}
+
+	// kept are the locked components which are already placed in the project
+	// and which are therefore not pulled again
+	var kept []app.LockedComponent
+
 	_, err = project.Components()
-	if err != nil && project.Template().Name() != "" {
+	if locked, ok := lock.Lookup(unikraft.ComponentTypeApp, project.Template().Name()); err == nil && ok && semver.Satisfies(project.Template().Version(), locked.Version) {
+		kept = append(kept, locked)
+	} else if project.Template().Name() != "" {
 		var packages []pack.Package
 		search := processtree.NewProcessTreeItem(
 			fmt.Sprintf("finding %s/%s:%s...", project.Template().Type(), project.Template().Name(), project.Template().Version()), "",
@@ -300,10 +340,15 @@ func buildRun(opts *buildOptions, workdir string) error {
 					packmanager.WithLogger(l),
 				)
 
+				version := project.Template().Version()
+				if locked, ok := lock.Lookup(unikraft.ComponentTypeApp, project.Template().Name()); ok && semver.Satisfies(version, locked.Version) {
+					version = locked.Version
+				}
+
 				packages, err = pm.Catalog(packmanager.CatalogQuery{
 					Name:    project.Template().Name(),
 					Types:   []unikraft.ComponentType{unikraft.ComponentTypeApp},
-					Version: project.Template().Version(),
+					Version: version,
 					NoCache: opts.NoCache,
 				})
 				if err != nil {
@@ -336,6 +381,15 @@ func buildRun(opts *buildOptions, workdir string) error {
 			return fmt.Errorf("could not complete search: %v", err)
 		}
 
+		if resolved := packages[0].Options().Version; len(project.Template().Version()) > 0 && resolved != project.Template().Version() {
+			plog.Infof("resolved %s/%s:%s to %s", project.Template().Type(), project.Template().Name(), project.Template().Version(), resolved)
+		}
+
+		lockOpts, err := lockedPullOptions(packages[0])
+		if err != nil {
+			return err
+		}
+
 		proc := paraprogress.NewProcess(
 			fmt.Sprintf("pulling %s", packages[0].Options().TypeNameVersion()),
 			func(l log.Logger, w func(progress float64)) error {
@@ -345,16 +399,18 @@ func buildRun(opts *buildOptions, workdir string) error {
 					pack.WithLogger(l),
 				)
 
-				return packages[0].Pull(
+				return packages[0].Pull(append([]pack.PullPackageOption{
 					pack.WithPullProgressFunc(w),
 					pack.WithPullWorkdir(workdir),
 					pack.WithPullLogger(l),
 					// pack.WithPullChecksum(!opts.NoChecksum),
 					// pack.WithPullCache(!opts.NoCache),
-				)
+				}, lockOpts...)...)
 			},
 		)
 
+		pulled = append(pulled, packages[0])
+
 		processes = append(processes, proc)
 
 		paramodel, err := paraprogress.NewParaProgress(
@@ -373,31 +429,9 @@ func buildRun(opts *buildOptions, workdir string) error {
 		}
 	}
 
-	if project.Template().Name() != "" {
-		templateWorkdir, err := unikraft.PlaceComponent(workdir, project.Template().Type(), project.Template().Name())
-		if err != nil {
-			return err
-		}
-
-		templateOps, err := app.NewProjectOptions(
-			nil,
-			app.WithLogger(plog),
-			app.WithWorkingDirectory(templateWorkdir),
-			app.WithDefaultConfigPath(),
-			app.WithPackageManager(&pm),
-			app.WithResolvedPaths(true),
-			app.WithDotConfig(false),
-		)
-		if err != nil {
-			return err
-		}
-
-		templateProject, err := app.NewApplicationFromOptions(templateOps)
-		if err != nil {
-			return err
-		}
-
-		project = templateProject.MergeTemplate(project)
+	project, err = app.MergeProjectTemplate(pm, plog, workdir, project)
+	if err != nil {
+		return err
 	}
 
 	// Overwrite template with user options
@@ -417,6 +451,13 @@ func buildRun(opts *buildOptions, workdir string) error {
 					packmanager.WithLogger(l),
 				)
 
+				// Prefer the locked version as long as it satisfies the version
+				// requested by the project
+				version := component.Version()
+				if locked, ok := lock.Lookup(component.Type(), component.Name()); ok && semver.Satisfies(version, locked.Version) {
+					version = locked.Version
+				}
+
 				p, err := pm.Catalog(packmanager.CatalogQuery{
 					Name: component.Name(),
 					Types: []unikraft.ComponentType{
@@ -425,7 +466,7 @@ func buildRun(opts *buildOptions, workdir string) error {
 						unikraft.ComponentTypePlat,
 						unikraft.ComponentTypeArch,
 					},
-					Version: component.Version(),
+					Version: version,
 					NoCache: opts.NoCache,
 				})
 				if err != nil {
@@ -438,6 +479,12 @@ func buildRun(opts *buildOptions, workdir string) error {
 					return fmt.Errorf("too many options for %s", component.Component().Name)
 				}
 
+				if resolved := p[0].Options().Version; len(component.Version()) > 0 && resolved != component.Version() {
+					resolutionsMu.Lock()
+					resolutions = append(resolutions, fmt.Sprintf("resolved %s/%s:%s to %s", component.Type(), component.Name(), component.Version(), resolved))
+					resolutionsMu.Unlock()
+				}
+
 				missingPacks = append(missingPacks, p...)
 				return nil
 			},
@@ -463,6 +510,42 @@ func buildRun(opts *buildOptions, workdir string) error {
 		if err := treemodel.Start(); err != nil {
 			return fmt.Errorf("could not complete search: %v", err)
 		}
+
+		for _, resolution := range resolutions {
+			plog.Info(resolution)
+		}
+	}
+
+	// Add the libraries which are transitively required by the components
+	graph, err := packmanager.ResolveDependencies(pm, missingPacks,
+		packmanager.WithResolveNoCache(opts.NoCache),
+		packmanager.WithResolvePreference(func(t unikraft.ComponentType, name string) (string, bool) {
+			locked, ok := lock.Lookup(t, name)
+			return locked.Version, ok
+		}),
+	)
+	if err != nil {
+		return fmt.Errorf("could not resolve dependencies: %v", err)
+	}
+
+	for _, p := range graph.Packages() {
+		var requiredBy []string
+		for _, req := range graph.Requirements(p) {
+			requiredBy = append(requiredBy, req.By.Options().TypeNameVersion())
+		}
+
+		plog.Infof("adding %s required by %s", p.Options().TypeNameVersion(), strings.Join(requiredBy, ", "))
+
+		if p.Options().Type == unikraft.ComponentTypeLib {
+			if err := project.AddLibrary(p.Name(), p.Options().Version,
+				component.WithLogger(plog),
+				component.WithPackageManager(&pm),
+			); err != nil {
+				return err
+			}
+		}
+
+		missingPacks = append(missingPacks, p)
 	}
 
 	if len(missingPacks) > 0 {
@@ -471,6 +554,12 @@ func buildRun(opts *buildOptions, workdir string) error {
 				return fmt.Errorf("unexpected error occurred please try again")
 			}
 			p := p // loop closure
+
+			lockOpts, err := lockedPullOptions(p)
+			if err != nil {
+				return err
+			}
+
 			processes = append(processes, paraprogress.NewProcess(
 				fmt.Sprintf("pulling %s", p.Options().TypeNameVersion()),
 				func(l log.Logger, w func(progress float64)) error {
@@ -480,15 +569,17 @@ func buildRun(opts *buildOptions, workdir string) error {
 						pack.WithLogger(l),
 					)
 
-					return p.Pull(
+					return p.Pull(append([]pack.PullPackageOption{
 						pack.WithPullProgressFunc(w),
 						pack.WithPullWorkdir(workdir),
 						pack.WithPullLogger(l),
 						// pack.WithPullChecksum(!opts.NoChecksum),
 						// pack.WithPullCache(!opts.NoCache),
-					)
+					}, lockOpts...)...)
 				},
 			))
+
+			pulled = append(pulled, p)
 		}
 
 		paramodel, err := paraprogress.NewParaProgress(
@@ -507,6 +598,28 @@ func buildRun(opts *buildOptions, workdir string) error {
 		}
 	}
 
+	// Record what the components were resolved to
+	if len(pulled) > 0 {
+		resolved := app.NewLockFile(lock.Path())
+		for _, locked := range kept {
+			resolved.Set(locked)
+		}
+		for _, p := range pulled {
+			resolved.Set(app.LockPackage(p))
+		}
+
+		if !resolved.Equal(lock) {
+			if opts.Frozen {
+				return fmt.Errorf("%s does not match the components of the project", lock.Path())
+			}
+
+			plog.Infof("updating %s", lock.Path())
+			if err := resolved.Save(); err != nil {
+				return fmt.Errorf("could not save lockfile: %v", err)
+			}
+		}
+	}
+
 	processes = []*paraprogress.Process{} // reset
 
 	targets, err := project.Targets()
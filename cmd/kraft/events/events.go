@@ -33,16 +33,20 @@ package events
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
 
 	"kraftkit.sh/config"
+	"kraftkit.sh/exec"
 	"kraftkit.sh/iostreams"
 	"kraftkit.sh/log"
 	"kraftkit.sh/machine"
@@ -50,6 +54,7 @@ import (
 	"kraftkit.sh/machine/driveropts"
 	"kraftkit.sh/machine/qemu/qmp"
 	"kraftkit.sh/packmanager"
+	"kraftkit.sh/utils"
 
 	"kraftkit.sh/internal/cmdfactory"
 	"kraftkit.sh/internal/cmdutil"
@@ -67,6 +72,10 @@ type eventsOptions struct {
 	// Command-line arguments
 	QuitTogether bool
 	Granularity  time.Duration
+	Since        string
+	Until        string
+	Filter       []string
+	Format       string
 }
 
 func EventsCmd(f *cmdfactory.Factory) *cobra.Command {
@@ -83,13 +92,32 @@ func EventsCmd(f *cmdfactory.Factory) *cobra.Command {
 	}
 
 	cmd.Short = "Follow the events of a unikernel"
-	cmd.Hidden = true
 	cmd.Use = "events [FLAGS] [MACHINE ID]"
 	cmd.Args = cobra.MaximumNArgs(1)
 	cmd.Aliases = []string{"event", "e"}
 	cmd.Long = heredoc.Doc(`
-		Follow the events of a unikernel`)
+		Follow the lifecycle events of unikernels.
+
+		Each event is printed with the time it occurred, the machine ID, its name and
+		the driver which manages it.  Past events are retained in a bounded history
+		and can be queried with --since and --until.  When --until is not set, new
+		events are streamed as they occur.
+
+		Filters are provided as KEY=VALUE, where KEY is one of id, name, driver,
+		type or state.  Filters with the same key are combined with OR, whereas
+		different keys are combined with AND.`)
+	cmd.Example = heredoc.Doc(`
+		# Stream all new events as they occur
+		kraft events
+
+		# Show all exit events which occurred in the last hour
+		kraft events --since 1h --until 0s --filter type=exit
+
+		# Stream events of a particular machine as JSON
+		kraft events --format json 4b2ea7e1b4ab`)
 	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		opts.Format = cmd.Flag("format").Value.String()
+
 		return runEvents(opts, args...)
 	}
 
@@ -107,9 +135,194 @@ func EventsCmd(f *cmdfactory.Factory) *cobra.Command {
 		"How often the machine store and state should polled",
 	)
 
+	cmd.Flags().StringVar(
+		&opts.Since,
+		"since",
+		"",
+		"Show events created since a timestamp (RFC3339 or UNIX) or relative duration (e.g. 10m)",
+	)
+
+	cmd.Flags().StringVar(
+		&opts.Until,
+		"until",
+		"",
+		"Show events created until a timestamp (RFC3339 or UNIX) or relative duration (e.g. 10m)",
+	)
+
+	cmd.Flags().StringArrayVarP(
+		&opts.Filter,
+		"filter", "f",
+		[]string{},
+		"Filter events based on the provided KEY=VALUE conditions",
+	)
+
+	cmd.Flags().VarP(
+		cmdutil.NewEnumFlag([]string{"text", "json"}, "text"),
+		"format",
+		"o",
+		"Set the output format of events",
+	)
+
 	return cmd
 }
 
+// eventFilter holds the accepted values for each filter key.
+type eventFilter map[string][]string
+
+func newEventFilter(filters []string) (eventFilter, error) {
+	ef := make(eventFilter)
+
+	for _, filter := range filters {
+		key, value, ok := strings.Cut(filter, "=")
+		if !ok || len(value) == 0 {
+			return nil, fmt.Errorf("invalid filter, expected KEY=VALUE: %s", filter)
+		}
+
+		switch key {
+		case "id", "name", "driver", "state":
+		case "type":
+			if !utils.Contains(machine.MachineEventTypes(), value) {
+				return nil, fmt.Errorf("unknown event type: %s", value)
+			}
+		default:
+			return nil, fmt.Errorf("unknown filter key: %s", key)
+		}
+
+		ef[key] = append(ef[key], value)
+	}
+
+	return ef, nil
+}
+
+func (ef eventFilter) matches(event machine.MachineEvent) bool {
+	for key, values := range ef {
+		found := false
+
+		for _, value := range values {
+			switch key {
+			case "id":
+				found = strings.HasPrefix(event.ID.String(), value)
+			case "name":
+				found = string(event.Name) == value
+			case "driver":
+				found = event.Driver == value
+			case "type":
+				found = event.Type.String() == value
+			case "state":
+				found = event.State.String() == value
+			}
+
+			if found {
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// eventCursor tracks the position in the history of events which has been
+// streamed.  Distinct events may share a timestamp, so the events seen at the
+// latest timestamp are remembered rather than only the timestamp itself.
+type eventCursor struct {
+	last time.Time
+	seen []machine.MachineEvent
+}
+
+// advance reports whether the event has not been streamed yet and moves the
+// cursor past it.
+func (ec *eventCursor) advance(event machine.MachineEvent) bool {
+	if event.Time.Before(ec.last) {
+		return false
+	}
+
+	if !event.Time.Equal(ec.last) {
+		ec.last = event.Time
+		ec.seen = ec.seen[:0]
+	}
+
+	for _, seen := range ec.seen {
+		if sameEvent(seen, event) {
+			return false
+		}
+	}
+
+	ec.seen = append(ec.seen, event)
+
+	return true
+}
+
+// sameEvent checks whether both events describe the same occurrence.  The
+// times are compared with Equal as their locations may differ once decoded.
+func sameEvent(a, b machine.MachineEvent) bool {
+	return a.Time.Equal(b.Time) &&
+		a.Type == b.Type &&
+		a.ID == b.ID &&
+		a.Name == b.Name &&
+		a.Driver == b.Driver &&
+		a.State == b.State &&
+		a.ExitStatus == b.ExitStatus
+}
+
+func printEvent(opts *eventsOptions, event machine.MachineEvent) error {
+	if opts.Format == "json" {
+		return json.NewEncoder(opts.IO.Out).Encode(event)
+	}
+
+	attrs := []string{"name=" + string(event.Name)}
+	if len(event.State) > 0 {
+		attrs = append(attrs, "state="+event.State.String())
+	}
+	if event.Type == machine.MachineEventExit || event.Type == machine.MachineEventCrash {
+		attrs = append(attrs, "exitStatus="+strconv.Itoa(event.ExitStatus))
+	}
+
+	_, err := fmt.Fprintf(opts.IO.Out, "%s %s machine %s %s (%s)\n",
+		event.Time.Format(time.RFC3339Nano),
+		event.Driver,
+		event.Type,
+		event.ID.ShortString(),
+		strings.Join(attrs, ", "),
+	)
+
+	return err
+}
+
+// SpawnMonitor launches a detached events monitor which keeps the state of all
+// machines up-to-date, unless a monitor is already active as indicated by the
+// presence of the `pidFile`.
+func SpawnMonitor(pidFile string, plog log.Logger) error {
+	_, err := os.Stat(pidFile)
+	if err == nil {
+		// TODO: Failsafe check if a a pidfile for the events monitor exists, let's
+		// check that it is active
+		return nil
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	plog.Debugf("launching event monitor...")
+
+	// Spawn and detach a new events monitor
+	e, err := exec.NewExecutable(os.Args[0], nil, "events", "--quit-together")
+	if err != nil {
+		return err
+	}
+
+	process, err := exec.NewProcessFromExecutable(e,
+		exec.WithDetach(true),
+	)
+	if err != nil {
+		return err
+	}
+
+	return process.Start()
+}
+
 type machineWaitGroup struct {
 	lock sync.RWMutex
 	mids []machine.MachineID
@@ -178,6 +391,27 @@ func runEvents(opts *eventsOptions, args ...string) error {
 		return err
 	}
 
+	now := time.Now()
+
+	since, err := cmdutil.ParseTimestamp(opts.Since, now)
+	if err != nil {
+		return err
+	}
+
+	until, err := cmdutil.ParseTimestamp(opts.Until, now)
+	if err != nil {
+		return err
+	}
+
+	filter, err := newEventFilter(opts.Filter)
+	if err != nil {
+		return err
+	}
+
+	if len(args) > 0 {
+		filter["id"] = append(filter["id"], args[0])
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 	store, err := machine.NewMachineStoreFromPath(cfgm.Config.RuntimeDir)
 	if err != nil {
@@ -185,6 +419,33 @@ func runEvents(opts *eventsOptions, args ...string) error {
 		return fmt.Errorf("could not access machine store: %v", err)
 	}
 
+	// Print the history of events first.  When an upper bound is provided there
+	// is nothing further to follow.
+	history, err := store.ListMachineEvents(since, until)
+	if err != nil {
+		cancel()
+		return fmt.Errorf("could not list machine events: %v", err)
+	}
+
+	cursor := eventCursor{last: since}
+	for _, event := range history {
+		if !cursor.advance(event) {
+			continue
+		}
+
+		if filter.matches(event) {
+			if err := printEvent(opts, event); err != nil {
+				cancel()
+				return err
+			}
+		}
+	}
+
+	if !until.IsZero() {
+		cancel()
+		return nil
+	}
+
 	var pidfile *os.File
 
 	// Check if a pid has already been enabled
@@ -240,6 +501,24 @@ seek:
 		default:
 		}
 
+		// Stream any events which occurred since the last iteration
+		latest, err := store.ListMachineEvents(cursor.last, time.Time{})
+		if err != nil {
+			return fmt.Errorf("could not list machine events: %v", err)
+		}
+
+		for _, event := range latest {
+			if !cursor.advance(event) {
+				continue
+			}
+
+			if filter.matches(event) {
+				if err := printEvent(opts, event); err != nil {
+					return err
+				}
+			}
+		}
+
 		var mids []machine.MachineID
 		allMids, err := store.ListAllMachineIDs()
 		if err != nil {
@@ -272,6 +551,7 @@ seek:
 			switch state {
 			case machine.MachineStateDead,
 				machine.MachineStateExited,
+				machine.MachineStateCrashed,
 				machine.MachineStateUnknown:
 				continue
 			default:
@@ -281,7 +561,7 @@ seek:
 				continue
 			}
 
-			plog.Infof("monitoring %s", mid.ShortString())
+			plog.Debugf("monitoring %s", mid.ShortString())
 
 			var mcfg machine.MachineConfig
 			if err := store.LookupMachineConfig(mid, &mcfg); err != nil {
@@ -351,7 +631,7 @@ seek:
 					// Wait on either channel
 					select {
 					case state := <-events:
-						plog.Infof("%s : %s", mid.ShortString(), state.String())
+						plog.Debugf("%s : %s", mid.ShortString(), state.String())
 						switch state {
 						case machine.MachineStateExited, machine.MachineStateDead:
 							if mcfg.DestroyOnExit {
@@ -362,6 +642,14 @@ seek:
 							}
 							observations.Done(mid)
 							return
+
+						case machine.MachineStateCrashed:
+							// Retain the machine such that its crash dump can be inspected
+							if mcfg.DestroyOnExit {
+								plog.Warnf("not removing %s as it has crashed", mid.ShortString())
+							}
+							observations.Done(mid)
+							return
 						}
 
 					case err := <-errs:
@@ -0,0 +1,130 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package events
+
+import (
+	"testing"
+	"time"
+
+	"kraftkit.sh/machine"
+)
+
+func TestNewEventFilter(t *testing.T) {
+	tests := []struct {
+		filters []string
+		wantErr bool
+	}{
+		{filters: nil},
+		{filters: []string{"id=4b2e", "name=nginx", "driver=qemu", "type=exit", "state=exited"}},
+		{filters: []string{"type=crash", "type=exit"}},
+		{filters: []string{"type=explode"}, wantErr: true},
+		{filters: []string{"colour=red"}, wantErr: true},
+		{filters: []string{"name"}, wantErr: true},
+		{filters: []string{"name="}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		if _, err := newEventFilter(tt.filters); (err != nil) != tt.wantErr {
+			t.Errorf("%v: unexpected error: %v", tt.filters, err)
+		}
+	}
+}
+
+func TestEventFilterMatches(t *testing.T) {
+	event := machine.MachineEvent{
+		Type:   machine.MachineEventExit,
+		ID:     machine.MachineID("4b2ea7e1b4ab4c3f9d1e0a7b6c5d4e3f"),
+		Name:   machine.MachineName("nginx"),
+		Driver: "qemu",
+		State:  machine.MachineStateExited,
+	}
+
+	tests := []struct {
+		filters []string
+		matches bool
+	}{
+		{filters: nil, matches: true},
+		{filters: []string{"id=4b2ea7e1b4ab"}, matches: true},
+		{filters: []string{"id=ffff"}, matches: false},
+		{filters: []string{"name=nginx", "driver=qemu"}, matches: true},
+		{filters: []string{"name=nginx", "driver=firecracker"}, matches: false},
+		{filters: []string{"type=crash", "type=exit"}, matches: true},
+		{filters: []string{"type=crash", "state=exited"}, matches: false},
+		{filters: []string{"state=exited", "state=running"}, matches: true},
+	}
+
+	for _, tt := range tests {
+		filter, err := newEventFilter(tt.filters)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := filter.matches(event); got != tt.matches {
+			t.Errorf("%v: expected %v, got %v", tt.filters, tt.matches, got)
+		}
+	}
+}
+
+func TestEventCursor(t *testing.T) {
+	at := time.Date(2023, 1, 2, 15, 4, 5, 0, time.UTC)
+
+	start := machine.MachineEvent{Time: at, Type: machine.MachineEventStart, ID: "a"}
+	other := machine.MachineEvent{Time: at, Type: machine.MachineEventStart, ID: "b"}
+	exit := machine.MachineEvent{Time: at.Add(time.Second), Type: machine.MachineEventExit, ID: "a"}
+
+	cursor := eventCursor{}
+
+	// The first listing contains a single event, the next listing returns it
+	// again alongside a distinct event with the same timestamp
+	for i, tt := range []struct {
+		event machine.MachineEvent
+		want  bool
+	}{
+		{event: start, want: true},
+		{event: start, want: false},
+		{event: other, want: true},
+		{event: exit, want: true},
+		{event: other, want: false},
+		{event: exit, want: false},
+	} {
+		if got := cursor.advance(tt.event); got != tt.want {
+			t.Errorf("event %d: expected %v, got %v", i, tt.want, got)
+		}
+	}
+
+	// Decoded events carry a different location for the same instant
+	decoded := exit
+	decoded.Time = exit.Time.In(time.FixedZone("CET", 3600))
+	if cursor.advance(decoded) {
+		t.Errorf("expected decoded event to be seen already")
+	}
+}
@@ -0,0 +1,158 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package inspect
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"kraftkit.sh/config"
+	"kraftkit.sh/iostreams"
+	"kraftkit.sh/log"
+	"kraftkit.sh/machine"
+	machinedriver "kraftkit.sh/machine/driver"
+	"kraftkit.sh/machine/driveropts"
+	"kraftkit.sh/packmanager"
+
+	"kraftkit.sh/internal/cmdfactory"
+	"kraftkit.sh/internal/cmdutil"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/spf13/cobra"
+)
+
+type inspectOptions struct {
+	PackageManager func(opts ...packmanager.PackageManagerOption) (packmanager.PackageManager, error)
+	ConfigManager  func() (*config.ConfigManager, error)
+	Logger         func() (log.Logger, error)
+	IO             *iostreams.IOStreams
+}
+
+// inspectResult is the representation of a machine output by `kraft inspect`.
+type inspectResult struct {
+	machine.MachineConfig
+
+	// State is the reconciled state of the machine.
+	State machine.MachineState `json:"state"`
+}
+
+func InspectCmd(f *cmdfactory.Factory) *cobra.Command {
+	cmd, err := cmdutil.NewCmd(f, "inspect")
+	if err != nil {
+		panic("could not initialize 'kraft inspect' command")
+	}
+
+	opts := &inspectOptions{
+		PackageManager: f.PackageManager,
+		ConfigManager:  f.ConfigManager,
+		Logger:         f.Logger,
+		IO:             f.IOStreams,
+	}
+
+	cmd.Short = "Display detailed information on one or more unikernels"
+	cmd.Use = "inspect [FLAGS] MACHINE [MACHINE [...]]"
+	cmd.Args = cobra.MinimumNArgs(1)
+	cmd.Long = heredoc.Doc(`
+		Display detailed information on one or more unikernels as JSON.
+
+		When a unikernel has crashed, the path to the ELF core containing its
+		memory at the time of the crash is reported as "crash_dump_path".`)
+	cmd.Example = heredoc.Doc(`
+		# Inspect a unikernel
+		kraft inspect 4b2ea7e1b4ab`)
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runInspect(opts, args...)
+	}
+
+	return cmd
+}
+
+func runInspect(opts *inspectOptions, args ...string) error {
+	plog, err := opts.Logger()
+	if err != nil {
+		return err
+	}
+
+	cfgm, err := opts.ConfigManager()
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	store, err := machine.NewMachineStoreFromPath(cfgm.Config.RuntimeDir)
+	if err != nil {
+		return fmt.Errorf("could not access machine store: %v", err)
+	}
+
+	mids, err := machinedriver.LookupMachines(store, args...)
+	if err != nil {
+		return err
+	}
+
+	drivers := machinedriver.NewDrivers(
+		driveropts.WithLogger(plog),
+		driveropts.WithMachineStore(store),
+		driveropts.WithRuntimeDir(cfgm.Config.RuntimeDir),
+	)
+
+	results := make([]inspectResult, 0, len(mids))
+
+	for _, mid := range mids {
+		driver, err := drivers.ForMachine(store, mid)
+		if err != nil {
+			return err
+		}
+
+		// Reconcile the state first as it may update the machine's configuration,
+		// e.g. with the location of a crash dump
+		state, err := driver.State(ctx, mid)
+		if err != nil {
+			plog.Warnf("could not reconcile state of %s: %v", mid.ShortString(), err)
+		}
+
+		var mcfg machine.MachineConfig
+		if err := store.LookupMachineConfig(mid, &mcfg); err != nil {
+			return fmt.Errorf("could not look up machine config: %v", err)
+		}
+
+		results = append(results, inspectResult{
+			MachineConfig: mcfg,
+			State:         state,
+		})
+	}
+
+	encoder := json.NewEncoder(opts.IO.Out)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(results)
+}
@@ -39,16 +39,25 @@ import (
 	"kraftkit.sh/internal/cmdfactory"
 	"kraftkit.sh/internal/cmdutil"
 
+	"kraftkit.sh/cmd/kraft/bench"
 	"kraftkit.sh/cmd/kraft/build"
 	"kraftkit.sh/cmd/kraft/events"
+	"kraftkit.sh/cmd/kraft/inspect"
+	"kraftkit.sh/cmd/kraft/logs"
+	"kraftkit.sh/cmd/kraft/monitor"
 	"kraftkit.sh/cmd/kraft/pkg"
+	"kraftkit.sh/cmd/kraft/prune"
 	"kraftkit.sh/cmd/kraft/ps"
+	"kraftkit.sh/cmd/kraft/restart"
 	"kraftkit.sh/cmd/kraft/rm"
 	"kraftkit.sh/cmd/kraft/run"
+	"kraftkit.sh/cmd/kraft/start"
 	"kraftkit.sh/cmd/kraft/stop"
+	"kraftkit.sh/cmd/kraft/symbolize"
 
 	// Additional initializers
 	_ "kraftkit.sh/manifest"
+	_ "kraftkit.sh/oci"
 )
 
 func main() {
@@ -60,10 +69,18 @@ func main() {
 			pkg.PkgCmd(f),
 			build.BuildCmd(f),
 			ps.PsCmd(f),
+			inspect.InspectCmd(f),
+			logs.LogsCmd(f),
+			prune.PruneCmd(f),
 			rm.RemoveCmd(f),
 			run.RunCmd(f),
+			start.StartCmd(f),
+			restart.RestartCmd(f),
 			stop.StopCmd(f),
 			events.EventsCmd(f),
+			monitor.MonitorCmd(f),
+			bench.BenchCmd(f),
+			symbolize.SymbolizeCmd(f),
 		),
 	)
 	if err != nil {
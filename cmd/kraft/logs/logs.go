@@ -0,0 +1,195 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package logs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"kraftkit.sh/config"
+	"kraftkit.sh/iostreams"
+	"kraftkit.sh/log"
+	"kraftkit.sh/machine"
+	machinedriver "kraftkit.sh/machine/driver"
+	"kraftkit.sh/machine/driveropts"
+	"kraftkit.sh/machine/symbolize"
+	"kraftkit.sh/packmanager"
+
+	"kraftkit.sh/internal/cmdfactory"
+	"kraftkit.sh/internal/cmdutil"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/spf13/cobra"
+)
+
+type logsOptions struct {
+	PackageManager func(opts ...packmanager.PackageManagerOption) (packmanager.PackageManager, error)
+	ConfigManager  func() (*config.ConfigManager, error)
+	Logger         func() (log.Logger, error)
+	IO             *iostreams.IOStreams
+
+	// Command-line arguments
+	Kernel      string
+	NoSymbolize bool
+}
+
+func LogsCmd(f *cmdfactory.Factory) *cobra.Command {
+	cmd, err := cmdutil.NewCmd(f, "logs")
+	if err != nil {
+		panic("could not initialize 'kraft logs' command")
+	}
+
+	opts := &logsOptions{
+		PackageManager: f.PackageManager,
+		ConfigManager:  f.ConfigManager,
+		Logger:         f.Logger,
+		IO:             f.IOStreams,
+	}
+
+	cmd.Short = "Follow the console output of a unikernel"
+	cmd.Use = "logs [FLAGS] MACHINE"
+	cmd.Args = cobra.ExactArgs(1)
+	cmd.Long = heredoc.Doc(`
+		Follow the console output of a unikernel
+
+		Addresses within register dumps, stack dumps and backtraces are resolved
+		to functions and source locations using the unstripped kernel of the
+		unikernel, if available.`)
+	cmd.Example = heredoc.Doc(`
+		# Follow the console output of a unikernel
+		kraft logs 4b2ea7e1b4ab`)
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runLogs(opts, args...)
+	}
+
+	cmd.Flags().StringVarP(
+		&opts.Kernel,
+		"kernel", "k",
+		"",
+		"Path to the unstripped kernel image used to symbolize crash output.",
+	)
+
+	cmd.Flags().BoolVar(
+		&opts.NoSymbolize,
+		"no-symbolize",
+		false,
+		"Do not symbolize crash output.",
+	)
+
+	return cmd
+}
+
+func runLogs(opts *logsOptions, args ...string) error {
+	plog, err := opts.Logger()
+	if err != nil {
+		return err
+	}
+
+	cfgm, err := opts.ConfigManager()
+	if err != nil {
+		return err
+	}
+
+	store, err := machine.NewMachineStoreFromPath(cfgm.Config.RuntimeDir)
+	if err != nil {
+		return fmt.Errorf("could not access machine store: %v", err)
+	}
+
+	mids, err := machinedriver.LookupMachines(store, args...)
+	if err != nil {
+		return err
+	}
+
+	mid := mids[0]
+
+	var mcfg machine.MachineConfig
+	if err := store.LookupMachineConfig(mid, &mcfg); err != nil {
+		return fmt.Errorf("could not look up machine config: %v", err)
+	}
+
+	if len(opts.Kernel) > 0 {
+		mcfg.KernelDbgPath = opts.Kernel
+	}
+
+	driver, err := machinedriver.NewDrivers(
+		driveropts.WithLogger(plog),
+		driveropts.WithMachineStore(store),
+		driveropts.WithRuntimeDir(cfgm.Config.RuntimeDir),
+	).ForMachine(store, mid)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	var out io.Writer = opts.IO.Out
+
+	if !opts.NoSymbolize {
+		if s, err := symbolize.NewFromMachineConfig(mcfg); err != nil {
+			plog.Debugf("not symbolizing output of %s: %v", mid.ShortString(), err)
+		} else {
+			w := symbolize.NewWriter(opts.IO.Out, s)
+			defer w.Flush()
+			out = w
+		}
+	}
+
+	// Stop following the output once the machine is no longer running
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Second):
+			}
+
+			state, err := driver.State(ctx, mid)
+			if err != nil {
+				continue
+			}
+
+			switch state {
+			case machine.MachineStateExited, machine.MachineStateDead, machine.MachineStateCrashed:
+				cancel()
+				return
+			}
+		}
+	}()
+
+	return driver.TailWriter(ctx, mid, out)
+}
@@ -0,0 +1,265 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package monitor
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"kraftkit.sh/config"
+	"kraftkit.sh/iostreams"
+	"kraftkit.sh/log"
+	"kraftkit.sh/machine"
+	machinedriver "kraftkit.sh/machine/driver"
+	"kraftkit.sh/machine/driveropts"
+	"kraftkit.sh/machine/qemu"
+	"kraftkit.sh/packmanager"
+
+	"kraftkit.sh/internal/cmdfactory"
+	"kraftkit.sh/internal/cmdutil"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+type monitorOptions struct {
+	PackageManager func(opts ...packmanager.PackageManagerOption) (packmanager.PackageManager, error)
+	ConfigManager  func() (*config.ConfigManager, error)
+	Logger         func() (log.Logger, error)
+	IO             *iostreams.IOStreams
+}
+
+func MonitorCmd(f *cmdfactory.Factory) *cobra.Command {
+	cmd, err := cmdutil.NewCmd(f, "monitor")
+	if err != nil {
+		panic("could not initialize 'kraft monitor' command")
+	}
+
+	opts := &monitorOptions{
+		PackageManager: f.PackageManager,
+		ConfigManager:  f.ConfigManager,
+		Logger:         f.Logger,
+		IO:             f.IOStreams,
+	}
+
+	cmd.Short = "Access the hypervisor monitor of a unikernel"
+	cmd.Use = "monitor [FLAGS] MACHINE [-- COMMAND]"
+	cmd.Args = cobra.MinimumNArgs(1)
+	cmd.Long = heredoc.Doc(`
+		Access the hypervisor monitor of a unikernel.
+
+		Without a command, an interactive session is opened with the QEMU Human
+		Monitor Protocol (HMP).  Leave the session with Ctrl-D or "exit".  Note that
+		the "quit" command is passed to the monitor and terminates the unikernel.
+
+		When a command is provided after "--", it is executed once and its output is
+		printed.`)
+	cmd.Example = heredoc.Doc(`
+		# Open an interactive monitor session
+		$ kraft monitor 4a3b2c1d
+
+		# Print the CPU registers of a unikernel
+		$ kraft monitor 4a3b2c1d -- "info registers"
+	`)
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		var command string
+		if at := cmd.ArgsLenAtDash(); at >= 0 {
+			if at != 1 {
+				return fmt.Errorf("expected exactly one machine before '--'")
+			}
+			command = strings.Join(args[at:], " ")
+		} else if len(args) > 1 {
+			return fmt.Errorf("expected exactly one machine, use '--' to provide a command")
+		}
+
+		return runMonitor(opts, args[0], command)
+	}
+
+	return cmd
+}
+
+func runMonitor(opts *monitorOptions, mid1, command string) error {
+	var err error
+
+	plog, err := opts.Logger()
+	if err != nil {
+		return err
+	}
+
+	cfgm, err := opts.ConfigManager()
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	store, err := machine.NewMachineStoreFromPath(cfgm.Config.RuntimeDir)
+	if err != nil {
+		return fmt.Errorf("could not access machine store: %v", err)
+	}
+
+	mids, err := machinedriver.LookupMachines(store, mid1)
+	if err != nil {
+		return err
+	}
+
+	if len(mids) > 1 {
+		return fmt.Errorf("%s matches more than one machine", mid1)
+	}
+
+	mid := mids[0]
+
+	mcfg := &machine.MachineConfig{}
+	if err := store.LookupMachineConfig(mid, mcfg); err != nil {
+		return fmt.Errorf("could not look up machine config: %v", err)
+	}
+
+	if machinedriver.DriverTypeFromName(mcfg.DriverName) != machinedriver.QemuDriver {
+		return fmt.Errorf("monitor access is not supported by the %s driver", mcfg.DriverName)
+	}
+
+	driver, err := qemu.NewQemuDriver(
+		driveropts.WithLogger(plog),
+		driveropts.WithMachineStore(store),
+		driveropts.WithRuntimeDir(cfgm.Config.RuntimeDir),
+	)
+	if err != nil {
+		return err
+	}
+
+	state, err := driver.State(ctx, mid)
+	if err != nil {
+		return fmt.Errorf("could not look up machine state: %v", err)
+	}
+
+	switch state {
+	case machine.MachineStateRunning, machine.MachineStatePaused, machine.MachineStateCreated:
+	default:
+		return fmt.Errorf("cannot access monitor of %s machine %s", state, mid.ShortString())
+	}
+
+	hmp, err := driver.HMPClient(ctx, mid)
+	if err != nil {
+		return err
+	}
+
+	defer hmp.Close()
+
+	if len(command) > 0 {
+		out, err := hmp.Run(command)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprint(opts.IO.Out, out)
+		return nil
+	}
+
+	if !opts.IO.IsStdinTTY() {
+		return runScript(hmp, opts.IO.In, opts.IO.Out)
+	}
+
+	return runInteractive(hmp, opts.IO)
+}
+
+// runScript executes each line read from `in` as a monitor command.
+func runScript(hmp *qemu.HMPClient, in io.Reader, out io.Writer) error {
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		command := strings.TrimSpace(scanner.Text())
+		if len(command) == 0 {
+			continue
+		}
+
+		res, err := hmp.Run(command)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprint(out, res)
+	}
+
+	return scanner.Err()
+}
+
+// runInteractive opens a monitor session on the terminal with line editing and
+// command history.
+func runInteractive(hmp *qemu.HMPClient, ios *iostreams.IOStreams) error {
+	fd := int(os.Stdin.Fd())
+
+	oldState, err := term.MakeRaw(fd)
+	if err != nil {
+		return fmt.Errorf("could not configure terminal: %v", err)
+	}
+
+	defer term.Restore(fd, oldState) //nolint:errcheck
+
+	terminal := term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{ios.In, ios.Out}, qemu.QemuHMPPrompt)
+
+	if width, height, err := term.GetSize(fd); err == nil {
+		terminal.SetSize(width, height) //nolint:errcheck
+	}
+
+	fmt.Fprint(terminal, hmp.Banner())
+
+	for {
+		line, err := terminal.ReadLine()
+		if err != nil {
+			// Ctrl-D
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		line = strings.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		} else if line == "exit" {
+			return nil
+		}
+
+		out, err := hmp.Run(line)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprint(terminal, out)
+	}
+}
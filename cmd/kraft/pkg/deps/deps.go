@@ -0,0 +1,240 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package deps
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/spf13/cobra"
+	"github.com/xlab/treeprint"
+
+	"kraftkit.sh/internal/cmdfactory"
+	"kraftkit.sh/internal/cmdutil"
+	"kraftkit.sh/internal/semver"
+	"kraftkit.sh/iostreams"
+	"kraftkit.sh/log"
+	"kraftkit.sh/pack"
+	"kraftkit.sh/packmanager"
+	"kraftkit.sh/unikraft"
+	"kraftkit.sh/unikraft/app"
+	"kraftkit.sh/unikraft/component"
+)
+
+type DepsOptions struct {
+	PackageManager func(opts ...packmanager.PackageManagerOption) (packmanager.PackageManager, error)
+	Logger         func() (log.Logger, error)
+	IO             *iostreams.IOStreams
+
+	// Command-line arguments
+	NoCache bool
+}
+
+func DepsCmd(f *cmdfactory.Factory) *cobra.Command {
+	opts := &DepsOptions{
+		PackageManager: f.PackageManager,
+		Logger:         f.Logger,
+		IO:             f.IOStreams,
+	}
+
+	cmd, err := cmdutil.NewCmd(f, "deps")
+	if err != nil {
+		panic("could not initialize 'kraft pkg deps' command")
+	}
+
+	cmd.Short = "Show the resolved dependency tree of a project"
+	cmd.Use = "deps [FLAGS] [DIR]"
+	cmd.Args = cmdutil.MaxDirArgs(1)
+	cmd.Long = heredoc.Docf(`
+		Show the resolved dependency tree of a project.
+
+		Each component of the project is resolved as it would be by %[1]skraft build%[1]s,
+		followed by the libraries which the components transitively require.  The
+		requirement of a dependency is shown next to the version it was resolved
+		to and a dependency which was already shown is marked with %[1]s(*)%[1]s.
+	`, "`")
+	cmd.Example = heredoc.Doc(`
+		# Show the dependency tree of the current project (cwd)
+		$ kraft pkg deps
+
+		# Show the dependency tree of a project at a path
+		$ kraft pkg deps path/to/app
+	`)
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		var workdir string
+		if len(args) == 0 {
+			cwd, err := os.Getwd()
+			if err != nil {
+				return err
+			}
+
+			workdir = cwd
+		} else {
+			workdir = args[0]
+		}
+
+		return depsRun(opts, workdir)
+	}
+
+	cmd.Flags().BoolVarP(
+		&opts.NoCache,
+		"no-cache", "F",
+		false,
+		"Do not use the cache of the package manager when searching for components",
+	)
+
+	return cmd
+}
+
+func depsRun(opts *DepsOptions, workdir string) error {
+	pm, err := opts.PackageManager()
+	if err != nil {
+		return err
+	}
+
+	plog, err := opts.Logger()
+	if err != nil {
+		return err
+	}
+
+	if !app.IsWorkdirInitialized(workdir) {
+		return fmt.Errorf("cannot show dependencies of uninitialized project: %s", workdir)
+	}
+
+	project, err := app.LoadProject(pm, plog, workdir)
+	if err != nil {
+		return err
+	}
+
+	if template := project.Template(); template.Name() != "" && !template.IsUnpackedInProject() {
+		return fmt.Errorf("template %s is not pulled, start with: kraft pkg update --lock", component.NameAndVersion(template))
+	}
+
+	project, err = app.MergeProjectTemplate(pm, plog, workdir, project)
+	if err != nil {
+		return err
+	}
+
+	lock, err := app.NewLockFileFromPath(app.LockFilePath(workdir))
+	if err != nil {
+		return err
+	}
+
+	components, err := project.Components()
+	if err != nil {
+		return err
+	}
+
+	var roots []pack.Package
+	requested := make(map[string]string)
+
+	for _, component := range components {
+		// Prefer the locked version as long as it satisfies the version
+		// requested by the project, as `kraft build` does
+		version := component.Version()
+		if locked, ok := lock.Lookup(component.Type(), component.Name()); ok && semver.Satisfies(version, locked.Version) {
+			version = locked.Version
+		}
+
+		packages, err := pm.Catalog(packmanager.CatalogQuery{
+			Name: component.Name(),
+			Types: []unikraft.ComponentType{
+				unikraft.ComponentTypeCore,
+				unikraft.ComponentTypeLib,
+				unikraft.ComponentTypePlat,
+				unikraft.ComponentTypeArch,
+			},
+			Version: version,
+			NoCache: opts.NoCache,
+		})
+		if err != nil {
+			return err
+		}
+
+		if len(packages) == 0 {
+			return fmt.Errorf("could not find: %s", component.Name())
+		} else if len(packages) > 1 {
+			return fmt.Errorf("too many options for %s", component.Name())
+		}
+
+		roots = append(roots, packages[0])
+		requested[packages[0].Options().TypeNameVersion()] = component.Version()
+	}
+
+	graph, err := packmanager.ResolveDependencies(pm, roots,
+		packmanager.WithResolveNoCache(opts.NoCache),
+		packmanager.WithResolvePreference(func(t unikraft.ComponentType, name string) (string, bool) {
+			locked, ok := lock.Lookup(t, name)
+			return locked.Version, ok
+		}),
+	)
+	if err != nil {
+		return fmt.Errorf("could not resolve dependencies: %v", err)
+	}
+
+	tree := treeprint.NewWithRoot(component.NameAndVersion(project))
+	shown := make(map[string]bool)
+
+	for _, root := range graph.Roots() {
+		addDependency(tree, graph, root, requested[root.Options().TypeNameVersion()], shown)
+	}
+
+	fmt.Fprintln(opts.IO.Out, tree.String())
+
+	return nil
+}
+
+// addDependency adds the package and, unless they were already shown, its
+// dependencies to the tree
+func addDependency(tree treeprint.Tree, graph *packmanager.DependencyGraph, p pack.Package, requested string, shown map[string]bool) {
+	label := p.Options().TypeNameVersion()
+	if len(requested) > 0 && requested != p.Options().Version {
+		label += fmt.Sprintf(" (%s)", requested)
+	}
+
+	dependencies := graph.Dependencies(p)
+	if len(dependencies) == 0 {
+		tree.AddNode(label)
+		return
+	} else if shown[p.Options().TypeNameVersion()] {
+		tree.AddNode(label + " (*)")
+		return
+	}
+
+	shown[p.Options().TypeNameVersion()] = true
+
+	branch := tree.AddBranch(label)
+	for i, dependency := range dependencies {
+		addDependency(branch, graph, dependency, p.Options().Dependencies[i].Version, shown)
+	}
+}
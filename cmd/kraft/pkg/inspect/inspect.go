@@ -0,0 +1,151 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package inspect
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/spf13/cobra"
+
+	"kraftkit.sh/internal/cmdfactory"
+	"kraftkit.sh/internal/cmdutil"
+	"kraftkit.sh/iostreams"
+	"kraftkit.sh/packmanager"
+)
+
+type InspectOptions struct {
+	PackageManager func(opts ...packmanager.PackageManagerOption) (packmanager.PackageManager, error)
+	IO             *iostreams.IOStreams
+}
+
+// inspectResult is the representation of a package output by `kraft pkg
+// inspect`.
+type inspectResult struct {
+	Reference    string    `json:"reference"`
+	Name         string    `json:"name"`
+	Version      string    `json:"version,omitempty"`
+	Format       string    `json:"format"`
+	Architecture string    `json:"architecture,omitempty"`
+	Platform     string    `json:"platform,omitempty"`
+	Digest       string    `json:"digest,omitempty"`
+	Size         int64     `json:"size"`
+	Created      time.Time `json:"created,omitempty"`
+	Source       string    `json:"source,omitempty"`
+	Command      []string  `json:"command,omitempty"`
+}
+
+func InspectCmd(f *cmdfactory.Factory) *cobra.Command {
+	opts := &InspectOptions{
+		PackageManager: f.PackageManager,
+		IO:             f.IOStreams,
+	}
+
+	cmd, err := cmdutil.NewCmd(f, "inspect")
+	if err != nil {
+		panic("could not initialize 'kraft pkg inspect' command")
+	}
+
+	cmd.Short = "Display detailed information on a package of the local package store"
+	cmd.Use = "inspect [FLAGS] REF"
+	cmd.Args = cobra.ExactArgs(1)
+	cmd.Long = heredoc.Doc(`
+		Display detailed information on a package of the local package store as
+		JSON.
+
+		The package is looked up either by its full reference or by its name and
+		version.  Every platform of the package is output separately.
+	`)
+	cmd.Example = heredoc.Doc(`
+		# Inspect a package of the local package store
+		$ kraft pkg inspect helloworld:latest
+	`)
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return inspectRun(opts, args[0])
+	}
+
+	return cmd
+}
+
+func inspectRun(opts *InspectOptions, ref string) error {
+	pm, err := opts.PackageManager()
+	if err != nil {
+		return err
+	}
+
+	packages, err := pm.Catalog(packmanager.CatalogQuery{
+		Source: ref,
+		Local:  true,
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(packages) == 0 {
+		return fmt.Errorf("could not find %s in the local package store", ref)
+	}
+
+	results := make([]inspectResult, 0, len(packages))
+
+	for _, p := range packages {
+		popts := p.Options()
+
+		result := inspectResult{
+			Reference: p.CanonicalName(),
+			Name:      p.Name(),
+			Version:   popts.Version,
+			Format:    p.Format(),
+			Digest:    popts.Digest,
+			Size:      popts.Size,
+			Created:   popts.Created,
+			Source:    popts.Source,
+			Command:   popts.Command,
+		}
+
+		if popts.Architecture != nil {
+			result.Architecture = *popts.Architecture
+		}
+
+		if popts.Platform != nil {
+			result.Platform = *popts.Platform
+		}
+
+		results = append(results, result)
+	}
+
+	encoder := json.NewEncoder(opts.IO.Out)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(results)
+}
@@ -33,6 +33,7 @@ package list
 
 import (
 	"github.com/MakeNowJust/heredoc"
+	"github.com/dustin/go-humanize"
 	"github.com/spf13/cobra"
 
 	"kraftkit.sh/config"
@@ -62,6 +63,7 @@ type ListOptions struct {
 	ShowPlats    bool
 	ShowLibs     bool
 	ShowApps     bool
+	Local        bool
 }
 
 func ListCmd(f *cmdfactory.Factory) *cobra.Command {
@@ -86,6 +88,9 @@ func ListCmd(f *cmdfactory.Factory) *cobra.Command {
 	`)
 	cmd.Example = heredoc.Doc(`
 		$ kraft pkg list
+
+		# List the packages within the local package store
+		$ kraft pkg list --local
 	`)
 	cmd.RunE = func(cmd *cobra.Command, args []string) error {
 		workdir := ""
@@ -155,6 +160,13 @@ func ListCmd(f *cmdfactory.Factory) *cobra.Command {
 		"Show applications",
 	)
 
+	cmd.Flags().BoolVar(
+		&opts.Local,
+		"local",
+		false,
+		"List the packages within the local package store",
+	)
+
 	return cmd
 }
 
@@ -188,6 +200,11 @@ func listRun(opts *ListOptions, workdir string) error {
 		query.Types = append(query.Types, unikraft.ComponentTypeApp)
 	}
 
+	if opts.Local {
+		query.Local = true
+		return listLocalRun(opts, pm, query)
+	}
+
 	var packages []pack.Package
 
 	// List pacakges part of a project
@@ -247,3 +264,42 @@ func listRun(opts *ListOptions, workdir string) error {
 
 	return table.Render()
 }
+
+// listLocalRun lists the packages within the local package store
+func listLocalRun(opts *ListOptions, pm packmanager.PackageManager, query packmanager.CatalogQuery) error {
+	packages, err := pm.Catalog(query)
+	if err != nil {
+		return err
+	}
+
+	cs := opts.IO.ColorScheme()
+	table := utils.NewTablePrinter(opts.IO)
+
+	// Header row
+	table.AddField("REF", nil, cs.Bold)
+	table.AddField("PLAT", nil, cs.Bold)
+	table.AddField("SIZE", nil, cs.Bold)
+	table.AddField("CREATED", nil, cs.Bold)
+	table.AddField("SOURCE", nil, cs.Bold)
+	table.AddField("FORMAT", nil, cs.Bold)
+	table.EndRow()
+
+	for _, pack := range packages {
+		popts := pack.Options()
+
+		created := ""
+		if !popts.Created.IsZero() {
+			created = humanize.Time(popts.Created)
+		}
+
+		table.AddField(pack.CanonicalName(), nil, nil)
+		table.AddField(popts.ArchPlatString(), nil, nil)
+		table.AddField(humanize.Bytes(uint64(popts.Size)), nil, nil)
+		table.AddField(created, nil, nil)
+		table.AddField(popts.Source, nil, nil)
+		table.AddField(pack.Format(), nil, nil)
+		table.EndRow()
+	}
+
+	return table.Render()
+}
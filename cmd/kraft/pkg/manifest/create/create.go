@@ -0,0 +1,189 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package create
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/spf13/cobra"
+
+	"kraftkit.sh/internal/cmdfactory"
+	"kraftkit.sh/internal/cmdutil"
+	"kraftkit.sh/log"
+	"kraftkit.sh/manifest"
+	"kraftkit.sh/unikraft"
+)
+
+type CreateOptions struct {
+	Logger func() (log.Logger, error)
+
+	// Command-line arguments
+	ArchiveURL string
+	Name       string
+	NoChecksum bool
+	Output     string
+	Type       string
+}
+
+func CreateCmd(f *cmdfactory.Factory) *cobra.Command {
+	opts := &CreateOptions{
+		Logger: f.Logger,
+	}
+
+	cmd, err := cmdutil.NewCmd(f, "create")
+	if err != nil {
+		panic("could not initialize 'kraft pkg manifest create' command")
+	}
+
+	cmd.Short = "Create the manifest of a component from its repository"
+	cmd.Use = "create [FLAGS] [DIR|GIT-URL]"
+	cmd.Args = cobra.MaximumNArgs(1)
+	cmd.Long = heredoc.Docf(`
+		Create the manifest of a Unikraft component from its Git repository.
+
+		The branches of the repository become the channels of the manifest and its
+		tags become the versions.  The resource of each channel and version is the
+		archive of the repository at the branch or tag, and the checksum of the
+		archive of every version is computed by downloading it.  Archives of
+		repositories hosted on GitHub are determined automatically; for other
+		hosts, provide the URL of the archives with %[1]s--archive-url%[1]s.
+
+		The type and name of the component are read from its %[1]sMakefile.uk%[1]s and
+		%[1]sConfig.uk%[1]s, and the manifest is written to %[1]sNAME.yaml%[1]s unless an
+		output path is given.
+	`, "`")
+	cmd.Example = heredoc.Doc(`
+		# Create the manifest of the library in the current directory (cwd)
+		$ kraft pkg manifest create
+
+		# Create the manifest of a library hosted on GitHub
+		$ kraft pkg manifest create https://github.com/unikraft/lib-musl.git
+
+		# Create the manifest of a library hosted elsewhere
+		$ kraft pkg manifest create --archive-url https://git.example.com/lib-foo/archive/{ref}.tar.gz path/to/lib-foo
+	`)
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		var source string
+		if len(args) == 0 {
+			cwd, err := os.Getwd()
+			if err != nil {
+				return err
+			}
+
+			source = cwd
+		} else {
+			source = args[0]
+		}
+
+		return createRun(opts, source)
+	}
+
+	cmd.Flags().StringVar(
+		&opts.ArchiveURL,
+		"archive-url",
+		"",
+		"URL of the archives of the repository where {ref} is replaced by each branch, tag or commit",
+	)
+
+	cmd.Flags().StringVarP(
+		&opts.Name,
+		"name", "n",
+		"",
+		"Set the name of the component instead of determining it from the repository",
+	)
+
+	cmd.Flags().BoolVar(
+		&opts.NoChecksum,
+		"no-checksum",
+		false,
+		"Do not download the archives of the versions to compute their checksums",
+	)
+
+	cmd.Flags().StringVarP(
+		&opts.Output,
+		"output", "o",
+		"",
+		"Save the manifest to the path instead of NAME.yaml",
+	)
+
+	cmd.Flags().StringVarP(
+		&opts.Type,
+		"type", "t",
+		"",
+		"Set the type of the component (core, arch, plat, lib or app) instead of determining it from the repository",
+	)
+
+	return cmd
+}
+
+func createRun(opts *CreateOptions, source string) error {
+	plog, err := opts.Logger()
+	if err != nil {
+		return err
+	}
+
+	copts := []manifest.CreateOption{
+		manifest.WithCreateArchiveURL(opts.ArchiveURL),
+		manifest.WithCreateName(opts.Name),
+		manifest.WithCreateNoChecksum(opts.NoChecksum),
+		manifest.WithCreateLogger(plog),
+	}
+
+	if len(opts.Type) > 0 {
+		t, ok := unikraft.ComponentTypes()[opts.Type]
+		if !ok {
+			return fmt.Errorf("unknown component type: %s", opts.Type)
+		}
+
+		copts = append(copts, manifest.WithCreateType(t))
+	}
+
+	m, err := manifest.NewManifestFromRepository(source, copts...)
+	if err != nil {
+		return err
+	}
+
+	output := opts.Output
+	if len(output) == 0 {
+		output = m.Name + ".yaml"
+	}
+
+	if err := m.WriteToFile(output); err != nil {
+		return fmt.Errorf("could not save manifest: %v", err)
+	}
+
+	plog.Infof("created manifest of %s/%s with %d channels and %d versions at %s", m.Type, m.Name, len(m.Channels), len(m.Versions), output)
+
+	return nil
+}
@@ -0,0 +1,164 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package mirror
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/spf13/cobra"
+
+	"kraftkit.sh/config"
+	"kraftkit.sh/internal/cmdfactory"
+	"kraftkit.sh/internal/cmdutil"
+	"kraftkit.sh/log"
+	"kraftkit.sh/manifest"
+	"kraftkit.sh/signature"
+)
+
+type MirrorOptions struct {
+	ConfigManager func() (*config.ConfigManager, error)
+	Logger        func() (log.Logger, error)
+
+	// Command-line arguments
+	Dir string
+}
+
+func MirrorCmd(f *cmdfactory.Factory) *cobra.Command {
+	opts := &MirrorOptions{
+		ConfigManager: f.ConfigManager,
+		Logger:        f.Logger,
+	}
+
+	cmd, err := cmdutil.NewCmd(f, "mirror")
+	if err != nil {
+		panic("could not initialize 'kraft pkg mirror' command")
+	}
+
+	cmd.Short = "Mirror manifests and their sources into a directory"
+	cmd.Use = "mirror [FLAGS] SOURCE [COMPONENT...]"
+	cmd.Args = cobra.MinimumNArgs(1)
+	cmd.Long = heredoc.Docf(`
+		Mirror the manifests of an upstream manifest index, or of any other
+		source of manifests, into a directory which can be served with
+		%[1]skraft pkg serve%[1]s.
+
+		The archive of every channel and version is downloaded into the %[1]ssources%[1]s
+		directory and checked against its checksum and, according to the signing
+		policy, its signature.  Archives of versions which were previously
+		mirrored are kept, whereas archives of channels are always downloaded
+		again.  The mirror can be restricted to some components by their name or
+		by their type and name, e.g. %[1]slib/musl%[1]s.
+
+		The index of the mirror is written to %[1]sindex.yaml%[1]s.  Sign it with
+		%[1]skraft pkg sign%[1]s after every mirror, since a previous signature is
+		removed when the index is written again.
+	`, "`")
+	cmd.Example = heredoc.Doc(`
+		# Mirror the default manifest index
+		$ kraft pkg mirror --dir /srv/kraftkit https://manifests.kraftkit.sh/index.yaml
+
+		# Mirror only the Unikraft core and some libraries
+		$ kraft pkg mirror --dir /srv/kraftkit https://manifests.kraftkit.sh/index.yaml unikraft lib/musl lib/lwip
+	`)
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return mirrorRun(opts, args[0], args[1:])
+	}
+
+	cmd.Flags().StringVarP(
+		&opts.Dir,
+		"dir", "d",
+		"",
+		"Populate the directory (default is the current directory)",
+	)
+
+	return cmd
+}
+
+func mirrorRun(opts *MirrorOptions, source string, components []string) error {
+	cfgm, err := opts.ConfigManager()
+	if err != nil {
+		return err
+	}
+
+	plog, err := opts.Logger()
+	if err != nil {
+		return err
+	}
+
+	dir := opts.Dir
+	if len(dir) == 0 {
+		dir, err = os.Getwd()
+		if err != nil {
+			return err
+		}
+	}
+
+	verifier, err := signature.NewVerifierFromConfig(cfgm.Config)
+	if err != nil {
+		return err
+	}
+
+	manifests, err := manifest.FindManifestsFromSource(source,
+		manifest.WithAuthConfig(cfgm.Config.Auth),
+		manifest.WithLogger(plog),
+		manifest.WithVerifier(verifier),
+	)
+	if err != nil {
+		return err
+	}
+
+	if len(components) > 0 {
+		var selected []*manifest.Manifest
+		for _, m := range manifests {
+			for _, component := range components {
+				if component == m.Name || component == string(m.Type)+"/"+m.Name || component == m.Type.Plural()+"/"+m.Name {
+					selected = append(selected, m)
+					break
+				}
+			}
+		}
+
+		manifests = selected
+	}
+
+	if len(manifests) == 0 {
+		return fmt.Errorf("could not find manifests to mirror in %s", source)
+	}
+
+	if err := os.MkdirAll(dir, 0o771); err != nil {
+		return err
+	}
+
+	return manifest.Mirror(dir, manifests)
+}
@@ -56,10 +56,19 @@ import (
 	"kraftkit.sh/unikraft/app"
 	"kraftkit.sh/unikraft/target"
 
+	"kraftkit.sh/cmd/kraft/pkg/deps"
+	"kraftkit.sh/cmd/kraft/pkg/inspect"
 	"kraftkit.sh/cmd/kraft/pkg/list"
+	"kraftkit.sh/cmd/kraft/pkg/manifest"
+	"kraftkit.sh/cmd/kraft/pkg/mirror"
 	"kraftkit.sh/cmd/kraft/pkg/pull"
+	"kraftkit.sh/cmd/kraft/pkg/push"
+	"kraftkit.sh/cmd/kraft/pkg/rm"
+	"kraftkit.sh/cmd/kraft/pkg/serve"
+	"kraftkit.sh/cmd/kraft/pkg/sign"
 	"kraftkit.sh/cmd/kraft/pkg/source"
 	"kraftkit.sh/cmd/kraft/pkg/update"
+	"kraftkit.sh/cmd/kraft/pkg/verify"
 )
 
 type pkgOptions struct {
@@ -87,10 +96,19 @@ type pkgOptions struct {
 func PkgCmd(f *cmdfactory.Factory) *cobra.Command {
 	cmd, err := cmdutil.NewCmd(f, "pkg",
 		cmdutil.WithSubcmds(
+			deps.DepsCmd(f),
+			inspect.InspectCmd(f),
 			list.ListCmd(f),
+			manifest.ManifestCmd(f),
+			mirror.MirrorCmd(f),
 			pull.PullCmd(f),
+			push.PushCmd(f),
+			rm.RmCmd(f),
+			serve.ServeCmd(f),
+			sign.SignCmd(f),
 			source.SourceCmd(f),
 			update.UpdateCmd(f),
+			verify.VerifyCmd(f),
 		),
 	)
 	if err != nil {
@@ -135,6 +153,9 @@ func PkgCmd(f *cmdfactory.Factory) *cobra.Command {
 
 		# Same as above but also save the resulting CPIO artifact locally
 		$ kraft pkg --initrd ./root-fs:./root-fs.cpio .
+
+		# Package as an OCI image within an image layout
+		$ kraft pkg --as oci --output ./oci .
 	`)
 	cmd.RunE = func(cmd *cobra.Command, args []string) error {
 		if (len(opts.Architecture) > 0 || len(opts.Platform) > 0) && len(opts.Target) > 0 {
@@ -447,6 +468,12 @@ func initAppPackage(ctx context.Context,
 		version = "latest"
 	}
 
+	// Default arguments of the application
+	command := []string(targ.Runtime.Args)
+	if len(command) == 0 {
+		command = targ.Command
+	}
+
 	extraPackOpts := []pack.PackageOption{
 		pack.WithName(name),
 		pack.WithVersion(version),
@@ -454,6 +481,7 @@ func initAppPackage(ctx context.Context,
 		pack.WithArchitecture(targ.Architecture.Name()),
 		pack.WithPlatform(targ.Platform.Name()),
 		pack.WithKernel(kernel),
+		pack.WithCommand(command),
 		pack.WithWorkdir(workdir),
 		pack.WithLocalLocation(opts.Output, opts.Force),
 	}
@@ -104,6 +104,9 @@ func PullCmd(f *cmdfactory.Factory) *cobra.Command {
 		# Pull an OCI-packaged Unikraft unikernel
 		$ kraft pkg pull unikraft.io/nginx:1.21.6
 
+		# Pull an OCI-packaged Unikraft unikernel for a particular platform
+		$ kraft pkg pull --plat kvm --arch x86_64 unikraft.io/nginx:1.21.6
+
 		# Pull from a manifest
 		$ kraft pkg pull nginx@1.21.6
 	`)
@@ -371,6 +374,11 @@ func pullRun(opts *PullOptions, query string) error {
 			query := packmanager.CatalogQuery{}
 			t, n, v, err := unikraft.GuessTypeNameVersion(c)
 			if err != nil {
+				// Not a component, e.g. the reference of an image within a registry,
+				// so let the package managers interpret it as a source
+				queries = append(queries, packmanager.CatalogQuery{
+					Source: c,
+				})
 				continue
 			}
 
@@ -403,6 +411,16 @@ func pullRun(opts *PullOptions, query string) error {
 
 		for _, p := range next {
 			p := p
+
+			// Skip packages which do not match the requested architecture or
+			// platform
+			if len(opts.Architecture) > 0 && p.Options().Architecture != nil && *p.Options().Architecture != opts.Architecture {
+				continue
+			}
+			if len(opts.Platform) > 0 && p.Options().Platform != nil && *p.Options().Platform != opts.Platform {
+				continue
+			}
+
 			processes = append(processes, paraprogress.NewProcess(
 				fmt.Sprintf("pulling %s", p.Options().TypeNameVersion()),
 				func(l log.Logger, w func(progress float64)) error {
@@ -418,6 +436,8 @@ func pullRun(opts *PullOptions, query string) error {
 						pack.WithPullLogger(l),
 						pack.WithPullChecksum(!opts.NoChecksum),
 						pack.WithPullCache(!opts.NoCache),
+						pack.WithPullArchitecture(opts.Architecture),
+						pack.WithPullPlatform(opts.Platform),
 					)
 				},
 			))
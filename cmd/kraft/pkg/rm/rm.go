@@ -0,0 +1,95 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package rm
+
+import (
+	"github.com/MakeNowJust/heredoc"
+	"github.com/spf13/cobra"
+
+	"kraftkit.sh/internal/cmdfactory"
+	"kraftkit.sh/internal/cmdutil"
+	"kraftkit.sh/iostreams"
+	"kraftkit.sh/packmanager"
+)
+
+type RmOptions struct {
+	PackageManager func(opts ...packmanager.PackageManagerOption) (packmanager.PackageManager, error)
+	IO             *iostreams.IOStreams
+}
+
+func RmCmd(f *cmdfactory.Factory) *cobra.Command {
+	opts := &RmOptions{
+		PackageManager: f.PackageManager,
+		IO:             f.IOStreams,
+	}
+
+	cmd, err := cmdutil.NewCmd(f, "rm")
+	if err != nil {
+		panic("could not initialize 'kraft pkg rm' command")
+	}
+
+	cmd.Short = "Remove packages from the local package store"
+	cmd.Use = "rm [FLAGS] REF [REF [...]]"
+	cmd.Aliases = []string{"remove"}
+	cmd.Args = cobra.MinimumNArgs(1)
+	cmd.Long = heredoc.Doc(`
+		Remove packages from the local package store.
+
+		A package is looked up either by its full reference or by its name and
+		version.  All platforms of the package are removed together with any
+		content which is no longer used by other packages.
+	`)
+	cmd.Example = heredoc.Doc(`
+		# Remove a package from the local package store
+		$ kraft pkg rm helloworld:latest
+	`)
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return rmRun(opts, args...)
+	}
+
+	return cmd
+}
+
+func rmRun(opts *RmOptions, refs ...string) error {
+	pm, err := opts.PackageManager()
+	if err != nil {
+		return err
+	}
+
+	for _, ref := range refs {
+		if err := pm.Delete(ref); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
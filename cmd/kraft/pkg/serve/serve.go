@@ -0,0 +1,168 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package serve
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/spf13/cobra"
+
+	"kraftkit.sh/config"
+	"kraftkit.sh/internal/cmdfactory"
+	"kraftkit.sh/internal/cmdutil"
+	"kraftkit.sh/log"
+	"kraftkit.sh/manifest"
+)
+
+type ServeOptions struct {
+	ConfigManager func() (*config.ConfigManager, error)
+	Logger        func() (log.Logger, error)
+
+	// Command-line arguments
+	Dir    string
+	Listen string
+	URL    string
+}
+
+func ServeCmd(f *cmdfactory.Factory) *cobra.Command {
+	opts := &ServeOptions{
+		ConfigManager: f.ConfigManager,
+		Logger:        f.Logger,
+	}
+
+	cmd, err := cmdutil.NewCmd(f, "serve")
+	if err != nil {
+		panic("could not initialize 'kraft pkg serve' command")
+	}
+
+	cmd.Short = "Serve a manifest index and its sources over HTTP"
+	cmd.Use = "serve [FLAGS]"
+	cmd.Args = cobra.NoArgs
+	cmd.Long = heredoc.Docf(`
+		Serve the manifests within a directory as a manifest index over HTTP,
+		together with the archives of their channels and versions.
+
+		The archives are served from the %[1]ssources%[1]s directory within the directory,
+		as populated by %[1]skraft pkg mirror%[1]s, and from the local cache of sources.
+		The resources of the served manifests refer to the archives of the server,
+		such that clients without access to the origin of the manifests are able
+		to pull them.  Channels and versions whose archive is not available are
+		not served.
+
+		The index is the %[1]sindex.yaml%[1]s written by %[1]skraft pkg mirror%[1]s, which is
+		served as-is together with its detached signature %[1]sindex.yaml.sig%[1]s, if
+		any.  Clients which enforce signatures only accept a signed index, so sign
+		the index after each mirror with %[1]skraft pkg sign index.yaml%[1]s.  A
+		directory without an index is served with an index generated from its
+		manifests, which cannot be signed.
+
+		Clients use the server by adding the index to their manifests:
+
+		  $ kraft pkg source http://HOST:PORT/index.yaml
+	`, "`")
+	cmd.Example = heredoc.Doc(`
+		# Sign the index of a mirror and serve it
+		$ kraft pkg sign /srv/kraftkit/index.yaml
+		$ kraft pkg serve --dir /srv/kraftkit
+
+		# Serve the mirror behind a proxy at a well-known URL
+		$ kraft pkg serve --dir /srv/kraftkit --listen 127.0.0.1:8080 --url https://mirror.example.com
+	`)
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return serveRun(opts)
+	}
+
+	cmd.Flags().StringVarP(
+		&opts.Dir,
+		"dir", "d",
+		"",
+		"Serve the manifests within the directory (default is the current directory)",
+	)
+
+	cmd.Flags().StringVarP(
+		&opts.Listen,
+		"listen", "l",
+		":8080",
+		"Address to listen on for HTTP requests",
+	)
+
+	cmd.Flags().StringVar(
+		&opts.URL,
+		"url",
+		"",
+		"URL at which clients reach the server (default is derived from each request)",
+	)
+
+	return cmd
+}
+
+func serveRun(opts *ServeOptions) error {
+	cfgm, err := opts.ConfigManager()
+	if err != nil {
+		return err
+	}
+
+	plog, err := opts.Logger()
+	if err != nil {
+		return err
+	}
+
+	dir := opts.Dir
+	if len(dir) == 0 {
+		dir, err = os.Getwd()
+		if err != nil {
+			return err
+		}
+	}
+
+	if f, err := os.Stat(dir); err != nil || !f.IsDir() {
+		return fmt.Errorf("could not access directory: %s", dir)
+	}
+
+	handler := manifest.NewMirrorHandler(dir,
+		manifest.WithMirrorBaseURL(opts.URL),
+		manifest.WithMirrorSourcesDirs(cfgm.Config.Paths.Sources),
+		manifest.WithMirrorManifestOptions(
+			manifest.WithLogger(plog),
+		),
+	)
+
+	plog.Infof("serving %s on %s", dir, opts.Listen)
+
+	return http.ListenAndServe(opts.Listen, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		plog.Debugf("%s %s", r.Method, r.URL.Path)
+		handler.ServeHTTP(w, r)
+	}))
+}
@@ -0,0 +1,199 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package sign
+
+import (
+	"crypto/ed25519"
+	"fmt"
+	"os"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/spf13/cobra"
+
+	"kraftkit.sh/config"
+	"kraftkit.sh/internal/cmdfactory"
+	"kraftkit.sh/internal/cmdutil"
+	"kraftkit.sh/iostreams"
+	"kraftkit.sh/log"
+	"kraftkit.sh/oci"
+	"kraftkit.sh/signature"
+)
+
+type SignOptions struct {
+	ConfigManager func() (*config.ConfigManager, error)
+	Logger        func() (log.Logger, error)
+	IO            *iostreams.IOStreams
+
+	// Command-line arguments
+	Key      string
+	Generate bool
+}
+
+func SignCmd(f *cmdfactory.Factory) *cobra.Command {
+	opts := &SignOptions{
+		ConfigManager: f.ConfigManager,
+		Logger:        f.Logger,
+		IO:            f.IOStreams,
+	}
+
+	cmd, err := cmdutil.NewCmd(f, "sign")
+	if err != nil {
+		panic("could not initialize 'kraft pkg sign' command")
+	}
+
+	cmd.Short = "Sign a package or a manifest index"
+	cmd.Use = "sign [FLAGS] [FILE|REF]"
+	cmd.Args = cobra.MaximumNArgs(1)
+	cmd.Long = heredoc.Doc(`
+		Sign a package or a manifest index with an ed25519 key.
+
+		A file, such as a package archive or a manifest index, is signed with a
+		detached signature which is written next to it with the extension ".sig".
+		Both files must be published together.
+
+		A reference is looked up in the local package store and the manifests of
+		all of its platforms are signed.  The signatures are kept within the image
+		index such that they are pushed together with the package.
+
+		The key is read from the path set by "signing.key" in the configuration
+		unless it is provided.  A new key is created with --generate, which prints
+		its public key to be added to "signing.trusted_keys".
+	`)
+	cmd.Example = heredoc.Doc(`
+		# Create a new signing key and print its public key
+		$ kraft pkg sign --generate
+
+		# Sign a manifest index
+		$ kraft pkg sign index.yaml
+
+		# Sign a package of the local package store before pushing it
+		$ kraft pkg sign helloworld:latest
+		$ kraft pkg push unikraft.io/helloworld:latest
+	`)
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		artifact := ""
+		if len(args) > 0 {
+			artifact = args[0]
+		}
+
+		return signRun(opts, artifact)
+	}
+
+	cmd.Flags().StringVarP(
+		&opts.Key,
+		"key", "k",
+		"",
+		"Path of the private key",
+	)
+
+	cmd.Flags().BoolVar(
+		&opts.Generate,
+		"generate",
+		false,
+		"Create a new private key at the path of the key",
+	)
+
+	return cmd
+}
+
+func signRun(opts *SignOptions, artifact string) error {
+	plog, err := opts.Logger()
+	if err != nil {
+		return err
+	}
+
+	cfgm, err := opts.ConfigManager()
+	if err != nil {
+		return err
+	}
+
+	keyPath := opts.Key
+	if len(keyPath) == 0 {
+		keyPath = cfgm.Config.Signing.Key
+	}
+
+	if opts.Generate {
+		pub, err := signature.GenerateKey(keyPath)
+		if err != nil {
+			return err
+		}
+
+		plog.Infof("created key %s in %s", signature.KeyID(pub), keyPath)
+		fmt.Fprintln(opts.IO.Out, signature.EncodePublicKey(pub))
+	}
+
+	if len(artifact) == 0 {
+		if opts.Generate {
+			return nil
+		}
+
+		return fmt.Errorf("no file or reference provided to sign")
+	}
+
+	key, err := signature.ReadPrivateKey(keyPath)
+	if err != nil {
+		return fmt.Errorf("could not read signing key: %v", err)
+	}
+
+	if f, err := os.Stat(artifact); err == nil && f.Mode().IsRegular() {
+		sig, err := signature.SignFile(key, artifact)
+		if err != nil {
+			return err
+		}
+
+		plog.Infof("signed %s with %s", artifact, sig.KeyID)
+
+		return nil
+	}
+
+	store, err := oci.OpenLayout(cfgm.Config.Paths.Packages)
+	if err != nil {
+		return fmt.Errorf("could not open local package store: %v", err)
+	}
+
+	ref, err := store.Find(artifact)
+	if err != nil {
+		return err
+	}
+
+	descs, err := store.Sign(ref, key)
+	if err != nil {
+		return err
+	}
+
+	keyID := signature.KeyID(key.Public().(ed25519.PublicKey))
+	for _, desc := range descs {
+		plog.Infof("signed %s for %s with %s", ref, desc.Platform, keyID)
+	}
+
+	return nil
+}
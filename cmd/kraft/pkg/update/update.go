@@ -32,6 +32,9 @@
 package update
 
 import (
+	"fmt"
+	"os"
+
 	"github.com/MakeNowJust/heredoc"
 	"github.com/spf13/cobra"
 
@@ -40,8 +43,11 @@ import (
 	"kraftkit.sh/internal/cmdutil"
 	"kraftkit.sh/internal/logger"
 	"kraftkit.sh/log"
+	"kraftkit.sh/pack"
 	"kraftkit.sh/packmanager"
 	"kraftkit.sh/tui/processtree"
+	"kraftkit.sh/unikraft"
+	"kraftkit.sh/unikraft/app"
 )
 
 type UpdateOptions struct {
@@ -51,6 +57,7 @@ type UpdateOptions struct {
 
 	// Command-line arguments
 	Manager string
+	Lock    bool
 }
 
 func UpdateCmd(f *cmdfactory.Factory) *cobra.Command {
@@ -66,16 +73,37 @@ func UpdateCmd(f *cmdfactory.Factory) *cobra.Command {
 	}
 
 	cmd.Short = "Retrieve new lists of Unikraft components, libraries and packages"
-	cmd.Use = "update [FLAGS]"
+	cmd.Use = "update [FLAGS] [DIR]"
+	cmd.Args = cmdutil.MaxDirArgs(1)
 	cmd.Long = heredoc.Doc(`
-		Retrieve new lists of Unikraft components, libraries and packages
+		Retrieve new lists of Unikraft components, libraries and packages.
+
+		With --lock, the components of the project are resolved again against
+		the new lists and pulled, and kraft.lock next to its Kraftfile is
+		rewritten to record their exact versions, Git commits or archives and
+		checksums.
 	`)
 	cmd.Aliases = []string{"u"}
 	cmd.Example = heredoc.Doc(`
 		$ kraft pkg update
+
+		# Refresh the lockfile of the project in the current directory
+		$ kraft pkg update --lock
 	`)
 	cmd.RunE = func(cmd *cobra.Command, args []string) error {
-		return updateRun(opts)
+		workdir := ""
+		if len(args) > 0 {
+			workdir = args[0]
+		} else if opts.Lock {
+			cwd, err := os.Getwd()
+			if err != nil {
+				return err
+			}
+
+			workdir = cwd
+		}
+
+		return updateRun(opts, workdir)
 	}
 
 	// TODO: Enable flag if multiple managers are detected?
@@ -86,10 +114,17 @@ func UpdateCmd(f *cmdfactory.Factory) *cobra.Command {
 		"Force the handler type",
 	)
 
+	cmd.Flags().BoolVar(
+		&opts.Lock,
+		"lock",
+		false,
+		"Refresh the lockfile of the project",
+	)
+
 	return cmd
 }
 
-func updateRun(opts *UpdateOptions) error {
+func updateRun(opts *UpdateOptions, workdir string) error {
 	plog, err := opts.Logger()
 	if err != nil {
 		return err
@@ -119,6 +154,39 @@ func updateRun(opts *UpdateOptions) error {
 		parallel = false
 	}
 
+	items := []*processtree.ProcessTreeItem{
+		processtree.NewProcessTreeItem(
+			"Updating...",
+			"",
+			func(l log.Logger) error {
+				// Apply the incoming logger which is tailored to display as a
+				// sub-terminal within the fancy processtree.
+				pm.ApplyOptions(
+					packmanager.WithLogger(l),
+				)
+
+				return pm.Update()
+			},
+		),
+	}
+
+	if opts.Lock {
+		// The lockfile can only be refreshed once the lists are updated
+		parallel = false
+
+		items = append(items, processtree.NewProcessTreeItem(
+			"Locking "+app.LockFilePath(workdir),
+			"",
+			func(l log.Logger) error {
+				pm.ApplyOptions(
+					packmanager.WithLogger(l),
+				)
+
+				return updateLock(pm, l, workdir)
+			},
+		))
+	}
+
 	model, err := processtree.NewProcessTree(
 		[]processtree.ProcessTreeOption{
 			// processtree.WithVerb("Updating"),
@@ -126,21 +194,7 @@ func updateRun(opts *UpdateOptions) error {
 			processtree.WithRenderer(norender),
 			processtree.WithLogger(plog),
 		},
-		[]*processtree.ProcessTreeItem{
-			processtree.NewProcessTreeItem(
-				"Updating...",
-				"",
-				func(l log.Logger) error {
-					// Apply the incoming logger which is tailored to display as a
-					// sub-terminal within the fancy processtree.
-					pm.ApplyOptions(
-						packmanager.WithLogger(l),
-					)
-
-					return pm.Update()
-				},
-			),
-		}...,
+		items...,
 	)
 	if err != nil {
 		return err
@@ -152,3 +206,97 @@ func updateRun(opts *UpdateOptions) error {
 
 	return nil
 }
+
+// updateLock resolves and pulls all components of the project within the
+// working directory and records them within its lockfile, regardless of its
+// current content
+func updateLock(pm packmanager.PackageManager, l log.Logger, workdir string) error {
+	if !app.IsWorkdirInitialized(workdir) {
+		return fmt.Errorf("cannot lock uninitialized project: %s", workdir)
+	}
+
+	project, err := app.LoadProject(pm, l, workdir)
+	if err != nil {
+		return err
+	}
+
+	lock := app.NewLockFile(app.LockFilePath(workdir))
+
+	// find returns the single package of the component
+	find := func(t unikraft.ComponentType, name, version string) (pack.Package, error) {
+		packages, err := pm.Catalog(packmanager.CatalogQuery{
+			Name:    name,
+			Types:   []unikraft.ComponentType{t},
+			Version: version,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		if len(packages) == 0 {
+			return nil, fmt.Errorf("could not find: %s", name)
+		} else if len(packages) > 1 {
+			return nil, fmt.Errorf("too many options for %s", name)
+		}
+
+		return packages[0], nil
+	}
+
+	// pull pulls the package and records it
+	pull := func(p pack.Package) error {
+		if err := p.Pull(
+			pack.WithPullWorkdir(workdir),
+			pack.WithPullLogger(l),
+		); err != nil {
+			return err
+		}
+
+		lock.Set(app.LockPackage(p))
+
+		return nil
+	}
+
+	if template := project.Template(); template.Name() != "" {
+		p, err := find(template.Type(), template.Name(), template.Version())
+		if err != nil {
+			return err
+		}
+
+		if err := pull(p); err != nil {
+			return err
+		}
+
+		project, err = app.MergeProjectTemplate(pm, l, workdir, project)
+		if err != nil {
+			return err
+		}
+	}
+
+	components, err := project.Components()
+	if err != nil {
+		return err
+	}
+
+	var packages []pack.Package
+	for _, component := range components {
+		p, err := find(component.Type(), component.Name(), component.Version())
+		if err != nil {
+			return err
+		}
+
+		packages = append(packages, p)
+	}
+
+	graph, err := packmanager.ResolveDependencies(pm, packages)
+	if err != nil {
+		return fmt.Errorf("could not resolve dependencies: %v", err)
+	}
+
+	for _, p := range append(packages, graph.Packages()...) {
+		if err := pull(p); err != nil {
+			return err
+		}
+	}
+
+	return lock.Save()
+}
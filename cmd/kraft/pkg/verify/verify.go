@@ -0,0 +1,182 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package verify
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/spf13/cobra"
+
+	"kraftkit.sh/config"
+	"kraftkit.sh/internal/cmdfactory"
+	"kraftkit.sh/internal/cmdutil"
+	"kraftkit.sh/iostreams"
+	"kraftkit.sh/log"
+	"kraftkit.sh/oci"
+	"kraftkit.sh/signature"
+)
+
+type VerifyOptions struct {
+	ConfigManager func() (*config.ConfigManager, error)
+	Logger        func() (log.Logger, error)
+	IO            *iostreams.IOStreams
+
+	// Command-line arguments
+	TrustedKeys []string
+}
+
+func VerifyCmd(f *cmdfactory.Factory) *cobra.Command {
+	opts := &VerifyOptions{
+		ConfigManager: f.ConfigManager,
+		Logger:        f.Logger,
+		IO:            f.IOStreams,
+	}
+
+	cmd, err := cmdutil.NewCmd(f, "verify")
+	if err != nil {
+		panic("could not initialize 'kraft pkg verify' command")
+	}
+
+	cmd.Short = "Verify the signature of a package or a manifest index"
+	cmd.Use = "verify [FLAGS] FILE|REF"
+	cmd.Args = cobra.ExactArgs(1)
+	cmd.Long = heredoc.Doc(`
+		Verify the signature of a package or a manifest index.
+
+		A file is verified against its detached signature with the extension
+		".sig".  A reference is looked up in the local package store and the
+		manifests of all of its platforms are verified.
+
+		Signatures must be made by one of the keys listed in
+		"signing.trusted_keys" of the configuration or provided with
+		--trusted-key.  Unlike when pulling, the verification is strict regardless
+		of "signing.policy".
+	`)
+	cmd.Example = heredoc.Doc(`
+		# Verify a manifest index
+		$ kraft pkg verify index.yaml
+
+		# Verify a package of the local package store
+		$ kraft pkg verify unikraft.io/helloworld:latest
+	`)
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return verifyRun(opts, args[0])
+	}
+
+	cmd.Flags().StringSliceVar(
+		&opts.TrustedKeys,
+		"trusted-key",
+		[]string{},
+		"Additionally trust the public key in the form ed25519:<base64>",
+	)
+
+	return cmd
+}
+
+func verifyRun(opts *VerifyOptions, artifact string) error {
+	plog, err := opts.Logger()
+	if err != nil {
+		return err
+	}
+
+	cfgm, err := opts.ConfigManager()
+	if err != nil {
+		return err
+	}
+
+	verifier, err := signature.NewVerifier(
+		signature.PolicyEnforce,
+		append(cfgm.Config.Signing.TrustedKeys, opts.TrustedKeys...),
+	)
+	if err != nil {
+		return err
+	}
+
+	if f, err := os.Stat(artifact); err == nil && f.Mode().IsRegular() {
+		digest, err := signature.DigestFile(artifact)
+		if err != nil {
+			return err
+		}
+
+		sig, err := signature.ReadFile(artifact)
+		if err != nil {
+			return err
+		}
+
+		if err := verifier.Verify(digest, sig); err != nil {
+			return fmt.Errorf("%s: %v", artifact, err)
+		}
+
+		plog.Infof("verified %s signed by %s", artifact, sig.KeyID)
+
+		return nil
+	}
+
+	store, err := oci.OpenLayout(cfgm.Config.Paths.Packages)
+	if err != nil {
+		return fmt.Errorf("could not open local package store: %v", err)
+	}
+
+	ref, err := store.Find(artifact)
+	if err != nil {
+		return err
+	}
+
+	index, err := store.ImageIndex(ref)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, desc := range index.Manifests {
+		sig, err := oci.DescriptorSignature(desc)
+		if err == nil {
+			err = verifier.Verify(desc.Digest, sig)
+		}
+
+		if err != nil {
+			plog.Errorf("%s for %s: %v", ref, desc.Platform, err)
+			failed++
+			continue
+		}
+
+		plog.Infof("verified %s for %s signed by %s", ref, desc.Platform, sig.KeyID)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("could not verify %d of %d platforms of %s", failed, len(index.Manifests), ref)
+	}
+
+	return nil
+}
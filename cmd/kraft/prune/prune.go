@@ -0,0 +1,367 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package prune
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"kraftkit.sh/config"
+	"kraftkit.sh/iostreams"
+	"kraftkit.sh/log"
+	"kraftkit.sh/machine"
+	machinedriver "kraftkit.sh/machine/driver"
+	"kraftkit.sh/machine/driveropts"
+	"kraftkit.sh/packmanager"
+
+	"kraftkit.sh/internal/cmdfactory"
+	"kraftkit.sh/internal/cmdutil"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/dustin/go-humanize"
+	"github.com/spf13/cobra"
+)
+
+// orphanGracePeriod is the minimum age of runtime files without a machine in
+// the store before they are considered orphaned.  This prevents removing the
+// files of a machine which is still in the process of being created.
+const orphanGracePeriod = time.Minute
+
+type pruneOptions struct {
+	PackageManager func(opts ...packmanager.PackageManagerOption) (packmanager.PackageManager, error)
+	ConfigManager  func() (*config.ConfigManager, error)
+	Logger         func() (log.Logger, error)
+	IO             *iostreams.IOStreams
+
+	// Command-line arguments
+	All    bool
+	Filter []string
+}
+
+func PruneCmd(f *cmdfactory.Factory) *cobra.Command {
+	cmd, err := cmdutil.NewCmd(f, "prune")
+	if err != nil {
+		panic("could not initialize 'kraft prune' command")
+	}
+
+	opts := &pruneOptions{
+		PackageManager: f.PackageManager,
+		ConfigManager:  f.ConfigManager,
+		Logger:         f.Logger,
+		IO:             f.IOStreams,
+	}
+
+	cmd.Short = "Remove exited unikernels and stale runtime files"
+	cmd.Use = "prune [FLAGS]"
+	cmd.Args = cobra.NoArgs
+	cmd.Long = heredoc.Doc(`
+		Remove exited unikernels and stale runtime files.
+
+		The state of every machine is first reconciled with its VMM such that
+		machines whose VMM is no longer active are marked as dead.  Exited and dead
+		machines are then removed alongside their PID files and sockets.  Runtime
+		files which belong to machines that no longer exist are also removed.
+
+		Filters are provided as KEY=VALUE, where KEY is one of id, name, driver,
+		state, arch, plat or until.  The until filter accepts a timestamp or a
+		relative duration and only matches machines created before it.`)
+	cmd.Example = heredoc.Doc(`
+		# Remove all exited machines
+		kraft prune
+
+		# Remove all machines which are not running
+		kraft prune --all
+
+		# Remove exited QEMU machines which were created more than a day ago
+		kraft prune --filter driver=qemu --filter until=24h`)
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runPrune(opts)
+	}
+
+	cmd.Flags().BoolVarP(
+		&opts.All,
+		"all", "a",
+		false,
+		"Remove all machines which are not running",
+	)
+
+	cmd.Flags().StringArrayVarP(
+		&opts.Filter,
+		"filter", "f",
+		[]string{},
+		"Only remove machines matching the provided KEY=VALUE conditions",
+	)
+
+	return cmd
+}
+
+// machineFilter holds the accepted values for each filter key.
+type machineFilter struct {
+	values map[string][]string
+	until  time.Time
+}
+
+func newMachineFilter(filters []string, now time.Time) (*machineFilter, error) {
+	mf := &machineFilter{
+		values: make(map[string][]string),
+	}
+
+	for _, filter := range filters {
+		key, value, ok := strings.Cut(filter, "=")
+		if !ok || len(value) == 0 {
+			return nil, fmt.Errorf("invalid filter, expected KEY=VALUE: %s", filter)
+		}
+
+		switch key {
+		case "id", "name", "driver", "state", "arch", "plat":
+			mf.values[key] = append(mf.values[key], value)
+		case "until":
+			until, err := cmdutil.ParseTimestamp(value, now)
+			if err != nil {
+				return nil, err
+			}
+
+			mf.until = until
+		default:
+			return nil, fmt.Errorf("unknown filter key: %s", key)
+		}
+	}
+
+	return mf, nil
+}
+
+func (mf *machineFilter) empty() bool {
+	return len(mf.values) == 0 && mf.until.IsZero()
+}
+
+func (mf *machineFilter) matches(mcfg machine.MachineConfig, state machine.MachineState) bool {
+	if !mf.until.IsZero() && !mcfg.CreatedAt.Before(mf.until) {
+		return false
+	}
+
+	for key, values := range mf.values {
+		found := false
+
+		for _, value := range values {
+			switch key {
+			case "id":
+				found = strings.HasPrefix(mcfg.ID.String(), value)
+			case "name":
+				found = string(mcfg.Name) == value
+			case "driver":
+				found = mcfg.DriverName == value
+			case "state":
+				found = state.String() == value
+			case "arch":
+				found = mcfg.Architecture == value
+			case "plat":
+				found = mcfg.Platform == value
+			}
+
+			if found {
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// diskUsage returns the combined size of the provided files.
+func diskUsage(files []string) uint64 {
+	var size uint64
+
+	for _, file := range files {
+		if fi, err := os.Lstat(file); err == nil {
+			size += uint64(fi.Size())
+		}
+	}
+
+	return size
+}
+
+func runPrune(opts *pruneOptions) error {
+	var err error
+
+	plog, err := opts.Logger()
+	if err != nil {
+		return err
+	}
+
+	cfgm, err := opts.ConfigManager()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	filter, err := newMachineFilter(opts.Filter, now)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	store, err := machine.NewMachineStoreFromPath(cfgm.Config.RuntimeDir)
+	if err != nil {
+		return fmt.Errorf("could not access machine store: %v", err)
+	}
+
+	dopts := []driveropts.DriverOption{
+		driveropts.WithLogger(plog),
+		driveropts.WithMachineStore(store),
+		driveropts.WithRuntimeDir(cfgm.Config.RuntimeDir),
+	}
+
+	// Bring the store up-to-date with machines whose VMM has gone away
+	states, err := machinedriver.Reconcile(ctx, store, dopts...)
+	if err != nil {
+		plog.Warnf("%v", err)
+	}
+
+	mcfgs, err := store.ListAllMachineConfigs()
+	if err != nil {
+		return fmt.Errorf("could not list machines: %v", err)
+	}
+
+	runtimeFiles, err := machine.AllRuntimeFiles(cfgm.Config.RuntimeDir)
+	if err != nil {
+		return fmt.Errorf("could not list runtime files: %v", err)
+	}
+
+	drivers := machinedriver.NewDrivers(dopts...)
+
+	var pruned []machine.MachineID
+	var reclaimed uint64
+
+	for mid, mcfg := range mcfgs {
+		// Machines which could not be reconciled may still be running
+		state, ok := states[mid]
+		if !ok {
+			continue
+		}
+
+		switch state {
+		case machine.MachineStateExited, machine.MachineStateDead, machine.MachineStateCrashed:
+		case machine.MachineStateRunning:
+			continue
+		default:
+			if !opts.All {
+				continue
+			}
+		}
+
+		if !filter.matches(mcfg, state) {
+			continue
+		}
+
+		size := diskUsage(runtimeFiles[mid])
+
+		driverType := machinedriver.DriverTypeFromName(mcfg.DriverName)
+		if driverType == machinedriver.UnknownDriver {
+			// Without a driver the machine can only be removed from the store
+			if err := store.Purge(mid); err != nil {
+				plog.Errorf("could not remove machine %s: %v", mid.ShortString(), err)
+				continue
+			}
+
+			if _, err := machine.RemoveRuntimeFiles(runtimeFiles[mid]); err != nil {
+				plog.Errorf("could not remove runtime files of %s: %v", mid.ShortString(), err)
+			}
+		} else {
+			driver, err := drivers.ForType(driverType)
+			if err != nil {
+				return fmt.Errorf("could not instantiate machine driver for %s: %v", mid.ShortString(), err)
+			}
+
+			if err := driver.Destroy(ctx, mid); err != nil {
+				plog.Errorf("could not remove machine %s: %v", mid.ShortString(), err)
+				continue
+			}
+		}
+
+		pruned = append(pruned, mid)
+		reclaimed += size
+	}
+
+	// Runtime files of machines which are no longer in the store cannot be
+	// matched against filters, so only remove them when none are provided.
+	orphans := 0
+	if filter.empty() {
+		for mid, files := range runtimeFiles {
+			if _, ok := mcfgs[mid]; ok {
+				continue
+			}
+
+			stale := true
+			for _, file := range files {
+				if fi, err := os.Lstat(file); err == nil && now.Sub(fi.ModTime()) < orphanGracePeriod {
+					stale = false
+				}
+			}
+
+			if !stale {
+				continue
+			}
+
+			n, err := machine.RemoveRuntimeFiles(files)
+			if err != nil {
+				plog.Errorf("could not remove orphaned runtime files of %s: %v", mid.ShortString(), err)
+			}
+
+			reclaimed += n
+			orphans++
+		}
+	}
+
+	if len(pruned) > 0 {
+		fmt.Fprintf(opts.IO.Out, "Deleted machines:\n")
+		for _, mid := range pruned {
+			fmt.Fprintf(opts.IO.Out, "%s\n", mid.ShortString())
+		}
+		fmt.Fprintf(opts.IO.Out, "\n")
+	}
+
+	if orphans > 0 {
+		fmt.Fprintf(opts.IO.Out, "Removed orphaned runtime files of %d machines\n\n", orphans)
+	}
+
+	fmt.Fprintf(opts.IO.Out, "Total reclaimed space: %s\n", humanize.Bytes(reclaimed))
+
+	return nil
+}
@@ -0,0 +1,114 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package prune
+
+import (
+	"testing"
+	"time"
+
+	"kraftkit.sh/machine"
+)
+
+func TestNewMachineFilter(t *testing.T) {
+	now := time.Date(2023, 1, 2, 15, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		filters []string
+		empty   bool
+		wantErr bool
+	}{
+		{filters: nil, empty: true},
+		{filters: []string{"id=4b2e", "name=nginx", "driver=qemu", "state=exited", "arch=x86_64", "plat=kvm"}},
+		{filters: []string{"until=24h"}},
+		{filters: []string{"until=2023-01-01T00:00:00Z"}},
+		{filters: []string{"until=yesterday"}, wantErr: true},
+		{filters: []string{"type=exit"}, wantErr: true},
+		{filters: []string{"name"}, wantErr: true},
+		{filters: []string{"name="}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		mf, err := newMachineFilter(tt.filters, now)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%v: unexpected error: %v", tt.filters, err)
+			continue
+		} else if err != nil {
+			continue
+		}
+
+		if mf.empty() != tt.empty {
+			t.Errorf("%v: expected empty to be %v", tt.filters, tt.empty)
+		}
+	}
+}
+
+func TestMachineFilterMatches(t *testing.T) {
+	now := time.Date(2023, 1, 2, 15, 4, 5, 0, time.UTC)
+
+	mcfg := machine.MachineConfig{
+		ID:           machine.MachineID("4b2ea7e1b4ab4c3f9d1e0a7b6c5d4e3f"),
+		Name:         machine.MachineName("nginx"),
+		DriverName:   "qemu",
+		Architecture: "x86_64",
+		Platform:     "kvm",
+		CreatedAt:    now.Add(-48 * time.Hour),
+	}
+
+	tests := []struct {
+		filters []string
+		matches bool
+	}{
+		{filters: nil, matches: true},
+		{filters: []string{"id=4b2ea7e1b4ab"}, matches: true},
+		{filters: []string{"id=ffff"}, matches: false},
+		{filters: []string{"name=nginx", "driver=qemu"}, matches: true},
+		{filters: []string{"name=nginx", "driver=firecracker"}, matches: false},
+		{filters: []string{"state=running", "state=exited"}, matches: true},
+		{filters: []string{"state=running"}, matches: false},
+		{filters: []string{"arch=arm64", "plat=kvm"}, matches: false},
+		{filters: []string{"arch=x86_64", "plat=kvm"}, matches: true},
+		{filters: []string{"until=24h"}, matches: true},
+		{filters: []string{"until=72h"}, matches: false},
+		{filters: []string{"until=24h", "name=redis"}, matches: false},
+	}
+
+	for _, tt := range tests {
+		mf, err := newMachineFilter(tt.filters, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := mf.matches(mcfg, machine.MachineStateExited); got != tt.matches {
+			t.Errorf("%v: expected %v, got %v", tt.filters, tt.matches, got)
+		}
+	}
+}
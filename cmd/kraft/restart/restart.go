@@ -0,0 +1,180 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package restart
+
+import (
+	"context"
+	"fmt"
+
+	"kraftkit.sh/cmd/kraft/events"
+	"kraftkit.sh/config"
+	"kraftkit.sh/iostreams"
+	"kraftkit.sh/log"
+	"kraftkit.sh/machine"
+	machinedriver "kraftkit.sh/machine/driver"
+	"kraftkit.sh/machine/driveropts"
+	"kraftkit.sh/packmanager"
+
+	"kraftkit.sh/internal/cmdfactory"
+	"kraftkit.sh/internal/cmdutil"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/spf13/cobra"
+)
+
+type restartOptions struct {
+	PackageManager func(opts ...packmanager.PackageManagerOption) (packmanager.PackageManager, error)
+	ConfigManager  func() (*config.ConfigManager, error)
+	Logger         func() (log.Logger, error)
+	IO             *iostreams.IOStreams
+
+	// Command-line arguments
+	NoMonitor bool
+}
+
+func RestartCmd(f *cmdfactory.Factory) *cobra.Command {
+	cmd, err := cmdutil.NewCmd(f, "restart")
+	if err != nil {
+		panic("could not initialize 'kraft restart' command")
+	}
+
+	opts := &restartOptions{
+		PackageManager: f.PackageManager,
+		ConfigManager:  f.ConfigManager,
+		Logger:         f.Logger,
+		IO:             f.IOStreams,
+	}
+
+	cmd.Short = "Restart one or more unikernels"
+	cmd.Use = "restart [FLAGS] MACHINE [MACHINE [...]]"
+	cmd.Args = cobra.MinimumNArgs(1)
+	cmd.Long = heredoc.Doc(`
+		Restart one or more unikernels.
+
+		Active unikernels are stopped before they are launched again from the
+		configuration they were created with, retaining their ID and name.`)
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runRestart(opts, args...)
+	}
+
+	cmd.Flags().BoolVar(
+		&opts.NoMonitor,
+		"no-monitor",
+		false,
+		"Do not spawn a (or attach to an existing) KraftKit unikernel monitor",
+	)
+
+	return cmd
+}
+
+func runRestart(opts *restartOptions, args ...string) error {
+	var err error
+
+	plog, err := opts.Logger()
+	if err != nil {
+		return err
+	}
+
+	cfgm, err := opts.ConfigManager()
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	store, err := machine.NewMachineStoreFromPath(cfgm.Config.RuntimeDir)
+	if err != nil {
+		return fmt.Errorf("could not access machine store: %v", err)
+	}
+
+	mids, err := machinedriver.LookupMachines(store, args...)
+	if err != nil {
+		return err
+	}
+
+	drivers := machinedriver.NewDrivers(
+		driveropts.WithLogger(plog),
+		driveropts.WithMachineStore(store),
+		driveropts.WithRuntimeDir(cfgm.Config.RuntimeDir),
+	)
+
+	restarted := 0
+	failed := 0
+
+	for _, mid := range mids {
+		driver, err := drivers.ForMachine(store, mid)
+		if err != nil {
+			plog.Errorf("%v", err)
+			failed++
+			continue
+		}
+
+		state, err := driver.State(ctx, mid)
+		if err != nil {
+			plog.Errorf("could not look up machine state: %v", err)
+			failed++
+			continue
+		}
+
+		plog.Infof("restarting %s...", mid.ShortString())
+
+		switch state {
+		case machine.MachineStateExited, machine.MachineStateDead, machine.MachineStateCrashed:
+		default:
+			if err := driver.Stop(ctx, mid); err != nil {
+				plog.Errorf("could not stop machine %s: %v", mid.ShortString(), err)
+				failed++
+				continue
+			}
+		}
+
+		if err := driver.Start(ctx, mid); err != nil {
+			plog.Errorf("could not start machine %s: %v", mid.ShortString(), err)
+			failed++
+			continue
+		}
+
+		plog.Infof("restarted %s", mid.ShortString())
+		restarted++
+	}
+
+	if restarted > 0 && !opts.NoMonitor {
+		if err := events.SpawnMonitor(cfgm.Config.EventsPidFile, plog); err != nil {
+			return err
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("could not restart %d of %d machines", failed, len(mids))
+	}
+
+	return nil
+}
@@ -0,0 +1,251 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package run
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"kraftkit.sh/config"
+	"kraftkit.sh/log"
+	machinedriver "kraftkit.sh/machine/driver"
+	"kraftkit.sh/machine/process"
+	"kraftkit.sh/pack"
+	"kraftkit.sh/packmanager"
+	"kraftkit.sh/unikraft"
+	"kraftkit.sh/unikraft/plat"
+)
+
+// packageQuery returns the catalog query of a package reference.  A directory
+// is used as the source of packages, e.g. an OCI image layout, and a plain
+// name, such as `nginx:latest` or `app/nginx:1.2`, is looked up within the
+// local package store.  Anything else, such as the reference of an OCI image,
+// is left to be interpreted by the package managers.
+func packageQuery(entity string) packmanager.CatalogQuery {
+	if f, err := os.Stat(entity); err == nil && f.IsDir() {
+		return packmanager.CatalogQuery{
+			Source: entity,
+		}
+	}
+
+	if t, n, v, err := unikraft.GuessTypeNameVersion(entity); err == nil && (t == unikraft.ComponentTypeApp || t == unikraft.ComponentTypeUnknown) {
+		return packmanager.CatalogQuery{
+			Local:   true,
+			Name:    n,
+			Version: v,
+			Types:   []unikraft.ComponentType{unikraft.ComponentTypeApp},
+		}
+	}
+
+	return packmanager.CatalogQuery{
+		Source: entity,
+	}
+}
+
+// selectPackage returns the package of the architecture and, if set, of the
+// platform.  Without a platform, the first package which can be booted by an
+// available driver is preferred.
+func selectPackage(packages []pack.Package, arch, platform string, auto bool) (pack.Package, error) {
+	var candidates []pack.Package
+	var available []string
+
+	for _, p := range packages {
+		popts := p.Options()
+		if popts.Architecture == nil || popts.Platform == nil {
+			continue
+		}
+
+		available = append(available, popts.ArchPlatString())
+
+		if *popts.Architecture != arch || (len(platform) > 0 && !samePlatform(*popts.Platform, platform)) {
+			continue
+		}
+
+		candidates = append(candidates, p)
+	}
+
+	if len(candidates) == 0 {
+		if len(available) == 0 {
+			return nil, fmt.Errorf("no runnable package found")
+		}
+
+		sort.Strings(available)
+		return nil, fmt.Errorf("no package found for %s/%s, available: %s", orAny(platform), arch, strings.Join(available, ", "))
+	}
+
+	names := map[string]bool{}
+	for _, p := range candidates {
+		names[p.Options().NameVersion()] = true
+	}
+
+	if len(names) > 1 {
+		var matches []string
+		for name := range names {
+			matches = append(matches, name)
+		}
+
+		sort.Strings(matches)
+		return nil, fmt.Errorf("too many packages match, choose one of: %s", strings.Join(matches, ", "))
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return *candidates[i].Options().Platform < *candidates[j].Options().Platform
+	})
+
+	if auto {
+		for _, p := range candidates {
+			if _, err := machinedriver.SelectDriver(*p.Options().Platform, arch); err == nil {
+				return p, nil
+			}
+		}
+	}
+
+	return candidates[0], nil
+}
+
+// catalog queries each package manager on its own, such that a manager which
+// cannot interpret the reference, e.g. the manifest manager given the reference
+// of an OCI image, does not prevent the package from being found by another.
+// The errors are only returned when no package was found.
+func catalog(query packmanager.CatalogQuery) ([]pack.Package, error) {
+	var packages []pack.Package
+	var errs []string
+
+	for _, manager := range packmanager.PackageManagers() {
+		found, err := manager.Catalog(query)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", manager.Format(), err))
+			continue
+		}
+
+		packages = append(packages, found...)
+	}
+
+	if len(packages) == 0 && len(errs) > 0 {
+		sort.Strings(errs)
+		return nil, fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+
+	return packages, nil
+}
+
+func orAny(s string) string {
+	if len(s) == 0 {
+		return "*"
+	}
+
+	return s
+}
+
+// samePlatform returns whether both names refer to the same platform, taking
+// aliases such as `qemu` for `kvm` into account.
+func samePlatform(a, b string) bool {
+	if name, err := plat.PlatformByName(a); err == nil {
+		a = name
+	}
+	if name, err := plat.PlatformByName(b); err == nil {
+		b = name
+	}
+
+	return a == b
+}
+
+// resolvePackage finds the package of the reference which matches the
+// requested platform and architecture, or the architecture of the host.  The
+// package is pulled into the local package store if it has not been before and
+// unpacked into the runtime directory.  The options of the returned package
+// refer to the unpacked kernel, initial ramdisk and default arguments.
+func resolvePackage(opts *runOptions, cfg *config.Config, plog log.Logger, entity string) (pack.Package, error) {
+	pm, err := opts.PackageManager()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := pm.ApplyOptions(packmanager.WithLogger(plog)); err != nil {
+		return nil, err
+	}
+
+	arch := opts.Architecture
+	if len(arch) == 0 {
+		arch = process.HostArchitecture()
+	}
+
+	auto := opts.Hypervisor == "auto" || len(opts.Hypervisor) == 0
+	query := packageQuery(entity)
+
+	packages, err := catalog(query)
+	if err != nil {
+		plog.Debugf("could not query %s: %v", entity, err)
+	}
+
+	p, err := selectPackage(packages, arch, opts.Platform, auto)
+	if err != nil {
+		// The local package store may only contain some platforms of an image,
+		// so query its origin before giving up
+		query.NoCache = true
+		if packages, qerr := catalog(query); qerr == nil {
+			p, err = selectPackage(packages, arch, opts.Platform, auto)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not run %s: %v", entity, err)
+	}
+
+	popts := p.Options()
+	dir := filepath.Join(
+		cfg.RuntimeDir,
+		"packages",
+		strings.NewReplacer("/", "_", ":", "_").Replace(popts.NameVersion()),
+		*popts.Platform+"-"+*popts.Architecture,
+	)
+
+	plog.Infof("using %s (%s)", popts.NameVersion(), popts.ArchPlatString())
+
+	if err := p.Pull(
+		pack.WithPullWorkdir(dir),
+		pack.WithPullLogger(plog),
+		pack.WithPullCache(true),
+		pack.WithPullArchitecture(*popts.Architecture),
+		pack.WithPullPlatform(*popts.Platform),
+	); err != nil {
+		return nil, fmt.Errorf("could not pull %s: %v", entity, err)
+	}
+
+	if len(popts.Kernel) == 0 {
+		return nil, fmt.Errorf("package %s does not provide a kernel", popts.NameVersion())
+	}
+
+	return p, nil
+}
@@ -34,11 +34,14 @@ package run
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"sort"
 	"syscall"
 
+	"kraftkit.sh/cmd/kraft/events"
 	"kraftkit.sh/config"
 	"kraftkit.sh/exec"
 	"kraftkit.sh/iostreams"
@@ -46,8 +49,10 @@ import (
 	"kraftkit.sh/machine"
 	machinedriver "kraftkit.sh/machine/driver"
 	machinedriveropts "kraftkit.sh/machine/driveropts"
+	"kraftkit.sh/machine/symbolize"
 	"kraftkit.sh/packmanager"
 	"kraftkit.sh/unikraft/app"
+	"kraftkit.sh/unikraft/plat"
 	"kraftkit.sh/utils"
 
 	"kraftkit.sh/internal/cmdfactory"
@@ -67,20 +72,35 @@ type runOptions struct {
 	IO             *iostreams.IOStreams
 
 	// Command-line arguments
+	AppArgs       []string
 	Architecture  string
 	Detach        bool
 	DisableAccel  bool
+	Env           []string
+	EnvFiles      []string
 	Hypervisor    string
+	Initrd        string
+	KernelArgs    []string
+	Machine       string
 	Memory        int
 	NoMonitor     bool
 	PinCPUs       string
 	Platform      string
+	Ports         []string
 	Remove        bool
 	Target        string
+	VCPUs         int
 	Volumes       []string
 	WithKernelDbg bool
+
+	// Whether the positional arguments were terminated by `--`
+	dashed bool
 }
 
+// defaultMemory is the amount of memory in MiB assigned to the unikernel when
+// neither set on the command-line nor by the target.
+const defaultMemory = 64
+
 func RunCmd(f *cmdfactory.Factory) *cobra.Command {
 	cmd, err := cmdutil.NewCmd(f, "run",
 		cmdutil.WithSubcmds(),
@@ -97,20 +117,59 @@ func RunCmd(f *cmdfactory.Factory) *cobra.Command {
 	}
 
 	cmd.Short = "Run a unikernel"
-	cmd.Use = "run [FLAGS] [PROJECT|KERNEL] [ARGS]"
+	cmd.Use = "run [FLAGS] [PROJECT|KERNEL|PACKAGE] [-- ARGS]"
 	cmd.Aliases = []string{"launch", "r"}
 	cmd.Long = heredoc.Doc(`
-		Launch a unikernel`)
+		Launch a unikernel
+
+		When running a project, the settings within the runtime section of the
+		target are used unless they are overridden via the command-line.
+
+		When running a package, the package which matches the architecture of the
+		host (or --arch) and the requested platform (--plat) is selected.  Packages
+		of a registry are pulled into the local package store the first time they
+		are run.  The default arguments and the initial ramdisk of the package are
+		used unless they are overridden via the command-line.
+
+		Within a project, positional arguments are passed to the application of
+		the project.  To run a kernel or package instead, terminate it with "--".`)
 	cmd.Example = heredoc.Doc(`
 		# Run a unikernel kernel image
 		kraft run path/to/kernel-x86_64-kvm
 
 		# Run a project which only has one target
 		kraft run path/to/project
+
+		# Run a package of the local package store
+		kraft run nginx:latest
+
+		# Run an OCI-packaged unikernel of a registry for the KVM platform
+		kraft run --plat kvm unikraft.io/nginx:1.21.6
+
+		# Run a package of an OCI image layout
+		kraft run path/to/oci-layout
+
+		# Run a package from within a project, where positional arguments are
+		# otherwise passed to the application of the project
+		kraft run nginx:latest --
+
+		# Run a unikernel using QEMU's minimal microvm machine type
+		kraft run --machine microvm path/to/project
+
+		# Run a unikernel with library parameters, environment variables and
+		# application arguments
+		kraft run --kernel-arg vfs.rootdev=fs0 -e HOME=/ path/to/kernel -- -c /etc/app.conf
 	`)
 	cmd.RunE = func(cmd *cobra.Command, args []string) error {
 		opts.Hypervisor = cmd.Flag("hypervisor").Value.String()
 
+		// Everything after `--` is passed to the application
+		if dash := cmd.ArgsLenAtDash(); dash >= 0 {
+			opts.AppArgs = args[dash:]
+			opts.dashed = true
+			args = args[:dash]
+		}
+
 		return runRun(opts, args...)
 	}
 
@@ -138,8 +197,15 @@ func RunCmd(f *cmdfactory.Factory) *cobra.Command {
 	cmd.Flags().IntVarP(
 		&opts.Memory,
 		"memory", "M",
-		64,
-		"Assign MB memory to the unikernel.",
+		0,
+		"Assign MB memory to the unikernel (default 64, or as set by the target).",
+	)
+
+	cmd.Flags().IntVar(
+		&opts.VCPUs,
+		"vcpus",
+		0,
+		"Assign virtual CPUs to the unikernel.",
 	)
 
 	cmd.Flags().StringVarP(
@@ -150,10 +216,17 @@ func RunCmd(f *cmdfactory.Factory) *cobra.Command {
 	)
 
 	cmd.Flags().VarP(
-		cmdutil.NewEnumFlag(machinedriver.DriverNames(), "auto"),
+		cmdutil.NewEnumFlag(append(machinedriver.DriverNames(), "auto"), "auto"),
 		"hypervisor",
 		"H",
-		"Set the hypervisor machine driver.",
+		"Set the hypervisor machine driver, by default selected based on the platform and architecture.",
+	)
+
+	cmd.Flags().StringVar(
+		&opts.Machine,
+		"machine",
+		"",
+		"Set the hypervisor machine type (e.g. pc or microvm for QEMU on x86_64).",
 	)
 
 	cmd.Flags().StringVar(
@@ -184,6 +257,48 @@ func RunCmd(f *cmdfactory.Factory) *cobra.Command {
 		"Automatically remove the unikernel when it shutsdown",
 	)
 
+	cmd.Flags().StringArrayVarP(
+		&opts.Env,
+		"env", "e",
+		[]string{},
+		"Set an environment variable of the application (KEY=VALUE, or KEY to use the value of the host).",
+	)
+
+	cmd.Flags().StringArrayVar(
+		&opts.EnvFiles,
+		"env-file",
+		[]string{},
+		"Read environment variables of the application from a file.",
+	)
+
+	cmd.Flags().StringArrayVarP(
+		&opts.Ports,
+		"port", "p",
+		[]string{},
+		"Forward a port of the host to the unikernel ([[HOSTIP:]HOSTPORT:]GUESTPORT[/PROTOCOL]).",
+	)
+
+	cmd.Flags().StringArrayVarP(
+		&opts.Volumes,
+		"volume", "v",
+		[]string{},
+		"Share a directory of the host with the unikernel (SOURCE:DESTINATION[:ro|rw]).",
+	)
+
+	cmd.Flags().StringVar(
+		&opts.Initrd,
+		"initrd",
+		"",
+		"Use the initial ramdisk at the given path.",
+	)
+
+	cmd.Flags().StringArrayVar(
+		&opts.KernelArgs,
+		"kernel-arg",
+		[]string{},
+		"Set a library parameter of the kernel (LIBRARY.PARAMETER=VALUE).",
+	)
+
 	return cmd
 }
 
@@ -200,21 +315,20 @@ func runRun(opts *runOptions, args ...string) error {
 		return err
 	}
 
-	var driverType *machinedriver.DriverType
-	if opts.Hypervisor == "auto" {
-		dt, err := machinedriver.DetectHostHypervisor()
-		if err != nil {
-			return err
-		}
-		driverType = &dt
-	} else if opts.Hypervisor == "config" {
+	if opts.Hypervisor == "config" {
 		opts.Hypervisor = cfgm.Config.DefaultPlat
 	}
 
-	if driverType == nil && len(opts.Hypervisor) > 0 && !utils.Contains(machinedriver.DriverNames(), opts.Hypervisor) {
+	if opts.Hypervisor != "auto" && len(opts.Hypervisor) > 0 && !utils.Contains(machinedriver.DriverNames(), opts.Hypervisor) {
 		return fmt.Errorf("unknown hypervisor driver: %s", opts.Hypervisor)
 	}
 
+	if len(opts.Platform) > 0 {
+		if opts.Platform, err = plat.PlatformByName(opts.Platform); err != nil {
+			return err
+		}
+	}
+
 	debug := logger.LogLevelFromString(cfgm.Config.Log.Level) >= logger.DEBUG
 	var msopts []machine.MachineStoreOption
 	if debug {
@@ -228,26 +342,14 @@ func runRun(opts *runOptions, args ...string) error {
 		return fmt.Errorf("could not access machine store: %v", err)
 	}
 
-	driver, err := machinedriver.New(*driverType,
-		machinedriveropts.WithBackground(opts.Detach),
-		machinedriveropts.WithRuntimeDir(cfgm.Config.RuntimeDir),
-		machinedriveropts.WithMachineStore(store),
-		machinedriveropts.WithLogger(plog),
-		machinedriveropts.WithDebug(debug),
-		machinedriveropts.WithExecOptions(
-			exec.WithStdout(os.Stdout),
-			exec.WithStderr(os.Stderr),
-		),
-	)
-	if err != nil {
-		return err
-	}
-
 	mopts := []machine.MachineOption{
-		machine.WithDriverName(driverType.String()),
 		machine.WithDestroyOnExit(opts.Remove),
 	}
 
+	// The architecture and platform of the kernel determine which drivers are
+	// able to boot it
+	var architecture, platform string
+
 	// The following sequence checks the position argument of `kraft run ENTITY`
 	// where ENTITY can either be:
 	// a). path to a project which either uses the only specified target or one
@@ -257,14 +359,17 @@ func runRun(opts *runOptions, args ...string) error {
 	// c). path to a kernel.
 	var workdir string
 	var entity string
-	var kernelArgs []string
+	var appArgs []string
+
+	// Environment variables set by the target, which take the lowest precedence
+	var runtimeEnv []string
 
 	// Determine if more than one positional arguments have been provided.  If
-	// this is the case, everything after the first position argument are kernel
-	// parameters which should be passed appropriately.
+	// this is the case, everything after the first position argument are
+	// arguments of the application which should be passed appropriately.
 	if len(args) > 1 {
 		entity = args[0]
-		kernelArgs = args[1:]
+		appArgs = args[1:]
 	} else if len(args) == 1 {
 		entity = args[0]
 	}
@@ -280,9 +385,12 @@ func runRun(opts *runOptions, args ...string) error {
 			return err
 		}
 
-		if app.IsWorkdirInitialized(cwd) {
+		// Within a project, positional arguments are passed to the application
+		// unless they are terminated by `--`, in which case the first one is the
+		// kernel or package to run instead
+		if app.IsWorkdirInitialized(cwd) && !(opts.dashed && len(entity) > 0) {
 			workdir = cwd
-			kernelArgs = args
+			appArgs = args
 		}
 	}
 
@@ -344,13 +452,61 @@ func runRun(opts *runOptions, args ...string) error {
 		if len(opts.Architecture) > 0 && (opts.Architecture != t.Architecture.Name()) {
 			return fmt.Errorf("selected target (%s) does not match specified architecture (%s)", t.ArchPlatString(), opts.Architecture)
 		}
-		if len(opts.Platform) > 0 && (opts.Platform != t.Platform.Name()) {
+		if len(opts.Platform) > 0 && !samePlatform(opts.Platform, t.Platform.Name()) {
 			return fmt.Errorf("selected target (%s) does not match specified platform (%s)", t.ArchPlatString(), opts.Platform)
 		}
 
+		architecture = t.Architecture.Name()
+		platform = t.Platform.Name()
+
+		// Use the machine type of the target unless overridden
+		if len(opts.Machine) == 0 {
+			opts.Machine = t.Machine
+		}
+
+		// Use the runtime settings of the target unless overridden
+		if opts.Memory == 0 && len(t.Runtime.Memory) > 0 {
+			memory, err := t.Runtime.MemorySize()
+			if err != nil {
+				return fmt.Errorf("target %s: %v", t.Name(), err)
+			}
+
+			opts.Memory = int(memory)
+		}
+		if opts.VCPUs == 0 {
+			opts.VCPUs = int(t.Runtime.VCPUs)
+		}
+		if len(opts.Ports) == 0 {
+			opts.Ports = t.Runtime.Ports
+		}
+		if len(opts.Volumes) == 0 {
+			opts.Volumes = t.Runtime.Volumes
+		}
+		if len(opts.Initrd) == 0 {
+			opts.Initrd = t.Runtime.Initrd
+		}
+		if len(opts.Initrd) == 0 && t.Initrd != nil && len(t.Initrd.Output) > 0 {
+			opts.Initrd = t.Initrd.Output
+		}
+		if (opts.Hypervisor == "auto" || len(opts.Hypervisor) == 0) && len(t.Runtime.Driver) > 0 {
+			opts.Hypervisor = t.Runtime.Driver
+		}
+		if len(appArgs) == 0 && len(opts.AppArgs) == 0 {
+			if len(t.Runtime.Args) > 0 {
+				appArgs = t.Runtime.Args
+			} else {
+				appArgs = t.Command
+			}
+		}
+
+		for key, value := range t.Runtime.Env {
+			runtimeEnv = append(runtimeEnv, key+"="+value)
+		}
+		sort.Strings(runtimeEnv)
+
 		mopts = append(mopts,
-			machine.WithArchitecture(t.Architecture.Name()),
-			machine.WithPlatform(t.Platform.Name()),
+			machine.WithArchitecture(architecture),
+			machine.WithPlatform(platform),
 			machine.WithName(machine.MachineName(t.Name())),
 			machine.WithAcceleration(!opts.DisableAccel),
 			machine.WithSource("project://"+app.Name()+":"+t.Name()),
@@ -363,6 +519,12 @@ func runRun(opts *runOptions, args ...string) error {
 			mopts = append(mopts, machine.WithKernel(t.Kernel))
 		}
 
+		// Retain the path to the symbolic kernel such that crash output can be
+		// symbolized
+		if len(t.KernelDbg) > 0 {
+			mopts = append(mopts, machine.WithKernelDbg(t.KernelDbg))
+		}
+
 		// If no entity was set earlier and we're not within the context of a working
 		// directory, then we're unsure what to run
 	} else if len(entity) == 0 {
@@ -374,24 +536,144 @@ func runRun(opts *runOptions, args ...string) error {
 			return fmt.Errorf("cannot use `kraft run KERNEL` without specifying --arch and --plat")
 		}
 
+		architecture = opts.Architecture
+		platform = opts.Platform
+
 		mopts = append(mopts,
-			machine.WithArchitecture(opts.Architecture),
-			machine.WithPlatform(opts.Platform),
+			machine.WithArchitecture(architecture),
+			machine.WithPlatform(platform),
 			machine.WithName(machine.MachineName(namesgenerator.GetRandomName(0))),
 			machine.WithKernel(entity),
 			machine.WithSource("kernel://"+filepath.Base(entity)),
 		)
+
+		// d). Otherwise the argument refers to a package
 	} else {
-		return fmt.Errorf("could not determine what to run: %s", entity)
+		p, err := resolvePackage(opts, cfgm.Config, plog, entity)
+		if err != nil {
+			return err
+		}
+
+		popts := p.Options()
+		architecture = *popts.Architecture
+		platform = *popts.Platform
+
+		if len(opts.Initrd) == 0 && popts.Initrd != nil {
+			opts.Initrd = popts.Initrd.Output
+		}
+		if len(appArgs) == 0 && len(opts.AppArgs) == 0 {
+			appArgs = popts.Command
+		}
+
+		mopts = append(mopts,
+			machine.WithArchitecture(architecture),
+			machine.WithPlatform(platform),
+			machine.WithName(machine.MachineName(namesgenerator.GetRandomName(0))),
+			machine.WithAcceleration(!opts.DisableAccel),
+			machine.WithKernel(popts.Kernel),
+			machine.WithSource("package://"+entity),
+		)
+	}
+
+	var driverType machinedriver.DriverType
+	if opts.Hypervisor == "auto" || len(opts.Hypervisor) == 0 {
+		if driverType, err = machinedriver.SelectDriver(platform, architecture); err != nil {
+			return err
+		}
+	} else {
+		driverType = machinedriver.DriverTypeFromName(opts.Hypervisor)
+		if err := machinedriver.ValidateCompatibility(driverType, platform, architecture); err != nil {
+			return err
+		}
+	}
+
+	if len(opts.Machine) > 0 && driverType != machinedriver.QemuDriver {
+		return fmt.Errorf("machine type %s is not supported by the %s driver", opts.Machine, driverType.String())
+	}
+
+	driver, err := machinedriver.New(driverType,
+		machinedriveropts.WithBackground(opts.Detach),
+		machinedriveropts.WithRuntimeDir(cfgm.Config.RuntimeDir),
+		machinedriveropts.WithMachineStore(store),
+		machinedriveropts.WithLogger(plog),
+		machinedriveropts.WithDebug(debug),
+		machinedriveropts.WithExecOptions(
+			exec.WithStdout(os.Stdout),
+			exec.WithStderr(os.Stderr),
+		),
+	)
+	if err != nil {
+		return err
+	}
+
+	if opts.Memory == 0 {
+		opts.Memory = defaultMemory
+	}
+
+	var ports []machine.MachinePort
+	for _, p := range opts.Ports {
+		port, err := machine.ParseMachinePort(p)
+		if err != nil {
+			return err
+		}
+
+		ports = append(ports, port)
+	}
+
+	var volumes []machine.MachineVolume
+	for _, v := range opts.Volumes {
+		volume, err := machine.ParseMachineVolume(v)
+		if err != nil {
+			return err
+		}
+
+		volumes = append(volumes, volume)
 	}
 
 	mopts = append(mopts,
+		machine.WithDriverName(driverType.String()),
 		machine.WithMemorySize(uint64(opts.Memory)),
-		machine.WithArguments(kernelArgs),
+		machine.WithNumVCPUs(uint64(opts.VCPUs)),
+		machine.WithMachineType(opts.Machine),
+		machine.WithKernelArgs(opts.KernelArgs),
+		machine.WithArguments(append(appArgs, opts.AppArgs...)),
+		machine.WithPorts(ports),
+		machine.WithVolumes(volumes),
+		machine.WithInitRd(opts.Initrd),
+		machine.WithEnv(runtimeEnv),
 	)
 
+	// Environment variables set via -e take precedence over those read from
+	// files, which take precedence over those set by the target
+	for _, file := range opts.EnvFiles {
+		env, err := readEnvFile(file)
+		if err != nil {
+			return err
+		}
+
+		mopts = append(mopts, machine.WithEnv(env))
+	}
+
+	mopts = append(mopts, machine.WithEnv(resolveEnv(opts.Env)))
+
 	ctx := context.Background()
 
+	// Validate the kernel and initrd such that an unbootable image is reported
+	// before it is handed to the driver
+	mcfg, err := machine.NewMachineConfig(mopts...)
+	if err != nil {
+		return err
+	}
+
+	image, err := mcfg.Validate()
+	if err != nil {
+		return err
+	}
+
+	if image.Debug && !opts.WithKernelDbg {
+		plog.Warnf("kernel %s has not been stripped of its debug information", mcfg.KernelPath)
+	}
+
 	// Create the machine
 	mid, err := driver.Create(ctx, mopts...)
 	if err != nil {
@@ -407,32 +689,9 @@ func runRun(opts *runOptions, args ...string) error {
 
 	if !opts.NoMonitor {
 		// Spawn an event monitor or attach to an existing monitor
-		_, err = os.Stat(cfgm.Config.EventsPidFile)
-		if err != nil && os.IsNotExist(err) {
-			plog.Debugf("launching event monitor...")
-
-			// Spawn and detach a new events monitor
-			e, err := exec.NewExecutable(os.Args[0], nil, "events", "--quit-together")
-			if err != nil {
-				return err
-			}
-
-			process, err := exec.NewProcessFromExecutable(e,
-				exec.WithDetach(true),
-			)
-			if err != nil {
-				return err
-			}
-
-			if err := process.Start(); err != nil {
-				return err
-			}
-		} else if err != nil {
+		if err := events.SpawnMonitor(cfgm.Config.EventsPidFile, plog); err != nil {
 			return err
 		}
-
-		// TODO: Failsafe check if a a pidfile for the events monitor exists, let's
-		// check that it is active
 	}
 
 	ctx, cancel := context.WithCancel(ctx)
@@ -481,6 +740,13 @@ func runRun(opts *runOptions, args ...string) error {
 							cancel()
 						}
 						return
+
+					case machine.MachineStateCrashed:
+						// Retain the machine, even with --rm, such that its crash dump
+						// can be inspected
+						plog.Errorf("%s has crashed, see: kraft inspect %s", mid.ShortString(), mid.ShortString())
+						cancel()
+						return
 					}
 
 				case err := <-errs:
@@ -490,7 +756,17 @@ func runRun(opts *runOptions, args ...string) error {
 			}
 		}()
 
-		driver.TailWriter(ctx, mid, opts.IO.Out)
+		var out io.Writer = opts.IO.Out
+
+		if s, err := symbolize.NewFromMachineConfig(*mcfg); err != nil {
+			plog.Debugf("not symbolizing output of %s: %v", mid.ShortString(), err)
+		} else {
+			w := symbolize.NewWriter(opts.IO.Out, s)
+			defer w.Flush()
+			out = w
+		}
+
+		driver.TailWriter(ctx, mid, out)
 	}
 
 	return nil
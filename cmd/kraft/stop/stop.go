@@ -201,7 +201,7 @@ func runStop(opts *stopOptions, args ...string) error {
 			}
 
 			switch state {
-			case machine.MachineStateDead, machine.MachineStateExited:
+			case machine.MachineStateDead, machine.MachineStateExited, machine.MachineStateCrashed:
 				plog.Errorf("%s has exited", mid.ShortString())
 				observations.Done(mid)
 				return
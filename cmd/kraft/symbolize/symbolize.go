@@ -0,0 +1,130 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package symbolize
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"kraftkit.sh/config"
+	"kraftkit.sh/iostreams"
+	"kraftkit.sh/log"
+	"kraftkit.sh/machine/symbolize"
+	"kraftkit.sh/packmanager"
+
+	"kraftkit.sh/internal/cmdfactory"
+	"kraftkit.sh/internal/cmdutil"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/spf13/cobra"
+)
+
+type symbolizeOptions struct {
+	PackageManager func(opts ...packmanager.PackageManagerOption) (packmanager.PackageManager, error)
+	ConfigManager  func() (*config.ConfigManager, error)
+	Logger         func() (log.Logger, error)
+	IO             *iostreams.IOStreams
+
+	// Command-line arguments
+	Kernel string
+}
+
+func SymbolizeCmd(f *cmdfactory.Factory) *cobra.Command {
+	cmd, err := cmdutil.NewCmd(f, "symbolize")
+	if err != nil {
+		panic("could not initialize 'kraft symbolize' command")
+	}
+
+	opts := &symbolizeOptions{
+		PackageManager: f.PackageManager,
+		ConfigManager:  f.ConfigManager,
+		Logger:         f.Logger,
+		IO:             f.IOStreams,
+	}
+
+	cmd.Short = "Resolve addresses in unikernel crash output"
+	cmd.Use = "symbolize [FLAGS] [FILE]"
+	cmd.Args = cobra.MaximumNArgs(1)
+	cmd.Long = heredoc.Doc(`
+		Resolve addresses in unikernel crash output
+
+		Console output is read from FILE or, if omitted, from standard input.
+		Each line of a register dump, stack dump or backtrace is followed by the
+		function and source location of the instruction addresses it contains,
+		resolved against the provided unstripped kernel.`)
+	cmd.Example = heredoc.Doc(`
+		# Symbolize a saved crash log
+		kraft symbolize --kernel build/app_kvm-x86_64.dbg < crash.log`)
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runSymbolize(opts, args...)
+	}
+
+	cmd.Flags().StringVarP(
+		&opts.Kernel,
+		"kernel", "k",
+		"",
+		"Path to the unstripped kernel image.",
+	)
+
+	return cmd
+}
+
+func runSymbolize(opts *symbolizeOptions, args ...string) error {
+	if len(opts.Kernel) == 0 {
+		return fmt.Errorf("cannot symbolize without specifying --kernel")
+	}
+
+	s, err := symbolize.New(opts.Kernel)
+	if err != nil {
+		return err
+	}
+
+	var in io.Reader = opts.IO.In
+	if len(args) == 1 {
+		f, err := os.Open(args[0])
+		if err != nil {
+			return fmt.Errorf("could not open %s: %v", args[0], err)
+		}
+
+		defer f.Close()
+
+		in = f
+	}
+
+	w := symbolize.NewWriter(opts.IO.Out, s)
+	if _, err := io.Copy(w, in); err != nil {
+		return err
+	}
+
+	return w.Flush()
+}
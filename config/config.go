@@ -60,6 +60,7 @@ type Config struct {
 		Config    string `json:"config"    yaml:"config,omitempty"    env:"KRAFTKIT_PATHS_CONFIG"`
 		Manifests string `json:"manifests" yaml:"manifests,omitempty" env:"KRAFTKIT_PATHS_MANIFESTS"`
 		Sources   string `json:"sources"   yaml:"sources,omitempty"   env:"KRAFTKIT_PATHS_SOURCES"`
+		Packages  string `json:"packages"  yaml:"packages,omitempty"  env:"KRAFTKIT_PATHS_PACKAGES"`
 	} `json:"paths" yaml:"paths,omitempty"`
 
 	Log struct {
@@ -73,6 +74,12 @@ type Config struct {
 		Manifests []string `json:"manifests" yaml:"manifests" env:"KRAFTKIT_UNIKRAFT_MANIFESTS"`
 	} `json:"unikraft" yaml:"unikraft"`
 
+	Signing struct {
+		Key         string   `json:"key"          yaml:"key,omitempty"          env:"KRAFTKIT_SIGNING_KEY"`
+		TrustedKeys []string `json:"trusted_keys" yaml:"trusted_keys,omitempty" env:"KRAFTKIT_SIGNING_TRUSTED_KEYS"`
+		Policy      string   `json:"policy"       yaml:"policy,omitempty"       env:"KRAFTKIT_SIGNING_POLICY"`
+	} `json:"signing" yaml:"signing,omitempty"`
+
 	Auth map[string]AuthConfig `json:"auth" yaml:"auth,omitempty"`
 
 	Aliases map[string]map[string]string `json:"aliases" yaml:"aliases"`
@@ -136,6 +143,23 @@ var configDetails = []ConfigDetail{
 		Key:         "log.timestamps",
 		Description: "Show timestamps with log output",
 	},
+	{
+		Key:         "signing.key",
+		Description: "the private key used to sign packages",
+	},
+	{
+		Key:         "signing.trusted_keys",
+		Description: "the public keys whose signatures are trusted",
+	},
+	{
+		Key:         "signing.policy",
+		Description: "how signatures of pulled packages and manifest indexes are checked",
+		AllowedValues: []string{
+			"none",
+			"warn",
+			"enforce",
+		},
+	},
 }
 
 func ConfigDetails() []ConfigDetail {
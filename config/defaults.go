@@ -87,11 +87,21 @@ func NewDefaultConfig() (*Config, error) {
 		c.Paths.Manifests = filepath.Join(DataDir(), "manifests")
 	}
 
-	// ..and for cached source files
+	// ..for cached source files..
 	if len(c.Paths.Sources) == 0 {
 		c.Paths.Sources = filepath.Join(DataDir(), "sources")
 	}
 
+	// ..and for packages
+	if len(c.Paths.Packages) == 0 {
+		c.Paths.Packages = filepath.Join(DataDir(), "packages")
+	}
+
+	// Add default path for the private key used for signing
+	if len(c.Signing.Key) == 0 {
+		c.Signing.Key = filepath.Join(ConfigDir(), "signing.key")
+	}
+
 	if len(c.Unikraft.Manifests) == 0 {
 		c.Unikraft.Manifests = append(c.Unikraft.Manifests, DefaultManifestIndex)
 	}
@@ -44,6 +44,7 @@ type Process struct {
 	executable *Executable
 	opts       *ExecOptions
 	cmd        *exec.Cmd
+	pid        int
 }
 
 // NewProcess prepares a process to be executed from a given binary name and
@@ -154,6 +155,10 @@ func (e *Process) Start() error {
 		return fmt.Errorf("could not start process: %v", err)
 	}
 
+	// Retain the PID as it is no longer accessible once a detached process has
+	// been released
+	e.pid = e.cmd.Process.Pid
+
 	if e.opts.detach {
 		if err := e.cmd.Process.Release(); err != nil {
 			return fmt.Errorf("could not release process: %v", err)
@@ -202,9 +207,9 @@ func (e *Process) Kill() error {
 
 // Pid returns the process ID
 func (e *Process) Pid() (int, error) {
-	if e.cmd == nil || e.cmd.Process == nil || e.cmd.Process.Pid == -1 {
+	if e.cmd == nil || e.pid <= 0 {
 		return -1, fmt.Errorf("could not locate pid")
 	}
 
-	return e.cmd.Process.Pid, nil
+	return e.pid, nil
 }
@@ -146,3 +146,110 @@ func ParseInitrdConfig(workdir string, value string) (*InitrdConfig, error) {
 
 	return initrd, nil
 }
+
+// inputDir returns the path of the directory of an input which is either of the
+// form `workdir:path` or a path relative to the working directory.
+func (i *InitrdConfig) inputDir(input string) string {
+	if workdir, path, ok := strings.Cut(input, InputDelimeter); ok {
+		if filepath.IsAbs(path) {
+			return path
+		}
+
+		return filepath.Join(workdir, path)
+	}
+
+	return i.RelativePath(input)
+}
+
+// Build archives the directories of the inputs into a CPIO image.  The image is
+// written to the output, or to a temporary file if the output is unset.  The
+// path to the image is returned.
+func (i *InitrdConfig) Build() (string, error) {
+	if len(i.Input) == 0 {
+		return "", fmt.Errorf("cannot build initrd without input")
+	}
+
+	if i.Compress {
+		return "", fmt.Errorf("compressed initrd images are not supported")
+	}
+
+	var f *os.File
+	var err error
+
+	if len(i.Output) > 0 {
+		if err := os.MkdirAll(filepath.Dir(i.Output), 0o755); err != nil {
+			return "", fmt.Errorf("could not create initrd directory: %v", err)
+		}
+
+		f, err = os.Create(i.Output)
+	} else {
+		f, err = os.CreateTemp("", "kraft-initrd-*.cpio")
+	}
+	if err != nil {
+		return "", fmt.Errorf("could not create initrd: %v", err)
+	}
+
+	defer f.Close()
+
+	w, err := i.NewWriter(f)
+	if err != nil {
+		return "", err
+	}
+
+	for _, input := range i.Input {
+		dir := i.inputDir(input)
+
+		if err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+
+			name, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			} else if name == "." {
+				return nil
+			}
+
+			var link string
+			if info.Mode()&os.ModeSymlink != 0 {
+				if link, err = os.Readlink(path); err != nil {
+					return err
+				}
+			}
+
+			header, err := cpio.FileInfoHeader(info, link)
+			if err != nil {
+				return err
+			}
+
+			header.Name = "./" + filepath.ToSlash(name)
+
+			if err := w.WriteHeader(header); err != nil {
+				return err
+			}
+
+			if !info.Mode().IsRegular() {
+				return nil
+			}
+
+			file, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+
+			defer file.Close()
+
+			_, err = io.Copy(w, file)
+			return err
+		}); err != nil {
+			return "", fmt.Errorf("could not archive %s: %v", dir, err)
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		return "", fmt.Errorf("could not finalize initrd: %v", err)
+	}
+
+	return f.Name(), nil
+}
@@ -0,0 +1,313 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package semver
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// operator compares a version against the version of a comparator
+type operator string
+
+const (
+	opEqual        = operator("=")
+	opGreater      = operator(">")
+	opGreaterEqual = operator(">=")
+	opLess         = operator("<")
+	opLessEqual    = operator("<=")
+)
+
+// comparator is a single comparison, such as `>=0.10.0`
+type comparator struct {
+	op      operator
+	version Version
+}
+
+func (c comparator) check(v Version) bool {
+	cmp := v.Compare(c.version)
+
+	switch c.op {
+	case opEqual:
+		return cmp == 0
+	case opGreater:
+		return cmp > 0
+	case opGreaterEqual:
+		return cmp >= 0
+	case opLess:
+		return cmp < 0
+	case opLessEqual:
+		return cmp <= 0
+	}
+
+	return false
+}
+
+// Constraint is a range of semantic versions.  Comparators separated by spaces
+// or commas must all be satisfied, while alternatives are separated by `||`.
+// Besides the comparison operators, caret (`^1.2`), tilde (`~1.2.3`) and
+// wildcard (`1.2.x`) ranges are supported.
+type Constraint struct {
+	alternatives [][]comparator
+	original     string
+}
+
+// IsConstraint checks whether the string is a range rather than a single,
+// exact version
+func IsConstraint(s string) bool {
+	if strings.ContainsAny(s, "^~<>=*|, ") {
+		return true
+	}
+
+	for _, part := range strings.Split(s, ".") {
+		if part == "x" || part == "X" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ParseConstraint returns the constraint of the string
+func ParseConstraint(s string) (*Constraint, error) {
+	c := &Constraint{original: s}
+
+	for _, alternative := range strings.Split(s, "||") {
+		var comparators []comparator
+
+		fields := strings.FieldsFunc(alternative, func(r rune) bool {
+			return r == ' ' || r == ','
+		})
+
+		// Allow a space between an operator and its version, e.g. `>= 0.10`
+		for i := 0; i < len(fields); i++ {
+			if strings.Trim(fields[i], "<>=") == "" && i+1 < len(fields) {
+				fields[i+1] = fields[i] + fields[i+1]
+				continue
+			}
+
+			expanded, err := parseComparator(fields[i])
+			if err != nil {
+				return nil, fmt.Errorf("invalid version constraint %q: %v", s, err)
+			}
+
+			comparators = append(comparators, expanded...)
+		}
+
+		if len(comparators) == 0 {
+			return nil, fmt.Errorf("invalid version constraint %q: empty range", s)
+		}
+
+		c.alternatives = append(c.alternatives, comparators)
+	}
+
+	return c, nil
+}
+
+// parseComparator expands a single term of a constraint into comparators
+func parseComparator(term string) ([]comparator, error) {
+	for _, op := range []operator{opGreaterEqual, opLessEqual, opGreater, opLess, opEqual} {
+		if strings.HasPrefix(term, string(op)) {
+			v, n, err := parse(strings.TrimPrefix(term, string(op)))
+			if err != nil {
+				return nil, err
+			}
+
+			// A partial version stands for all of its versions, e.g. `>0.11`
+			// excludes `0.11.1` and `<=0.11` includes it
+			if n < 3 && op == opGreater {
+				return []comparator{{opGreaterEqual, next(v, n)}}, nil
+			} else if n < 3 && op == opLessEqual {
+				return []comparator{{opLess, next(v, n)}}, nil
+			}
+
+			return []comparator{{op, v}}, nil
+		}
+	}
+
+	switch {
+	case strings.HasPrefix(term, "^"):
+		v, n, err := parse(term[1:])
+		if err != nil {
+			return nil, err
+		}
+
+		// Changes to the left-most non-zero component are incompatible
+		var upper Version
+		switch {
+		case v.Major > 0 || n == 1:
+			upper = Version{Major: v.Major + 1}
+		case v.Minor > 0 || n == 2:
+			upper = Version{Minor: v.Minor + 1}
+		default:
+			upper = Version{Patch: v.Patch + 1}
+		}
+
+		return []comparator{{opGreaterEqual, v}, {opLess, upper}}, nil
+
+	case strings.HasPrefix(term, "~"):
+		v, n, err := parse(term[1:])
+		if err != nil {
+			return nil, err
+		}
+
+		// Only patch changes are allowed, unless no minor version is provided
+		return []comparator{{opGreaterEqual, v}, {opLess, next(v, n)}}, nil
+	}
+
+	return parseWildcard(term)
+}
+
+// next returns the lowest version following all versions of the partial
+// version with `n` components
+func next(v Version, n int) Version {
+	if n == 1 {
+		return Version{Major: v.Major + 1}
+	}
+
+	return Version{Major: v.Major, Minor: v.Minor + 1}
+}
+
+// parseWildcard expands an exact or partial version, such as `1.2`, `1.2.x` or
+// `*`, into comparators
+func parseWildcard(term string) ([]comparator, error) {
+	var parts []string
+	for _, part := range strings.Split(term, ".") {
+		if part == "x" || part == "X" || part == "*" {
+			break
+		}
+
+		parts = append(parts, part)
+	}
+
+	if len(parts) == 0 {
+		return []comparator{{opGreaterEqual, Version{}}}, nil
+	}
+
+	v, n, err := parse(strings.Join(parts, "."))
+	if err != nil {
+		return nil, err
+	}
+
+	if n < 3 {
+		return []comparator{{opGreaterEqual, v}, {opLess, next(v, n)}}, nil
+	}
+
+	return []comparator{{opEqual, v}}, nil
+}
+
+// Check returns whether the version satisfies the constraint.  Pre-releases
+// only satisfy a constraint which explicitly refers to a pre-release of the
+// same major, minor and patch version.
+func (c *Constraint) Check(v Version) bool {
+	for _, comparators := range c.alternatives {
+		satisfied := true
+		prerelease := len(v.Prerelease) == 0
+
+		for _, comp := range comparators {
+			if !comp.check(v) {
+				satisfied = false
+				break
+			}
+
+			if len(comp.version.Prerelease) > 0 &&
+				comp.version.Major == v.Major &&
+				comp.version.Minor == v.Minor &&
+				comp.version.Patch == v.Patch {
+				prerelease = true
+			}
+		}
+
+		if satisfied && prerelease {
+			return true
+		}
+	}
+
+	return false
+}
+
+// String returns the constraint as it was originally provided
+func (c *Constraint) String() string {
+	return c.original
+}
+
+// Highest returns the highest of the versions which satisfies the constraint.
+// Versions which are not semantic versions are ignored.
+func (c *Constraint) Highest(versions []string) (string, bool) {
+	var matches []Version
+
+	for _, s := range versions {
+		v, err := Parse(s)
+		if err != nil {
+			continue
+		}
+
+		if c.Check(v) {
+			matches = append(matches, v)
+		}
+	}
+
+	if len(matches) == 0 {
+		return "", false
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].Compare(matches[j]) > 0
+	})
+
+	return matches[0].String(), true
+}
+
+// Satisfies checks whether the version is the requested version or, if a
+// constraint is requested, whether it satisfies the constraint
+func Satisfies(requested, version string) bool {
+	if requested == version {
+		return true
+	}
+
+	if !IsConstraint(requested) {
+		return false
+	}
+
+	c, err := ParseConstraint(requested)
+	if err != nil {
+		return false
+	}
+
+	v, err := Parse(version)
+	if err != nil {
+		return false
+	}
+
+	return c.Check(v)
+}
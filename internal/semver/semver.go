@@ -0,0 +1,180 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+// Package semver parses semantic versions and resolves range constraints, such
+// as `^0.11`, `~1.2.3` or `>=0.10 <0.12`, against them.
+package semver
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version is a semantic version.  Partial versions, such as `0.11`, are
+// completed with zeros and a leading `v` is ignored.
+type Version struct {
+	Major      uint64
+	Minor      uint64
+	Patch      uint64
+	Prerelease string
+
+	original string
+}
+
+// Parse returns the semantic version of the string
+func Parse(s string) (Version, error) {
+	v, _, err := parse(s)
+	return v, err
+}
+
+// parse returns the semantic version of the string and the number of its
+// numeric components which were provided
+func parse(s string) (Version, int, error) {
+	v := Version{original: s}
+
+	str := strings.TrimPrefix(strings.TrimSpace(s), "v")
+
+	// Build metadata does not take part in precedence
+	if i := strings.IndexByte(str, '+'); i >= 0 {
+		str = str[:i]
+	}
+
+	if i := strings.IndexByte(str, '-'); i >= 0 {
+		v.Prerelease = str[i+1:]
+		str = str[:i]
+
+		if len(v.Prerelease) == 0 {
+			return Version{}, 0, fmt.Errorf("invalid semantic version: %s", s)
+		}
+	}
+
+	parts := strings.Split(str, ".")
+	if len(parts) > 3 {
+		return Version{}, 0, fmt.Errorf("invalid semantic version: %s", s)
+	}
+
+	nums := make([]uint64, 3)
+	for i, part := range parts {
+		n, err := strconv.ParseUint(part, 10, 64)
+		if err != nil {
+			return Version{}, 0, fmt.Errorf("invalid semantic version: %s", s)
+		}
+
+		nums[i] = n
+	}
+
+	v.Major, v.Minor, v.Patch = nums[0], nums[1], nums[2]
+
+	return v, len(parts), nil
+}
+
+// String returns the version as it was originally provided
+func (v Version) String() string {
+	if len(v.original) > 0 {
+		return v.original
+	}
+
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if len(v.Prerelease) > 0 {
+		s += "-" + v.Prerelease
+	}
+
+	return s
+}
+
+// Compare returns -1, 0 or 1 if the version is respectively lower than, equal
+// to or greater than the other version
+func (v Version) Compare(other Version) int {
+	if c := compareUint(v.Major, other.Major); c != 0 {
+		return c
+	}
+
+	if c := compareUint(v.Minor, other.Minor); c != 0 {
+		return c
+	}
+
+	if c := compareUint(v.Patch, other.Patch); c != 0 {
+		return c
+	}
+
+	// A pre-release has a lower precedence than the release itself
+	switch {
+	case v.Prerelease == other.Prerelease:
+		return 0
+	case len(v.Prerelease) == 0:
+		return 1
+	case len(other.Prerelease) == 0:
+		return -1
+	}
+
+	return comparePrerelease(v.Prerelease, other.Prerelease)
+}
+
+func compareUint(a, b uint64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+
+	return 0
+}
+
+// comparePrerelease compares the dot-separated identifiers of pre-releases,
+// where numeric identifiers are lower than alphanumeric ones
+func comparePrerelease(a, b string) int {
+	as := strings.Split(a, ".")
+	bs := strings.Split(b, ".")
+
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		an, aerr := strconv.ParseUint(as[i], 10, 64)
+		bn, berr := strconv.ParseUint(bs[i], 10, 64)
+
+		switch {
+		case aerr == nil && berr == nil:
+			if c := compareUint(an, bn); c != 0 {
+				return c
+			}
+		case aerr == nil:
+			return -1
+		case berr == nil:
+			return 1
+		default:
+			if c := strings.Compare(as[i], bs[i]); c != 0 {
+				return c
+			}
+		}
+	}
+
+	return compareUint(uint64(len(as)), uint64(len(bs)))
+}
@@ -0,0 +1,142 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package semver
+
+import "testing"
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{a: "0.11.0", b: "0.11.0", want: 0},
+		{a: "v0.11", b: "0.11.0", want: 0},
+		{a: "0.11.1", b: "0.11.0", want: 1},
+		{a: "0.10.9", b: "0.11.0", want: -1},
+		{a: "1.0.0", b: "0.99.99", want: 1},
+		{a: "1.0.0-rc.1", b: "1.0.0", want: -1},
+		{a: "1.0.0-rc.2", b: "1.0.0-rc.10", want: -1},
+		{a: "1.0.0-beta", b: "1.0.0-1", want: 1},
+	}
+
+	for _, tt := range tests {
+		a, err := Parse(tt.a)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		b, err := Parse(tt.b)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := a.Compare(b); got != tt.want {
+			t.Errorf("Compare(%s, %s) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, s := range []string{"", "stable", "1.2.3.4", "1.x", "1.2.3-"} {
+		if _, err := Parse(s); err == nil {
+			t.Errorf("Parse(%q): expected error", s)
+		}
+	}
+}
+
+func TestConstraintHighest(t *testing.T) {
+	versions := []string{"0.9.0", "0.10.0", "0.10.4", "0.11.0", "0.11.2", "0.12.0-rc.1", "0.12.0", "1.2.3", "1.2.9", "1.3.0", "staging"}
+
+	tests := []struct {
+		constraint string
+		want       string
+		ok         bool
+	}{
+		{constraint: "^0.11", want: "0.11.2", ok: true},
+		{constraint: "^0.10.1", want: "0.10.4", ok: true},
+		{constraint: "^1.2", want: "1.3.0", ok: true},
+		{constraint: "~1.2.3", want: "1.2.9", ok: true},
+		{constraint: "~1", want: "1.3.0", ok: true},
+		{constraint: ">=0.10 <0.12", want: "0.11.2", ok: true},
+		{constraint: ">= 0.10, < 0.12", want: "0.11.2", ok: true},
+		{constraint: ">0.11", want: "1.3.0", ok: true},
+		{constraint: "<=0.11", want: "0.11.2", ok: true},
+		{constraint: "0.10.x", want: "0.10.4", ok: true},
+		{constraint: "*", want: "1.3.0", ok: true},
+		{constraint: "^0.9 || ^1.2", want: "1.3.0", ok: true},
+		{constraint: ">=0.12.0-rc.0 <0.12.0", want: "0.12.0-rc.1", ok: true},
+		{constraint: "^2", ok: false},
+		{constraint: ">0.12.0 <1.0.0", ok: false},
+	}
+
+	for _, tt := range tests {
+		c, err := ParseConstraint(tt.constraint)
+		if err != nil {
+			t.Fatalf("ParseConstraint(%q): %v", tt.constraint, err)
+		}
+
+		got, ok := c.Highest(versions)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("%q: got %q (%v), want %q (%v)", tt.constraint, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestIsConstraint(t *testing.T) {
+	for _, s := range []string{"^0.11", "~1.2.3", ">=0.10 <0.12", "1.x", "*"} {
+		if !IsConstraint(s) {
+			t.Errorf("IsConstraint(%q): expected constraint", s)
+		}
+	}
+
+	for _, s := range []string{"0.11.0", "stable", "staging", "6a3f1c2"} {
+		if IsConstraint(s) {
+			t.Errorf("IsConstraint(%q): expected exact version", s)
+		}
+	}
+
+	for _, s := range []string{"^", ">=", "^stable", "1.2 ||"} {
+		if _, err := ParseConstraint(s); err == nil {
+			t.Errorf("ParseConstraint(%q): expected error", s)
+		}
+	}
+}
+
+func TestSatisfies(t *testing.T) {
+	if !Satisfies("stable", "stable") || !Satisfies("^0.11", "0.11.3") {
+		t.Errorf("expected requested version to be satisfied")
+	}
+
+	if Satisfies("^0.11", "0.12.0") || Satisfies("stable", "0.11.0") {
+		t.Errorf("expected requested version not to be satisfied")
+	}
+}
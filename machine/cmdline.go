@@ -0,0 +1,119 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package machine
+
+import (
+	"fmt"
+	"strings"
+)
+
+// BootArgs returns the command-line of the kernel in the syntax understood by
+// Unikraft.  Library parameters are listed first, followed by the mounts of
+// the machine's volumes as the `vfs.fstab` library parameter array and the
+// environment variables as the `env.vars` library parameter array.  The
+// arguments of the application are separated from the library parameters by
+// `--`.  Each element of the returned slice is a single argument which has not
+// been quoted, such that it can be passed as-is to a process.
+func (mcfg MachineConfig) BootArgs() []string {
+	var args []string
+
+	args = append(args, mcfg.KernelArgs...)
+
+	if len(mcfg.Volumes) > 0 {
+		args = append(args, "vfs.fstab=[")
+		for i, volume := range mcfg.Volumes {
+			args = append(args, VolumeTag(i)+":"+volume.Destination+":9pfs")
+		}
+		args = append(args, "]")
+	}
+
+	if len(mcfg.Env) > 0 {
+		args = append(args, "env.vars=[")
+		args = append(args, mcfg.Env...)
+		args = append(args, "]")
+	}
+
+	if len(mcfg.Arguments) > 0 {
+		args = append(args, "--")
+		args = append(args, mcfg.Arguments...)
+	}
+
+	return args
+}
+
+// Cmdline returns the command-line of the kernel as a single string, as it is
+// passed to hypervisors, with arguments containing whitespace or quotes
+// quoted such that they are preserved when Unikraft splits the command-line.
+func (mcfg MachineConfig) Cmdline() string {
+	args := mcfg.BootArgs()
+
+	for i, arg := range args {
+		args[i] = quoteArg(arg)
+	}
+
+	return strings.Join(args, " ")
+}
+
+// quoteArg quotes an individual argument of the command-line when necessary.
+// An argument containing both kinds of quotes is double-quoted, with each
+// double quote closing the quoted segment and being emitted single-quoted, as
+// adjacent quoted segments are joined into a single argument.
+func quoteArg(arg string) string {
+	if len(arg) > 0 && !strings.ContainsAny(arg, " \t\n\"'") {
+		return arg
+	}
+
+	if strings.Contains(arg, "\"") {
+		if !strings.Contains(arg, "'") {
+			return "'" + arg + "'"
+		}
+
+		return "\"" + strings.ReplaceAll(arg, "\"", "\"'\"'\"") + "\""
+	}
+
+	return "\"" + arg + "\""
+}
+
+// ParseEnv splits an environment variable of the form KEY=VALUE into its key
+// and value.
+func ParseEnv(env string) (string, string, error) {
+	key, value, ok := strings.Cut(env, "=")
+	if !ok || len(key) == 0 {
+		return "", "", fmt.Errorf("invalid environment variable, expected KEY=VALUE: %s", env)
+	}
+
+	if strings.ContainsAny(key, " \t\n") {
+		return "", "", fmt.Errorf("invalid environment variable name: %s", key)
+	}
+
+	return key, value, nil
+}
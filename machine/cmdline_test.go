@@ -0,0 +1,106 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package machine
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBootArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		mopts   []MachineOption
+		args    []string
+		cmdline string
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name:    "application arguments",
+			mopts:   []MachineOption{WithArguments([]string{"-c", "/etc/app.conf"})},
+			args:    []string{"--", "-c", "/etc/app.conf"},
+			cmdline: "-- -c /etc/app.conf",
+		},
+		{
+			name: "all",
+			mopts: []MachineOption{
+				WithKernelArgs([]string{"vfs.rootdev=fs0", "netdev.ip=172.44.0.2/24"}),
+				WithEnv([]string{"HOME=/", "GREETING=hello world", "HOME=/root"}),
+				WithArguments([]string{"say", `"hi"`}),
+			},
+			args: []string{
+				"vfs.rootdev=fs0", "netdev.ip=172.44.0.2/24",
+				"env.vars=[", "HOME=/root", "GREETING=hello world", "]",
+				"--", "say", `"hi"`,
+			},
+			cmdline: `vfs.rootdev=fs0 netdev.ip=172.44.0.2/24 env.vars=[ HOME=/root "GREETING=hello world" ] -- say '"hi"'`,
+		},
+		{
+			name:    "mixed quotes",
+			mopts:   []MachineOption{WithArguments([]string{`it's "quoted"`, `'`, ""})},
+			args:    []string{"--", `it's "quoted"`, `'`, ""},
+			cmdline: `-- "it's "'"'"quoted"'"'"" "'" ""`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mcfg, err := NewMachineConfig(tt.mopts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if args := mcfg.BootArgs(); !reflect.DeepEqual(args, tt.args) {
+				t.Errorf("expected boot arguments %q, got %q", tt.args, args)
+			}
+
+			if cmdline := mcfg.Cmdline(); cmdline != tt.cmdline {
+				t.Errorf("expected command-line %q, got %q", tt.cmdline, cmdline)
+			}
+		})
+	}
+}
+
+func TestInvalidBootArgs(t *testing.T) {
+	for _, mopt := range []MachineOption{
+		WithKernelArgs([]string{"rootdev=fs0"}),
+		WithKernelArgs([]string{"vfs.rootdev"}),
+		WithEnv([]string{"HOME"}),
+		WithEnv([]string{"=/"}),
+	} {
+		if _, err := NewMachineConfig(mopt); err == nil {
+			t.Errorf("expected invalid option to be rejected")
+		}
+	}
+}
@@ -34,7 +34,11 @@ package machine
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 	"time"
+
+	"kraftkit.sh/unikraft/plat"
 )
 
 // MachineConfig describes an individual virtual machine
@@ -54,6 +58,11 @@ type MachineConfig struct {
 	// Platform of the machine, e.g.: kvm, xen.
 	Platform string `json:"platform"`
 
+	// MachineType is the type of virtual machine emulated by the driver, e.g.:
+	// pc, microvm.  When unset, the driver's default for the architecture is
+	// used.
+	MachineType string `json:"machine_type,omitempty"`
+
 	// Driver represents the hypervisor once the machine has entered into an
 	// instantiated lifecycle.
 	DriverName string `json:"driver,omitempty"`
@@ -64,7 +73,19 @@ type MachineConfig struct {
 	// KernelPath is the guest kernel host path.
 	KernelPath string `json:"kernel_path,omitempty"`
 
-	// Arguments are the list of arguments to pass to the kernel
+	// KernelDbgPath is the host path of the unstripped guest kernel which is
+	// used to symbolize crash output.
+	KernelDbgPath string `json:"kernel_dbg_path,omitempty"`
+
+	// KernelArgs are the library parameters passed to the kernel, e.g.:
+	// vfs.rootdev=fs0.
+	KernelArgs []string `json:"kernel_args,omitempty"`
+
+	// Env is the list of environment variables of the application in the form
+	// KEY=VALUE.
+	Env []string `json:"env,omitempty"`
+
+	// Arguments are the list of arguments passed to the application
 	Arguments []string `json:"arguments,omitempty"`
 
 	// InitrdPath is the guest initrd image host path.
@@ -81,6 +102,12 @@ type MachineConfig struct {
 	// MemorySize specifies default memory size in MiB for the VM.
 	MemorySize uint64 `json:"mem_size,omitempty"`
 
+	// Ports are the ports of the host which are forwarded to the guest.
+	Ports []MachinePort `json:"ports,omitempty"`
+
+	// Volumes are the directories of the host which are shared with the guest.
+	Volumes []MachineVolume `json:"volumes,omitempty"`
+
 	// DestroyOnExit indicates whether the machine should be destroyed once it
 	// exists
 	DestroyOnExit bool
@@ -94,6 +121,10 @@ type MachineConfig struct {
 
 	// ExitStatus represents the error code returned after a machine exits
 	ExitStatus int `json:"exit_status"`
+
+	// CrashDumpPath is the host path of the guest memory dump which was captured
+	// when the machine last crashed.
+	CrashDumpPath string `json:"crash_dump_path,omitempty"`
 }
 
 type MachineOption func(mo *MachineConfig) error
@@ -139,14 +170,28 @@ func WithDescription(description string) MachineOption {
 
 func WithArchitecture(arch string) MachineOption {
 	return func(mo *MachineConfig) error {
-		mo.Architecture = arch
+		mo.Architecture = ArchitectureName(arch)
+		return nil
+	}
+}
+
+// WithPlatform sets the platform of the kernel by its canonical name, such that
+// aliases like `qemu` or `linux` are treated the same as `kvm` or `linuxu`.
+func WithPlatform(platform string) MachineOption {
+	return func(mo *MachineConfig) error {
+		name, err := plat.PlatformByName(platform)
+		if err != nil {
+			return err
+		}
+
+		mo.Platform = name
 		return nil
 	}
 }
 
-func WithPlatform(plat string) MachineOption {
+func WithMachineType(machineType string) MachineOption {
 	return func(mo *MachineConfig) error {
-		mo.Platform = plat
+		mo.MachineType = machineType
 		return nil
 	}
 }
@@ -179,6 +224,16 @@ func WithKernel(kernel string) MachineOption {
 	}
 }
 
+// WithKernelDbg sets the path of the unstripped kernel which is used to
+// symbolize crash output.  Unlike WithKernel, the kernel is not required to
+// exist.
+func WithKernelDbg(kernelDbg string) MachineOption {
+	return func(mo *MachineConfig) error {
+		mo.KernelDbgPath = kernelDbg
+		return nil
+	}
+}
+
 func WithArguments(arguments []string) MachineOption {
 	return func(mo *MachineConfig) error {
 		mo.Arguments = arguments
@@ -186,6 +241,47 @@ func WithArguments(arguments []string) MachineOption {
 	}
 }
 
+// WithKernelArgs sets the library parameters passed to the kernel.  Each
+// parameter must be of the form LIBRARY.PARAMETER=VALUE.
+func WithKernelArgs(kernelArgs []string) MachineOption {
+	return func(mo *MachineConfig) error {
+		for _, arg := range kernelArgs {
+			key, _, ok := strings.Cut(arg, "=")
+			if !ok || !strings.Contains(key, ".") {
+				return fmt.Errorf("invalid kernel argument, expected LIBRARY.PARAMETER=VALUE: %s", arg)
+			}
+		}
+
+		mo.KernelArgs = kernelArgs
+		return nil
+	}
+}
+
+// WithEnv adds environment variables of the form KEY=VALUE to the
+// application.  A variable which has previously been set is overridden.
+func WithEnv(env []string) MachineOption {
+	return func(mo *MachineConfig) error {
+	next:
+		for _, e := range env {
+			key, _, err := ParseEnv(e)
+			if err != nil {
+				return err
+			}
+
+			for i, existing := range mo.Env {
+				if strings.HasPrefix(existing, key+"=") {
+					mo.Env[i] = e
+					continue next
+				}
+			}
+
+			mo.Env = append(mo.Env, e)
+		}
+
+		return nil
+	}
+}
+
 func WithInitRd(initrd string) MachineOption {
 	return func(mo *MachineConfig) error {
 		mo.InitrdPath = initrd
@@ -214,6 +310,38 @@ func WithMemorySize(memorySize uint64) MachineOption {
 	}
 }
 
+func WithPorts(ports []MachinePort) MachineOption {
+	return func(mo *MachineConfig) error {
+		mo.Ports = ports
+		return nil
+	}
+}
+
+// WithVolumes sets the directories of the host which are shared with the
+// guest.  Each source must be an existing directory.
+func WithVolumes(volumes []MachineVolume) MachineOption {
+	return func(mo *MachineConfig) error {
+		for i, volume := range volumes {
+			source, err := filepath.Abs(volume.Source)
+			if err != nil {
+				return fmt.Errorf("could not resolve volume source: %v", err)
+			}
+
+			f, err := os.Stat(source)
+			if err != nil {
+				return fmt.Errorf("could not access volume: %v", err)
+			} else if !f.IsDir() {
+				return fmt.Errorf("volume source is not a directory: %s", volume.Source)
+			}
+
+			volumes[i].Source = source
+		}
+
+		mo.Volumes = volumes
+		return nil
+	}
+}
+
 func WithDestroyOnExit(destroyOnExit bool) MachineOption {
 	return func(mo *MachineConfig) error {
 		mo.DestroyOnExit = destroyOnExit
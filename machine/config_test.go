@@ -0,0 +1,66 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package machine
+
+import "testing"
+
+func TestWithPlatform(t *testing.T) {
+	tests := []struct {
+		plat     string
+		expected string
+	}{
+		{plat: "kvm", expected: "kvm"},
+		{plat: "qemu", expected: "kvm"},
+		{plat: "firecracker", expected: "kvm"},
+		{plat: "fc", expected: "kvm"},
+		{plat: "linux", expected: "linuxu"},
+		{plat: "linuxu", expected: "linuxu"},
+		{plat: "Xen", expected: "xen"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.plat, func(t *testing.T) {
+			mcfg, err := NewMachineConfig(WithPlatform(tt.plat))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if mcfg.Platform != tt.expected {
+				t.Errorf("expected platform %s, got %s", tt.expected, mcfg.Platform)
+			}
+		})
+	}
+
+	if _, err := NewMachineConfig(WithPlatform("bogus")); err == nil {
+		t.Errorf("expected unknown platform to be rejected")
+	}
+}
@@ -39,6 +39,8 @@ import (
 
 	"kraftkit.sh/machine"
 	"kraftkit.sh/machine/driveropts"
+	"kraftkit.sh/machine/firecracker"
+	"kraftkit.sh/machine/process"
 	"kraftkit.sh/machine/qemu"
 	"kraftkit.sh/utils"
 )
@@ -51,6 +53,12 @@ const (
 
 	// QemuDriver is the QEMU hypervisor
 	QemuDriver = DriverType("qemu")
+
+	// FirecrackerDriver is the Firecracker microVM monitor
+	FirecrackerDriver = DriverType("firecracker")
+
+	// ProcessDriver runs linuxu kernels as processes on the host
+	ProcessDriver = DriverType("process")
 )
 
 func (dt DriverType) String() string {
@@ -61,6 +69,8 @@ func (dt DriverType) String() string {
 func DriverNames() []string {
 	return []string{
 		string(QemuDriver),
+		string(FirecrackerDriver),
+		string(ProcessDriver),
 	}
 }
 
@@ -78,7 +88,9 @@ type Driver interface {
 	// Create a machine using this driver with the defined `MachineOption`s.
 	Create(context.Context, ...machine.MachineOption) (machine.MachineID, error)
 
-	// Start requests the machine to begin its execution if paused.
+	// Start requests the machine to begin its execution if paused.  A machine
+	// which has exited is launched again from its persisted configuration,
+	// retaining its MachineID.
 	Start(context.Context, machine.MachineID) error
 
 	// Stop requests the machine to stop its execution if running.
@@ -125,6 +137,10 @@ func New(driverType DriverType, opts ...driveropts.DriverOption) (driver Driver,
 	switch driverType {
 	case QemuDriver:
 		driver, err = qemu.NewQemuDriver(opts...)
+	case FirecrackerDriver:
+		driver, err = firecracker.NewFirecrackerDriver(opts...)
+	case ProcessDriver:
+		driver, err = process.NewProcessDriver(opts...)
 	default:
 		return nil, fmt.Errorf("unknown machine driver: %s", driverType.String())
 	}
@@ -0,0 +1,110 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package driver
+
+import (
+	"fmt"
+
+	"kraftkit.sh/machine"
+	"kraftkit.sh/machine/driveropts"
+)
+
+// LookupMachines resolves each of the provided full or short machine IDs
+// against the `store`.
+func LookupMachines(store machine.MachineStore, ids ...string) ([]machine.MachineID, error) {
+	allMids, err := store.ListAllMachineIDs()
+	if err != nil {
+		return nil, fmt.Errorf("could not list machines: %v", err)
+	}
+
+	var mids []machine.MachineID
+
+	for _, mid1 := range ids {
+		found := false
+		for _, mid2 := range allMids {
+			if mid1 == mid2.ShortString() || mid1 == mid2.String() {
+				mids = append(mids, mid2)
+				found = true
+			}
+		}
+
+		if !found {
+			return nil, fmt.Errorf("could not find machine %s", mid1)
+		}
+	}
+
+	return mids, nil
+}
+
+// Drivers instantiates machine drivers on demand such that each driver type is
+// only instantiated once.
+type Drivers struct {
+	opts    []driveropts.DriverOption
+	drivers map[DriverType]Driver
+}
+
+// NewDrivers prepares a set of drivers which are instantiated with `opts`.
+func NewDrivers(opts ...driveropts.DriverOption) *Drivers {
+	return &Drivers{
+		opts:    opts,
+		drivers: make(map[DriverType]Driver),
+	}
+}
+
+// ForType returns the driver of the type `driverType`.
+func (d *Drivers) ForType(driverType DriverType) (Driver, error) {
+	if _, ok := d.drivers[driverType]; !ok {
+		driver, err := New(driverType, d.opts...)
+		if err != nil {
+			return nil, err
+		}
+
+		d.drivers[driverType] = driver
+	}
+
+	return d.drivers[driverType], nil
+}
+
+// ForMachine returns the driver which manages the machine `mid`.
+func (d *Drivers) ForMachine(store machine.MachineStore, mid machine.MachineID) (Driver, error) {
+	mcfg := &machine.MachineConfig{}
+	if err := store.LookupMachineConfig(mid, mcfg); err != nil {
+		return nil, fmt.Errorf("could not look up machine config: %v", err)
+	}
+
+	driver, err := d.ForType(DriverTypeFromName(mcfg.DriverName))
+	if err != nil {
+		return nil, fmt.Errorf("could not instantiate machine driver for %s: %v", mid.ShortString(), err)
+	}
+
+	return driver, nil
+}
@@ -0,0 +1,72 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package driver
+
+import (
+	"testing"
+
+	"kraftkit.sh/machine"
+)
+
+func TestLookupMachines(t *testing.T) {
+	store, err := machine.NewMachineStoreFromPath(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mids []machine.MachineID
+	for i := 0; i < 2; i++ {
+		mid, err := machine.NewRandomMachineID()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := store.SaveMachineConfig(mid, machine.MachineConfig{ID: mid}); err != nil {
+			t.Fatal(err)
+		}
+
+		mids = append(mids, mid)
+	}
+
+	found, err := LookupMachines(store, mids[1].ShortString(), mids[0].String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(found) != 2 || found[0] != mids[1] || found[1] != mids[0] {
+		t.Errorf("expected %v, got %v", []machine.MachineID{mids[1], mids[0]}, found)
+	}
+
+	if _, err := LookupMachines(store, mids[0].ShortString(), "ffffffffffff"); err == nil {
+		t.Errorf("expected unknown machine to fail")
+	}
+}
@@ -0,0 +1,94 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"kraftkit.sh/machine"
+	"kraftkit.sh/machine/driveropts"
+)
+
+// Reconcile checks every machine in the `store` against its driver, which
+// compares the recorded state with the liveness of the machine's VMM and
+// updates the store accordingly.  The reconciled state of each machine is
+// returned, indexed by its ID.  Machines with an unknown driver are reported
+// in the state `machine.MachineStateUnknown`.  Failing to reconcile one machine
+// does not prevent the others from being reconciled; the returned error then
+// describes all failures and the machines which failed are left out of the
+// returned states, since their actual state is not known.
+func Reconcile(ctx context.Context, store machine.MachineStore, opts ...driveropts.DriverOption) (map[machine.MachineID]machine.MachineState, error) {
+	mcfgs, err := store.ListAllMachineConfigs()
+	if err != nil {
+		return nil, fmt.Errorf("could not list machines: %v", err)
+	}
+
+	states := make(map[machine.MachineID]machine.MachineState, len(mcfgs))
+
+	var errs []error
+
+	drivers := NewDrivers(append(opts, driveropts.WithMachineStore(store))...)
+
+	for mid, mcfg := range mcfgs {
+		driverType := DriverTypeFromName(mcfg.DriverName)
+		if driverType == UnknownDriver {
+			states[mid] = machine.MachineStateUnknown
+			continue
+		}
+
+		driver, err := drivers.ForType(driverType)
+		if err != nil {
+			return nil, fmt.Errorf("could not instantiate machine driver for %s: %v", mid.ShortString(), err)
+		}
+
+		state, err := driver.State(ctx, mid)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", mid.ShortString(), err))
+			continue
+		}
+
+		states[mid] = state
+	}
+
+	if len(errs) > 0 {
+		msg := "could not reconcile machine"
+		for _, err := range errs {
+			msg += ": " + err.Error()
+		}
+
+		return states, errors.New(msg)
+	}
+
+	return states, nil
+}
@@ -0,0 +1,229 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package driver
+
+import (
+	"fmt"
+	"os"
+	osexec "os/exec"
+	"runtime"
+	"strings"
+
+	"kraftkit.sh/machine"
+	"kraftkit.sh/machine/firecracker"
+	"kraftkit.sh/machine/process"
+	"kraftkit.sh/machine/qemu"
+	"kraftkit.sh/unikraft/plat"
+)
+
+// Compatibility records a platform and the architectures of the kernels built
+// for it which a driver is able to boot.
+type Compatibility struct {
+	Platform      string
+	Architectures []string
+}
+
+// driverRegistration describes what a driver can boot and whether it can be
+// used on the host.
+type driverRegistration struct {
+	driver    DriverType
+	compat    []Compatibility
+	available func(arch string) bool
+}
+
+// registry lists the drivers in the order of preference in which they are
+// selected when booting a kernel.
+var registry = []driverRegistration{
+	{
+		driver: QemuDriver,
+		compat: []Compatibility{
+			{Platform: plat.PlatformKVM, Architectures: []string{"x86_64", "arm"}},
+		},
+		available: func(arch string) bool {
+			bin, err := qemu.QemuSystemBinary(arch)
+			if err != nil {
+				return false
+			}
+
+			_, err = osexec.LookPath(bin)
+			return err == nil
+		},
+	},
+	{
+		driver: FirecrackerDriver,
+		compat: []Compatibility{
+			{Platform: plat.PlatformKVM, Architectures: []string{"x86_64", "arm64"}},
+		},
+		available: func(arch string) bool {
+			if _, err := os.Stat(KvmPath); err != nil {
+				return false
+			}
+
+			_, err := osexec.LookPath(firecracker.FirecrackerBin)
+			return err == nil
+		},
+	},
+	{
+		driver: ProcessDriver,
+		compat: []Compatibility{
+			{Platform: plat.PlatformLinuxu, Architectures: []string{"x86_64", "arm", "arm64"}},
+		},
+		available: func(arch string) bool {
+			return runtime.GOOS == "linux" && arch == process.HostArchitecture()
+		},
+	},
+}
+
+// Compatibilities returns the platform and architecture combinations which the
+// driver is able to boot.
+func Compatibilities(driverType DriverType) []Compatibility {
+	for _, reg := range registry {
+		if reg.driver == driverType {
+			return reg.compat
+		}
+	}
+
+	return nil
+}
+
+// Supports returns whether the driver is able to boot kernels which have been
+// built for the platform and architecture.
+func Supports(driverType DriverType, platform, arch string) bool {
+	platform, err := plat.PlatformByName(platform)
+	if err != nil {
+		return false
+	}
+
+	arch = machine.ArchitectureName(arch)
+
+	for _, compat := range Compatibilities(driverType) {
+		if compat.Platform != platform {
+			continue
+		}
+
+		for _, a := range compat.Architectures {
+			if a == arch {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// CompatibleDrivers returns the drivers which are able to boot kernels built
+// for the platform and architecture in order of preference.
+func CompatibleDrivers(platform, arch string) []DriverType {
+	var drivers []DriverType
+
+	for _, reg := range registry {
+		if Supports(reg.driver, platform, arch) {
+			drivers = append(drivers, reg.driver)
+		}
+	}
+
+	return drivers
+}
+
+func driverNames(drivers []DriverType) string {
+	names := make([]string, len(drivers))
+	for i, d := range drivers {
+		names[i] = d.String()
+	}
+
+	return strings.Join(names, ", ")
+}
+
+// validatePlatformArchitecture checks that the combination of platform and
+// architecture exists at all and returns their canonical names.
+func validatePlatformArchitecture(platform, arch string) (string, string, error) {
+	canonical, err := plat.PlatformByName(platform)
+	if err != nil {
+		return "", "", err
+	}
+
+	archs, err := plat.PlatformArchitectures(canonical)
+	if err != nil {
+		return "", "", err
+	}
+
+	arch = machine.ArchitectureName(arch)
+	for _, a := range archs {
+		if a == arch {
+			return canonical, arch, nil
+		}
+	}
+
+	return "", "", fmt.Errorf("the %s platform does not support the %s architecture (expected one of: %s)", canonical, arch, strings.Join(archs, ", "))
+}
+
+// ValidateCompatibility returns an error describing why the driver cannot boot
+// kernels which have been built for the platform and architecture.
+func ValidateCompatibility(driverType DriverType, platform, arch string) error {
+	canonical, arch, err := validatePlatformArchitecture(platform, arch)
+	if err != nil {
+		return err
+	}
+
+	if Supports(driverType, canonical, arch) {
+		return nil
+	}
+
+	if drivers := CompatibleDrivers(canonical, arch); len(drivers) > 0 {
+		return fmt.Errorf("the %s driver cannot boot %s/%s kernels, use one of: %s", driverType, canonical, arch, driverNames(drivers))
+	}
+
+	return fmt.Errorf("the %s driver cannot boot %s/%s kernels and no other driver supports them", driverType, canonical, arch)
+}
+
+// SelectDriver returns the most preferred driver which is able to boot
+// kernels built for the platform and architecture and which is available on
+// the host.
+func SelectDriver(platform, arch string) (DriverType, error) {
+	canonical, arch, err := validatePlatformArchitecture(platform, arch)
+	if err != nil {
+		return UnknownDriver, err
+	}
+
+	drivers := CompatibleDrivers(canonical, arch)
+	if len(drivers) == 0 {
+		return UnknownDriver, fmt.Errorf("no driver is able to boot %s/%s kernels", canonical, arch)
+	}
+
+	for _, reg := range registry {
+		if Supports(reg.driver, canonical, arch) && reg.available(arch) {
+			return reg.driver, nil
+		}
+	}
+
+	return UnknownDriver, fmt.Errorf("no driver able to boot %s/%s kernels is available on this host, install one of: %s", canonical, arch, driverNames(drivers))
+}
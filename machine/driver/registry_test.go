@@ -0,0 +1,96 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package driver
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCompatibleDrivers(t *testing.T) {
+	tests := []struct {
+		platform string
+		arch     string
+		expected []DriverType
+	}{
+		{"kvm", "x86_64", []DriverType{QemuDriver, FirecrackerDriver}},
+		{"qemu", "x86_64", []DriverType{QemuDriver, FirecrackerDriver}},
+		{"kvm", "arm64", []DriverType{FirecrackerDriver}},
+		{"kvm", "amd64", []DriverType{QemuDriver, FirecrackerDriver}},
+		{"kvm", "aarch64", []DriverType{FirecrackerDriver}},
+		{"linuxu", "amd64", []DriverType{ProcessDriver}},
+		{"linuxu", "x86_64", []DriverType{ProcessDriver}},
+		{"xen", "x86_64", nil},
+		{"unknown", "x86_64", nil},
+	}
+
+	for _, test := range tests {
+		drivers := CompatibleDrivers(test.platform, test.arch)
+		if !reflect.DeepEqual(drivers, test.expected) {
+			t.Errorf("%s/%s: expected %v, got %v", test.platform, test.arch, test.expected, drivers)
+		}
+	}
+}
+
+func TestValidateCompatibility(t *testing.T) {
+	tests := []struct {
+		driver   DriverType
+		platform string
+		arch     string
+		err      string
+	}{
+		{QemuDriver, "kvm", "x86_64", ""},
+		{QemuDriver, "qemu", "amd64", ""},
+		{QemuDriver, "kvm", "aarch64", "the qemu driver cannot boot kvm/arm64 kernels, use one of: firecracker"},
+		{ProcessDriver, "linuxu", "arm64", ""},
+		{QemuDriver, "linuxu", "x86_64", "the qemu driver cannot boot linuxu/x86_64 kernels, use one of: process"},
+		{QemuDriver, "xen", "x86_64", "no other driver supports them"},
+		{FirecrackerDriver, "kvm", "riscv64", "the kvm platform does not support the riscv64 architecture"},
+		{QemuDriver, "solo5", "x86_64", "unknown platform: solo5"},
+	}
+
+	for _, test := range tests {
+		err := ValidateCompatibility(test.driver, test.platform, test.arch)
+		if len(test.err) == 0 && err != nil {
+			t.Errorf("%s %s/%s: unexpected error: %v", test.driver, test.platform, test.arch, err)
+		} else if len(test.err) > 0 && (err == nil || !strings.Contains(err.Error(), test.err)) {
+			t.Errorf("%s %s/%s: expected error containing %q, got %v", test.driver, test.platform, test.arch, test.err, err)
+		}
+	}
+}
+
+func TestSelectDriverWithoutCompatibleDriver(t *testing.T) {
+	if _, err := SelectDriver("xen", "x86_64"); err == nil {
+		t.Errorf("expected selection for xen to fail")
+	}
+}
@@ -46,7 +46,7 @@ type DriverOptions struct {
 	Debug       bool
 	RuntimeDir  string
 	Background  bool
-	Store       *machine.MachineStore
+	Store       machine.MachineStore
 }
 
 type DriverOption func(do *DriverOptions) error
@@ -131,7 +131,7 @@ func WithBackground(background bool) DriverOption {
 }
 
 // WithMachineStore passes in an already instantiated `machine.MachineStore`
-func WithMachineStore(store *machine.MachineStore) DriverOption {
+func WithMachineStore(store machine.MachineStore) DriverOption {
 	return func(do *DriverOptions) error {
 		do.Store = store
 		return nil
@@ -0,0 +1,123 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package machine
+
+import (
+	"time"
+)
+
+// MachineEventType represents the lifecycle transition which was recorded for
+// a machine.
+type MachineEventType string
+
+func (met MachineEventType) String() string {
+	return string(met)
+}
+
+const (
+	// The machine was created by its driver
+	MachineEventCreate = MachineEventType("create")
+	// The machine was requested to start its execution
+	MachineEventStart = MachineEventType("start")
+	// The machine was requested to pause its execution
+	MachineEventPause = MachineEventType("pause")
+	// The machine was requested to stop its execution
+	MachineEventStop = MachineEventType("stop")
+	// The machine has exited, see the exit status of the event
+	MachineEventExit = MachineEventType("exit")
+	// The guest has panicked and a crash dump was captured
+	MachineEventCrash = MachineEventType("crash")
+	// The machine and all its references were removed
+	MachineEventDestroy = MachineEventType("destroy")
+	// The state of the machine changed without being requested to, e.g. the
+	// VMM was found to have disappeared
+	MachineEventHealth = MachineEventType("health")
+)
+
+// MachineEventTypes returns the list of all known machine event types.
+func MachineEventTypes() []string {
+	return []string{
+		MachineEventCreate.String(),
+		MachineEventStart.String(),
+		MachineEventPause.String(),
+		MachineEventStop.String(),
+		MachineEventExit.String(),
+		MachineEventCrash.String(),
+		MachineEventDestroy.String(),
+		MachineEventHealth.String(),
+	}
+}
+
+// MachineEvent is a single entry in the lifecycle history of a machine.
+type MachineEvent struct {
+	// Time represents when the event occurred.
+	Time time.Time `json:"time"`
+
+	// Type of the event.
+	Type MachineEventType `json:"type"`
+
+	// ID is the UUID of the guest the event relates to.
+	ID MachineID `json:"id"`
+
+	// Name is the name of the guest at the time of the event.
+	Name MachineName `json:"name,omitempty"`
+
+	// Driver is the name of the driver managing the guest.
+	Driver string `json:"driver,omitempty"`
+
+	// State is the state of the machine after the event occurred.
+	State MachineState `json:"state,omitempty"`
+
+	// ExitStatus is the exit code of the machine for exit and crash events and
+	// -1 otherwise.
+	ExitStatus int `json:"exit_status"`
+}
+
+// NewMachineEvent prepares a MachineEvent of type `met` based on the current
+// configuration of a machine.
+func NewMachineEvent(met MachineEventType, mcfg MachineConfig, state MachineState) MachineEvent {
+	event := MachineEvent{
+		Time:       time.Now(),
+		Type:       met,
+		ID:         mcfg.ID,
+		Name:       mcfg.Name,
+		Driver:     mcfg.DriverName,
+		State:      state,
+		ExitStatus: -1,
+	}
+
+	if met == MachineEventExit || met == MachineEventCrash {
+		event.ExitStatus = mcfg.ExitStatus
+	}
+
+	return event
+}
@@ -0,0 +1,147 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package firecracker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+)
+
+// FirecrackerClient speaks to the HTTP API which Firecracker exposes on its
+// UNIX socket.
+type FirecrackerClient struct {
+	client *http.Client
+}
+
+// NewFirecrackerClient returns a client for the API served on `socket`.
+func NewFirecrackerClient(socket string) *FirecrackerClient {
+	return &FirecrackerClient{
+		client: &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var dialer net.Dialer
+					return dialer.DialContext(ctx, "unix", socket)
+				},
+			},
+		},
+	}
+}
+
+// do performs a request against the API and decodes the response into `out`
+// if it is non-nil.
+func (fc *FirecrackerClient) do(ctx context.Context, method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("could not encode request: %v", err)
+		}
+		body = bytes.NewReader(b)
+	}
+
+	// The host is ignored as the connection is always made to the socket
+	req, err := http.NewRequestWithContext(ctx, method, "http://localhost"+path, body)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := fc.client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var ferr FirecrackerError
+		if err := json.NewDecoder(resp.Body).Decode(&ferr); err == nil && len(ferr.FaultMessage) > 0 {
+			return fmt.Errorf("%s %s: %s", method, path, ferr.FaultMessage)
+		}
+
+		return fmt.Errorf("%s %s: unexpected status: %s", method, path, resp.Status)
+	}
+
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("could not decode response: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// DescribeInstance returns general information about the microVM.
+func (fc *FirecrackerClient) DescribeInstance(ctx context.Context) (*FirecrackerInstanceInfo, error) {
+	info := &FirecrackerInstanceInfo{}
+	if err := fc.do(ctx, http.MethodGet, "/", nil, info); err != nil {
+		return nil, err
+	}
+
+	return info, nil
+}
+
+// PutBootSource configures the kernel, initrd and boot arguments of the
+// microVM.  This is only possible before the instance is started.
+func (fc *FirecrackerClient) PutBootSource(ctx context.Context, source FirecrackerBootSource) error {
+	return fc.do(ctx, http.MethodPut, "/boot-source", source, nil)
+}
+
+// PutMachineConfig configures the vCPUs and memory of the microVM.  This is
+// only possible before the instance is started.
+func (fc *FirecrackerClient) PutMachineConfig(ctx context.Context, config FirecrackerMachineConfig) error {
+	return fc.do(ctx, http.MethodPut, "/machine-config", config, nil)
+}
+
+// CreateSyncAction requests the VMM to perform a synchronous action.
+func (fc *FirecrackerClient) CreateSyncAction(ctx context.Context, action FirecrackerActionType) error {
+	return fc.do(ctx, http.MethodPut, "/actions", FirecrackerAction{ActionType: action}, nil)
+}
+
+// PatchVM pauses or resumes the microVM.
+func (fc *FirecrackerClient) PatchVM(ctx context.Context, state FirecrackerVMState) error {
+	return fc.do(ctx, http.MethodPatch, "/vm", FirecrackerVM{State: state}, nil)
+}
+
+// Close releases idle connections to the socket.
+func (fc *FirecrackerClient) Close() {
+	fc.client.CloseIdleConnections()
+}
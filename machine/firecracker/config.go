@@ -0,0 +1,123 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package firecracker
+
+const (
+	FirecrackerBin = "firecracker"
+)
+
+// FirecrackerConfig is the persisted configuration of a Firecracker microVM.
+// Command-line arguments are passed to the VMM whilst the remaining resources
+// are configured through its API socket before the instance is started.
+type FirecrackerConfig struct {
+	// Command-line arguments for firecracker
+	APISock string `flag:"--api-sock" json:"api_sock,omitempty"`
+	ID      string `flag:"--id"       json:"id,omitempty"`
+
+	// PidFile contains the PID of the VMM.  Firecracker does not create one
+	// itself, so the driver writes it once the process has been spawned.
+	PidFile string `json:"pidfile,omitempty"`
+
+	// LogFile captures the serial console of the guest alongside the output of
+	// the VMM.
+	LogFile string `json:"logfile,omitempty"`
+
+	// Resources configured via the Firecracker API
+	BootSource    FirecrackerBootSource    `json:"boot_source"`
+	MachineConfig FirecrackerMachineConfig `json:"machine_config"`
+}
+
+// FirecrackerBootSource is the body of the `PUT /boot-source` endpoint.
+type FirecrackerBootSource struct {
+	KernelImagePath string `json:"kernel_image_path"`
+	InitrdPath      string `json:"initrd_path,omitempty"`
+	BootArgs        string `json:"boot_args,omitempty"`
+}
+
+// FirecrackerMachineConfig is the body of the `PUT /machine-config` endpoint.
+type FirecrackerMachineConfig struct {
+	VcpuCount  uint64 `json:"vcpu_count"`
+	MemSizeMib uint64 `json:"mem_size_mib"`
+	Smt        bool   `json:"smt"`
+}
+
+// FirecrackerActionType is an action which can be requested through the
+// `PUT /actions` endpoint.
+type FirecrackerActionType string
+
+const (
+	FirecrackerActionInstanceStart  = FirecrackerActionType("InstanceStart")
+	FirecrackerActionSendCtrlAltDel = FirecrackerActionType("SendCtrlAltDel")
+	FirecrackerActionFlushMetrics   = FirecrackerActionType("FlushMetrics")
+)
+
+// FirecrackerAction is the body of the `PUT /actions` endpoint.
+type FirecrackerAction struct {
+	ActionType FirecrackerActionType `json:"action_type"`
+}
+
+// FirecrackerVMState is the state of the microVM which can be requested
+// through the `PATCH /vm` endpoint.
+type FirecrackerVMState string
+
+const (
+	FirecrackerVMStatePaused  = FirecrackerVMState("Paused")
+	FirecrackerVMStateResumed = FirecrackerVMState("Resumed")
+)
+
+// FirecrackerVM is the body of the `PATCH /vm` endpoint.
+type FirecrackerVM struct {
+	State FirecrackerVMState `json:"state"`
+}
+
+// FirecrackerInstanceState is the state of the microVM as reported by the
+// `GET /` endpoint.
+type FirecrackerInstanceState string
+
+const (
+	FirecrackerInstanceStateNotStarted = FirecrackerInstanceState("Not started")
+	FirecrackerInstanceStateRunning    = FirecrackerInstanceState("Running")
+	FirecrackerInstanceStatePaused     = FirecrackerInstanceState("Paused")
+)
+
+// FirecrackerInstanceInfo is the response of the `GET /` endpoint.
+type FirecrackerInstanceInfo struct {
+	ID         string                   `json:"id"`
+	State      FirecrackerInstanceState `json:"state"`
+	VMMVersion string                   `json:"vmm_version"`
+	AppName    string                   `json:"app_name"`
+}
+
+// FirecrackerError is the body of an unsuccessful response from the API.
+type FirecrackerError struct {
+	FaultMessage string `json:"fault_message"`
+}
@@ -0,0 +1,676 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package firecracker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	goprocess "github.com/shirou/gopsutil/v3/process"
+
+	"kraftkit.sh/exec"
+	"kraftkit.sh/internal/retrytimeout"
+	"kraftkit.sh/machine"
+	"kraftkit.sh/machine/driveropts"
+)
+
+const (
+	// DefaultNumVCPUs is used when the machine does not specify its vCPUs.
+	DefaultNumVCPUs = 1
+
+	// DefaultMemorySize in MiB is used when the machine does not specify its
+	// memory.
+	DefaultMemorySize = 64
+
+	// statusPollInterval is the period at which the state of the microVM is
+	// checked as Firecracker does not offer an event stream.
+	statusPollInterval = 250 * time.Millisecond
+)
+
+// exitCodePattern matches the line which Firecracker logs when the VMM exits,
+// e.g. once the guest has shut down.
+var exitCodePattern = regexp.MustCompile(`exit_code=(\d+)`)
+
+type FirecrackerDriver struct {
+	dopts  *driveropts.DriverOptions
+	binary string
+}
+
+func NewFirecrackerDriver(opts ...driveropts.DriverOption) (*FirecrackerDriver, error) {
+	dopts, err := driveropts.NewDriverOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	if dopts.Store == nil {
+		return nil, fmt.Errorf("cannot instantiate Firecracker driver without machine store")
+	}
+
+	driver := FirecrackerDriver{
+		dopts:  dopts,
+		binary: FirecrackerBin,
+	}
+
+	return &driver, nil
+}
+
+func (fd *FirecrackerDriver) Create(ctx context.Context, opts ...machine.MachineOption) (machine.MachineID, error) {
+	mcfg, err := machine.NewMachineConfig(opts...)
+	if err != nil {
+		return machine.NullMachineID, fmt.Errorf("could build machine config: %v", err)
+	}
+
+	switch mcfg.Architecture {
+	case "x86_64", "arm64":
+	default:
+		return machine.NullMachineID, fmt.Errorf("unsupported architecture: %s", mcfg.Architecture)
+	}
+
+	if len(mcfg.Ports) > 0 {
+		return machine.NullMachineID, fmt.Errorf("port forwarding is not supported by the firecracker driver")
+	}
+
+	if len(mcfg.Volumes) > 0 {
+		return machine.NullMachineID, fmt.Errorf("volumes are not supported by the firecracker driver")
+	}
+
+	mid, err := machine.NewRandomMachineID()
+	if err != nil {
+		return machine.NullMachineID, fmt.Errorf("could not generate new machine ID: %v", err)
+	}
+
+	mcfg.ID = mid
+
+	vcpus := mcfg.NumVCPUs
+	if vcpus == 0 {
+		vcpus = DefaultNumVCPUs
+	}
+
+	memory := mcfg.MemorySize
+	if memory == 0 {
+		memory = DefaultMemorySize
+	}
+
+	fcfg := &FirecrackerConfig{
+		APISock: filepath.Join(fd.dopts.RuntimeDir, mid.String()+"_api.sock"),
+		ID:      mid.String(),
+		PidFile: filepath.Join(fd.dopts.RuntimeDir, mid.String()+".pid"),
+		LogFile: filepath.Join(fd.dopts.RuntimeDir, mid.String()+".log"),
+		BootSource: FirecrackerBootSource{
+			KernelImagePath: mcfg.KernelPath,
+			InitrdPath:      mcfg.InitrdPath,
+			BootArgs:        mcfg.Cmdline(),
+		},
+		MachineConfig: FirecrackerMachineConfig{
+			VcpuCount:  vcpus,
+			MemSizeMib: memory,
+		},
+	}
+
+	mcfg.CreatedAt = time.Now()
+
+	if err := fd.launch(ctx, fcfg); err != nil {
+		return machine.NullMachineID, err
+	}
+
+	// The machine may not have made it into the store, so tear down the VMM
+	// and its runtime files from the driver config at hand
+	defer func() {
+		if err != nil {
+			fd.kill(fcfg)
+			fd.dopts.Store.Purge(mid)
+
+			if files, err := machine.RuntimeFiles(fd.dopts.RuntimeDir, mid); err == nil {
+				machine.RemoveRuntimeFiles(files)
+			}
+		}
+	}()
+
+	if err = fd.dopts.Store.SaveMachineConfig(mid, *mcfg); err != nil {
+		return machine.NullMachineID, fmt.Errorf("could not save machine config: %v", err)
+	}
+
+	if err = fd.dopts.Store.SaveDriverConfig(mid, *fcfg); err != nil {
+		return machine.NullMachineID, fmt.Errorf("could not save driver config: %v", err)
+	}
+
+	if err = fd.dopts.Store.SaveMachineState(mid, machine.MachineStateCreated); err != nil {
+		return machine.NullMachineID, fmt.Errorf("could not save machine state: %v", err)
+	}
+
+	machine.SaveEvent(fd.dopts.Store, fd.dopts.Log, machine.NewMachineEvent(machine.MachineEventCreate, *mcfg, machine.MachineStateCreated))
+
+	return mid, nil
+}
+
+// launch spawns the VMM in the background and configures the microVM through
+// its API socket such that it is ready to be started.
+func (fd *FirecrackerDriver) launch(ctx context.Context, fcfg *FirecrackerConfig) error {
+	if _, err := os.Stat(fcfg.BootSource.KernelImagePath); err != nil {
+		return fmt.Errorf("could not access kernel: %v", err)
+	}
+
+	if len(fcfg.BootSource.InitrdPath) > 0 {
+		if _, err := os.Stat(fcfg.BootSource.InitrdPath); err != nil {
+			return fmt.Errorf("could not access initrd: %v", err)
+		}
+	}
+
+	// Firecracker refuses to bind to an existing socket, which may have been
+	// left behind by a previous VMM of the same machine
+	if err := os.Remove(fcfg.APISock); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("could not remove stale API socket: %v", err)
+	}
+
+	// The log starts afresh with each VMM such that the exit code logged by a
+	// previous VMM is not mistaken for that of this one
+	logFile, err := os.OpenFile(fcfg.LogFile, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return fmt.Errorf("could not open log file: %v", err)
+	}
+
+	defer logFile.Close()
+
+	e, err := exec.NewExecutable(fd.binary, *fcfg)
+	if err != nil {
+		return fmt.Errorf("could not prepare Firecracker executable: %v", err)
+	}
+
+	process, err := exec.NewProcessFromExecutable(e,
+		append(fd.dopts.ExecOptions,
+			exec.WithStdout(logFile),
+			exec.WithStderr(logFile),
+			exec.WithDetach(true),
+		)...,
+	)
+	if err != nil {
+		return fmt.Errorf("could not prepare Firecracker process: %v", err)
+	}
+
+	if err := process.Start(); err != nil {
+		return fmt.Errorf("could not start Firecracker process: %v", err)
+	}
+
+	pid, err := process.Pid()
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(fcfg.PidFile, []byte(strconv.Itoa(pid)), 0o644); err != nil {
+		return fmt.Errorf("could not write pid file: %v", err)
+	}
+
+	client := NewFirecrackerClient(fcfg.APISock)
+	defer client.Close()
+
+	// Wait for the VMM to begin serving its API
+	if err := retrytimeout.RetryTimeout(5*time.Second, func() error {
+		if !isFirecrackerProcessAlive(fcfg.PidFile) {
+			return nil
+		}
+
+		_, err := client.DescribeInstance(ctx)
+		return err
+	}); err != nil {
+		fd.kill(fcfg)
+		return fmt.Errorf("could not connect to Firecracker API: %v", err)
+	}
+
+	if !isFirecrackerProcessAlive(fcfg.PidFile) {
+		return fmt.Errorf("Firecracker exited prematurely, see %s", fcfg.LogFile)
+	}
+
+	if err := client.PutBootSource(ctx, fcfg.BootSource); err != nil {
+		fd.kill(fcfg)
+		return fmt.Errorf("could not configure boot source: %v", err)
+	}
+
+	if err := client.PutMachineConfig(ctx, fcfg.MachineConfig); err != nil {
+		fd.kill(fcfg)
+		return fmt.Errorf("could not configure machine: %v", err)
+	}
+
+	return nil
+}
+
+// kill terminates the VMM and waits for it to exit.
+func (fd *FirecrackerDriver) kill(fcfg *FirecrackerConfig) error {
+	process, err := machine.ProcessFromPidFile(fcfg.PidFile)
+	if err != nil {
+		return err
+	}
+
+	if err := process.Terminate(); err != nil && isFirecrackerProcessAlive(fcfg.PidFile) {
+		return fmt.Errorf("could not terminate Firecracker process: %v", err)
+	}
+
+	if err := retrytimeout.RetryTimeout(5*time.Second, func() error {
+		if isFirecrackerProcessAlive(fcfg.PidFile) {
+			return fmt.Errorf("process still active")
+		}
+
+		return nil
+	}); err != nil {
+		if err := process.Kill(); err != nil {
+			return fmt.Errorf("could not kill Firecracker process: %v", err)
+		}
+	}
+
+	return nil
+}
+
+func (fd *FirecrackerDriver) Config(ctx context.Context, mid machine.MachineID) (*FirecrackerConfig, error) {
+	dcfg := &FirecrackerConfig{}
+
+	if err := fd.dopts.Store.LookupDriverConfig(mid, dcfg); err != nil {
+		return nil, err
+	}
+
+	return dcfg, nil
+}
+
+// Client returns a client for the API socket of the machine.
+func (fd *FirecrackerDriver) Client(ctx context.Context, mid machine.MachineID) (*FirecrackerClient, error) {
+	fcfg, err := fd.Config(ctx, mid)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewFirecrackerClient(fcfg.APISock), nil
+}
+
+func (fd *FirecrackerDriver) Pid(ctx context.Context, mid machine.MachineID) (uint32, error) {
+	fcfg, err := fd.Config(ctx, mid)
+	if err != nil {
+		return 0, err
+	}
+
+	return machine.ReadPidFile(fcfg.PidFile)
+}
+
+// isFirecrackerProcessAlive determines whether the process referenced by the
+// PID file is still active and still a Firecracker process, as the PID may
+// have been recycled by the host since the VMM exited.  A VMM which has exited
+// but has not yet been reaped by its parent is not considered alive.
+func isFirecrackerProcessAlive(pidFile string) bool {
+	process, err := machine.ProcessFromPidFile(pidFile)
+	if err != nil {
+		return false
+	}
+
+	running, err := process.IsRunning()
+	if err != nil || !running {
+		return false
+	}
+
+	status, err := process.Status()
+	if err != nil {
+		return false
+	}
+
+	for _, s := range status {
+		if s == goprocess.Zombie {
+			return false
+		}
+	}
+
+	name, err := process.Name()
+	if err != nil {
+		return false
+	}
+
+	return strings.HasPrefix(name, FirecrackerBin)
+}
+
+// exitCodeFromLog returns the exit code which Firecracker logged before it
+// exited, or -1 if none was logged.
+func exitCodeFromLog(logFile string) int {
+	data, err := os.ReadFile(logFile)
+	if err != nil {
+		return -1
+	}
+
+	matches := exitCodePattern.FindAllSubmatch(data, -1)
+	if len(matches) == 0 {
+		return -1
+	}
+
+	code, err := strconv.Atoi(string(matches[len(matches)-1][1]))
+	if err != nil {
+		return -1
+	}
+
+	return code
+}
+
+func (fd *FirecrackerDriver) ListenStatusUpdate(ctx context.Context, mid machine.MachineID) (chan machine.MachineState, chan error, error) {
+	if _, err := fd.Config(ctx, mid); err != nil {
+		return nil, nil, err
+	}
+
+	events, errs := machine.PollStatusUpdate(ctx, statusPollInterval, func(ctx context.Context) (machine.MachineState, error) {
+		return fd.State(ctx, mid)
+	})
+
+	return events, errs, nil
+}
+
+func (fd *FirecrackerDriver) Start(ctx context.Context, mid machine.MachineID) error {
+	fcfg, err := fd.Config(ctx, mid)
+	if err != nil {
+		return err
+	}
+
+	// A machine which has exited no longer has a VMM to resume, so launch a new
+	// one from the configuration it was originally created with
+	if !isFirecrackerProcessAlive(fcfg.PidFile) {
+		if err := fd.relaunch(ctx, mid, fcfg); err != nil {
+			return fmt.Errorf("could not relaunch %s: %v", mid.ShortString(), err)
+		}
+	}
+
+	client := NewFirecrackerClient(fcfg.APISock)
+	defer client.Close()
+
+	info, err := client.DescribeInstance(ctx)
+	if err != nil {
+		return fmt.Errorf("could not describe Firecracker instance: %v", err)
+	}
+
+	switch info.State {
+	case FirecrackerInstanceStateNotStarted:
+		err = client.CreateSyncAction(ctx, FirecrackerActionInstanceStart)
+	case FirecrackerInstanceStatePaused:
+		err = client.PatchVM(ctx, FirecrackerVMStateResumed)
+	}
+	if err != nil {
+		return fmt.Errorf("could not start Firecracker instance: %v", err)
+	}
+
+	if err := fd.dopts.Store.SaveMachineState(mid, machine.MachineStateRunning); err != nil {
+		return err
+	}
+
+	machine.RecordEvent(fd.dopts.Store, fd.dopts.Log, mid, machine.MachineEventStart, machine.MachineStateRunning)
+
+	return nil
+}
+
+// relaunch starts a new VMM for a machine which has exited, based on its
+// persisted configuration.  The machine retains its ID, socket and log file.
+func (fd *FirecrackerDriver) relaunch(ctx context.Context, mid machine.MachineID, fcfg *FirecrackerConfig) error {
+	var mcfg machine.MachineConfig
+	if err := fd.dopts.Store.LookupMachineConfig(mid, &mcfg); err != nil {
+		return fmt.Errorf("could not look up machine config: %v", err)
+	}
+
+	if err := fd.launch(ctx, fcfg); err != nil {
+		return err
+	}
+
+	mcfg.ExitedAt = time.Time{}
+	mcfg.ExitStatus = -1
+
+	if err := fd.dopts.Store.SaveMachineConfig(mid, mcfg); err != nil {
+		return fmt.Errorf("could not save machine config: %v", err)
+	}
+
+	if err := fd.dopts.Store.SaveMachineState(mid, machine.MachineStateCreated); err != nil {
+		return fmt.Errorf("could not save machine state: %v", err)
+	}
+
+	return nil
+}
+
+func (fd *FirecrackerDriver) Wait(ctx context.Context, mid machine.MachineID) (exitStatus int, exitedAt time.Time, err error) {
+	return machine.WaitForExit(ctx, fd.dopts.Store, fd, mid)
+}
+
+func (fd *FirecrackerDriver) StartAndWait(ctx context.Context, mid machine.MachineID) (int, time.Time, error) {
+	if err := fd.Start(ctx, mid); err != nil {
+		// return -1 if the process hasn't started.
+		return -1, time.Time{}, err
+	}
+
+	return fd.Wait(ctx, mid)
+}
+
+func (fd *FirecrackerDriver) Pause(ctx context.Context, mid machine.MachineID) error {
+	client, err := fd.Client(ctx, mid)
+	if err != nil {
+		return err
+	}
+
+	defer client.Close()
+
+	if err := client.PatchVM(ctx, FirecrackerVMStatePaused); err != nil {
+		return fmt.Errorf("could not pause Firecracker instance: %v", err)
+	}
+
+	if err := fd.dopts.Store.SaveMachineState(mid, machine.MachineStatePaused); err != nil {
+		return err
+	}
+
+	machine.RecordEvent(fd.dopts.Store, fd.dopts.Log, mid, machine.MachineEventPause, machine.MachineStatePaused)
+
+	return nil
+}
+
+func (fd *FirecrackerDriver) TailWriter(ctx context.Context, mid machine.MachineID, writer io.Writer) error {
+	fcfg, err := fd.Config(ctx, mid)
+	if err != nil {
+		return err
+	}
+
+	return machine.TailLog(ctx, mid, fcfg.LogFile, writer, statusPollInterval, func() bool {
+		return isFirecrackerProcessAlive(fcfg.PidFile)
+	})
+}
+
+func (fd *FirecrackerDriver) State(ctx context.Context, mid machine.MachineID) (state machine.MachineState, err error) {
+	state = machine.MachineStateUnknown
+
+	fcfg, err := fd.Config(ctx, mid)
+	if err != nil {
+		return
+	}
+
+	state, err = fd.dopts.Store.LookupMachineState(mid)
+	if err != nil {
+		return
+	}
+
+	savedState := state
+
+	var mcfg machine.MachineConfig
+	if err := fd.dopts.Store.LookupMachineConfig(mid, &mcfg); err != nil {
+		return state, fmt.Errorf("could not look up machine config: %v", err)
+	}
+
+	exitedAt := mcfg.ExitedAt
+	exitStatus := mcfg.ExitStatus
+
+	defer func() {
+		if exitStatus >= 0 && mcfg.ExitedAt.IsZero() {
+			exitedAt = time.Now()
+		}
+
+		// Update the machine config with the latest values if they are different from
+		// what we have on record
+		if mcfg.ExitedAt != exitedAt || mcfg.ExitStatus != exitStatus {
+			mcfg.ExitedAt = exitedAt
+			mcfg.ExitStatus = exitStatus
+			if err = fd.dopts.Store.SaveMachineConfig(mid, mcfg); err != nil {
+				return
+			}
+		}
+
+		// Finally, save the state if it is different from the what we have on record
+		if state != savedState {
+			err = machine.SaveObservedState(fd.dopts.Store, fd.dopts.Log, mid, mcfg, state)
+		}
+	}()
+
+	if !isFirecrackerProcessAlive(fcfg.PidFile) {
+		switch state {
+		case machine.MachineStateExited, machine.MachineStateDead:
+			return
+		}
+
+		// The VMM exits once the guest shuts down and logs its exit code.  If it
+		// has gone away without doing so, it has crashed or has been killed.
+		if code := exitCodeFromLog(fcfg.LogFile); code >= 0 {
+			state = machine.MachineStateExited
+			exitStatus = code
+		} else {
+			state = machine.MachineStateDead
+			if exitStatus < 0 {
+				exitStatus = 1
+			}
+		}
+
+		return
+	}
+
+	client := NewFirecrackerClient(fcfg.APISock)
+	defer client.Close()
+
+	info, err := client.DescribeInstance(ctx)
+	if err != nil {
+		// We cannot amend the status at this point, even if the process is alive,
+		// since it is not an indicator of the state of the VM, only of the VMM.
+		return state, fmt.Errorf("could not query machine status via API: %v", err)
+	}
+
+	switch info.State {
+	case FirecrackerInstanceStateNotStarted:
+		state = machine.MachineStateCreated
+	case FirecrackerInstanceStateRunning:
+		state = machine.MachineStateRunning
+	case FirecrackerInstanceStatePaused:
+		state = machine.MachineStatePaused
+	default:
+		state = machine.MachineStateUnknown
+	}
+
+	exitStatus = -1
+
+	return
+}
+
+func (fd *FirecrackerDriver) List(ctx context.Context) ([]machine.MachineID, error) {
+	var mids []machine.MachineID
+
+	midmap, err := fd.dopts.Store.ListAllMachineConfigs()
+	if err != nil {
+		return nil, err
+	}
+
+	for mid, mcfg := range midmap {
+		if mcfg.DriverName == "firecracker" {
+			mids = append(mids, mid)
+		}
+	}
+
+	return mids, nil
+}
+
+func (fd *FirecrackerDriver) Stop(ctx context.Context, mid machine.MachineID) error {
+	fcfg, err := fd.Config(ctx, mid)
+	if err != nil {
+		return err
+	}
+
+	// Firecracker does not offer a way to quit the VMM through its API
+	if isFirecrackerProcessAlive(fcfg.PidFile) {
+		if err := fd.kill(fcfg); err != nil {
+			return err
+		}
+	}
+
+	var mcfg machine.MachineConfig
+	if err := fd.dopts.Store.LookupMachineConfig(mid, &mcfg); err != nil {
+		return fmt.Errorf("could not look up machine config: %v", err)
+	}
+
+	mcfg.ExitedAt = time.Now()
+	mcfg.ExitStatus = 0
+	if err := fd.dopts.Store.SaveMachineConfig(mid, mcfg); err != nil {
+		return err
+	}
+
+	if err := fd.dopts.Store.SaveMachineState(mid, machine.MachineStateExited); err != nil {
+		return err
+	}
+
+	machine.SaveEvent(fd.dopts.Store, fd.dopts.Log, machine.NewMachineEvent(machine.MachineEventStop, mcfg, machine.MachineStateExited))
+
+	return nil
+}
+
+func (fd *FirecrackerDriver) Destroy(ctx context.Context, mid machine.MachineID) error {
+	// Use the reconciled state such that the VMM is not left running
+	state, err := fd.State(ctx, mid)
+	if err != nil {
+		if state, err = fd.dopts.Store.LookupMachineState(mid); err != nil {
+			return err
+		}
+	}
+
+	switch state {
+	case machine.MachineStateUnknown,
+		machine.MachineStateExited,
+		machine.MachineStateDead:
+	default:
+		fd.Stop(ctx, mid)
+	}
+
+	return machine.PurgeMachine(fd.dopts.Store, fd.dopts.Log, fd.dopts.RuntimeDir, mid)
+}
+
+func (fd *FirecrackerDriver) Shutdown(ctx context.Context, mid machine.MachineID) error {
+	client, err := fd.Client(ctx, mid)
+	if err != nil {
+		return err
+	}
+
+	defer client.Close()
+
+	return client.CreateSyncAction(ctx, FirecrackerActionSendCtrlAltDel)
+}
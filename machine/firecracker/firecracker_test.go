@@ -0,0 +1,456 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package firecracker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"kraftkit.sh/exec"
+	"kraftkit.sh/machine"
+	"kraftkit.sh/machine/driveropts"
+	"kraftkit.sh/machine/machinetest"
+)
+
+const envFakeFirecracker = "KRAFTKIT_TEST_FAKE_FIRECRACKER"
+
+func TestMain(m *testing.M) {
+	// The test binary doubles as a fake Firecracker VMM when spawned by the
+	// driver under test
+	if os.Getenv(envFakeFirecracker) == "1" {
+		if err := runFakeFirecracker(os.Args[1:]); err != nil {
+			fmt.Fprintf(os.Stderr, "fake firecracker: %v\n", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	os.Exit(m.Run())
+}
+
+// fakeFirecracker implements the subset of the Firecracker API used by the
+// driver with the same state transitions and validation as the real VMM.
+type fakeFirecracker struct {
+	mu         sync.Mutex
+	id         string
+	state      FirecrackerInstanceState
+	bootSource *FirecrackerBootSource
+	config     *FirecrackerMachineConfig
+	exit       chan int
+}
+
+func runFakeFirecracker(args []string) error {
+	flags := flag.NewFlagSet(FirecrackerBin, flag.ContinueOnError)
+	apiSock := flags.String("api-sock", "", "")
+	id := flags.String("id", "anonymous-instance", "")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	listener, err := net.Listen("unix", *apiSock)
+	if err != nil {
+		return err
+	}
+
+	fake := &fakeFirecracker{
+		id:    *id,
+		state: FirecrackerInstanceStateNotStarted,
+		exit:  make(chan int, 1),
+	}
+
+	go http.Serve(listener, fake) //nolint:errcheck
+
+	code := <-fake.exit
+	fmt.Printf("Firecracker exiting successfully. exit_code=%d\n", code)
+	os.Exit(code)
+
+	return nil
+}
+
+func (f *fakeFirecracker) fault(w http.ResponseWriter, format string, args ...interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(FirecrackerError{ //nolint:errcheck
+		FaultMessage: fmt.Sprintf(format, args...),
+	})
+}
+
+func (f *fakeFirecracker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/":
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(FirecrackerInstanceInfo{ //nolint:errcheck
+			ID:         f.id,
+			State:      f.state,
+			VMMVersion: "1.1.0",
+			AppName:    "Firecracker",
+		})
+		return
+
+	case r.Method == http.MethodPut && r.URL.Path == "/boot-source":
+		var source FirecrackerBootSource
+		if err := json.NewDecoder(r.Body).Decode(&source); err != nil {
+			f.fault(w, "invalid body: %v", err)
+			return
+		}
+		if f.state != FirecrackerInstanceStateNotStarted {
+			f.fault(w, "The requested operation is not supported after starting the microVM.")
+			return
+		}
+		if _, err := os.Stat(source.KernelImagePath); err != nil {
+			f.fault(w, "Invalid kernel path: %v", err)
+			return
+		}
+		f.bootSource = &source
+
+	case r.Method == http.MethodPut && r.URL.Path == "/machine-config":
+		var config FirecrackerMachineConfig
+		if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
+			f.fault(w, "invalid body: %v", err)
+			return
+		}
+		if f.state != FirecrackerInstanceStateNotStarted {
+			f.fault(w, "The requested operation is not supported after starting the microVM.")
+			return
+		}
+		if config.VcpuCount == 0 || config.MemSizeMib == 0 {
+			f.fault(w, "The vCPU number is invalid!")
+			return
+		}
+		f.config = &config
+
+	case r.Method == http.MethodPut && r.URL.Path == "/actions":
+		var action FirecrackerAction
+		if err := json.NewDecoder(r.Body).Decode(&action); err != nil {
+			f.fault(w, "invalid body: %v", err)
+			return
+		}
+
+		switch action.ActionType {
+		case FirecrackerActionInstanceStart:
+			if f.state != FirecrackerInstanceStateNotStarted {
+				f.fault(w, "The microVM is already running.")
+				return
+			}
+			if f.bootSource == nil {
+				f.fault(w, "Cannot start microvm without kernel configuration.")
+				return
+			}
+			f.state = FirecrackerInstanceStateRunning
+
+			// Emulate the serial console of the guest
+			fmt.Printf("booted with %d vCPUs and %d MiB: %s\n",
+				f.config.VcpuCount,
+				f.config.MemSizeMib,
+				f.bootSource.BootArgs,
+			)
+
+		case FirecrackerActionSendCtrlAltDel:
+			if f.state == FirecrackerInstanceStateNotStarted {
+				f.fault(w, "The microVM is not running.")
+				return
+			}
+			f.exit <- 0
+
+		default:
+			f.fault(w, "unsupported action: %s", action.ActionType)
+			return
+		}
+
+	case r.Method == http.MethodPatch && r.URL.Path == "/vm":
+		var vm FirecrackerVM
+		if err := json.NewDecoder(r.Body).Decode(&vm); err != nil {
+			f.fault(w, "invalid body: %v", err)
+			return
+		}
+		if f.state == FirecrackerInstanceStateNotStarted {
+			f.fault(w, "The microVM is not running.")
+			return
+		}
+
+		switch vm.State {
+		case FirecrackerVMStatePaused:
+			f.state = FirecrackerInstanceStatePaused
+		case FirecrackerVMStateResumed:
+			f.state = FirecrackerInstanceStateRunning
+		default:
+			f.fault(w, "unsupported state: %s", vm.State)
+			return
+		}
+
+	default:
+		f.fault(w, "Invalid request method and/or path: %s %s.", r.Method, r.URL.Path)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func newTestDriver(t *testing.T) (*FirecrackerDriver, machine.MachineStore, string) {
+	t.Helper()
+
+	opts, store, dir := machinetest.DriverOptions(t,
+		driveropts.WithExecOptions(
+			exec.WithEnvKey(envFakeFirecracker, "1"),
+		),
+	)
+
+	driver, err := NewFirecrackerDriver(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	driver.binary = os.Args[0]
+
+	return driver, store, dir
+}
+
+func TestFirecrackerDriverLifecycle(t *testing.T) {
+	fd, store, dir := newTestDriver(t)
+	ctx := context.Background()
+
+	kernel := filepath.Join(dir, "kernel")
+	if err := os.WriteFile(kernel, []byte("kernel"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	mid, err := fd.Create(ctx,
+		machine.WithArchitecture("x86_64"),
+		machine.WithDriverName("firecracker"),
+		machine.WithKernel(kernel),
+		machine.WithKernelArgs([]string{"vfs.rootdev=fs0"}),
+		machine.WithArguments([]string{"app"}),
+		machine.WithMemorySize(32),
+	)
+	if err != nil {
+		t.Fatalf("could not create machine: %v", err)
+	}
+
+	t.Cleanup(func() {
+		fd.Destroy(ctx, mid)
+	})
+
+	machinetest.ExpectState(t, fd, mid, machine.MachineStateCreated)
+
+	fcfg, err := fd.Config(ctx, mid)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fcfg.MachineConfig.VcpuCount != DefaultNumVCPUs || fcfg.MachineConfig.MemSizeMib != 32 {
+		t.Errorf("unexpected machine config: %+v", fcfg.MachineConfig)
+	}
+
+	if err := fd.Start(ctx, mid); err != nil {
+		t.Fatalf("could not start machine: %v", err)
+	}
+
+	machinetest.ExpectState(t, fd, mid, machine.MachineStateRunning)
+
+	// The console is captured in the log which TailWriter follows
+	var console bytes.Buffer
+	tailCtx, cancel := context.WithTimeout(ctx, 2*statusPollInterval)
+	defer cancel()
+
+	if err := fd.TailWriter(tailCtx, mid, &console); err != nil {
+		t.Fatalf("could not tail console: %v", err)
+	}
+
+	if expected := "booted with 1 vCPUs and 32 MiB: vfs.rootdev=fs0 -- app"; !strings.Contains(console.String(), expected) {
+		t.Errorf("expected console to contain %q, got %q", expected, console.String())
+	}
+
+	if err := fd.Pause(ctx, mid); err != nil {
+		t.Fatalf("could not pause machine: %v", err)
+	}
+
+	machinetest.ExpectState(t, fd, mid, machine.MachineStatePaused)
+
+	if err := fd.Start(ctx, mid); err != nil {
+		t.Fatalf("could not resume machine: %v", err)
+	}
+
+	machinetest.ExpectState(t, fd, mid, machine.MachineStateRunning)
+
+	if err := fd.Stop(ctx, mid); err != nil {
+		t.Fatalf("could not stop machine: %v", err)
+	}
+
+	machinetest.ExpectState(t, fd, mid, machine.MachineStateExited)
+
+	// An exited machine is relaunched with its original configuration
+	if err := fd.Start(ctx, mid); err != nil {
+		t.Fatalf("could not restart machine: %v", err)
+	}
+
+	machinetest.ExpectState(t, fd, mid, machine.MachineStateRunning)
+
+	// A guest-initiated shutdown exits the VMM with the logged exit code
+	if err := fd.Shutdown(ctx, mid); err != nil {
+		t.Fatalf("could not shut down machine: %v", err)
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	exitStatus, exitedAt, err := fd.Wait(waitCtx, mid)
+	if err != nil {
+		t.Fatalf("could not wait for machine: %v", err)
+	}
+
+	if exitStatus != 0 || exitedAt.IsZero() {
+		t.Errorf("expected machine to exit with status 0, got %d at %v", exitStatus, exitedAt)
+	}
+
+	machinetest.ExpectState(t, fd, mid, machine.MachineStateExited)
+
+	if err := fd.Destroy(ctx, mid); err != nil {
+		t.Fatalf("could not destroy machine: %v", err)
+	}
+
+	mids, err := store.ListAllMachineIDs()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(mids) != 0 {
+		t.Errorf("expected no machines after destroy, got %v", mids)
+	}
+
+	files, err := machine.RuntimeFiles(dir, mid)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(files) != 0 {
+		t.Errorf("expected runtime files to be removed, got %v", files)
+	}
+}
+
+func TestFirecrackerDriverInvalidConfig(t *testing.T) {
+	fd, store, _ := newTestDriver(t)
+
+	_, err := fd.Create(context.Background(),
+		machine.WithArchitecture("x86_64"),
+		machine.WithDriverName("firecracker"),
+		machine.WithKernel("/does/not/exist"),
+	)
+	if err == nil {
+		t.Fatalf("expected machine with missing kernel to fail")
+	}
+
+	mids, err := store.ListAllMachineIDs()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(mids) != 0 {
+		t.Errorf("expected no machines to be recorded, got %v", mids)
+	}
+}
+
+// failingStore refuses to save machine configs and keeps a copy of the pid file
+// of the VMM which was launched at the time.
+type failingStore struct {
+	machine.MachineStore
+	dir string
+}
+
+func (fs *failingStore) SaveMachineConfig(mid machine.MachineID, mcfg machine.MachineConfig) error {
+	if pid, err := os.ReadFile(filepath.Join(fs.dir, mid.String()+".pid")); err == nil {
+		os.WriteFile(filepath.Join(fs.dir, "vmm.pid"), pid, 0o644) //nolint:errcheck
+	}
+
+	return fmt.Errorf("store is read-only")
+}
+
+func TestFirecrackerDriverCreateCleanup(t *testing.T) {
+	opts, store, dir := machinetest.DriverOptions(t,
+		driveropts.WithExecOptions(
+			exec.WithEnvKey(envFakeFirecracker, "1"),
+		),
+	)
+
+	fd, err := NewFirecrackerDriver(append(opts,
+		driveropts.WithMachineStore(&failingStore{MachineStore: store, dir: dir}),
+	)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fd.binary = os.Args[0]
+
+	kernel := filepath.Join(dir, "kernel")
+	if err := os.WriteFile(kernel, []byte("kernel"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := fd.Create(context.Background(),
+		machine.WithArchitecture("x86_64"),
+		machine.WithDriverName("firecracker"),
+		machine.WithKernel(kernel),
+	); err == nil {
+		t.Fatalf("expected create to fail when the machine config cannot be saved")
+	}
+
+	pidFile := filepath.Join(dir, "vmm.pid")
+	if _, err := os.Stat(pidFile); err != nil {
+		t.Fatalf("expected the VMM to have been launched: %v", err)
+	}
+
+	if isFirecrackerProcessAlive(pidFile) {
+		t.Errorf("expected the VMM to be terminated")
+	}
+
+	files, err := machine.AllRuntimeFiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(files) != 0 {
+		t.Errorf("expected runtime files to be removed, got %v", files)
+	}
+}
@@ -0,0 +1,385 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package machine
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"kraftkit.sh/unikraft/plat"
+)
+
+const (
+	// multibootMagic is the magic of a Multiboot header, which must be located
+	// within the first 8 KiB of the image and be 4-byte aligned.
+	multibootMagic       = 0x1BADB002
+	multibootSearchLimit = 8192
+
+	// multiboot2Magic is the magic of a Multiboot2 header, which must be
+	// located within the first 32 KiB of the image and be 8-byte aligned.
+	multiboot2Magic       = 0xE85250D6
+	multiboot2SearchLimit = 32768
+
+	// xenElfNotePhys32Entry is the type of the "Xen" ELF note which advertises
+	// the 32-bit entry point of the PVH boot protocol.
+	xenElfNotePhys32Entry = 18
+)
+
+// KernelBootProtocol is a boot protocol which a kernel image supports.
+type KernelBootProtocol string
+
+const (
+	KernelBootProtocolMultiboot  = KernelBootProtocol("multiboot")
+	KernelBootProtocolMultiboot2 = KernelBootProtocol("multiboot2")
+	KernelBootProtocolPVH        = KernelBootProtocol("pvh")
+)
+
+// KernelImage describes a kernel image as determined by ValidateKernel.
+type KernelImage struct {
+	// Machine is the ELF machine type of the image.
+	Machine elf.Machine
+
+	// BootProtocols are the protocols the image can be booted with on x86.
+	BootProtocols []KernelBootProtocol
+
+	// Debug indicates that the image has not been stripped of its symbols or
+	// debug information, which is the case for the `.dbg` image of a build.
+	Debug bool
+}
+
+// elfMachines maps architectures onto the machine type of the ELF images
+// which are built for them.
+var elfMachines = map[string]elf.Machine{
+	"x86_64": elf.EM_X86_64,
+	"arm":    elf.EM_ARM,
+	"arm64":  elf.EM_AARCH64,
+}
+
+// architectureFromELFMachine returns the architecture of an ELF machine type.
+func architectureFromELFMachine(m elf.Machine) string {
+	switch m {
+	case elf.EM_X86_64:
+		return "x86_64"
+	case elf.EM_ARM:
+		return "arm"
+	case elf.EM_AARCH64:
+		return "arm64"
+	default:
+		return m.String()
+	}
+}
+
+// ValidateKernel checks that the kernel image can be booted on the platform
+// and architecture before it is handed to a driver.
+func ValidateKernel(kernel, arch, platform string) (*KernelImage, error) {
+	f, err := os.Open(kernel)
+	if err != nil {
+		return nil, fmt.Errorf("could not open kernel: %v", err)
+	}
+
+	defer f.Close()
+
+	arch = ArchitectureName(arch)
+	if name, err := plat.PlatformByName(platform); err == nil {
+		platform = name
+	}
+
+	image := &KernelImage{}
+
+	ef, err := elf.NewFile(f)
+	if err != nil {
+		var ferr *elf.FormatError
+		if !errors.As(err, &ferr) {
+			return nil, fmt.Errorf("could not read kernel %s: %v", kernel, err)
+		}
+
+		// ARM kernels can also be booted as raw images
+		switch arch {
+		case "arm", "arm64":
+			return image, nil
+		}
+
+		return nil, fmt.Errorf("kernel %s is not an ELF image, ensure that the path refers to the unikernel built for %s/%s rather than e.g. its initrd or package", kernel, platform, arch)
+	}
+
+	defer ef.Close()
+
+	image.Machine = ef.Machine
+
+	if expected, ok := elfMachines[arch]; ok && ef.Machine != expected {
+		return nil, fmt.Errorf("kernel %s is built for %s but the machine is %s, select the target for %s or set the architecture to %s", kernel, architectureFromELFMachine(ef.Machine), arch, arch, architectureFromELFMachine(ef.Machine))
+	}
+
+	switch ef.Type {
+	case elf.ET_EXEC:
+	case elf.ET_DYN:
+		// Position-independent executables are only run natively
+		if platform != plat.PlatformLinuxu {
+			return nil, fmt.Errorf("kernel %s is a position-independent executable which can only be run on the linuxu platform", kernel)
+		}
+	default:
+		return nil, fmt.Errorf("kernel %s is not executable (ELF type %s), ensure that the final unikernel image was linked", kernel, ef.Type)
+	}
+
+	for _, name := range []string{".debug_info", ".zdebug_info", ".symtab"} {
+		if ef.Section(name) != nil {
+			image.Debug = true
+		}
+	}
+
+	if ef.Machine == elf.EM_X86_64 && platform == plat.PlatformKVM {
+		image.BootProtocols, err = x86BootProtocols(f, ef)
+		if err != nil {
+			return nil, fmt.Errorf("could not read kernel %s: %v", kernel, err)
+		}
+
+		if len(image.BootProtocols) == 0 {
+			return nil, fmt.Errorf("kernel %s has neither a Multiboot header nor a PVH note and cannot be booted on x86_64 KVM, ensure that it was built for the kvm platform", kernel)
+		}
+	}
+
+	return image, nil
+}
+
+// x86BootProtocols determines the boot protocols advertised by the image.
+func x86BootProtocols(f io.ReaderAt, ef *elf.File) ([]KernelBootProtocol, error) {
+	var protocols []KernelBootProtocol
+
+	header := make([]byte, multiboot2SearchLimit)
+	n, err := f.ReadAt(header, 0)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	header = header[:n]
+
+	if hasMultibootHeader(header) {
+		protocols = append(protocols, KernelBootProtocolMultiboot)
+	}
+
+	if hasMultiboot2Header(header) {
+		protocols = append(protocols, KernelBootProtocolMultiboot2)
+	}
+
+	pvh, err := hasPVHNote(ef)
+	if err != nil {
+		return nil, err
+	}
+
+	if pvh {
+		protocols = append(protocols, KernelBootProtocolPVH)
+	}
+
+	return protocols, nil
+}
+
+// hasMultibootHeader searches for a Multiboot header with a valid checksum.
+func hasMultibootHeader(header []byte) bool {
+	limit := len(header)
+	if limit > multibootSearchLimit {
+		limit = multibootSearchLimit
+	}
+
+	for off := 0; off+12 <= limit; off += 4 {
+		magic := binary.LittleEndian.Uint32(header[off:])
+		if magic != multibootMagic {
+			continue
+		}
+
+		flags := binary.LittleEndian.Uint32(header[off+4:])
+		checksum := binary.LittleEndian.Uint32(header[off+8:])
+		if magic+flags+checksum == 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hasMultiboot2Header searches for a Multiboot2 header with a valid checksum.
+func hasMultiboot2Header(header []byte) bool {
+	for off := 0; off+16 <= len(header); off += 8 {
+		magic := binary.LittleEndian.Uint32(header[off:])
+		if magic != multiboot2Magic {
+			continue
+		}
+
+		arch := binary.LittleEndian.Uint32(header[off+4:])
+		length := binary.LittleEndian.Uint32(header[off+8:])
+		checksum := binary.LittleEndian.Uint32(header[off+12:])
+		if magic+arch+length+checksum == 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hasPVHNote searches the notes of the image for the PVH entry point.
+func hasPVHNote(ef *elf.File) (bool, error) {
+	var notes [][]byte
+
+	for _, prog := range ef.Progs {
+		if prog.Type != elf.PT_NOTE {
+			continue
+		}
+
+		data, err := io.ReadAll(prog.Open())
+		if err != nil {
+			return false, err
+		}
+
+		notes = append(notes, data)
+	}
+
+	// Fall back to the sections if the notes are not part of a segment
+	if len(notes) == 0 {
+		for _, sec := range ef.Sections {
+			if sec.Type != elf.SHT_NOTE {
+				continue
+			}
+
+			data, err := sec.Data()
+			if err != nil {
+				return false, err
+			}
+
+			notes = append(notes, data)
+		}
+	}
+
+	for _, data := range notes {
+		for len(data) >= 12 {
+			namesz := ef.ByteOrder.Uint32(data[0:])
+			descsz := ef.ByteOrder.Uint32(data[4:])
+			typ := ef.ByteOrder.Uint32(data[8:])
+			data = data[12:]
+
+			// The sizes are taken from the image as-is and are widened before they
+			// are aligned such that they cannot wrap around
+			nameLen := alignUp(uint64(namesz), 4)
+			descLen := alignUp(uint64(descsz), 4)
+			if nameLen+descLen > uint64(len(data)) {
+				return false, fmt.Errorf("kernel contains a malformed ELF note of %d bytes but only %d remain", nameLen+descLen, len(data))
+			}
+
+			name := bytes.TrimRight(data[:namesz], "\x00")
+			if string(name) == "Xen" && typ == xenElfNotePhys32Entry {
+				return true, nil
+			}
+
+			data = data[nameLen+descLen:]
+		}
+	}
+
+	return false, nil
+}
+
+func alignUp(v, align uint64) uint64 {
+	return (v + align - 1) &^ (align - 1)
+}
+
+// initrdMagics are the magic numbers of the formats which are accepted as an
+// initrd: CPIO archives and their compressed forms.
+var initrdMagics = []struct {
+	format string
+	magic  []byte
+}{
+	{"cpio (newc)", []byte("070701")},
+	{"cpio (crc)", []byte("070702")},
+	{"cpio (odc)", []byte("070707")},
+	{"gzip", []byte{0x1f, 0x8b}},
+	{"xz", []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}},
+	{"zstd", []byte{0x28, 0xb5, 0x2f, 0xfd}},
+	{"lz4", []byte{0x04, 0x22, 0x4d, 0x18}},
+	{"bzip2", []byte("BZh")},
+}
+
+// ValidateInitrd checks that the initrd image is a (compressed) CPIO archive
+// and returns its format.
+func ValidateInitrd(initrd string) (string, error) {
+	f, err := os.Open(initrd)
+	if err != nil {
+		return "", fmt.Errorf("could not open initrd: %v", err)
+	}
+
+	defer f.Close()
+
+	header := make([]byte, 8)
+	n, err := io.ReadFull(f, header)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		if err == io.EOF {
+			return "", fmt.Errorf("initrd %s is empty", initrd)
+		}
+		return "", fmt.Errorf("could not read initrd %s: %v", initrd, err)
+	}
+
+	header = header[:n]
+
+	for _, m := range initrdMagics {
+		if bytes.HasPrefix(header, m.magic) {
+			return m.format, nil
+		}
+	}
+
+	if bytes.HasPrefix(header, []byte(elf.ELFMAG)) {
+		return "", fmt.Errorf("initrd %s is an ELF image, ensure that the kernel and initrd have not been swapped", initrd)
+	}
+
+	return "", fmt.Errorf("initrd %s is not a CPIO archive, create one from a directory with `kraft pkg --initrd DIR`", initrd)
+}
+
+// Validate checks the kernel and initrd of the machine before it is created.
+// The returned image describes the kernel if it could be inspected.
+func (mcfg *MachineConfig) Validate() (*KernelImage, error) {
+	if len(mcfg.KernelPath) == 0 {
+		return nil, fmt.Errorf("no kernel provided")
+	}
+
+	image, err := ValidateKernel(mcfg.KernelPath, mcfg.Architecture, mcfg.Platform)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(mcfg.InitrdPath) > 0 {
+		if _, err := ValidateInitrd(mcfg.InitrdPath); err != nil {
+			return nil, err
+		}
+	}
+
+	return image, nil
+}
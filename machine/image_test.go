@@ -0,0 +1,355 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package machine
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// buildELF writes a minimal 64-bit ELF image with the provided payload at a
+// fixed offset, an optional PT_NOTE segment and empty sections named after
+// `sections`.
+func buildELF(t *testing.T, machine elf.Machine, typ elf.Type, payload, note []byte, sections ...string) string {
+	t.Helper()
+
+	const payloadOff = 0x200
+
+	phnum := 1
+	if len(note) > 0 {
+		phnum++
+	}
+
+	noteOff := payloadOff + len(payload)
+	size := noteOff + len(note)
+
+	hdr := elf.Header64{
+		Type:      uint16(typ),
+		Machine:   uint16(machine),
+		Version:   uint32(elf.EV_CURRENT),
+		Entry:     0x100000 + payloadOff,
+		Phoff:     64,
+		Ehsize:    64,
+		Phentsize: 56,
+		Phnum:     uint16(phnum),
+	}
+	copy(hdr.Ident[:], elf.ELFMAG)
+	hdr.Ident[elf.EI_CLASS] = byte(elf.ELFCLASS64)
+	hdr.Ident[elf.EI_DATA] = byte(elf.ELFDATA2LSB)
+	hdr.Ident[elf.EI_VERSION] = byte(elf.EV_CURRENT)
+
+	progs := []elf.Prog64{{
+		Type:   uint32(elf.PT_LOAD),
+		Flags:  uint32(elf.PF_R | elf.PF_X),
+		Vaddr:  0x100000,
+		Paddr:  0x100000,
+		Filesz: uint64(size),
+		Memsz:  uint64(size),
+		Align:  0x1000,
+	}}
+
+	if len(note) > 0 {
+		progs = append(progs, elf.Prog64{
+			Type:   uint32(elf.PT_NOTE),
+			Flags:  uint32(elf.PF_R),
+			Off:    uint64(noteOff),
+			Filesz: uint64(len(note)),
+			Memsz:  uint64(len(note)),
+			Align:  4,
+		})
+	}
+
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, hdr)   //nolint:errcheck
+	binary.Write(&buf, binary.LittleEndian, progs) //nolint:errcheck
+	buf.Write(make([]byte, payloadOff-buf.Len()))
+	buf.Write(payload)
+	buf.Write(note)
+
+	if len(sections) > 0 {
+		shstrtab := []byte{0}
+		var nameOffs []uint32
+		for _, name := range append(sections, ".shstrtab") {
+			nameOffs = append(nameOffs, uint32(len(shstrtab)))
+			shstrtab = append(shstrtab, append([]byte(name), 0)...)
+		}
+
+		shstrtabOff := buf.Len()
+		buf.Write(shstrtab)
+		buf.Write(make([]byte, (8-buf.Len()%8)%8))
+
+		shdrs := []elf.Section64{{}}
+		for i := range sections {
+			shdrs = append(shdrs, elf.Section64{
+				Name: nameOffs[i],
+				Type: uint32(elf.SHT_PROGBITS),
+				Off:  uint64(shstrtabOff),
+			})
+		}
+		shdrs = append(shdrs, elf.Section64{
+			Name: nameOffs[len(sections)],
+			Type: uint32(elf.SHT_STRTAB),
+			Off:  uint64(shstrtabOff),
+			Size: uint64(len(shstrtab)),
+		})
+
+		hdr.Shoff = uint64(buf.Len())
+		hdr.Shentsize = 64
+		hdr.Shnum = uint16(len(shdrs))
+		hdr.Shstrndx = uint16(len(shdrs) - 1)
+		binary.Write(&buf, binary.LittleEndian, shdrs) //nolint:errcheck
+
+		// Rewrite the header now that the section headers have been placed
+		var header bytes.Buffer
+		binary.Write(&header, binary.LittleEndian, hdr) //nolint:errcheck
+		copy(buf.Bytes(), header.Bytes())
+	}
+
+	path := filepath.Join(t.TempDir(), "kernel")
+	if err := os.WriteFile(path, buf.Bytes(), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func multibootHeader() []byte {
+	header := make([]byte, 12)
+	flags := uint32(0x00010003)
+	binary.LittleEndian.PutUint32(header[0:], multibootMagic)
+	binary.LittleEndian.PutUint32(header[4:], flags)
+	binary.LittleEndian.PutUint32(header[8:], -(uint32(multibootMagic) + flags))
+	return header
+}
+
+func pvhNote() []byte {
+	note := make([]byte, 12)
+	binary.LittleEndian.PutUint32(note[0:], 4) // "Xen\0"
+	binary.LittleEndian.PutUint32(note[4:], 4)
+	binary.LittleEndian.PutUint32(note[8:], xenElfNotePhys32Entry)
+	note = append(note, []byte("Xen\x00")...)
+	return append(note, 0x00, 0x00, 0x10, 0x00)
+}
+
+// malformedNote returns a note whose name size wraps around when it is aligned
+// in 32 bits.
+func malformedNote() []byte {
+	note := pvhNote()
+	binary.LittleEndian.PutUint32(note[0:], 0xfffffffd)
+	return note
+}
+
+func TestValidateKernel(t *testing.T) {
+	tests := []struct {
+		name      string
+		kernel    string
+		arch      string
+		plat      string
+		protocols []KernelBootProtocol
+		err       string
+	}{
+		{
+			name:      "multiboot",
+			kernel:    buildELF(t, elf.EM_X86_64, elf.ET_EXEC, multibootHeader(), nil),
+			arch:      "x86_64",
+			plat:      "kvm",
+			protocols: []KernelBootProtocol{KernelBootProtocolMultiboot},
+		},
+		{
+			name:      "pvh",
+			kernel:    buildELF(t, elf.EM_X86_64, elf.ET_EXEC, make([]byte, 16), pvhNote()),
+			arch:      "x86_64",
+			plat:      "kvm",
+			protocols: []KernelBootProtocol{KernelBootProtocolPVH},
+		},
+		{
+			name:   "malformed note",
+			kernel: buildELF(t, elf.EM_X86_64, elf.ET_EXEC, make([]byte, 16), malformedNote()),
+			arch:   "x86_64",
+			plat:   "kvm",
+			err:    "malformed ELF note",
+		},
+		{
+			name:   "no boot header",
+			kernel: buildELF(t, elf.EM_X86_64, elf.ET_EXEC, make([]byte, 16), nil),
+			arch:   "x86_64",
+			plat:   "kvm",
+			err:    "neither a Multiboot header nor a PVH note",
+		},
+		{
+			name:   "wrong architecture",
+			kernel: buildELF(t, elf.EM_AARCH64, elf.ET_EXEC, multibootHeader(), nil),
+			arch:   "x86_64",
+			plat:   "kvm",
+			err:    "built for arm64 but the machine is x86_64",
+		},
+		{
+			name:   "relocatable object",
+			kernel: buildELF(t, elf.EM_X86_64, elf.ET_REL, multibootHeader(), nil),
+			arch:   "x86_64",
+			plat:   "kvm",
+			err:    "is not executable",
+		},
+		{
+			name:   "pie outside linuxu",
+			kernel: buildELF(t, elf.EM_X86_64, elf.ET_DYN, multibootHeader(), nil),
+			arch:   "x86_64",
+			plat:   "kvm",
+			err:    "position-independent",
+		},
+		{
+			name:   "linuxu",
+			kernel: buildELF(t, elf.EM_X86_64, elf.ET_DYN, nil, nil),
+			arch:   "x86_64",
+			plat:   "linuxu",
+		},
+		{
+			name:   "pie outside linuxu alias",
+			kernel: buildELF(t, elf.EM_X86_64, elf.ET_DYN, multibootHeader(), nil),
+			arch:   "x86_64",
+			plat:   "qemu",
+			err:    "position-independent",
+		},
+		{
+			name:   "no boot header with kvm alias",
+			kernel: buildELF(t, elf.EM_X86_64, elf.ET_EXEC, make([]byte, 16), nil),
+			arch:   "x86_64",
+			plat:   "fc",
+			err:    "neither a Multiboot header nor a PVH note",
+		},
+		{
+			name:   "linuxu alias",
+			kernel: buildELF(t, elf.EM_X86_64, elf.ET_DYN, nil, nil),
+			arch:   "x86_64",
+			plat:   "linux",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			image, err := ValidateKernel(test.kernel, test.arch, test.plat)
+			if len(test.err) > 0 {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("expected error containing %q, got %v", test.err, err)
+				}
+				return
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(image.BootProtocols) != len(test.protocols) {
+				t.Fatalf("expected boot protocols %v, got %v", test.protocols, image.BootProtocols)
+			}
+
+			for i := range test.protocols {
+				if image.BootProtocols[i] != test.protocols[i] {
+					t.Errorf("expected boot protocols %v, got %v", test.protocols, image.BootProtocols)
+				}
+			}
+
+			if image.Debug {
+				t.Errorf("expected image without debug information")
+			}
+		})
+	}
+}
+
+func TestValidateKernelNotELF(t *testing.T) {
+	kernel := filepath.Join(t.TempDir(), "kernel")
+	if err := os.WriteFile(kernel, []byte("070701not a kernel"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ValidateKernel(kernel, "x86_64", "kvm"); err == nil || !strings.Contains(err.Error(), "not an ELF image") {
+		t.Errorf("expected non-ELF kernel to be rejected, got %v", err)
+	}
+}
+
+func TestValidateKernelDebug(t *testing.T) {
+	for _, section := range []string{".debug_info", ".symtab"} {
+		kernel := buildELF(t, elf.EM_X86_64, elf.ET_EXEC, multibootHeader(), nil, ".text", section)
+
+		image, err := ValidateKernel(kernel, "x86_64", "kvm")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if !image.Debug {
+			t.Errorf("expected image with %s to be detected as unstripped", section)
+		}
+	}
+}
+
+func TestValidateInitrd(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name    string
+		content []byte
+		format  string
+		err     string
+	}{
+		{"newc", []byte("070701000000"), "cpio (newc)", ""},
+		{"gzip", []byte{0x1f, 0x8b, 0x08, 0x00}, "gzip", ""},
+		{"empty", nil, "", "is empty"},
+		{"elf", []byte(elf.ELFMAG + "\x02\x01\x01"), "", "have not been swapped"},
+		{"unknown", []byte("hello world"), "", "is not a CPIO archive"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(dir, test.name)
+			if err := os.WriteFile(path, test.content, 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			format, err := ValidateInitrd(path)
+			if len(test.err) > 0 {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("expected error containing %q, got %v", test.err, err)
+				}
+				return
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if format != test.format {
+				t.Errorf("expected format %s, got %s", test.format, format)
+			}
+		})
+	}
+}
@@ -0,0 +1,312 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package machine
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	goprocess "github.com/shirou/gopsutil/v3/process"
+
+	"kraftkit.sh/log"
+)
+
+// ReadPidFile returns the PID which has been written to the file at `pidFile`
+// by a VMM or kernel process.
+func ReadPidFile(pidFile string) (uint32, error) {
+	pidData, err := os.ReadFile(pidFile)
+	if err != nil {
+		return 0, fmt.Errorf("could not read pid file: %v", err)
+	}
+
+	pid, err := strconv.ParseUint(strings.TrimSpace(string(pidData)), 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("could not convert pid string \"%s\" to uint64: %v", pidData, err)
+	}
+
+	return uint32(pid), nil
+}
+
+// ProcessFromPidFile looks up the host process referenced by the PID file.
+func ProcessFromPidFile(pidFile string) (*goprocess.Process, error) {
+	pid, err := ReadPidFile(pidFile)
+	if err != nil {
+		return nil, err
+	}
+
+	process, err := goprocess.NewProcess(int32(pid))
+	if err != nil {
+		return nil, fmt.Errorf("could not look up process %d: %v", pid, err)
+	}
+
+	return process, nil
+}
+
+// SaveEvent appends the event to the history of the `store`.  An event which
+// cannot be recorded does not affect the lifecycle of the machine and is only
+// reported to the logger `l`, if set.
+func SaveEvent(store MachineStore, l log.Logger, event MachineEvent) {
+	if err := store.SaveMachineEvent(event); err != nil && l != nil {
+		l.Warnf("could not record %s event for %s: %v", event.Type, event.ID.ShortString(), err)
+	}
+}
+
+// RecordEvent saves an event of type `met` for the machine `mid` based on its
+// current configuration in the `store`.
+func RecordEvent(store MachineStore, l log.Logger, mid MachineID, met MachineEventType, state MachineState) {
+	var mcfg MachineConfig
+	if err := store.LookupMachineConfig(mid, &mcfg); err != nil {
+		if l != nil {
+			l.Warnf("could not record %s event for %s: %v", met, mid.ShortString(), err)
+		}
+		return
+	}
+
+	SaveEvent(store, l, NewMachineEvent(met, mcfg, state))
+}
+
+// SaveObservedState saves the `state` of the machine `mid` which a driver has
+// observed, rather than brought about, and records the matching event.
+func SaveObservedState(store MachineStore, l log.Logger, mid MachineID, mcfg MachineConfig, state MachineState) error {
+	if err := store.SaveMachineState(mid, state); err != nil {
+		return err
+	}
+
+	switch state {
+	case MachineStateExited, MachineStateDead:
+		SaveEvent(store, l, NewMachineEvent(MachineEventExit, mcfg, state))
+	case MachineStateCrashed:
+		SaveEvent(store, l, NewMachineEvent(MachineEventCrash, mcfg, state))
+	default:
+		SaveEvent(store, l, NewMachineEvent(MachineEventHealth, mcfg, state))
+	}
+
+	return nil
+}
+
+// ExitStatus returns the exit status and the time at which the machine `mid`
+// exited as recorded in the `store`.  The exit status is -1 if the machine has
+// not been started.
+func ExitStatus(store MachineStore, mid MachineID) (exitStatus int, exitedAt time.Time, err error) {
+	exitStatus = -1
+
+	var mcfg MachineConfig
+	if err := store.LookupMachineConfig(mid, &mcfg); err != nil {
+		return exitStatus, exitedAt, fmt.Errorf("could not look up machine config: %v", err)
+	}
+
+	return mcfg.ExitStatus, mcfg.ExitedAt, nil
+}
+
+// StatusListener is implemented by drivers which report the changes in state
+// of their machines.
+type StatusListener interface {
+	ListenStatusUpdate(context.Context, MachineID) (chan MachineState, chan error, error)
+}
+
+// WaitForExit blocks until the machine `mid` has exited as reported by the
+// `listener`, until the listener fails or until the context is cancelled.  The
+// exit status and the time at which the machine exited are returned as
+// recorded in the `store`.
+func WaitForExit(ctx context.Context, store MachineStore, listener StatusListener, mid MachineID) (exitStatus int, exitedAt time.Time, err error) {
+	exitStatus, exitedAt, err = ExitStatus(store, mid)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	events, errs, err := listener.ListenStatusUpdate(ctx, mid)
+	if err != nil {
+		return
+	}
+
+	for {
+		select {
+		case state := <-events:
+			exitStatus, exitedAt, err = ExitStatus(store, mid)
+
+			switch state {
+			case MachineStateExited, MachineStateDead:
+				return
+			}
+
+		case err2 := <-errs:
+			exitStatus, exitedAt, err = ExitStatus(store, mid)
+			if err == nil {
+				err = err2
+			}
+
+			return
+
+		case <-ctx.Done():
+			exitStatus, exitedAt, err = ExitStatus(store, mid)
+
+			return
+		}
+	}
+}
+
+// PollStatusUpdate queries the `state` of a machine every `interval` and
+// reports it whenever it changes, starting with the current state such that it
+// can be immediately acted upon.  Failures to query the state are reported on
+// the error channel.  Polling stops once the machine has exited or the context
+// is cancelled.
+func PollStatusUpdate(ctx context.Context, interval time.Duration, state func(context.Context) (MachineState, error)) (chan MachineState, chan error) {
+	events := make(chan MachineState)
+	errs := make(chan error)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		last := MachineState("")
+
+		for {
+			current, err := state(ctx)
+			if err != nil {
+				select {
+				case errs <- err:
+				case <-ctx.Done():
+					return
+				}
+			} else if current != last {
+				select {
+				case events <- current:
+				case <-ctx.Done():
+					return
+				}
+
+				last = current
+
+				switch current {
+				case MachineStateExited, MachineStateDead:
+					return
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return events, errs
+}
+
+// TailLog copies the console log of the machine `mid` at `logFile` to the
+// `writer` as it grows.  Once the end of the log has been reached, it is
+// checked again every `interval` until the context is cancelled or until the
+// process writing the log is no longer `alive`.
+func TailLog(ctx context.Context, mid MachineID, logFile string, writer io.Writer, interval time.Duration, alive func() bool) error {
+	f, err := os.Open(logFile)
+	if err != nil {
+		return fmt.Errorf("could not open console log for %s: %v", mid, err)
+	}
+
+	defer f.Close()
+
+	buf := make([]byte, 1024)
+
+	for {
+		// First check if the context has been cancelled
+		select {
+		case <-ctx.Done():
+			return nil
+		default:
+		}
+
+		n, err := f.Read(buf)
+		if err != nil && err != io.EOF {
+			return fmt.Errorf("error reading console log for %s: %v", mid, err)
+		}
+
+		if n > 0 {
+			if _, err := writer.Write(buf[:n]); err != nil {
+				return fmt.Errorf("error writing output from console log for %s: %v", mid, err)
+			}
+			continue
+		}
+
+		// No more output will be written once the process has gone away
+		if !alive() {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(interval):
+		}
+	}
+}
+
+// PurgeMachine removes the machine `mid` from the `store` alongside the files
+// which its VMM or kernel left behind in the `runtimeDir`, and records that the
+// machine was destroyed.  The configuration of the machine is only needed for
+// the event, such that a machine with a missing or corrupt configuration can
+// still be removed.
+func PurgeMachine(store MachineStore, l log.Logger, runtimeDir string, mid MachineID) error {
+	var mcfg MachineConfig
+	if err := store.LookupMachineConfig(mid, &mcfg); err != nil {
+		if l != nil {
+			l.Warnf("could not look up machine config of %s: %v", mid.ShortString(), err)
+		}
+
+		mcfg = MachineConfig{ID: mid}
+	}
+
+	if err := store.Purge(mid); err != nil {
+		return err
+	}
+
+	files, err := RuntimeFiles(runtimeDir, mid)
+	if err != nil {
+		return fmt.Errorf("could not list runtime files: %v", err)
+	}
+
+	if _, err := RemoveRuntimeFiles(files); err != nil {
+		return fmt.Errorf("could not remove runtime files: %v", err)
+	}
+
+	SaveEvent(store, l, NewMachineEvent(MachineEventDestroy, mcfg, MachineStateUnknown))
+
+	return nil
+}
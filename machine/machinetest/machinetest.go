@@ -0,0 +1,100 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+// Package machinetest provides utilities for testing machine drivers.
+package machinetest
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"kraftkit.sh/machine"
+	"kraftkit.sh/machine/driveropts"
+)
+
+// TempDir returns a temporary directory which is removed once the test has
+// completed.  Unlike t.TempDir(), the path is kept short such that the UNIX
+// sockets of a VMM can be created within it.
+func TempDir(t testing.TB) string {
+	t.Helper()
+
+	dir, err := os.MkdirTemp("", "machine")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		os.RemoveAll(dir)
+	})
+
+	return dir
+}
+
+// DriverOptions prepares a machine store and runtime directory within a new
+// temporary directory.  The options to instantiate a driver which uses them are
+// returned alongside `opts`, together with the store and the directory.
+func DriverOptions(t testing.TB, opts ...driveropts.DriverOption) ([]driveropts.DriverOption, machine.MachineStore, string) {
+	t.Helper()
+
+	dir := TempDir(t)
+
+	store, err := machine.NewMachineStoreFromPath(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return append([]driveropts.DriverOption{
+		driveropts.WithMachineStore(store),
+		driveropts.WithRuntimeDir(dir),
+	}, opts...), store, dir
+}
+
+// StateReporter is implemented by drivers which report the state of their
+// machines.
+type StateReporter interface {
+	State(context.Context, machine.MachineID) (machine.MachineState, error)
+}
+
+// ExpectState fails the test immediately if the `driver` does not report the
+// machine `mid` in the state `expected`.
+func ExpectState(t testing.TB, driver StateReporter, mid machine.MachineID, expected machine.MachineState) {
+	t.Helper()
+
+	state, err := driver.State(context.Background(), mid)
+	if err != nil {
+		t.Fatalf("could not get state: %v", err)
+	}
+
+	if state != expected {
+		t.Fatalf("expected state %s, got %s", expected, state)
+	}
+}
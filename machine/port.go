@@ -0,0 +1,138 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package machine
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// MachinePort describes a port of the host which is forwarded to a port of
+// the guest.
+type MachinePort struct {
+	// HostIP is the address of the host the port is bound to.  When unset, the
+	// port is bound to all addresses.
+	HostIP string `json:"host_ip,omitempty"`
+
+	// HostPort is the port of the host.
+	HostPort uint16 `json:"host_port"`
+
+	// GuestPort is the port of the guest.
+	GuestPort uint16 `json:"guest_port"`
+
+	// Protocol is either tcp or udp.
+	Protocol string `json:"protocol"`
+}
+
+// ParseMachinePort parses a port forwarding of the form
+// [[HOSTIP:]HOSTPORT:]GUESTPORT[/PROTOCOL].  When the port of the host is
+// omitted, the same port as the guest's is used.  The protocol defaults to tcp.
+func ParseMachinePort(port string) (MachinePort, error) {
+	mp := MachinePort{Protocol: "tcp"}
+
+	spec, proto, ok := strings.Cut(port, "/")
+	if ok {
+		switch proto {
+		case "tcp", "udp":
+			mp.Protocol = proto
+		default:
+			return mp, fmt.Errorf("invalid port protocol, expected tcp or udp: %s", port)
+		}
+	}
+
+	var hostPort, guestPort string
+
+	// The address of the host may be an IPv6 address which contains colons
+	if i := strings.LastIndex(spec, ":"); i >= 0 {
+		guestPort = spec[i+1:]
+		spec = spec[:i]
+
+		if j := strings.LastIndex(spec, ":"); j >= 0 {
+			mp.HostIP = strings.Trim(spec[:j], "[]")
+			hostPort = spec[j+1:]
+
+			if net.ParseIP(mp.HostIP) == nil {
+				return mp, fmt.Errorf("invalid host address: %s", port)
+			}
+		} else {
+			hostPort = spec
+		}
+	} else {
+		guestPort = spec
+		hostPort = spec
+	}
+
+	var err error
+	if mp.HostPort, err = parsePortNumber(hostPort); err != nil {
+		return mp, fmt.Errorf("invalid host port: %s", port)
+	}
+	if mp.GuestPort, err = parsePortNumber(guestPort); err != nil {
+		return mp, fmt.Errorf("invalid guest port: %s", port)
+	}
+
+	return mp, nil
+}
+
+func parsePortNumber(port string) (uint16, error) {
+	n, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return 0, err
+	} else if n == 0 {
+		return 0, fmt.Errorf("port cannot be zero")
+	}
+
+	return uint16(n), nil
+}
+
+// String returns the port forwarding in the form accepted by ParseMachinePort.
+func (mp MachinePort) String() string {
+	var ret strings.Builder
+
+	if len(mp.HostIP) > 0 {
+		if strings.Contains(mp.HostIP, ":") {
+			ret.WriteString("[" + mp.HostIP + "]")
+		} else {
+			ret.WriteString(mp.HostIP)
+		}
+		ret.WriteString(":")
+	}
+
+	ret.WriteString(strconv.Itoa(int(mp.HostPort)))
+	ret.WriteString(":")
+	ret.WriteString(strconv.Itoa(int(mp.GuestPort)))
+	ret.WriteString("/")
+	ret.WriteString(mp.Protocol)
+
+	return ret.String()
+}
@@ -0,0 +1,77 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package machine
+
+import (
+	"testing"
+)
+
+func TestParseMachinePort(t *testing.T) {
+	tests := []struct {
+		port     string
+		expected MachinePort
+		err      bool
+	}{
+		{port: "80", expected: MachinePort{HostPort: 80, GuestPort: 80, Protocol: "tcp"}},
+		{port: "8080:80", expected: MachinePort{HostPort: 8080, GuestPort: 80, Protocol: "tcp"}},
+		{port: "53:53/udp", expected: MachinePort{HostPort: 53, GuestPort: 53, Protocol: "udp"}},
+		{port: "127.0.0.1:8080:80", expected: MachinePort{HostIP: "127.0.0.1", HostPort: 8080, GuestPort: 80, Protocol: "tcp"}},
+		{port: "[::1]:8080:80", expected: MachinePort{HostIP: "::1", HostPort: 8080, GuestPort: 80, Protocol: "tcp"}},
+		{port: "80/sctp", err: true},
+		{port: "http", err: true},
+		{port: "0:80", err: true},
+		{port: "65536:80", err: true},
+		{port: "localhost:8080:80", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.port, func(t *testing.T) {
+			port, err := ParseMachinePort(tt.port)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", port)
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			if port != tt.expected {
+				t.Errorf("expected %+v, got %+v", tt.expected, port)
+			}
+
+			if reparsed, err := ParseMachinePort(port.String()); err != nil || reparsed != port {
+				t.Errorf("could not round-trip %s: %+v, %v", port, reparsed, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,621 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package process
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	goprocess "github.com/shirou/gopsutil/v3/process"
+
+	"kraftkit.sh/exec"
+	"kraftkit.sh/internal/retrytimeout"
+	"kraftkit.sh/machine"
+	"kraftkit.sh/machine/driveropts"
+	"kraftkit.sh/unikraft/plat"
+	"kraftkit.sh/utils"
+)
+
+const (
+	// statusPollInterval is the period at which the state of the process is
+	// checked as there is no event stream to subscribe to.
+	statusPollInterval = 250 * time.Millisecond
+
+	// supervisorShell runs the supervisor script.
+	supervisorShell = "/bin/sh"
+
+	// supervisorScript runs the kernel as a child of a shell which outlives
+	// KraftKit and records the PID and the exit status of the kernel.  The exit
+	// status is written atomically such that it is never read partially.
+	supervisorScript = `pidfile="$1"; exitfile="$2"; shift 2; ` +
+		`"$@" </dev/null & pid=$!; echo "$pid" > "$pidfile"; ` +
+		`wait "$pid"; status=$?; ` +
+		`echo "$status" > "$exitfile.tmp" && mv "$exitfile.tmp" "$exitfile"`
+)
+
+type ProcessDriver struct {
+	dopts *driveropts.DriverOptions
+}
+
+func NewProcessDriver(opts ...driveropts.DriverOption) (*ProcessDriver, error) {
+	dopts, err := driveropts.NewDriverOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	if dopts.Store == nil {
+		return nil, fmt.Errorf("cannot instantiate process driver without machine store")
+	}
+
+	driver := ProcessDriver{
+		dopts: dopts,
+	}
+
+	return &driver, nil
+}
+
+// HostArchitecture returns the architecture of the host in the naming used by
+// Unikraft.
+func HostArchitecture() string {
+	return machine.ArchitectureName(runtime.GOARCH)
+}
+
+func (pd *ProcessDriver) Create(ctx context.Context, opts ...machine.MachineOption) (machine.MachineID, error) {
+	mcfg, err := machine.NewMachineConfig(opts...)
+	if err != nil {
+		return machine.NullMachineID, fmt.Errorf("could build machine config: %v", err)
+	}
+
+	if runtime.GOOS != "linux" {
+		return machine.NullMachineID, fmt.Errorf("linuxu kernels can only be run on Linux hosts")
+	}
+
+	if len(mcfg.Platform) > 0 && mcfg.Platform != plat.PlatformLinuxu {
+		return machine.NullMachineID, fmt.Errorf("unsupported platform: %s", mcfg.Platform)
+	}
+
+	switch mcfg.Architecture {
+	case "", HostArchitecture():
+	default:
+		return machine.NullMachineID, fmt.Errorf("cannot run %s kernel on %s host", mcfg.Architecture, HostArchitecture())
+	}
+
+	if len(mcfg.InitrdPath) > 0 {
+		return machine.NullMachineID, fmt.Errorf("initrd images are not supported by the process driver")
+	}
+
+	if len(mcfg.Ports) > 0 {
+		return machine.NullMachineID, fmt.Errorf("port forwarding is not supported by the process driver")
+	}
+
+	if len(mcfg.Volumes) > 0 {
+		return machine.NullMachineID, fmt.Errorf("volumes are not supported by the process driver")
+	}
+
+	kernel, err := filepath.Abs(mcfg.KernelPath)
+	if err != nil {
+		return machine.NullMachineID, fmt.Errorf("could not resolve kernel path: %v", err)
+	}
+
+	fi, err := os.Stat(kernel)
+	if err != nil {
+		return machine.NullMachineID, fmt.Errorf("could not access kernel: %v", err)
+	} else if fi.Mode()&0o111 == 0 {
+		return machine.NullMachineID, fmt.Errorf("kernel is not executable: %s", kernel)
+	}
+
+	mid, err := machine.NewRandomMachineID()
+	if err != nil {
+		return machine.NullMachineID, fmt.Errorf("could not generate new machine ID: %v", err)
+	}
+
+	mcfg.ID = mid
+	mcfg.KernelPath = kernel
+	mcfg.CreatedAt = time.Now()
+
+	pcfg := ProcessConfig{
+		Kernel:    kernel,
+		Arguments: mcfg.BootArgs(),
+		PidFile:   filepath.Join(pd.dopts.RuntimeDir, mid.String()+".pid"),
+		ExitFile:  filepath.Join(pd.dopts.RuntimeDir, mid.String()+".exit"),
+		LogFile:   filepath.Join(pd.dopts.RuntimeDir, mid.String()+".log"),
+	}
+
+	defer func() {
+		if err != nil {
+			pd.dopts.Store.Purge(mid)
+		}
+	}()
+
+	if err = pd.dopts.Store.SaveMachineConfig(mid, *mcfg); err != nil {
+		return machine.NullMachineID, fmt.Errorf("could not save machine config: %v", err)
+	}
+
+	if err = pd.dopts.Store.SaveDriverConfig(mid, pcfg); err != nil {
+		return machine.NullMachineID, fmt.Errorf("could not save driver config: %v", err)
+	}
+
+	if err = pd.dopts.Store.SaveMachineState(mid, machine.MachineStateCreated); err != nil {
+		return machine.NullMachineID, fmt.Errorf("could not save machine state: %v", err)
+	}
+
+	machine.SaveEvent(pd.dopts.Store, pd.dopts.Log, machine.NewMachineEvent(machine.MachineEventCreate, *mcfg, machine.MachineStateCreated))
+
+	return mid, nil
+}
+
+// launch spawns the kernel in the background through the supervisor and waits
+// for it to have started.
+func (pd *ProcessDriver) launch(pcfg *ProcessConfig) error {
+	if _, err := os.Stat(pcfg.Kernel); err != nil {
+		return fmt.Errorf("kernel is no longer available: %v", err)
+	}
+
+	// Remove the records of a previous execution of the kernel
+	for _, file := range []string{pcfg.PidFile, pcfg.ExitFile} {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("could not remove %s: %v", file, err)
+		}
+	}
+
+	logFile, err := os.OpenFile(pcfg.LogFile, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return fmt.Errorf("could not open log file: %v", err)
+	}
+
+	defer logFile.Close()
+
+	args := append([]string{
+		"-c", supervisorScript,
+		"kraftkit-supervisor",
+		pcfg.PidFile,
+		pcfg.ExitFile,
+		pcfg.Kernel,
+	}, pcfg.Arguments...)
+
+	process, err := exec.NewProcess(supervisorShell, args,
+		append(pd.dopts.ExecOptions,
+			exec.WithStdout(logFile),
+			exec.WithStderr(logFile),
+			exec.WithDetach(true),
+		)...,
+	)
+	if err != nil {
+		return fmt.Errorf("could not prepare process: %v", err)
+	}
+
+	if err := process.Start(); err != nil {
+		return err
+	}
+
+	return retrytimeout.RetryTimeout(5*time.Second, func() error {
+		if _, err := os.Stat(pcfg.PidFile); err != nil {
+			return fmt.Errorf("kernel did not start: %v", err)
+		}
+
+		return nil
+	})
+}
+
+func (pd *ProcessDriver) Config(ctx context.Context, mid machine.MachineID) (*ProcessConfig, error) {
+	dcfg := &ProcessConfig{}
+
+	if err := pd.dopts.Store.LookupDriverConfig(mid, dcfg); err != nil {
+		return nil, err
+	}
+
+	return dcfg, nil
+}
+
+func (pd *ProcessDriver) Pid(ctx context.Context, mid machine.MachineID) (uint32, error) {
+	pcfg, err := pd.Config(ctx, mid)
+	if err != nil {
+		return 0, err
+	}
+
+	return machine.ReadPidFile(pcfg.PidFile)
+}
+
+// kernelProcess returns the process of the kernel if it is still active.  The
+// executable of the process is compared against the kernel as the PID may
+// have been recycled by the host since the kernel exited.
+func kernelProcess(pcfg *ProcessConfig) (*goprocess.Process, bool) {
+	process, err := machine.ProcessFromPidFile(pcfg.PidFile)
+	if err != nil {
+		return nil, false
+	}
+
+	running, err := process.IsRunning()
+	if err != nil || !running {
+		return nil, false
+	}
+
+	status, err := process.Status()
+	if err != nil {
+		return nil, false
+	}
+
+	for _, s := range status {
+		if s == goprocess.Zombie {
+			return nil, false
+		}
+	}
+
+	exe, err := process.Exe()
+	if err != nil {
+		return nil, false
+	}
+
+	kernel, err := filepath.EvalSymlinks(pcfg.Kernel)
+	if err != nil {
+		kernel = pcfg.Kernel
+	}
+
+	// Scripts are executed by their interpreter, so also accept a command-line
+	// which refers to the kernel
+	if exe != kernel {
+		cmdline, err := process.CmdlineSlice()
+		if err != nil || !utils.Contains(cmdline, pcfg.Kernel) {
+			return nil, false
+		}
+	}
+
+	return process, true
+}
+
+// exitStatusFromFile returns the exit status recorded by the supervisor once
+// the kernel has exited.
+func exitStatusFromFile(exitFile string) (int, error) {
+	data, err := os.ReadFile(exitFile)
+	if err != nil {
+		return -1, err
+	}
+
+	return strconv.Atoi(strings.TrimSpace(string(data)))
+}
+
+func (pd *ProcessDriver) ListenStatusUpdate(ctx context.Context, mid machine.MachineID) (chan machine.MachineState, chan error, error) {
+	if _, err := pd.Config(ctx, mid); err != nil {
+		return nil, nil, err
+	}
+
+	events, errs := machine.PollStatusUpdate(ctx, statusPollInterval, func(ctx context.Context) (machine.MachineState, error) {
+		return pd.State(ctx, mid)
+	})
+
+	return events, errs, nil
+}
+
+func (pd *ProcessDriver) Start(ctx context.Context, mid machine.MachineID) error {
+	pcfg, err := pd.Config(ctx, mid)
+	if err != nil {
+		return err
+	}
+
+	if process, alive := kernelProcess(pcfg); alive {
+		// Resume the kernel if it has been paused
+		if err := process.Resume(); err != nil {
+			return fmt.Errorf("could not resume %s: %v", mid.ShortString(), err)
+		}
+	} else {
+		if err := pd.launch(pcfg); err != nil {
+			return fmt.Errorf("could not launch %s: %v", mid.ShortString(), err)
+		}
+
+		var mcfg machine.MachineConfig
+		if err := pd.dopts.Store.LookupMachineConfig(mid, &mcfg); err != nil {
+			return fmt.Errorf("could not look up machine config: %v", err)
+		}
+
+		mcfg.ExitedAt = time.Time{}
+		mcfg.ExitStatus = -1
+
+		if err := pd.dopts.Store.SaveMachineConfig(mid, mcfg); err != nil {
+			return fmt.Errorf("could not save machine config: %v", err)
+		}
+	}
+
+	if err := pd.dopts.Store.SaveMachineState(mid, machine.MachineStateRunning); err != nil {
+		return err
+	}
+
+	machine.RecordEvent(pd.dopts.Store, pd.dopts.Log, mid, machine.MachineEventStart, machine.MachineStateRunning)
+
+	return nil
+}
+
+func (pd *ProcessDriver) Wait(ctx context.Context, mid machine.MachineID) (exitStatus int, exitedAt time.Time, err error) {
+	return machine.WaitForExit(ctx, pd.dopts.Store, pd, mid)
+}
+
+func (pd *ProcessDriver) StartAndWait(ctx context.Context, mid machine.MachineID) (int, time.Time, error) {
+	if err := pd.Start(ctx, mid); err != nil {
+		// return -1 if the process hasn't started.
+		return -1, time.Time{}, err
+	}
+
+	return pd.Wait(ctx, mid)
+}
+
+func (pd *ProcessDriver) Pause(ctx context.Context, mid machine.MachineID) error {
+	pcfg, err := pd.Config(ctx, mid)
+	if err != nil {
+		return err
+	}
+
+	process, alive := kernelProcess(pcfg)
+	if !alive {
+		return fmt.Errorf("machine %s is not running", mid.ShortString())
+	}
+
+	if err := process.Suspend(); err != nil {
+		return fmt.Errorf("could not pause %s: %v", mid.ShortString(), err)
+	}
+
+	if err := pd.dopts.Store.SaveMachineState(mid, machine.MachineStatePaused); err != nil {
+		return err
+	}
+
+	machine.RecordEvent(pd.dopts.Store, pd.dopts.Log, mid, machine.MachineEventPause, machine.MachineStatePaused)
+
+	return nil
+}
+
+func (pd *ProcessDriver) TailWriter(ctx context.Context, mid machine.MachineID, writer io.Writer) error {
+	pcfg, err := pd.Config(ctx, mid)
+	if err != nil {
+		return err
+	}
+
+	return machine.TailLog(ctx, mid, pcfg.LogFile, writer, statusPollInterval, func() bool {
+		_, alive := kernelProcess(pcfg)
+		return alive
+	})
+}
+
+func (pd *ProcessDriver) State(ctx context.Context, mid machine.MachineID) (state machine.MachineState, err error) {
+	state = machine.MachineStateUnknown
+
+	pcfg, err := pd.Config(ctx, mid)
+	if err != nil {
+		return
+	}
+
+	state, err = pd.dopts.Store.LookupMachineState(mid)
+	if err != nil {
+		return
+	}
+
+	savedState := state
+
+	var mcfg machine.MachineConfig
+	if err := pd.dopts.Store.LookupMachineConfig(mid, &mcfg); err != nil {
+		return state, fmt.Errorf("could not look up machine config: %v", err)
+	}
+
+	exitedAt := mcfg.ExitedAt
+	exitStatus := mcfg.ExitStatus
+
+	defer func() {
+		if exitStatus >= 0 && mcfg.ExitedAt.IsZero() {
+			exitedAt = time.Now()
+		}
+
+		// Update the machine config with the latest values if they are different from
+		// what we have on record
+		if mcfg.ExitedAt != exitedAt || mcfg.ExitStatus != exitStatus {
+			mcfg.ExitedAt = exitedAt
+			mcfg.ExitStatus = exitStatus
+			if err = pd.dopts.Store.SaveMachineConfig(mid, mcfg); err != nil {
+				return
+			}
+		}
+
+		// Finally, save the state if it is different from the what we have on record
+		if state != savedState {
+			err = machine.SaveObservedState(pd.dopts.Store, pd.dopts.Log, mid, mcfg, state)
+		}
+	}()
+
+	process, alive := kernelProcess(pcfg)
+	if !alive {
+		switch state {
+		case machine.MachineStateCreated, machine.MachineStateExited, machine.MachineStateDead:
+			return
+		}
+
+		// The supervisor records the exit status shortly after the kernel has
+		// exited.  If it has gone away without doing so, it has been killed.
+		var code int
+		if rerr := retrytimeout.RetryTimeout(time.Second, func() error {
+			var ferr error
+			code, ferr = exitStatusFromFile(pcfg.ExitFile)
+			return ferr
+		}); rerr == nil {
+			state = machine.MachineStateExited
+			exitStatus = code
+		} else {
+			state = machine.MachineStateDead
+			if exitStatus < 0 {
+				exitStatus = 1
+			}
+		}
+
+		return state, nil
+	}
+
+	status, err := process.Status()
+	if err != nil {
+		return state, fmt.Errorf("could not query process status: %v", err)
+	}
+
+	state = machine.MachineStateRunning
+	for _, s := range status {
+		if s == goprocess.Stop {
+			state = machine.MachineStatePaused
+		}
+	}
+
+	exitStatus = -1
+
+	return
+}
+
+func (pd *ProcessDriver) List(ctx context.Context) ([]machine.MachineID, error) {
+	var mids []machine.MachineID
+
+	midmap, err := pd.dopts.Store.ListAllMachineConfigs()
+	if err != nil {
+		return nil, err
+	}
+
+	for mid, mcfg := range midmap {
+		if mcfg.DriverName == "process" {
+			mids = append(mids, mid)
+		}
+	}
+
+	return mids, nil
+}
+
+// terminate sends SIGTERM to the kernel and waits for it to exit, resorting to
+// SIGKILL if it does not do so in time.
+func terminate(pcfg *ProcessConfig, process *goprocess.Process) error {
+	// A paused kernel cannot act on the signal until it has been resumed
+	process.Resume() //nolint:errcheck
+
+	if err := process.Terminate(); err != nil {
+		if _, alive := kernelProcess(pcfg); alive {
+			return fmt.Errorf("could not terminate process: %v", err)
+		}
+	}
+
+	if err := retrytimeout.RetryTimeout(5*time.Second, func() error {
+		if _, alive := kernelProcess(pcfg); alive {
+			return fmt.Errorf("process still active")
+		}
+
+		return nil
+	}); err != nil {
+		if err := process.Kill(); err != nil {
+			return fmt.Errorf("could not kill process: %v", err)
+		}
+	}
+
+	return nil
+}
+
+func (pd *ProcessDriver) Stop(ctx context.Context, mid machine.MachineID) error {
+	pcfg, err := pd.Config(ctx, mid)
+	if err != nil {
+		return err
+	}
+
+	if process, alive := kernelProcess(pcfg); alive {
+		if err := terminate(pcfg, process); err != nil {
+			return err
+		}
+	}
+
+	var mcfg machine.MachineConfig
+	if err := pd.dopts.Store.LookupMachineConfig(mid, &mcfg); err != nil {
+		return fmt.Errorf("could not look up machine config: %v", err)
+	}
+
+	// Prefer the exit status of the kernel as recorded by the supervisor
+	mcfg.ExitedAt = time.Now()
+	mcfg.ExitStatus = 0
+	retrytimeout.RetryTimeout(time.Second, func() error { //nolint:errcheck
+		code, err := exitStatusFromFile(pcfg.ExitFile)
+		if err != nil {
+			return err
+		}
+
+		mcfg.ExitStatus = code
+		return nil
+	})
+
+	if err := pd.dopts.Store.SaveMachineConfig(mid, mcfg); err != nil {
+		return err
+	}
+
+	if err := pd.dopts.Store.SaveMachineState(mid, machine.MachineStateExited); err != nil {
+		return err
+	}
+
+	machine.SaveEvent(pd.dopts.Store, pd.dopts.Log, machine.NewMachineEvent(machine.MachineEventStop, mcfg, machine.MachineStateExited))
+
+	return nil
+}
+
+func (pd *ProcessDriver) Destroy(ctx context.Context, mid machine.MachineID) error {
+	// Use the reconciled state such that the kernel is not left running
+	state, err := pd.State(ctx, mid)
+	if err != nil {
+		if state, err = pd.dopts.Store.LookupMachineState(mid); err != nil {
+			return err
+		}
+	}
+
+	switch state {
+	case machine.MachineStateUnknown,
+		machine.MachineStateCreated,
+		machine.MachineStateExited,
+		machine.MachineStateDead:
+	default:
+		pd.Stop(ctx, mid)
+	}
+
+	return machine.PurgeMachine(pd.dopts.Store, pd.dopts.Log, pd.dopts.RuntimeDir, mid)
+}
+
+func (pd *ProcessDriver) Shutdown(ctx context.Context, mid machine.MachineID) error {
+	pcfg, err := pd.Config(ctx, mid)
+	if err != nil {
+		return err
+	}
+
+	process, alive := kernelProcess(pcfg)
+	if !alive {
+		return fmt.Errorf("machine %s is not running", mid.ShortString())
+	}
+
+	// Request the kernel to shut down without waiting for it to do so
+	return process.Terminate()
+}
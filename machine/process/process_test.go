@@ -0,0 +1,254 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package process
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"kraftkit.sh/machine"
+	"kraftkit.sh/machine/machinetest"
+)
+
+// testKernel stands in for a linuxu kernel.  Like Unikraft, it consumes the
+// library parameters preceding the `--` separator.  It prints the remaining
+// arguments and runs until the file named by its first argument is created,
+// upon which it exits with the status given by its second argument.
+const testKernel = `#!/bin/sh
+while [ $# -gt 0 ] && [ "$1" != "--" ]; do shift; done
+shift
+echo "hello from $0: $*"
+while [ ! -f "$1" ]; do sleep 0.05; done
+exit "$2"
+`
+
+func newTestDriver(t *testing.T) (*ProcessDriver, machine.MachineStore, string) {
+	t.Helper()
+
+	if runtime.GOOS != "linux" {
+		t.Skip("linuxu kernels can only be run on Linux hosts")
+	}
+
+	opts, store, dir := machinetest.DriverOptions(t)
+
+	driver, err := NewProcessDriver(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return driver, store, dir
+}
+
+func TestProcessDriverLifecycle(t *testing.T) {
+	pd, store, dir := newTestDriver(t)
+	ctx := context.Background()
+
+	kernel := filepath.Join(dir, "app_linuxu-x86_64")
+	if err := os.WriteFile(kernel, []byte(testKernel), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	quit := filepath.Join(dir, "quit")
+
+	mid, err := pd.Create(ctx,
+		machine.WithPlatform("linuxu"),
+		machine.WithDriverName("process"),
+		machine.WithKernel(kernel),
+		machine.WithKernelArgs([]string{"vfs.rootdev=fs0"}),
+		machine.WithEnv([]string{"HOME=/"}),
+		machine.WithArguments([]string{quit, "3"}),
+	)
+	if err != nil {
+		t.Fatalf("could not create machine: %v", err)
+	}
+
+	t.Cleanup(func() {
+		pd.Destroy(ctx, mid)
+	})
+
+	machinetest.ExpectState(t, pd, mid, machine.MachineStateCreated)
+
+	if err := pd.Start(ctx, mid); err != nil {
+		t.Fatalf("could not start machine: %v", err)
+	}
+
+	machinetest.ExpectState(t, pd, mid, machine.MachineStateRunning)
+
+	if _, err := pd.Pid(ctx, mid); err != nil {
+		t.Errorf("could not get pid: %v", err)
+	}
+
+	var console bytes.Buffer
+	tailCtx, cancel := context.WithTimeout(ctx, 2*statusPollInterval)
+	defer cancel()
+
+	if err := pd.TailWriter(tailCtx, mid, &console); err != nil {
+		t.Fatalf("could not tail console: %v", err)
+	}
+
+	if expected := "hello from " + kernel + ": " + quit + " 3"; !strings.Contains(console.String(), expected) {
+		t.Errorf("expected console to contain %q, got %q", expected, console.String())
+	}
+
+	if err := pd.Pause(ctx, mid); err != nil {
+		t.Fatalf("could not pause machine: %v", err)
+	}
+
+	machinetest.ExpectState(t, pd, mid, machine.MachineStatePaused)
+
+	if err := pd.Start(ctx, mid); err != nil {
+		t.Fatalf("could not resume machine: %v", err)
+	}
+
+	machinetest.ExpectState(t, pd, mid, machine.MachineStateRunning)
+
+	// Let the kernel exit by itself
+	if err := os.WriteFile(quit, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	exitStatus, exitedAt, err := pd.Wait(waitCtx, mid)
+	if err != nil {
+		t.Fatalf("could not wait for machine: %v", err)
+	}
+
+	if exitStatus != 3 || exitedAt.IsZero() {
+		t.Errorf("expected machine to exit with status 3, got %d at %v", exitStatus, exitedAt)
+	}
+
+	machinetest.ExpectState(t, pd, mid, machine.MachineStateExited)
+
+	// An exited machine is relaunched with its original configuration
+	if err := os.Remove(quit); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := pd.Start(ctx, mid); err != nil {
+		t.Fatalf("could not restart machine: %v", err)
+	}
+
+	machinetest.ExpectState(t, pd, mid, machine.MachineStateRunning)
+
+	if err := pd.Stop(ctx, mid); err != nil {
+		t.Fatalf("could not stop machine: %v", err)
+	}
+
+	machinetest.ExpectState(t, pd, mid, machine.MachineStateExited)
+
+	if err := pd.Destroy(ctx, mid); err != nil {
+		t.Fatalf("could not destroy machine: %v", err)
+	}
+
+	mids, err := store.ListAllMachineIDs()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(mids) != 0 {
+		t.Errorf("expected no machines after destroy, got %v", mids)
+	}
+
+	files, err := machine.RuntimeFiles(dir, mid)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(files) != 0 {
+		t.Errorf("expected runtime files to be removed, got %v", files)
+	}
+}
+
+func TestProcessDriverDestroyWithoutConfig(t *testing.T) {
+	pd, store, dir := newTestDriver(t)
+
+	// A machine which was only partially created has a state but no config
+	mid, err := machine.NewRandomMachineID()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	record := filepath.Join(dir, "machines", mid.String(), "machine.json")
+	if err := os.MkdirAll(filepath.Dir(record), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(record, []byte(`{"state":"created"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := pd.Destroy(context.Background(), mid); err != nil {
+		t.Fatalf("could not destroy machine: %v", err)
+	}
+
+	mids, err := store.ListAllMachineIDs()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(mids) != 0 {
+		t.Errorf("expected no machines after destroy, got %v", mids)
+	}
+
+	events, err := store.ListMachineEvents(time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(events) != 1 || events[0].Type != machine.MachineEventDestroy || events[0].ID != mid {
+		t.Errorf("expected destroy event of %s, got %v", mid.ShortString(), events)
+	}
+}
+
+func TestProcessDriverRejectsOtherPlatforms(t *testing.T) {
+	pd, _, dir := newTestDriver(t)
+
+	kernel := filepath.Join(dir, "app_kvm-x86_64")
+	if err := os.WriteFile(kernel, []byte(testKernel), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := pd.Create(context.Background(),
+		machine.WithPlatform("kvm"),
+		machine.WithKernel(kernel),
+	); err == nil {
+		t.Errorf("expected kvm kernel to be rejected")
+	}
+}
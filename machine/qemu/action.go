@@ -0,0 +1,99 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package qemu
+
+import "strings"
+
+type QemuActionRebootType string
+
+const (
+	QemuActionRebootReset    = QemuActionRebootType("reset")
+	QemuActionRebootShutdown = QemuActionRebootType("shutdown")
+)
+
+type QemuActionShutdownType string
+
+const (
+	QemuActionShutdownPoweroff = QemuActionShutdownType("poweroff")
+	QemuActionShutdownPause    = QemuActionShutdownType("pause")
+)
+
+type QemuActionPanicType string
+
+const (
+	QemuActionPanicPause       = QemuActionPanicType("pause")
+	QemuActionPanicShutdown    = QemuActionPanicType("shutdown")
+	QemuActionPanicExitFailure = QemuActionPanicType("exit-failure")
+	QemuActionPanicNone        = QemuActionPanicType("none")
+)
+
+type QemuActionWatchdogType string
+
+const (
+	QemuActionWatchdogReset     = QemuActionWatchdogType("reset")
+	QemuActionWatchdogShutdown  = QemuActionWatchdogType("shutdown")
+	QemuActionWatchdogPoweroff  = QemuActionWatchdogType("poweroff")
+	QemuActionWatchdogPause     = QemuActionWatchdogType("pause")
+	QemuActionWatchdogDebug     = QemuActionWatchdogType("debug")
+	QemuActionWatchdogNone      = QemuActionWatchdogType("none")
+	QemuActionWatchdogInjectNmi = QemuActionWatchdogType("inject-nmi")
+)
+
+// QemuAction represents the actions taken by QEMU in response to guest
+// events.  Unset actions retain QEMU's defaults.
+type QemuAction struct {
+	Reboot   QemuActionRebootType   `json:"reboot,omitempty"`
+	Shutdown QemuActionShutdownType `json:"shutdown,omitempty"`
+	Panic    QemuActionPanicType    `json:"panic,omitempty"`
+	Watchdog QemuActionWatchdogType `json:"watchdog,omitempty"`
+}
+
+// String returns a QEMU command-line compatible action string with the
+// format: reboot=reset,shutdown=poweroff,panic=pause,watchdog=reset
+func (qa QemuAction) String() string {
+	var opts []string
+
+	if qa.Reboot != "" {
+		opts = append(opts, "reboot="+string(qa.Reboot))
+	}
+	if qa.Shutdown != "" {
+		opts = append(opts, "shutdown="+string(qa.Shutdown))
+	}
+	if qa.Panic != "" {
+		opts = append(opts, "panic="+string(qa.Panic))
+	}
+	if qa.Watchdog != "" {
+		opts = append(opts, "watchdog="+string(qa.Watchdog))
+	}
+
+	return strings.Join(opts, ",")
+}
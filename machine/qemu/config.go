@@ -35,6 +35,7 @@ import "strings"
 
 type QemuConfig struct {
 	// Command-line arguments for qemu-system-*
+	Action     QemuAction        `flag:"-action"      json:"action,omitempty"`
 	Append     string            `flag:"-append"      json:"append,omitempty"`
 	CharDevs   []QemuCharDev     `flag:"-chardev"     json:"chardev,omitempty"`
 	CPU        QemuCPU           `flag:"-cpu"         json:"cpu,omitempty"`
@@ -42,12 +43,14 @@ type QemuConfig struct {
 	Devices    []QemuDevice      `flag:"-device"      json:"device,omitempty"`
 	Display    QemuDisplay       `flag:"-display"     json:"display,omitempty"`
 	EnableKVM  bool              `flag:"-enable-kvm"  json:"enable_kvm,omitempty"`
+	FsDevs     []QemuFsDev       `flag:"-fsdev"       json:"fsdev,omitempty"`
 	InitRd     string            `flag:"-initrd"      json:"initrd,omitempty"`
 	Kernel     string            `flag:"-kernel"      json:"kernel,omitempty"`
 	Machine    QemuMachine       `flag:"-machine"     json:"machine,omitempty"`
 	Memory     QemuMemory        `flag:"-m"           json:"memory,omitempty"`
 	Monitor    QemuHostCharDev   `flag:"-monitor"     json:"monitor,omitempty"`
 	Name       string            `flag:"-name"        json:"name,omitempty"`
+	NetDevs    []QemuNetDev      `flag:"-netdev"      json:"netdev,omitempty"`
 	NoACPI     bool              `flag:"-no-acpi"     json:"no_acpi,omitempty"`
 	NoDefaults bool              `flag:"-nodefaults"  json:"no_defaults,omitempty"`
 	NoGraphic  bool              `flag:"-nographic"   json:"no_graphic,omitempty"`
@@ -81,6 +84,13 @@ func NewQemuConfig(qopts ...QemuOption) (*QemuConfig, error) {
 	return &qcfg, nil
 }
 
+func WithAction(action QemuAction) QemuOption {
+	return func(qc *QemuConfig) error {
+		qc.Action = action
+		return nil
+	}
+}
+
 func WithAppend(append ...string) QemuOption {
 	return func(qc *QemuConfig) error {
 		qc.Append = qc.Append + " " + strings.Join(append, " ")
@@ -140,6 +150,18 @@ func WithEnableKVM(enableKVM bool) QemuOption {
 	}
 }
 
+func WithFsDevice(fsdev QemuFsDev) QemuOption {
+	return func(qc *QemuConfig) error {
+		if qc.FsDevs == nil {
+			qc.FsDevs = make([]QemuFsDev, 0)
+		}
+
+		qc.FsDevs = append(qc.FsDevs, fsdev)
+
+		return nil
+	}
+}
+
 func WithInitRd(initrd string) QemuOption {
 	return func(qc *QemuConfig) error {
 		qc.InitRd = initrd
@@ -182,6 +204,18 @@ func WithName(name string) QemuOption {
 	}
 }
 
+func WithNetDevice(netdev QemuNetDev) QemuOption {
+	return func(qc *QemuConfig) error {
+		if qc.NetDevs == nil {
+			qc.NetDevs = make([]QemuNetDev, 0)
+		}
+
+		qc.NetDevs = append(qc.NetDevs, netdev)
+
+		return nil
+	}
+}
+
 func WithNoACPI(noACPI bool) QemuOption {
 	return func(qc *QemuConfig) error {
 		qc.NoACPI = noACPI
@@ -0,0 +1,131 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package qemu
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"os"
+	"strings"
+	"testing"
+
+	"kraftkit.sh/machine"
+	"kraftkit.sh/machine/driveropts"
+	qmpv1alpha "kraftkit.sh/machine/qemu/qmp/v1alpha"
+)
+
+// fakeQMPDump responds to a single dump-guest-memory command, writing `core`
+// to the requested file when it is non-empty.  Asynchronous events are emitted
+// before the dump is written such that a client which mistakes them for the
+// return does not find the dump.
+func fakeQMPDump(t *testing.T, conn net.Conn, core []byte) {
+	t.Helper()
+
+	defer conn.Close()
+
+	line, err := bufio.NewReader(conn).ReadBytes('\n')
+	if err != nil {
+		return
+	}
+
+	var req qmpv1alpha.DumpGuestMemoryRequest
+	if err := json.Unmarshal(line, &req); err != nil {
+		t.Errorf("could not parse request: %v", err)
+		return
+	}
+
+	if req.Execute != "dump-guest-memory" {
+		t.Errorf("unexpected command: %s", req.Execute)
+	}
+
+	if req.Arguments.Format != qmpv1alpha.DUMP_GUEST_MEMORY_FORMAT_ELF {
+		t.Errorf("unexpected format: %s", req.Arguments.Format)
+	}
+
+	conn.Write([]byte(`{"event": "STOP", "timestamp": {"seconds": 1, "microseconds": 0}}` + "\n"))
+	conn.Write([]byte(`{"event": "DUMP_COMPLETED", "data": {"result": {"total": 4, "status": "completed", "completed": 4}}, "timestamp": {"seconds": 1, "microseconds": 1}}` + "\n"))
+
+	if len(core) > 0 {
+		path := strings.TrimPrefix(req.Arguments.Protocol, "file:")
+		if err := os.WriteFile(path, core, 0o400); err != nil {
+			t.Errorf("could not write dump: %v", err)
+		}
+	}
+
+	conn.Write([]byte(`{"return": {}}` + "\n"))
+}
+
+func TestDumpGuestMemory(t *testing.T) {
+	dir := t.TempDir()
+
+	store, err := machine.NewMachineStoreFromPath(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	qd, err := NewQemuDriver(
+		driveropts.WithMachineStore(store),
+		driveropts.WithRuntimeDir(dir),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mid, err := machine.NewRandomMachineID()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		server, client := net.Pipe()
+		go fakeQMPDump(t, server, []byte("\x7fELF"))
+
+		path, err := qd.dumpGuestMemory(qmpv1alpha.NewQEMUMachineProtocolClient(client), mid)
+		if err != nil {
+			t.Fatalf("could not dump guest memory: %v", err)
+		}
+
+		if !strings.HasPrefix(path, dir) || !strings.HasSuffix(path, mid.String()+".core") {
+			t.Errorf("unexpected dump path: %s", path)
+		}
+	}
+
+	// A dump which was not written is reported even if QEMU did not respond with
+	// an error
+	server, client := net.Pipe()
+	go fakeQMPDump(t, server, nil)
+
+	if _, err := qd.dumpGuestMemory(qmpv1alpha.NewQEMUMachineProtocolClient(client), mid); err == nil {
+		t.Errorf("expected error for missing dump")
+	}
+}
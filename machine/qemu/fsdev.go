@@ -0,0 +1,94 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package qemu
+
+import (
+	"fmt"
+	"strings"
+)
+
+type QemuFsDev interface {
+	fmt.Stringer
+}
+
+type QemuFsDevType string
+
+const (
+	QemuFsDevTypeLocal = QemuFsDevType("local")
+	QemuFsDevTypeProxy = QemuFsDevType("proxy")
+	QemuFsDevTypeSynth = QemuFsDevType("synth")
+)
+
+type QemuFsDevSecurityModel string
+
+const (
+	QemuFsDevSecurityModelMappedXattr = QemuFsDevSecurityModel("mapped-xattr")
+	QemuFsDevSecurityModelMappedFile  = QemuFsDevSecurityModel("mapped-file")
+	QemuFsDevSecurityModelPassthrough = QemuFsDevSecurityModel("passthrough")
+	QemuFsDevSecurityModelNone        = QemuFsDevSecurityModel("none")
+)
+
+// QemuFsDevLocal represents a file system device which exports a directory of
+// the host
+type QemuFsDevLocal struct {
+	Id            string
+	Path          string
+	SecurityModel QemuFsDevSecurityModel
+	ReadOnly      bool
+}
+
+// String returns a QEMU command-line compatible fsdev string with the format:
+// local,id=id,path=path,security_model=model[,readonly=on]
+func (fd QemuFsDevLocal) String() string {
+	if len(fd.Id) == 0 || len(fd.Path) == 0 {
+		// Cannot stringify local file system device without id or path
+		return ""
+	}
+
+	var ret strings.Builder
+
+	ret.WriteString(string(QemuFsDevTypeLocal))
+	ret.WriteString(",id=")
+	ret.WriteString(fd.Id)
+	ret.WriteString(",path=")
+	ret.WriteString(fd.Path)
+
+	if len(fd.SecurityModel) > 0 {
+		ret.WriteString(",security_model=")
+		ret.WriteString(string(fd.SecurityModel))
+	}
+	if fd.ReadOnly {
+		ret.WriteString(",readonly=on")
+	}
+
+	return ret.String()
+}
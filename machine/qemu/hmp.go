@@ -0,0 +1,140 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package qemu
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+)
+
+// QemuHMPPrompt is the prompt which the Human Monitor Protocol emits once it
+// is ready to accept the next command.
+const QemuHMPPrompt = "(qemu) "
+
+// hmpEscapeSequence matches the terminal control sequences which QEMU's
+// readline implementation emits whilst echoing input.
+var hmpEscapeSequence = regexp.MustCompile(`\x1b\[[0-9;]*[A-Za-z]`)
+
+// HMPClient is a client for QEMU's Human Monitor Protocol, the text-based
+// monitor which is attached via `-monitor`.
+type HMPClient struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	banner string
+}
+
+// NewHMPClient wraps an established connection to a HMP monitor and consumes
+// its greeting banner.
+func NewHMPClient(conn net.Conn) (*HMPClient, error) {
+	client := &HMPClient{
+		conn:   conn,
+		reader: bufio.NewReader(conn),
+	}
+
+	banner, err := client.readUntilPrompt()
+	if err != nil {
+		return nil, fmt.Errorf("could not read monitor greeting: %v", err)
+	}
+
+	client.banner = cleanHMPOutput(banner)
+
+	return client, nil
+}
+
+// Banner returns the greeting which the monitor sent upon connection.
+func (c *HMPClient) Banner() string {
+	return c.banner
+}
+
+// Run executes a single HMP command and returns its output.
+func (c *HMPClient) Run(command string) (string, error) {
+	command = strings.TrimSpace(command)
+	if strings.ContainsAny(command, "\r\n") {
+		return "", fmt.Errorf("monitor commands cannot span multiple lines")
+	}
+
+	if _, err := c.conn.Write([]byte(command + "\n")); err != nil {
+		return "", fmt.Errorf("could not send monitor command: %v", err)
+	}
+
+	out, err := c.readUntilPrompt()
+	if err != nil {
+		return "", fmt.Errorf("could not read monitor output: %v", err)
+	}
+
+	// The monitor echoes the command back before its output
+	if i := strings.IndexByte(out, '\n'); i >= 0 {
+		out = out[i+1:]
+	} else {
+		out = ""
+	}
+
+	return cleanHMPOutput(out), nil
+}
+
+// Close the connection to the monitor.
+func (c *HMPClient) Close() error {
+	return c.conn.Close()
+}
+
+// readUntilPrompt consumes the monitor's output up to and excluding the next
+// prompt.
+func (c *HMPClient) readUntilPrompt() (string, error) {
+	var buf bytes.Buffer
+
+	for {
+		b, err := c.reader.ReadByte()
+		if err != nil {
+			return buf.String(), err
+		}
+
+		buf.WriteByte(b)
+
+		if bytes.HasSuffix(buf.Bytes(), []byte(QemuHMPPrompt)) {
+			buf.Truncate(buf.Len() - len(QemuHMPPrompt))
+			return buf.String(), nil
+		}
+	}
+}
+
+// cleanHMPOutput removes terminal control sequences and carriage returns from
+// the monitor's output.
+func cleanHMPOutput(out string) string {
+	out = hmpEscapeSequence.ReplaceAllString(out, "")
+	out = strings.ReplaceAll(out, "\r", "")
+
+	return out
+}
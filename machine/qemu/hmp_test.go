@@ -0,0 +1,112 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package qemu
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+)
+
+// fakeHMPMonitor mimics QEMU's monitor on a character device, echoing input
+// with readline control sequences before responding.
+func fakeHMPMonitor(t *testing.T, conn net.Conn, responses map[string]string) {
+	t.Helper()
+
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte("QEMU 7.0.0 monitor - type 'help' for more information\r\n" + QemuHMPPrompt)); err != nil {
+		return
+	}
+
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		command := scanner.Text()
+		out := command + "\x1b[K\r\n" + responses[command] + QemuHMPPrompt
+		if _, err := conn.Write([]byte(out)); err != nil {
+			return
+		}
+	}
+}
+
+func TestHMPClientRun(t *testing.T) {
+	server, client := net.Pipe()
+
+	go fakeHMPMonitor(t, server, map[string]string{
+		"info status": "VM status: running\r\n",
+		"info registers": "RAX=0000000000000000 RBX=0000000000000001\r\n" +
+			"RIP=ffffffff81000000 RFL=00000046\r\n",
+	})
+
+	hmp, err := NewHMPClient(client)
+	if err != nil {
+		t.Fatalf("could not create client: %v", err)
+	}
+
+	defer hmp.Close()
+
+	if !strings.HasPrefix(hmp.Banner(), "QEMU 7.0.0 monitor") {
+		t.Errorf("unexpected banner: %q", hmp.Banner())
+	}
+
+	out, err := hmp.Run("info status")
+	if err != nil {
+		t.Fatalf("could not run command: %v", err)
+	}
+
+	if expected := "VM status: running\n"; out != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+
+	out, err = hmp.Run("  info registers ")
+	if err != nil {
+		t.Fatalf("could not run command: %v", err)
+	}
+
+	if !strings.HasPrefix(out, "RAX=") || !strings.HasSuffix(out, "RFL=00000046\n") {
+		t.Errorf("unexpected output: %q", out)
+	}
+
+	out, err = hmp.Run("stop")
+	if err != nil {
+		t.Fatalf("could not run command: %v", err)
+	}
+
+	if out != "" {
+		t.Errorf("expected no output, got %q", out)
+	}
+
+	if _, err := hmp.Run("info\nstatus"); err == nil {
+		t.Errorf("expected multi-line command to be rejected")
+	}
+}
@@ -83,6 +83,14 @@ type QemuMachine struct {
 	SupressVMDesc bool                     `json_name:"suppress-vmdesc,omitempty"`
 	NVDIMM        bool                     `json_name:"nvdimm,omitempty"`
 	HMAT          bool                     `json_name:"hmat,omitempty"`
+
+	// Options specific to the microvm machine type
+	PIT               QemuMachineOptOnOffAuto `json_name:"pit,omitempty"`
+	PIC               QemuMachineOptOnOffAuto `json_name:"pic,omitempty"`
+	RTC               QemuMachineOptOnOffAuto `json_name:"rtc,omitempty"`
+	ISASerial         QemuMachineOptOnOffAuto `json_name:"isa-serial,omitempty"`
+	OptionROMs        QemuMachineOptOnOffAuto `json_name:"x-option-roms,omitempty"`
+	AutoKernelCmdline QemuMachineOptOnOffAuto `json_name:"auto-kernel-cmdline,omitempty"`
 }
 
 // String returns a QEMU command-line compatible -machine flag value
@@ -138,5 +146,24 @@ func (qm QemuMachine) String() string {
 		ret.WriteString(",hmat=on")
 	}
 
+	for _, opt := range []struct {
+		name  string
+		value QemuMachineOptOnOffAuto
+	}{
+		{"pit", qm.PIT},
+		{"pic", qm.PIC},
+		{"rtc", qm.RTC},
+		{"isa-serial", qm.ISASerial},
+		{"x-option-roms", qm.OptionROMs},
+		{"auto-kernel-cmdline", qm.AutoKernelCmdline},
+	} {
+		if string(opt.value) != "" {
+			ret.WriteString(",")
+			ret.WriteString(opt.name)
+			ret.WriteString("=")
+			ret.WriteString(string(opt.value))
+		}
+	}
+
 	return ret.String()
 }
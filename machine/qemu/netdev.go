@@ -0,0 +1,108 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package qemu
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type QemuNetDev interface {
+	fmt.Stringer
+}
+
+type QemuNetDevType string
+
+const (
+	QemuNetDevTypeBridge    = QemuNetDevType("bridge")
+	QemuNetDevTypeSocket    = QemuNetDevType("socket")
+	QemuNetDevTypeTap       = QemuNetDevType("tap")
+	QemuNetDevTypeUser      = QemuNetDevType("user")
+	QemuNetDevTypeVhostUser = QemuNetDevType("vhost-user")
+)
+
+// QemuNetDevUserHostForward represents the forwarding of a port of the host
+// to a port of the guest via user mode networking
+type QemuNetDevUserHostForward struct {
+	Protocol  string
+	HostAddr  string
+	HostPort  uint16
+	GuestAddr string
+	GuestPort uint16
+}
+
+// String returns a QEMU command-line compatible hostfwd string with the
+// format: [tcp|udp]:[hostaddr]:hostport-[guestaddr]:guestport
+func (hf QemuNetDevUserHostForward) String() string {
+	var ret strings.Builder
+
+	ret.WriteString(hf.Protocol)
+	ret.WriteString(":")
+	ret.WriteString(hf.HostAddr)
+	ret.WriteString(":")
+	ret.WriteString(strconv.Itoa(int(hf.HostPort)))
+	ret.WriteString("-")
+	ret.WriteString(hf.GuestAddr)
+	ret.WriteString(":")
+	ret.WriteString(strconv.Itoa(int(hf.GuestPort)))
+
+	return ret.String()
+}
+
+// QemuNetDevUser represents a network backend using user mode networking
+type QemuNetDevUser struct {
+	Id           string
+	HostForwards []QemuNetDevUserHostForward
+}
+
+// String returns a QEMU command-line compatible netdev string with the format:
+// user,id=id[,hostfwd=rule][,hostfwd=rule]...
+func (nd QemuNetDevUser) String() string {
+	if len(nd.Id) == 0 {
+		// Cannot stringify user network backend without id
+		return ""
+	}
+
+	var ret strings.Builder
+
+	ret.WriteString(string(QemuNetDevTypeUser))
+	ret.WriteString(",id=")
+	ret.WriteString(nd.Id)
+
+	for _, hf := range nd.HostForwards {
+		ret.WriteString(",hostfwd=")
+		ret.WriteString(hf.String())
+	}
+
+	return ret.String()
+}
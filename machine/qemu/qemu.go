@@ -40,7 +40,6 @@ import (
 	"net"
 	"os"
 	"path/filepath"
-	"strconv"
 	"strings"
 	"time"
 
@@ -50,8 +49,6 @@ import (
 	"kraftkit.sh/machine/driveropts"
 	"kraftkit.sh/machine/qemu/qmp"
 	qmpv1alpha "kraftkit.sh/machine/qemu/qmp/v1alpha"
-
-	goprocess "github.com/shirou/gopsutil/v3/process"
 )
 
 const (
@@ -79,6 +76,12 @@ func init() {
 	// gob.Register(QemuCharDevPipe{})
 	// gob.Register(QemuCharDevPty{})
 	// gob.Register(QemuCharDevStdio{})
+
+	// File system devices
+	gob.Register(QemuFsDevLocal{})
+
+	// Network backends
+	gob.Register(QemuNetDevUser{})
 	// gob.Register(QemuCharDevSerial{})
 	// gob.Register(QemuCharDevTty{})
 	// gob.Register(QemuCharDevParallel{})
@@ -319,7 +322,7 @@ func init() {
 	// gob.Register(QemuDevicePcTestdev{})
 	// gob.Register(QemuDevicePciTestdev{})
 	// gob.Register(QemuDevicePcm3680Pci{})
-	// gob.Register(QemuDevicePvpanic{})
+	gob.Register(QemuDevicePvpanic{})
 	// gob.Register(QemuDeviceSmbusIpmi{})
 	// gob.Register(QemuDeviceTpmCrb{})
 	// gob.Register(QemuDeviceUsbRedir{})
@@ -379,8 +382,8 @@ func init() {
 	// gob.Register(QemuDeviceRtl8139{})
 	// gob.Register(QemuDeviceTulip{})
 	// gob.Register(QemuDeviceUsbNet{})
-	// gob.Register(QemuDeviceVirtioNetDevice{})
-	// gob.Register(QemuDeviceVirtioNetPci{})
+	gob.Register(QemuDeviceVirtioNetDevice{})
+	gob.Register(QemuDeviceVirtioNetPci{})
 	// gob.Register(QemuDeviceVirtioNetPciNonTransitional{})
 	// gob.Register(QemuDeviceVirtioNetPciTransitional{})
 	// gob.Register(QemuDeviceVmxnet3{})
@@ -445,8 +448,8 @@ func init() {
 	// gob.Register(QemuDeviceVhostUserScsiPci{})
 	// gob.Register(QemuDeviceVhostUserScsiPciNonTransitional{})
 	// gob.Register(QemuDeviceVhostUserScsiPciTransitional{})
-	// gob.Register(QemuDeviceVirtio9pDevice{})
-	// gob.Register(QemuDeviceVirtio9pPci{})
+	gob.Register(QemuDeviceVirtio9pDevice{})
+	gob.Register(QemuDeviceVirtio9pPci{})
 	// gob.Register(QemuDeviceVirtio9pPciNonTransitional{})
 	// gob.Register(QemuDeviceVirtio9pPciTransitional{})
 	// gob.Register(QemuDeviceVirtioBlkDevice{})
@@ -548,7 +551,7 @@ func (qd *QemuDriver) Create(ctx context.Context, opts ...machine.MachineOption)
 		WithPidFile(pidFile),
 		WithName(mid.String()),
 		WithKernel(mcfg.KernelPath),
-		WithAppend(mcfg.Arguments...),
+		WithAppend(mcfg.Cmdline()),
 		WithVGA(QemuVGANone),
 		WithMemory(QemuMemory{
 			Size: mcfg.MemorySize,
@@ -599,18 +602,80 @@ func (qd *QemuDriver) Create(ctx context.Context, opts ...machine.MachineOption)
 		)
 	}
 
-	var bin string
+	bin, err := QemuSystemBinary(mcfg.Architecture)
+	if err != nil {
+		return machine.NullMachineID, err
+	}
 
-	switch mcfg.Architecture {
-	case "x86_64", "amd64":
-		bin = QemuSystemX86
+	// Keep the guest paused when it reports a panic such that its memory can be
+	// captured before the VMM is terminated.  The -action flag was introduced in
+	// QEMU 6.0, whereas earlier versions pause a panicked guest and only shut it
+	// down when -no-shutdown is not given.
+	if major, _, err := QemuVersion(bin); err == nil && major >= 6 {
+		qopts = append(qopts,
+			WithAction(QemuAction{
+				Panic: QemuActionPanicPause,
+			}),
+		)
+	} else {
+		qopts = append(qopts,
+			WithNoShutdown(true),
+		)
+	}
 
+	switch mcfg.MachineType {
+	case "", QemuMachineTypePC.String():
+	case QemuMachineTypeMicroVM.String():
+		switch mcfg.Architecture {
+		case "x86_64":
+		default:
+			return machine.NullMachineID, fmt.Errorf("the %s machine type is only available for x86_64", mcfg.MachineType)
+		}
+	default:
+		return machine.NullMachineID, fmt.Errorf("unsupported machine type: %s", mcfg.MachineType)
+	}
+
+	switch mcfg.Architecture {
+	case "x86_64":
+		var accelerators []QemuMachineAccelerator
 		if mcfg.HardwareAcceleration {
+			accelerators = []QemuMachineAccelerator{QemuMachineAccelKVM}
+		}
+
+		if mcfg.MachineType == QemuMachineTypeMicroVM.String() {
+			// The microvm machine type has no PCI bus, so devices must be attached
+			// via virtio-mmio and no default devices are created.  The serial
+			// console is wired to the ISA serial port.  The PIT, PIC and RTC are
+			// left enabled as Unikraft relies on them for its timer and wall clock.
+			// The automatic kernel command-line is disabled since QEMU would
+			// otherwise prepend virtio-mmio device descriptions to the arguments
+			// passed to the unikernel.
+			qopts = append(qopts,
+				WithNoDefaults(true),
+				WithMachine(QemuMachine{
+					Type:              QemuMachineTypeMicroVM,
+					Accelerators:      accelerators,
+					ISASerial:         QemuMachineOptOn,
+					AutoKernelCmdline: QemuMachineOptOff,
+				}),
+			)
+		} else {
 			qopts = append(qopts,
 				WithMachine(QemuMachine{
 					Type:         QemuMachineTypePC,
-					Accelerators: []QemuMachineAccelerator{QemuMachineAccelKVM},
+					Accelerators: accelerators,
 				}),
+				WithDevice(QemuDeviceSga{}),
+			)
+		}
+
+		// Allow the guest to report panics, see QemuDriver.State
+		qopts = append(qopts,
+			WithDevice(QemuDevicePvpanic{}),
+		)
+
+		if mcfg.HardwareAcceleration {
+			qopts = append(qopts,
 				WithCPU(QemuCPU{
 					CPU: QemuCPUX86Host,
 					On:  QemuCPUFeatures{QemuCPUFeatureX2apic},
@@ -619,9 +684,6 @@ func (qd *QemuDriver) Create(ctx context.Context, opts ...machine.MachineOption)
 			)
 		} else {
 			qopts = append(qopts,
-				WithMachine(QemuMachine{
-					Type: QemuMachineTypePC,
-				}),
 				WithCPU(QemuCPU{
 					CPU: QemuCPUX86Qemu64,
 					On:  QemuCPUFeatures{QemuCPUFeatureVmx},
@@ -630,13 +692,7 @@ func (qd *QemuDriver) Create(ctx context.Context, opts ...machine.MachineOption)
 			)
 		}
 
-		qopts = append(qopts,
-			WithDevice(QemuDeviceSga{}),
-		)
-
 	case "arm":
-		bin = QemuSystemArm
-
 		qopts = append(qopts,
 			WithMachine(QemuMachine{
 				Type: QemuMachineTypeVirt,
@@ -650,6 +706,54 @@ func (qd *QemuDriver) Create(ctx context.Context, opts ...machine.MachineOption)
 		return machine.NullMachineID, fmt.Errorf("unsupported architecture: %s", mcfg.Architecture)
 	}
 
+	// Devices of the microvm machine type are attached via virtio-mmio as it has
+	// no PCI bus
+	microvm := mcfg.MachineType == QemuMachineTypeMicroVM.String()
+
+	// Share volumes with the guest via 9pfs, tagged such that the guest can
+	// mount them, see machine.MachineConfig.BootArgs
+	for i, volume := range mcfg.Volumes {
+		tag := machine.VolumeTag(i)
+
+		qopts = append(qopts,
+			WithFsDevice(QemuFsDevLocal{
+				Id:            tag,
+				Path:          volume.Source,
+				SecurityModel: QemuFsDevSecurityModelPassthrough,
+				ReadOnly:      volume.ReadOnly,
+			}),
+		)
+
+		if microvm {
+			qopts = append(qopts, WithDevice(QemuDeviceVirtio9pDevice{Fsdev: tag, MountTag: tag}))
+		} else {
+			qopts = append(qopts, WithDevice(QemuDeviceVirtio9pPci{Fsdev: tag, MountTag: tag}))
+		}
+	}
+
+	// Forward ports via user mode networking which does not require any
+	// privileges on the host
+	if len(mcfg.Ports) > 0 {
+		netdev := QemuNetDevUser{Id: "net0"}
+
+		for _, port := range mcfg.Ports {
+			netdev.HostForwards = append(netdev.HostForwards, QemuNetDevUserHostForward{
+				Protocol:  port.Protocol,
+				HostAddr:  port.HostIP,
+				HostPort:  port.HostPort,
+				GuestPort: port.GuestPort,
+			})
+		}
+
+		qopts = append(qopts, WithNetDevice(netdev))
+
+		if microvm {
+			qopts = append(qopts, WithDevice(QemuDeviceVirtioNetDevice{Netdev: netdev.Id}))
+		} else {
+			qopts = append(qopts, WithDevice(QemuDeviceVirtioNetPci{Netdev: netdev.Id}))
+		}
+	}
+
 	qcfg, err := NewQemuConfig(qopts...)
 	if err != nil {
 		return machine.NullMachineID, fmt.Errorf("could not generate QEMU config: %v", err)
@@ -692,9 +796,57 @@ func (qd *QemuDriver) Create(ctx context.Context, opts ...machine.MachineOption)
 		return machine.NullMachineID, fmt.Errorf("could not save machine state: %v", err)
 	}
 
+	machine.SaveEvent(qd.dopts.Store, qd.dopts.Log, machine.NewMachineEvent(machine.MachineEventCreate, *mcfg, machine.MachineStateCreated))
+
 	return mid, nil
 }
 
+// markExited saves the exit status of the machine and records the transition
+// unless it has already been registered as having exited.
+func (qd *QemuDriver) markExited(mid machine.MachineID, exitStatus int) error {
+	state, err := qd.dopts.Store.LookupMachineState(mid)
+	if err != nil {
+		return err
+	}
+
+	switch state {
+	case machine.MachineStateExited, machine.MachineStateDead, machine.MachineStateCrashed:
+		return nil
+	}
+
+	var mcfg machine.MachineConfig
+	if err := qd.dopts.Store.LookupMachineConfig(mid, &mcfg); err != nil {
+		return fmt.Errorf("could not look up machine config: %v", err)
+	}
+
+	mcfg.ExitedAt = time.Now()
+	mcfg.ExitStatus = exitStatus
+	if err := qd.dopts.Store.SaveMachineConfig(mid, mcfg); err != nil {
+		return err
+	}
+
+	if err := qd.dopts.Store.SaveMachineState(mid, machine.MachineStateExited); err != nil {
+		return err
+	}
+
+	machine.SaveEvent(qd.dopts.Store, qd.dopts.Log, machine.NewMachineEvent(machine.MachineEventExit, mcfg, machine.MachineStateExited))
+
+	return nil
+}
+
+// QemuSystemBinary returns the name of the QEMU system emulator binary which
+// is used for the provided architecture.
+func QemuSystemBinary(arch string) (string, error) {
+	switch machine.ArchitectureName(arch) {
+	case "x86_64":
+		return QemuSystemX86, nil
+	case "arm":
+		return QemuSystemArm, nil
+	default:
+		return "", fmt.Errorf("unsupported architecture: %s", arch)
+	}
+}
+
 func (qd *QemuDriver) Config(ctx context.Context, mid machine.MachineID) (*QemuConfig, error) {
 	dcfg := &QemuConfig{}
 
@@ -740,42 +892,60 @@ func (qd *QemuDriver) QMPClient(ctx context.Context, mid machine.MachineID) (*qm
 	return qmpClientHandshake(&conn)
 }
 
-func (qd *QemuDriver) Pid(ctx context.Context, mid machine.MachineID) (uint32, error) {
+// HMPClient connects to the Human Monitor Protocol socket of the machine.
+func (qd *QemuDriver) HMPClient(ctx context.Context, mid machine.MachineID) (*HMPClient, error) {
 	qcfg, err := qd.Config(ctx, mid)
 	if err != nil {
-		return 0, err
+		return nil, err
+	}
+
+	if qcfg.Monitor == nil {
+		return nil, fmt.Errorf("monitor not available for %s", mid)
 	}
 
-	pidData, err := os.ReadFile(qcfg.PidFile)
+	conn, err := qcfg.Monitor.Connection()
 	if err != nil {
-		return 0, fmt.Errorf("could not read pid file: %v", err)
+		return nil, fmt.Errorf("could not connect to monitor for %s: %v", mid, err)
 	}
 
-	pid, err := strconv.ParseUint(strings.TrimSpace(string(pidData)), 10, 32)
+	client, err := NewHMPClient(conn)
 	if err != nil {
-		return 0, fmt.Errorf("could not convert pid string \"%s\" to uint64: %v", pidData, err)
+		conn.Close()
+		return nil, err
 	}
 
-	return uint32(pid), nil
+	return client, nil
 }
 
-func processFromPidFile(pidFile string) (*goprocess.Process, error) {
-	pidData, err := os.ReadFile(pidFile)
+func (qd *QemuDriver) Pid(ctx context.Context, mid machine.MachineID) (uint32, error) {
+	qcfg, err := qd.Config(ctx, mid)
 	if err != nil {
-		return nil, fmt.Errorf("could not read pid file: %v", err)
+		return 0, err
 	}
 
-	pid, err := strconv.ParseUint(strings.TrimSpace(string(pidData)), 10, 64)
+	return machine.ReadPidFile(qcfg.PidFile)
+}
+
+// isQemuProcessAlive determines whether the process referenced by the PID file
+// is still active and still a QEMU process, as the PID may have been recycled
+// by the host since the VMM exited.
+func isQemuProcessAlive(pidFile string) bool {
+	process, err := machine.ProcessFromPidFile(pidFile)
 	if err != nil {
-		return nil, fmt.Errorf("could not convert pid string \"%s\" to uint64: %v", pidData, err)
+		return false
+	}
+
+	running, err := process.IsRunning()
+	if err != nil || !running {
+		return false
 	}
 
-	process, err := goprocess.NewProcess(int32(pid))
+	name, err := process.Name()
 	if err != nil {
-		return nil, fmt.Errorf("could not look up process %d: %v", pid, err)
+		return false
 	}
 
-	return process, nil
+	return strings.HasPrefix(name, "qemu")
 }
 
 func (qd *QemuDriver) ListenStatusUpdate(ctx context.Context, mid machine.MachineID) (chan machine.MachineState, chan error, error) {
@@ -852,7 +1022,22 @@ func (qd *QemuDriver) ListenStatusUpdate(ctx context.Context, mid machine.Machin
 			case qmpv1alpha.EVENT_RESET, qmpv1alpha.EVENT_WAKEUP:
 				events <- machine.MachineStateRestarting
 
+			case qmpv1alpha.EVENT_GUEST_PANICKED:
+				// Capture the crash via the reconciled state of the machine
+				state, err := qd.State(ctx, mid)
+				if err != nil {
+					errs <- err
+				}
+
+				events <- state
+
+				break accept
+
 			case qmpv1alpha.EVENT_SHUTDOWN:
+				if err := qd.markExited(mid, 0); err != nil {
+					errs <- err
+				}
+
 				events <- machine.MachineStateExited
 
 				if !qcfg.NoShutdown {
@@ -869,7 +1054,72 @@ func (qd *QemuDriver) ListenStatusUpdate(ctx context.Context, mid machine.Machin
 
 func (qd *QemuDriver) AddBridge() {}
 
+// relaunch starts a new VMM for a machine which has exited, based on its
+// persisted configuration.  The machine retains its ID, sockets and PID file,
+// and the VMM is started paused as it was when the machine was first created.
+func (qd *QemuDriver) relaunch(ctx context.Context, mid machine.MachineID, qcfg *QemuConfig) error {
+	var mcfg machine.MachineConfig
+	if err := qd.dopts.Store.LookupMachineConfig(mid, &mcfg); err != nil {
+		return fmt.Errorf("could not look up machine config: %v", err)
+	}
+
+	if _, err := os.Stat(qcfg.Kernel); err != nil {
+		return fmt.Errorf("kernel is no longer available: %v", err)
+	}
+
+	if len(qcfg.InitRd) > 0 {
+		if _, err := os.Stat(qcfg.InitRd); err != nil {
+			return fmt.Errorf("initrd is no longer available: %v", err)
+		}
+	}
+
+	bin, err := QemuSystemBinary(mcfg.Architecture)
+	if err != nil {
+		return err
+	}
+
+	e, err := exec.NewExecutable(bin, *qcfg)
+	if err != nil {
+		return fmt.Errorf("could not prepare QEMU executable: %v", err)
+	}
+
+	process, err := exec.NewProcessFromExecutable(e, qd.dopts.ExecOptions...)
+	if err != nil {
+		return fmt.Errorf("could not prepare QEMU process: %v", err)
+	}
+
+	if err := process.StartAndWait(); err != nil {
+		return fmt.Errorf("could not start and wait for QEMU process: %v", err)
+	}
+
+	mcfg.ExitedAt = time.Time{}
+	mcfg.ExitStatus = -1
+
+	if err := qd.dopts.Store.SaveMachineConfig(mid, mcfg); err != nil {
+		return fmt.Errorf("could not save machine config: %v", err)
+	}
+
+	if err := qd.dopts.Store.SaveMachineState(mid, machine.MachineStateCreated); err != nil {
+		return fmt.Errorf("could not save machine state: %v", err)
+	}
+
+	return nil
+}
+
 func (qd *QemuDriver) Start(ctx context.Context, mid machine.MachineID) error {
+	qcfg, err := qd.Config(ctx, mid)
+	if err != nil {
+		return err
+	}
+
+	// A machine which has exited no longer has a VMM to resume, so launch a new
+	// one from the configuration it was originally created with
+	if !isQemuProcessAlive(qcfg.PidFile) {
+		if err := qd.relaunch(ctx, mid, qcfg); err != nil {
+			return fmt.Errorf("could not relaunch %s: %v", mid.ShortString(), err)
+		}
+	}
+
 	qmpClient, err := qd.QMPClient(ctx, mid)
 	if err != nil {
 		return fmt.Errorf("could not start qemu instance: %v", err)
@@ -884,13 +1134,8 @@ func (qd *QemuDriver) Start(ctx context.Context, mid machine.MachineID) error {
 	// TODO: Timeout? Unikernels boot quickly, but a user environment may be
 	// saturated...
 
-	qcfg, err := qd.Config(ctx, mid)
-	if err != nil {
-		return err
-	}
-
 	// Check if the process is alive
-	process, err := processFromPidFile(qcfg.PidFile)
+	process, err := machine.ProcessFromPidFile(qcfg.PidFile)
 	if err != nil {
 		return err
 	}
@@ -904,28 +1149,18 @@ func (qd *QemuDriver) Start(ctx context.Context, mid machine.MachineID) error {
 		if err := qd.dopts.Store.SaveMachineState(mid, machine.MachineStateRunning); err != nil {
 			return err
 		}
-	}
-
-	return err
-}
 
-func (qd *QemuDriver) exitStatusAndAtFromConfig(ctx context.Context, mid machine.MachineID) (exitStatus int, exitedAt time.Time, err error) {
-	exitStatus = -1 // return -1 if the process hasn't started
-	exitedAt = time.Time{}
-
-	var mcfg machine.MachineConfig
-	if err := qd.dopts.Store.LookupMachineConfig(mid, &mcfg); err != nil {
-		return exitStatus, exitedAt, fmt.Errorf("could not look up machine config: %v", err)
+		machine.RecordEvent(qd.dopts.Store, qd.dopts.Log, mid, machine.MachineEventStart, machine.MachineStateRunning)
 	}
 
-	exitStatus = mcfg.ExitStatus
-	exitedAt = mcfg.ExitedAt
-
-	return
+	return err
 }
 
+// Wait blocks until the machine has exited.  Unlike machine.WaitForExit,
+// replies on the monitor which are not events are skipped and a monitor which
+// is closed by the exiting VMM ends the wait without an error.
 func (qd *QemuDriver) Wait(ctx context.Context, mid machine.MachineID) (exitStatus int, exitedAt time.Time, err error) {
-	exitStatus, exitedAt, err = qd.exitStatusAndAtFromConfig(ctx, mid)
+	exitStatus, exitedAt, err = machine.ExitStatus(qd.dopts.Store, mid)
 	if err != nil {
 		return
 	}
@@ -938,15 +1173,15 @@ func (qd *QemuDriver) Wait(ctx context.Context, mid machine.MachineID) (exitStat
 	for {
 		select {
 		case state := <-events:
-			exitStatus, exitedAt, err = qd.exitStatusAndAtFromConfig(ctx, mid)
+			exitStatus, exitedAt, err = machine.ExitStatus(qd.dopts.Store, mid)
 
 			switch state {
-			case machine.MachineStateExited, machine.MachineStateDead:
+			case machine.MachineStateExited, machine.MachineStateDead, machine.MachineStateCrashed:
 				return
 			}
 
 		case err2 := <-errs:
-			exitStatus, exitedAt, err = qd.exitStatusAndAtFromConfig(ctx, mid)
+			exitStatus, exitedAt, err = machine.ExitStatus(qd.dopts.Store, mid)
 
 			if errors.Is(err2, qmp.ErrAcceptedNonEvent) {
 				continue
@@ -955,7 +1190,7 @@ func (qd *QemuDriver) Wait(ctx context.Context, mid machine.MachineID) (exitStat
 			return
 
 		case <-ctx.Done():
-			exitStatus, exitedAt, err = qd.exitStatusAndAtFromConfig(ctx, mid)
+			exitStatus, exitedAt, err = machine.ExitStatus(qd.dopts.Store, mid)
 
 			// TODO: Should we return an error if the context is cancelled?
 			return
@@ -989,6 +1224,8 @@ func (qd *QemuDriver) Pause(ctx context.Context, mid machine.MachineID) error {
 		return err
 	}
 
+	machine.RecordEvent(qd.dopts.Store, qd.dopts.Log, mid, machine.MachineEventPause, machine.MachineStatePaused)
+
 	return nil
 }
 
@@ -1056,18 +1293,11 @@ func (qd *QemuDriver) State(ctx context.Context, mid machine.MachineID) (state m
 
 	// Check if the process is alive, which ultimately indicates to us whether we
 	// able to speak to the exposed QMP socket
-	process, err := processFromPidFile(qcfg.PidFile)
-	activeProcess := false
-	if err == nil {
-		activeProcess, err = process.IsRunning()
-		if err != nil {
-			state = machine.MachineStateDead
-			activeProcess = false
-		}
-	}
+	activeProcess := isQemuProcessAlive(qcfg.PidFile)
 
 	exitedAt := mcfg.ExitedAt
 	exitStatus := mcfg.ExitStatus
+	crashDumpPath := mcfg.CrashDumpPath
 
 	defer func() {
 		if exitStatus >= 0 && mcfg.ExitedAt.IsZero() {
@@ -1076,9 +1306,10 @@ func (qd *QemuDriver) State(ctx context.Context, mid machine.MachineID) (state m
 
 		// Update the machine config with the latest values if they are different from
 		// what we have on record
-		if mcfg.ExitedAt != exitedAt || mcfg.ExitStatus != exitStatus {
+		if mcfg.ExitedAt != exitedAt || mcfg.ExitStatus != exitStatus || mcfg.CrashDumpPath != crashDumpPath {
 			mcfg.ExitedAt = exitedAt
 			mcfg.ExitStatus = exitStatus
+			mcfg.CrashDumpPath = crashDumpPath
 			if err = qd.dopts.Store.SaveMachineConfig(mid, mcfg); err != nil {
 				return
 			}
@@ -1086,13 +1317,22 @@ func (qd *QemuDriver) State(ctx context.Context, mid machine.MachineID) (state m
 
 		// Finally, save the state if it is different from the what we have on record
 		if state != savedState {
-			if err = qd.dopts.Store.SaveMachineState(mid, state); err != nil {
-				return
-			}
+			err = machine.SaveObservedState(qd.dopts.Store, qd.dopts.Log, mid, mcfg, state)
 		}
 	}()
 
 	if !activeProcess {
+		// The VMM has gone away without the exit having been recorded, e.g. due
+		// to a crash of the host or the VMM itself, so reconcile the state.
+		switch state {
+		case machine.MachineStateExited, machine.MachineStateDead, machine.MachineStateCrashed:
+		default:
+			state = machine.MachineStateDead
+			if exitStatus < 0 {
+				exitStatus = 1
+			}
+		}
+
 		return
 	}
 
@@ -1118,7 +1358,23 @@ func (qd *QemuDriver) State(ctx context.Context, mid machine.MachineID) (state m
 
 	// Map the QMP status to supported machine states
 	switch status.Return.Status {
-	case qmpv1alpha.RUN_STATE_GUEST_PANICKED, qmpv1alpha.RUN_STATE_INTERNAL_ERROR, qmpv1alpha.RUN_STATE_IO_ERROR:
+	case qmpv1alpha.RUN_STATE_GUEST_PANICKED:
+		// The guest is kept paused after a panic, see QemuDriver.Create, such
+		// that its memory can be captured before the VMM is terminated
+		state = machine.MachineStateCrashed
+		exitStatus = 1
+
+		if path, err := qd.dumpGuestMemory(qmpClient, mid); err != nil && qd.dopts.Log != nil {
+			qd.dopts.Log.Warnf("could not capture crash dump of %s: %v", mid.ShortString(), err)
+		} else if err == nil {
+			crashDumpPath = path
+		}
+
+		if _, err := qmpClient.Quit(qmpv1alpha.QuitRequest{}); err != nil && qd.dopts.Log != nil {
+			qd.dopts.Log.Warnf("could not terminate %s: %v", mid.ShortString(), err)
+		}
+
+	case qmpv1alpha.RUN_STATE_INTERNAL_ERROR, qmpv1alpha.RUN_STATE_IO_ERROR:
 		state = machine.MachineStateDead
 		exitStatus = 1
 
@@ -1134,6 +1390,13 @@ func (qd *QemuDriver) State(ctx context.Context, mid machine.MachineID) (state m
 		state = machine.MachineStateExited
 		exitStatus = 0
 
+		// The VMM outlives the guest with -no-shutdown, see QemuDriver.Create
+		if qcfg.NoShutdown {
+			if _, err := qmpClient.Quit(qmpv1alpha.QuitRequest{}); err != nil && qd.dopts.Log != nil {
+				qd.dopts.Log.Warnf("could not terminate %s: %v", mid.ShortString(), err)
+			}
+		}
+
 	case qmpv1alpha.RUN_STATE_SUSPENDED:
 		state = machine.MachineStateSuspended
 		exitStatus = -1
@@ -1150,6 +1413,37 @@ func (qd *QemuDriver) State(ctx context.Context, mid machine.MachineID) (state m
 	return
 }
 
+// dumpGuestMemory captures the memory of the guest as an ELF core in the
+// runtime directory and returns its path.
+func (qd *QemuDriver) dumpGuestMemory(qmpClient *qmpv1alpha.QEMUMachineProtocolClient, mid machine.MachineID) (string, error) {
+	path := filepath.Join(qd.dopts.RuntimeDir, mid.String()+".core")
+
+	// Remove the dump of any previous crash such that it is not mistaken for
+	// the dump of this crash
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+
+	if _, err := qmpClient.DumpGuestMemory(qmpv1alpha.DumpGuestMemoryRequest{
+		Arguments: qmpv1alpha.DumpGuestMemoryRequestArguments{
+			Paging:   false,
+			Protocol: "file:" + path,
+			Format:   qmpv1alpha.DUMP_GUEST_MEMORY_FORMAT_ELF,
+		},
+	}); err != nil {
+		return "", err
+	}
+
+	// The response may not indicate failure, so check the dump was written
+	if fi, err := os.Stat(path); err != nil {
+		return "", err
+	} else if fi.Size() == 0 {
+		return "", fmt.Errorf("crash dump is empty")
+	}
+
+	return path, nil
+}
+
 func (qd *QemuDriver) List(ctx context.Context) ([]machine.MachineID, error) {
 	var mids []machine.MachineID
 
@@ -1198,24 +1492,30 @@ func (qd *QemuDriver) Stop(ctx context.Context, mid machine.MachineID) error {
 		return err
 	}
 
+	machine.RecordEvent(qd.dopts.Store, qd.dopts.Log, mid, machine.MachineEventStop, machine.MachineStateExited)
+
 	return nil
 }
 
 func (qd *QemuDriver) Destroy(ctx context.Context, mid machine.MachineID) error {
-	state, err := qd.dopts.Store.LookupMachineState(mid)
+	// Use the reconciled state such that the VMM is not left running
+	state, err := qd.State(ctx, mid)
 	if err != nil {
-		return err
+		if state, err = qd.dopts.Store.LookupMachineState(mid); err != nil {
+			return err
+		}
 	}
 
 	switch state {
 	case machine.MachineStateUnknown,
 		machine.MachineStateExited,
-		machine.MachineStateDead:
+		machine.MachineStateDead,
+		machine.MachineStateCrashed:
 	default:
 		qd.Stop(ctx, mid)
 	}
 
-	return qd.dopts.Store.Purge(mid)
+	return machine.PurgeMachine(qd.dopts.Store, qd.dopts.Log, qd.dopts.RuntimeDir, mid)
 }
 
 func (qd *QemuDriver) Shutdown(ctx context.Context, mid machine.MachineID) error {
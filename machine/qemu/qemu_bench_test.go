@@ -0,0 +1,155 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package qemu
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"sync"
+	"testing"
+	"time"
+
+	"kraftkit.sh/machine"
+	"kraftkit.sh/machine/driveropts"
+)
+
+// envBenchKernel is the path to an x86_64 KVM unikernel which prints to the
+// console when booted.  The benchmarks are skipped unless it is set.
+const envBenchKernel = "KRAFTKIT_BENCH_KERNEL"
+
+// firstByteWriter signals once the first byte has been written to it.
+type firstByteWriter struct {
+	once sync.Once
+	done chan struct{}
+}
+
+func (w *firstByteWriter) Write(p []byte) (int, error) {
+	if len(p) > 0 {
+		w.once.Do(func() { close(w.done) })
+	}
+
+	return len(p), nil
+}
+
+// BenchmarkTimeToFirstConsoleOutput measures the time between starting a
+// machine and receiving its first byte of console output for each of the
+// supported x86_64 machine types.
+func BenchmarkTimeToFirstConsoleOutput(b *testing.B) {
+	kernel := os.Getenv(envBenchKernel)
+	if len(kernel) == 0 {
+		b.Skipf("%s not set", envBenchKernel)
+	}
+
+	bin, err := QemuSystemBinary("x86_64")
+	if err != nil {
+		b.Skip(err)
+	}
+
+	if _, err := exec.LookPath(bin); err != nil {
+		b.Skipf("%s not available", bin)
+	}
+
+	_, err = os.Stat("/dev/kvm")
+	accel := err == nil
+
+	for _, machineType := range []QemuMachineType{
+		QemuMachineTypePC,
+		QemuMachineTypeMicroVM,
+	} {
+		b.Run(machineType.String(), func(b *testing.B) {
+			// UNIX socket paths are limited in length, so avoid the long paths of
+			// b.TempDir()
+			dir, err := os.MkdirTemp("", "qemu")
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			defer os.RemoveAll(dir)
+
+			store, err := machine.NewMachineStoreFromPath(dir)
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			qd, err := NewQemuDriver(
+				driveropts.WithMachineStore(store),
+				driveropts.WithRuntimeDir(dir),
+			)
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			var total time.Duration
+
+			for i := 0; i < b.N; i++ {
+				ctx, cancel := context.WithCancel(context.Background())
+
+				mid, err := qd.Create(ctx,
+					machine.WithArchitecture("x86_64"),
+					machine.WithPlatform("kvm"),
+					machine.WithKernel(kernel),
+					machine.WithMemorySize(64),
+					machine.WithAcceleration(accel),
+					machine.WithMachineType(machineType.String()),
+				)
+				if err != nil {
+					b.Fatal(err)
+				}
+
+				w := &firstByteWriter{done: make(chan struct{})}
+				go qd.TailWriter(ctx, mid, w)
+
+				start := time.Now()
+
+				if err := qd.Start(ctx, mid); err != nil {
+					b.Fatal(err)
+				}
+
+				select {
+				case <-w.done:
+					total += time.Since(start)
+				case <-time.After(30 * time.Second):
+					b.Fatalf("no console output from %s", machineType)
+				}
+
+				cancel()
+
+				if err := qd.Destroy(context.Background(), mid); err != nil {
+					b.Fatal(err)
+				}
+			}
+
+			b.ReportMetric(float64(total.Microseconds())/1000/float64(b.N), "ms/first-byte")
+		})
+	}
+}
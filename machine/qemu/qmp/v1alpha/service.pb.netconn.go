@@ -65,6 +65,26 @@ func (c *QEMUMachineProtocolClient) setRpcRequestSetDefaults(face any) error {
 	return nil
 }
 
+// readResponse reads the next line which is not an asynchronous event.  QEMU
+// may emit events at any time, including between a command and its return.
+func (c *QEMUMachineProtocolClient) readResponse() ([]byte, error) {
+	for {
+		b, err := c.recv.ReadBytes('\n')
+		if err != nil {
+			return nil, err
+		}
+
+		var event struct {
+			Event *string `json:"event"`
+		}
+		if err := json.Unmarshal(b, &event); err == nil && event.Event != nil {
+			continue
+		}
+
+		return b, nil
+	}
+}
+
 func (c *QEMUMachineProtocolClient) Greeting() (*GreetingResponse, error) {
 	var b []byte
 	var err error
@@ -73,7 +93,7 @@ func (c *QEMUMachineProtocolClient) Greeting() (*GreetingResponse, error) {
 	defer c.lock.Unlock()
 
 	var res GreetingResponse
-	b, err = c.recv.ReadBytes('\n')
+	b, err = c.readResponse()
 	if err != nil {
 		return nil, err
 	}
@@ -107,7 +127,7 @@ func (c *QEMUMachineProtocolClient) Quit(req QuitRequest) (*QuitResponse, error)
 	}
 
 	var res QuitResponse
-	b, err = c.recv.ReadBytes('\n')
+	b, err = c.readResponse()
 	if err != nil {
 		return nil, err
 	}
@@ -141,7 +161,7 @@ func (c *QEMUMachineProtocolClient) Stop(req StopRequest) (*any, error) {
 	}
 
 	var res any
-	b, err = c.recv.ReadBytes('\n')
+	b, err = c.readResponse()
 	if err != nil {
 		return nil, err
 	}
@@ -175,7 +195,7 @@ func (c *QEMUMachineProtocolClient) Cont(req ContRequest) (*any, error) {
 	}
 
 	var res any
-	b, err = c.recv.ReadBytes('\n')
+	b, err = c.readResponse()
 	if err != nil {
 		return nil, err
 	}
@@ -209,7 +229,7 @@ func (c *QEMUMachineProtocolClient) SystemReset(req SystemResetRequest) (*any, e
 	}
 
 	var res any
-	b, err = c.recv.ReadBytes('\n')
+	b, err = c.readResponse()
 	if err != nil {
 		return nil, err
 	}
@@ -243,7 +263,7 @@ func (c *QEMUMachineProtocolClient) SystemPowerdown(req SystemPowerdownRequest)
 	}
 
 	var res any
-	b, err = c.recv.ReadBytes('\n')
+	b, err = c.readResponse()
 	if err != nil {
 		return nil, err
 	}
@@ -277,7 +297,7 @@ func (c *QEMUMachineProtocolClient) SystemWakeup(req SystemWakeupRequest) (*any,
 	}
 
 	var res any
-	b, err = c.recv.ReadBytes('\n')
+	b, err = c.readResponse()
 	if err != nil {
 		return nil, err
 	}
@@ -311,7 +331,7 @@ func (c *QEMUMachineProtocolClient) Capabilities(req CapabilitiesRequest) (*Capa
 	}
 
 	var res CapabilitiesResponse
-	b, err = c.recv.ReadBytes('\n')
+	b, err = c.readResponse()
 	if err != nil {
 		return nil, err
 	}
@@ -345,7 +365,7 @@ func (c *QEMUMachineProtocolClient) QueryKvm(req QueryKvmRequest) (*QueryKvmResp
 	}
 
 	var res QueryKvmResponse
-	b, err = c.recv.ReadBytes('\n')
+	b, err = c.readResponse()
 	if err != nil {
 		return nil, err
 	}
@@ -379,7 +399,41 @@ func (c *QEMUMachineProtocolClient) QueryStatus(req QueryStatusRequest) (*QueryS
 	}
 
 	var res QueryStatusResponse
-	b, err = c.recv.ReadBytes('\n')
+	b, err = c.readResponse()
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &res); err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+func (c *QEMUMachineProtocolClient) DumpGuestMemory(req DumpGuestMemoryRequest) (*any, error) {
+	var b []byte
+	var err error
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if err := c.setRpcRequestSetDefaults(&req); err != nil {
+		return nil, err
+	}
+
+	b, err = json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := c.send.Write(append(b, '\x0a')); err != nil {
+		return nil, err
+	}
+	if err := c.send.Flush(); err != nil {
+		return nil, err
+	}
+
+	var res any
+	b, err = c.readResponse()
 	if err != nil {
 		return nil, err
 	}
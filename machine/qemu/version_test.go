@@ -0,0 +1,60 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package qemu
+
+import "testing"
+
+func TestParseQemuVersion(t *testing.T) {
+	tests := []struct {
+		out     string
+		major   int
+		minor   int
+		wantErr bool
+	}{
+		{out: "QEMU emulator version 6.2.0 (Debian 1:6.2+dfsg-2ubuntu6)\n", major: 6, minor: 2},
+		{out: "QEMU emulator version 5.0.1\nCopyright (c) 2003-2020 Fabrice Bellard", major: 5, minor: 0},
+		{out: "QEMU emulator version 10.1.50\n", major: 10, minor: 1},
+		{out: "qemu-system-x86_64: unknown option\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		major, minor, err := parseQemuVersion(tt.out)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: unexpected error: %v", tt.out, err)
+			continue
+		}
+
+		if major != tt.major || minor != tt.minor {
+			t.Errorf("%q: expected %d.%d, got %d.%d", tt.out, tt.major, tt.minor, major, minor)
+		}
+	}
+}
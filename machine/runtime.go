@@ -0,0 +1,109 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package machine
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// runtimeFilePattern matches the files which drivers create in the runtime
+// directory on behalf of a machine, e.g. `<mid>.pid` or `<mid>_serial`.
+var runtimeFilePattern = regexp.MustCompile(fmt.Sprintf(`^([a-f0-9]{%d})([._].*)?$`, MachineIDLen))
+
+// RuntimeFiles returns all files within the runtime directory `dir` which
+// belong to the machine identified by `mid`.
+func RuntimeFiles(dir string, mid MachineID) ([]string, error) {
+	files, err := AllRuntimeFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return files[mid], nil
+}
+
+// AllRuntimeFiles returns all files within the runtime directory `dir` which
+// belong to a machine, indexed by the machine's ID.  The machine may no longer
+// exist in the machine store.
+func AllRuntimeFiles(dir string) (map[MachineID][]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	files := make(map[MachineID][]string)
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		matches := runtimeFilePattern.FindStringSubmatch(entry.Name())
+		if matches == nil {
+			continue
+		}
+
+		mid := MachineID(matches[1])
+		files[mid] = append(files[mid], filepath.Join(dir, entry.Name()))
+	}
+
+	return files, nil
+}
+
+// RemoveRuntimeFiles deletes the provided runtime files and returns the number
+// of bytes which were reclaimed.  Files which no longer exist are ignored.
+func RemoveRuntimeFiles(files []string) (uint64, error) {
+	var reclaimed uint64
+
+	for _, file := range files {
+		fi, err := os.Lstat(file)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return reclaimed, err
+		}
+
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return reclaimed, err
+		}
+
+		reclaimed += uint64(fi.Size())
+	}
+
+	return reclaimed, nil
+}
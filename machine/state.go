@@ -56,4 +56,7 @@ const (
 	MachineStateExited = MachineState("exited")
 	// The machine has not exited gracefully
 	MachineStateDead = MachineState("dead")
+	// The guest has reported a panic and its memory has been captured in a
+	// crash dump.  In this state, the VMM is no longer present or active.
+	MachineStateCrashed = MachineState("crashed")
 )
@@ -32,400 +32,195 @@
 package machine
 
 import (
-	"bytes"
-	"encoding/gob"
 	"fmt"
+	"os"
 	"path/filepath"
 	"time"
 
 	"kraftkit.sh/config"
-	"kraftkit.sh/internal/retrytimeout"
 
 	"github.com/dgraph-io/badger/v3"
 )
 
-type MachineStore struct {
-	db      *badger.DB
-	bopts   badger.Options
-	timeout time.Duration
-}
-
-type MachineStoreOption func(ms *MachineStore) error
-
-// WithMachineStoreLogger sets the Badger DB logger interface
-func WithMachineStoreLogger(l badger.Logger) MachineStoreOption {
-	return func(ms *MachineStore) error {
-		ms.bopts.Logger = l
-		return nil
-	}
-}
-
-// WithMachineStoreTimeout sets a timeout to use when connection to the store
-func WithMachineStoreTimeout(timeout time.Duration) MachineStoreOption {
-	return func(ms *MachineStore) error {
-		ms.timeout = timeout
-		return nil
-	}
-}
-
-// NewMachineStoreFromPath prepares a `*MachineStore` to use to manipulate,
-// save, list, view, etc. in the machine store.
-func NewMachineStoreFromPath(dir string, msopts ...MachineStoreOption) (*MachineStore, error) {
-	if len(dir) == 0 {
-		dir = config.DefaultRuntimeDir
-	}
-
-	dir = filepath.Join(dir, "machinestore")
-	ms := &MachineStore{
-		bopts:   badger.DefaultOptions(dir),
-		timeout: 5 * time.Second,
-	}
+// MachineStore is the source-of-truth for the configuration and state of
+// machines which are instantiated by KraftKit.  Implementations must be safe to
+// use from multiple processes concurrently.
+type MachineStore interface {
+	// SaveMachineConfig saves the machine config `mcfg` for the machine based on
+	// the MachineID `mid`.  The machine is added to the store if it is unknown.
+	SaveMachineConfig(MachineID, MachineConfig) error
 
-	// TODO: Badger uses an internal `Infof` logger method entry which is too low
-	// level to be considered "info" in the context of KraftKit's output.  This
-	// should somehow be shifted into debug.
-	//
-	// For now, disable the logger entirely.  An option, `WithMachineStoreLogger`,
-	// exists to enable it, though in this case it is only used if debugging is
-	// enabled.  This will, however, report as "info" in the console which is
-	// inconsistent with enabling "debugging".
-	ms.bopts.Logger = nil
+	// LookupMachineConfig uses pass-by-reference to return the machine config
+	// for the machine defined by the MachineID `mid` to the variable `mcfg`.
+	LookupMachineConfig(MachineID, any) error
 
-	for _, o := range msopts {
-		if err := o(ms); err != nil {
-			return nil, fmt.Errorf("could not apply machine store option: %v", err)
-		}
-	}
-
-	return ms, nil
-}
+	// SaveMachineState saves the machine `state` for the machine based on the
+	// MachineID `mid`, which must already be known to the store.
+	SaveMachineState(MachineID, MachineState) error
 
-func (ms *MachineStore) connect() error {
-	var db *badger.DB
+	// LookupMachineState returns the machine state in the store for the machine
+	// defined by the MachineID `mid`.
+	LookupMachineState(MachineID) (MachineState, error)
 
-	// Perform a continuous re-try to check for the dir lock on the badger
-	// database which may become free during a specified timeout period
-	if err := retrytimeout.RetryTimeout(ms.timeout, func() error {
-		var err error
-		db, err = badger.Open(ms.bopts)
-		if err != nil {
-			return fmt.Errorf("could not open machine store: %v", err)
-		}
+	// SaveDriverConfig saves the driver config `dcfg` for the machine based on
+	// the MachineID `mid`, which must already be known to the store.
+	SaveDriverConfig(MachineID, any) error
 
-		return nil
-	}); err != nil {
-		return fmt.Errorf("could not open machine store: %v", err)
-	}
+	// LookupDriverConfig uses pass-by-reference to return the driver config for
+	// the machine defined by the MachineID `mid` to the variable `dcfg`.
+	LookupDriverConfig(MachineID, any) error
 
-	ms.db = db
+	// Purge completely removes all reference of configuration from the store
+	// based on the MachineID `mid`.
+	Purge(MachineID) error
 
-	return nil
-}
+	// ListAllMachineIDs returns a slice of all machine's saved to the store.
+	ListAllMachineIDs() ([]MachineID, error)
 
-const (
-	suffixMachineConfig = "_machineconfig"
-	suffixMachineState  = "_machinestate"
-	suffixDriverConfig  = "_driverconfig"
-)
+	// ListAllMachineConfigs returns a map of all machine configs saved in the
+	// store where the index to the map is the machine's ID.
+	ListAllMachineConfigs() (map[MachineID]MachineConfig, error)
 
-func keyMachineConfig(mid MachineID) []byte {
-	return []byte(mid.String() + suffixMachineConfig)
-}
+	// SaveMachineEvent appends the `event` to the history of machine events.
+	// Once the history exceeds its maximum size, the oldest events are
+	// discarded.
+	SaveMachineEvent(MachineEvent) error
 
-func keyMachineState(mid MachineID) []byte {
-	return []byte(mid.String() + suffixMachineState)
+	// ListMachineEvents returns the history of machine events in chronological
+	// order which occurred between `since` and `until`.  A zero-value for either
+	// bound leaves it open.
+	ListMachineEvents(since, until time.Time) ([]MachineEvent, error)
 }
 
-func keyDriverConfig(mid MachineID) []byte {
-	return []byte(mid.String() + suffixDriverConfig)
-}
+// DefaultMachineEventHistory is the number of machine events which are
+// retained in the store before the oldest entries are discarded.
+const DefaultMachineEventHistory = 1024
 
-// SaveMachineConfig saves the machine config `mcfg` for the machine based on
-// the MachineID `mid`.
-func (ms *MachineStore) SaveMachineConfig(mid MachineID, mcfg MachineConfig) error {
-	if err := ms.connect(); err != nil {
-		return err
-	}
-
-	defer ms.close()
-
-	b := bytes.Buffer{}
-	e := gob.NewEncoder(&b)
-	if err := e.Encode(mcfg); err != nil {
-		return fmt.Errorf("could not encode machine config for %s: %v", mid.ShortString(), err)
-	}
-
-	txn := ms.db.NewTransaction(true)
-	if err := txn.SetEntry(badger.NewEntry(keyMachineConfig(mid), b.Bytes())); err != nil {
-		return fmt.Errorf("could not save machine config to store for %s: %v", mid.ShortString(), err)
-	}
-
-	return txn.Commit()
-}
-
-// LookupMachineConfig uses pass-by-reference to return the machine config for
-// the machine defined by the MachineID `mid` to the variable `mcfg`.
-func (ms *MachineStore) LookupMachineConfig(mid MachineID, mcfg any) error {
-	if err := ms.connect(); err != nil {
-		return err
-	}
-
-	defer ms.close()
-
-	if err := ms.db.View(func(txn *badger.Txn) error {
-		item, err := txn.Get(keyMachineConfig(mid))
-		if err != nil {
-			return fmt.Errorf("could not access machine config from store for %s: %v", mid.ShortString(), err)
-		}
-
-		val, err := item.ValueCopy(nil)
-		if err != nil {
-			return fmt.Errorf("could not copy machine config from store for %s: %v", mid.ShortString(), err)
-		}
-
-		b := bytes.Buffer{}
-		b.Write(val)
-
-		return gob.NewDecoder(&b).Decode(mcfg)
-	}); err != nil {
-		return fmt.Errorf("could not read machine config from store for %s: %v", mid.ShortString(), err)
-	}
-
-	return nil
-}
-
-// SaveMachineState saves the machine `state` for the machine based on the
-// MachineID `mid`.
-func (ms *MachineStore) SaveMachineState(mid MachineID, state MachineState) error {
-	if err := ms.connect(); err != nil {
-		return err
-	}
-
-	defer ms.close()
-
-	txn := ms.db.NewTransaction(true)
-	if err := txn.SetEntry(badger.NewEntry(keyMachineState(mid), []byte(state.String()))); err != nil {
-		return fmt.Errorf("could not save machine state to store for %s: %v", mid.ShortString(), err)
-	}
-
-	return txn.Commit()
+type machineStoreOptions struct {
+	logger  badger.Logger
+	timeout time.Duration
+	history int
 }
 
-// LookupMachineState returns the machine state in the store for the machine
-// defined by the MachineID `mid`.
-func (ms *MachineStore) LookupMachineState(mid MachineID) (MachineState, error) {
-	state := MachineStateUnknown
-
-	if err := ms.connect(); err != nil {
-		return state, err
-	}
-
-	defer ms.close()
+type MachineStoreOption func(mso *machineStoreOptions) error
 
-	if err := ms.db.View(func(txn *badger.Txn) error {
-		item, err := txn.Get(keyMachineState(mid))
-		if err != nil {
-			return fmt.Errorf("could not access machine config from store for %s: %v", mid.ShortString(), err)
-		}
-
-		val, err := item.ValueCopy(nil)
-		if err != nil {
-			return fmt.Errorf("could not copy machine config from store for %s: %v", mid.ShortString(), err)
-		}
-
-		state = MachineState(string(val))
+// WithMachineStoreLogger sets the Badger DB logger interface which is used when
+// migrating entries from the legacy store
+func WithMachineStoreLogger(l badger.Logger) MachineStoreOption {
+	return func(mso *machineStoreOptions) error {
+		mso.logger = l
 		return nil
-	}); err != nil {
-		return MachineStateUnknown, fmt.Errorf("could not read machine config from store for %s: %v", mid.ShortString(), err)
 	}
-
-	return state, nil
 }
 
-// SaveDriverConfig saves the driver config `dcfg` for the machine based on the
-// MachineID `mid`.
-func (ms *MachineStore) SaveDriverConfig(mid MachineID, dcfg any) error {
-	if err := ms.connect(); err != nil {
-		return err
-	}
-
-	defer ms.close()
-
-	b := bytes.Buffer{}
-	if err := gob.NewEncoder(&b).Encode(dcfg); err != nil {
-		return fmt.Errorf("could not encode driver config for %s: %v", mid.ShortString(), err)
-	}
-
-	txn := ms.db.NewTransaction(true)
-	if err := txn.SetEntry(badger.NewEntry(keyDriverConfig(mid), b.Bytes())); err != nil {
-		return fmt.Errorf("could not save machine driver to store for %s: %v", mid.ShortString(), err)
+// WithMachineStoreTimeout sets a timeout to use when acquiring access to the
+// store
+func WithMachineStoreTimeout(timeout time.Duration) MachineStoreOption {
+	return func(mso *machineStoreOptions) error {
+		mso.timeout = timeout
+		return nil
 	}
-
-	return txn.Commit()
 }
 
-// LookupDriverConfig uses pass-by-reference to return the driver config for
-// the machine defined by the MachineID `mid` to the variable `dcfg`,
-func (ms *MachineStore) LookupDriverConfig(mid MachineID, dcfg any) error {
-	if err := ms.connect(); err != nil {
-		return err
-	}
-
-	defer ms.close()
-
-	if err := ms.db.View(func(txn *badger.Txn) error {
-		item, err := txn.Get(keyDriverConfig(mid))
-		if err != nil {
-			return fmt.Errorf("could not access driver config from store for %s: %v", mid.ShortString(), err)
+// WithMachineStoreEventHistory sets the maximum number of machine events which
+// are retained in the store
+func WithMachineStoreEventHistory(history int) MachineStoreOption {
+	return func(mso *machineStoreOptions) error {
+		if history <= 0 {
+			return fmt.Errorf("machine event history must be positive")
 		}
 
-		val, err := item.ValueCopy(nil)
-		if err != nil {
-			return fmt.Errorf("could not copy driver config from store for %s: %v", mid.ShortString(), err)
-		}
-
-		b := bytes.Buffer{}
-		b.Write(val)
-
-		return gob.NewDecoder(&b).Decode(dcfg)
-	}); err != nil {
-		return fmt.Errorf("could not read driver config from store for %s: %v", mid.ShortString(), err)
+		mso.history = history
+		return nil
 	}
-
-	return nil
 }
 
-// Purge completely removes all reference of configuration from the store based
-// on the MachineID `mid`.
-func (ms *MachineStore) Purge(mid MachineID) error {
-	if err := ms.connect(); err != nil {
-		return err
-	}
-
-	defer ms.close()
-
-	txn := ms.db.NewTransaction(true)
-
-	var errs []error
-
-	if err := txn.Delete([]byte(keyDriverConfig(mid))); err != nil {
-		errs = append(errs, err)
-	}
-
-	if err := txn.Delete([]byte(keyMachineConfig(mid))); err != nil {
-		errs = append(errs, err)
-	}
-
-	if err := txn.Delete([]byte(keyMachineState(mid))); err != nil {
-		errs = append(errs, err)
+// NewMachineStoreFromPath prepares a `MachineStore` to use to manipulate,
+// save, list, view, etc. in the machine store.  Entries of the legacy Badger
+// store which may exist in the same directory are migrated on first use.
+func NewMachineStoreFromPath(dir string, msopts ...MachineStoreOption) (MachineStore, error) {
+	if len(dir) == 0 {
+		dir = config.DefaultRuntimeDir
 	}
 
-	if err := txn.Commit(); err != nil {
-		errs = append(errs, err)
+	mso := &machineStoreOptions{
+		timeout: 5 * time.Second,
+		history: DefaultMachineEventHistory,
 	}
 
-	if len(errs) > 0 {
-		msg := "could not purge machine"
-		for _, err := range errs {
-			msg += ": " + err.Error()
+	for _, o := range msopts {
+		if err := o(mso); err != nil {
+			return nil, fmt.Errorf("could not apply machine store option: %v", err)
 		}
-
-		return fmt.Errorf(msg)
-	}
-
-	return nil
-}
-
-func (ms *MachineStore) close() error {
-	return ms.db.Close()
-}
-
-// ListAllMachineIDs returns a slice of all machine's saved to the store.
-func (ms *MachineStore) ListAllMachineIDs() ([]MachineID, error) {
-	if err := ms.connect(); err != nil {
-		return nil, err
 	}
 
-	defer ms.close()
-
-	found := make(map[MachineID]bool)
-
-	opt := badger.DefaultIteratorOptions
-	opt.PrefetchSize = 10
-
-	// Iterate over 1000 items
-	if err := ms.db.View(func(txn *badger.Txn) error {
-		it := txn.NewIterator(opt)
-		defer it.Close()
-
-		for it.Rewind(); it.Valid(); it.Next() {
-			mid := MachineID(it.Item().Key()[:MachineIDLen])
-			if _, ok := found[mid]; !ok {
-				found[mid] = true
-			}
-		}
-
-		return nil
-	}); err != nil {
+	ms, err := newFileMachineStore(filepath.Join(dir, "machines"), mso)
+	if err != nil {
 		return nil, err
 	}
 
-	mids := make([]MachineID, len(found))
-	i := 0
-	for k := range found {
-		mids[i] = k
-		i++
+	if err := migrateBadgerMachineStore(filepath.Join(dir, "machinestore"), ms, mso); err != nil {
+		return nil, fmt.Errorf("could not migrate legacy machine store: %v", err)
 	}
 
-	return mids, nil
+	return ms, nil
 }
 
-// ListAllMachineConfigs returns a map of all machine configs saved in the store
-// where the index to the map is the machine's ID.
-func (ms *MachineStore) ListAllMachineConfigs() (map[MachineID]MachineConfig, error) {
-	if err := ms.connect(); err != nil {
-		return nil, err
+// migrateBadgerMachineStore copies all entries of the legacy Badger store at
+// `dir` to the store `ms`.  Once complete, the legacy store is renamed such
+// that it is not migrated again but remains available as a backup.
+func migrateBadgerMachineStore(dir string, ms *fileMachineStore, mso *machineStoreOptions) error {
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return nil
 	}
 
-	defer ms.close()
+	// Only allow one process to perform the migration
+	return ms.withLock(ms.migrateLockPath(), true, func() error {
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			return nil
+		}
 
-	found := make(map[MachineID]MachineConfig)
+		legacy := newBadgerMachineStore(dir, mso)
 
-	opt := badger.DefaultIteratorOptions
-	opt.PrefetchSize = 10
+		mids, err := legacy.ListAllMachineIDs()
+		if err != nil {
+			return err
+		}
 
-	if err := ms.db.View(func(txn *badger.Txn) error {
-		var option MachineConfig
-		it := txn.NewIterator(opt)
-		defer it.Close()
+		for _, mid := range mids {
+			var mcfg MachineConfig
+			if err := legacy.LookupMachineConfig(mid, &mcfg); err != nil {
+				return err
+			}
 
-		for it.Rewind(); it.Valid(); it.Next() {
-			if string(it.Item().Key()[MachineIDLen:]) != suffixMachineConfig {
-				continue
+			if err := ms.SaveMachineConfig(mid, mcfg); err != nil {
+				return err
 			}
 
-			mid := MachineID(it.Item().Key()[:MachineIDLen])
-			if _, ok := found[mid]; !ok {
-				val, err := it.Item().ValueCopy(nil)
-				if err != nil {
+			if state, err := legacy.LookupMachineState(mid); err == nil {
+				if err := ms.SaveMachineState(mid, state); err != nil {
 					return err
 				}
+			}
 
-				b := bytes.Buffer{}
-				b.Write(val)
-
-				if gob.NewDecoder(&b).Decode(&option); err != nil {
+			if dcfg, err := legacy.lookupDriverConfigRaw(mid); err == nil {
+				if err := ms.saveDriverConfigRaw(mid, dcfg); err != nil {
 					return err
 				}
-
-				found[mid] = option
 			}
 		}
 
-		return nil
-	}); err != nil {
-		return nil, err
-	}
+		events, err := legacy.ListMachineEvents(time.Time{}, time.Time{})
+		if err != nil {
+			return err
+		}
+
+		for _, event := range events {
+			if err := ms.SaveMachineEvent(event); err != nil {
+				return err
+			}
+		}
 
-	return found, nil
+		return os.Rename(dir, dir+".migrated")
+	})
 }
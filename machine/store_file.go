@@ -0,0 +1,442 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package machine
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	fileMachineRecord = "machine.json"
+	fileMachineLock   = "machine.lock"
+	fileEvents        = "_events.jsonl"
+	fileEventsLock    = "_events.lock"
+	fileMigrateLock   = "_migrate.lock"
+)
+
+// fileMachineStore keeps each machine in a separate directory containing a
+// JSON record.  Access to each record is serialized with an advisory file lock
+// such that any number of processes can read and write to the store
+// concurrently.  Records are replaced atomically so a reader never observes a
+// partially written record.
+type fileMachineStore struct {
+	dir     string
+	timeout time.Duration
+	history int
+}
+
+// machineRecord is the on-disk representation of a machine.
+type machineRecord struct {
+	// Config is the JSON-encoded machine config.
+	Config json.RawMessage `json:"config,omitempty"`
+
+	// State is the last known state of the machine.
+	State MachineState `json:"state,omitempty"`
+
+	// Driver is the gob-encoded driver config, since it can contain interfaces
+	// which are only registered with gob.
+	Driver []byte `json:"driver,omitempty"`
+}
+
+func newFileMachineStore(dir string, msopts *machineStoreOptions) (*fileMachineStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("could not create machine store: %v", err)
+	}
+
+	return &fileMachineStore{
+		dir:     dir,
+		timeout: msopts.timeout,
+		history: msopts.history,
+	}, nil
+}
+
+func (fs *fileMachineStore) machineDir(mid MachineID) string {
+	return filepath.Join(fs.dir, mid.String())
+}
+
+func (fs *fileMachineStore) migrateLockPath() string {
+	return filepath.Join(fs.dir, fileMigrateLock)
+}
+
+// withLock acquires an advisory lock on the file at `path` for the duration of
+// `fn`.  Shared locks may be held by many readers at the same time whereas an
+// exclusive lock is held by a single writer.
+func (fs *fileMachineStore) withLock(path string, exclusive bool, fn func() error) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return fmt.Errorf("could not open lock: %w", err)
+	}
+
+	defer f.Close()
+
+	deadline := time.Now().Add(fs.timeout)
+	for {
+		err = lockFile(f, exclusive)
+		if err == nil {
+			break
+		} else if !errors.Is(err, errLockBusy) {
+			return fmt.Errorf("could not acquire lock: %v", err)
+		} else if time.Now().After(deadline) {
+			return fmt.Errorf("could not acquire lock on %s within %s", path, fs.timeout)
+		}
+
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	defer unlockFile(f)
+
+	return fn()
+}
+
+// writeFileAtomic writes `data` to a temporary file next to `path` before
+// renaming it in place.
+func writeFileAtomic(path string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
+
+func (fs *fileMachineStore) readRecord(mid MachineID) (*machineRecord, error) {
+	data, err := os.ReadFile(filepath.Join(fs.machineDir(mid), fileMachineRecord))
+	if err != nil {
+		return nil, err
+	}
+
+	record := &machineRecord{}
+	if err := json.Unmarshal(data, record); err != nil {
+		return nil, fmt.Errorf("could not decode record for %s: %v", mid.ShortString(), err)
+	}
+
+	return record, nil
+}
+
+// viewRecord reads the record of the machine `mid` under a shared lock.
+func (fs *fileMachineStore) viewRecord(mid MachineID) (*machineRecord, error) {
+	if _, err := os.Stat(fs.machineDir(mid)); err != nil {
+		return nil, err
+	}
+
+	var record *machineRecord
+
+	if err := fs.withLock(filepath.Join(fs.machineDir(mid), fileMachineLock), false, func() error {
+		var err error
+		record, err = fs.readRecord(mid)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	return record, nil
+}
+
+// updateRecord reads, modifies via `fn` and writes back the record of the
+// machine `mid` under an exclusive lock.  Only when `create` is set is the
+// record created if it does not exist.  Otherwise, the record must still exist
+// once the lock is held, since the machine may have been purged whilst waiting
+// for the lock and must not be brought back with a partial record.
+func (fs *fileMachineStore) updateRecord(mid MachineID, create bool, fn func(*machineRecord) error) error {
+	if create {
+		if err := os.MkdirAll(fs.machineDir(mid), 0o755); err != nil {
+			return err
+		}
+	} else if _, err := os.Stat(fs.machineDir(mid)); err != nil {
+		return err
+	}
+
+	return fs.withLock(filepath.Join(fs.machineDir(mid), fileMachineLock), true, func() error {
+		record, err := fs.readRecord(mid)
+		if errors.Is(err, os.ErrNotExist) && create {
+			record = &machineRecord{}
+		} else if err != nil {
+			return err
+		}
+
+		if err := fn(record); err != nil {
+			return err
+		}
+
+		data, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+
+		return writeFileAtomic(filepath.Join(fs.machineDir(mid), fileMachineRecord), data)
+	})
+}
+
+func (fs *fileMachineStore) SaveMachineConfig(mid MachineID, mcfg MachineConfig) error {
+	config, err := json.Marshal(mcfg)
+	if err != nil {
+		return fmt.Errorf("could not encode machine config for %s: %v", mid.ShortString(), err)
+	}
+
+	if err := fs.updateRecord(mid, true, func(record *machineRecord) error {
+		record.Config = config
+		return nil
+	}); err != nil {
+		return fmt.Errorf("could not save machine config to store for %s: %v", mid.ShortString(), err)
+	}
+
+	return nil
+}
+
+func (fs *fileMachineStore) LookupMachineConfig(mid MachineID, mcfg any) error {
+	record, err := fs.viewRecord(mid)
+	if err != nil {
+		return fmt.Errorf("could not read machine config from store for %s: %w", mid.ShortString(), err)
+	}
+
+	if len(record.Config) == 0 {
+		return fmt.Errorf("could not read machine config from store for %s: %w", mid.ShortString(), os.ErrNotExist)
+	}
+
+	return json.Unmarshal(record.Config, mcfg)
+}
+
+func (fs *fileMachineStore) SaveMachineState(mid MachineID, state MachineState) error {
+	if err := fs.updateRecord(mid, false, func(record *machineRecord) error {
+		record.State = state
+		return nil
+	}); err != nil {
+		return fmt.Errorf("could not save machine state to store for %s: %v", mid.ShortString(), err)
+	}
+
+	return nil
+}
+
+func (fs *fileMachineStore) LookupMachineState(mid MachineID) (MachineState, error) {
+	record, err := fs.viewRecord(mid)
+	if err != nil {
+		return MachineStateUnknown, fmt.Errorf("could not read machine state from store for %s: %w", mid.ShortString(), err)
+	}
+
+	if len(record.State) == 0 {
+		return MachineStateUnknown, fmt.Errorf("could not read machine state from store for %s: %w", mid.ShortString(), os.ErrNotExist)
+	}
+
+	return record.State, nil
+}
+
+func (fs *fileMachineStore) SaveDriverConfig(mid MachineID, dcfg any) error {
+	b := bytes.Buffer{}
+	if err := gob.NewEncoder(&b).Encode(dcfg); err != nil {
+		return fmt.Errorf("could not encode driver config for %s: %v", mid.ShortString(), err)
+	}
+
+	return fs.saveDriverConfigRaw(mid, b.Bytes())
+}
+
+// saveDriverConfigRaw saves the already encoded driver config `dcfg` for the
+// machine based on the MachineID `mid`.
+func (fs *fileMachineStore) saveDriverConfigRaw(mid MachineID, dcfg []byte) error {
+	if err := fs.updateRecord(mid, false, func(record *machineRecord) error {
+		record.Driver = dcfg
+		return nil
+	}); err != nil {
+		return fmt.Errorf("could not save machine driver to store for %s: %v", mid.ShortString(), err)
+	}
+
+	return nil
+}
+
+func (fs *fileMachineStore) LookupDriverConfig(mid MachineID, dcfg any) error {
+	record, err := fs.viewRecord(mid)
+	if err != nil {
+		return fmt.Errorf("could not read driver config from store for %s: %w", mid.ShortString(), err)
+	}
+
+	if len(record.Driver) == 0 {
+		return fmt.Errorf("could not read driver config from store for %s: %w", mid.ShortString(), os.ErrNotExist)
+	}
+
+	return gob.NewDecoder(bytes.NewReader(record.Driver)).Decode(dcfg)
+}
+
+func (fs *fileMachineStore) Purge(mid MachineID) error {
+	if _, err := os.Stat(fs.machineDir(mid)); err != nil {
+		return fmt.Errorf("could not purge machine: %v", err)
+	}
+
+	// The lock is removed alongside the record.  Updates which are waiting for
+	// the lock find the record gone once they hold it and do not recreate it.
+	if err := fs.withLock(filepath.Join(fs.machineDir(mid), fileMachineLock), true, func() error {
+		return os.RemoveAll(fs.machineDir(mid))
+	}); err != nil {
+		return fmt.Errorf("could not purge machine: %v", err)
+	}
+
+	return nil
+}
+
+func (fs *fileMachineStore) ListAllMachineIDs() ([]MachineID, error) {
+	entries, err := os.ReadDir(fs.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var mids []MachineID
+
+	for _, entry := range entries {
+		if !entry.IsDir() || len(entry.Name()) != MachineIDLen {
+			continue
+		}
+
+		mids = append(mids, MachineID(entry.Name()))
+	}
+
+	return mids, nil
+}
+
+func (fs *fileMachineStore) ListAllMachineConfigs() (map[MachineID]MachineConfig, error) {
+	mids, err := fs.ListAllMachineIDs()
+	if err != nil {
+		return nil, err
+	}
+
+	found := make(map[MachineID]MachineConfig)
+
+	for _, mid := range mids {
+		var mcfg MachineConfig
+		if err := fs.LookupMachineConfig(mid, &mcfg); err != nil {
+			// The machine may have been purged or not yet saved in the meantime
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+
+			return nil, err
+		}
+
+		found[mid] = mcfg
+	}
+
+	return found, nil
+}
+
+func (fs *fileMachineStore) readEvents() ([]MachineEvent, error) {
+	f, err := os.Open(filepath.Join(fs.dir, fileEvents))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	var events []MachineEvent
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var event MachineEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return nil, fmt.Errorf("could not decode machine event: %v", err)
+		}
+
+		events = append(events, event)
+	}
+
+	return events, scanner.Err()
+}
+
+func (fs *fileMachineStore) SaveMachineEvent(event MachineEvent) error {
+	return fs.withLock(filepath.Join(fs.dir, fileEventsLock), true, func() error {
+		events, err := fs.readEvents()
+		if err != nil {
+			return err
+		}
+
+		events = append(events, event)
+		if len(events) > fs.history {
+			events = events[len(events)-fs.history:]
+		}
+
+		var b bytes.Buffer
+		e := json.NewEncoder(&b)
+		for _, event := range events {
+			if err := e.Encode(event); err != nil {
+				return fmt.Errorf("could not encode machine event for %s: %v", event.ID.ShortString(), err)
+			}
+		}
+
+		return writeFileAtomic(filepath.Join(fs.dir, fileEvents), b.Bytes())
+	})
+}
+
+func (fs *fileMachineStore) ListMachineEvents(since, until time.Time) ([]MachineEvent, error) {
+	var events []MachineEvent
+
+	if err := fs.withLock(filepath.Join(fs.dir, fileEventsLock), false, func() error {
+		all, err := fs.readEvents()
+		if err != nil {
+			return err
+		}
+
+		for _, event := range all {
+			if !since.IsZero() && event.Time.Before(since) {
+				continue
+			}
+
+			if !until.IsZero() && event.Time.After(until) {
+				break
+			}
+
+			events = append(events, event)
+		}
+
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("could not read machine events from store: %v", err)
+	}
+
+	return events, nil
+}
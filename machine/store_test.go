@@ -0,0 +1,337 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package machine
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	envStoreHelperDir = "KRAFTKIT_TEST_MACHINESTORE_DIR"
+	envStoreHelperID  = "KRAFTKIT_TEST_MACHINESTORE_WORKER"
+	storeHelperOps    = 20
+)
+
+// TestMachineStoreHelperProcess is not a real test.  It is executed as a
+// separate process by TestMachineStoreConcurrentProcesses to act as one of many
+// concurrent users of the same store.
+func TestMachineStoreHelperProcess(t *testing.T) {
+	dir := os.Getenv(envStoreHelperDir)
+	if len(dir) == 0 {
+		t.Skip("helper process")
+	}
+
+	worker, err := strconv.Atoi(os.Getenv(envStoreHelperID))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := NewMachineStoreFromPath(dir, WithMachineStoreTimeout(30*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < storeHelperOps; i++ {
+		mid, err := NewRandomMachineID()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		mcfg := MachineConfig{
+			ID:         mid,
+			Name:       MachineName(fmt.Sprintf("worker-%d-%d", worker, i)),
+			DriverName: "qemu",
+			MemorySize: uint64(i),
+		}
+
+		if err := store.SaveMachineConfig(mid, mcfg); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := store.SaveMachineState(mid, MachineStateRunning); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := store.SaveMachineEvent(NewMachineEvent(MachineEventCreate, mcfg, MachineStateRunning)); err != nil {
+			t.Fatal(err)
+		}
+
+		// Read back everything, including the machines of all other workers
+		if _, err := store.ListAllMachineConfigs(); err != nil {
+			t.Fatal(err)
+		}
+
+		var found MachineConfig
+		if err := store.LookupMachineConfig(mid, &found); err != nil {
+			t.Fatal(err)
+		}
+
+		if found.Name != mcfg.Name || found.MemorySize != mcfg.MemorySize {
+			t.Fatalf("unexpected machine config for %s: %+v", mid, found)
+		}
+	}
+}
+
+func TestMachineStoreConcurrentProcesses(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping multi-process stress test in short mode")
+	}
+
+	const workers = 16
+
+	dir := t.TempDir()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+
+		go func(worker int) {
+			defer wg.Done()
+
+			cmd := exec.Command(os.Args[0], "-test.run=^TestMachineStoreHelperProcess$")
+			cmd.Env = append(os.Environ(),
+				envStoreHelperDir+"="+dir,
+				envStoreHelperID+"="+strconv.Itoa(worker),
+			)
+
+			if out, err := cmd.CombinedOutput(); err != nil {
+				errs <- fmt.Errorf("worker %d: %v: %s", worker, err, out)
+			}
+		}(i)
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+
+	store, err := NewMachineStoreFromPath(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mcfgs, err := store.ListAllMachineConfigs()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(mcfgs) != workers*storeHelperOps {
+		t.Errorf("expected %d machines, got %d", workers*storeHelperOps, len(mcfgs))
+	}
+
+	for mid := range mcfgs {
+		state, err := store.LookupMachineState(mid)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if state != MachineStateRunning {
+			t.Errorf("unexpected state for %s: %s", mid.ShortString(), state)
+		}
+	}
+
+	events, err := store.ListMachineEvents(time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(events) != workers*storeHelperOps {
+		t.Errorf("expected %d events, got %d", workers*storeHelperOps, len(events))
+	}
+}
+
+func TestMachineStoreEventHistory(t *testing.T) {
+	store, err := NewMachineStoreFromPath(t.TempDir(), WithMachineStoreEventHistory(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mid, err := NewRandomMachineID()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		event := NewMachineEvent(MachineEventStart, MachineConfig{ID: mid}, MachineStateRunning)
+		event.Time = start.Add(time.Duration(i) * time.Second)
+
+		if err := store.SaveMachineEvent(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	events, err := store.ListMachineEvents(time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(events) != 3 || !events[0].Time.Equal(start.Add(2*time.Second)) {
+		t.Fatalf("expected the 3 most recent events, got %+v", events)
+	}
+
+	events, err = store.ListMachineEvents(start.Add(3*time.Second), start.Add(3*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(events) != 1 {
+		t.Fatalf("expected 1 event within bounds, got %d", len(events))
+	}
+}
+
+func TestMachineStorePurge(t *testing.T) {
+	store, err := NewMachineStoreFromPath(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mid, err := NewRandomMachineID()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.SaveMachineState(mid, MachineStateRunning); err == nil {
+		t.Errorf("expected state of unknown machine to be refused")
+	}
+
+	if err := store.SaveMachineConfig(mid, MachineConfig{ID: mid}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Race updates of the machine against its removal
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < 20; j++ {
+				_ = store.SaveMachineState(mid, MachineStateRunning)
+			}
+		}()
+	}
+
+	if err := store.Purge(mid); err != nil {
+		t.Fatal(err)
+	}
+
+	wg.Wait()
+
+	mids, err := store.ListAllMachineIDs()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(mids) != 0 {
+		t.Errorf("expected purged machine not to be recreated, got %v", mids)
+	}
+
+	if err := store.SaveMachineState(mid, MachineStateExited); err == nil {
+		t.Errorf("expected state of purged machine to be refused")
+	}
+}
+
+func TestMachineStoreMigrateBadger(t *testing.T) {
+	dir := t.TempDir()
+
+	legacy := newBadgerMachineStore(filepath.Join(dir, "machinestore"), &machineStoreOptions{
+		timeout: 5 * time.Second,
+		history: DefaultMachineEventHistory,
+	})
+
+	mid, err := NewRandomMachineID()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mcfg := MachineConfig{ID: mid, Name: "legacy", DriverName: "qemu"}
+	if err := legacy.SaveMachineConfig(mid, mcfg); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := legacy.SaveMachineState(mid, MachineStateExited); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := legacy.SaveDriverConfig(mid, []string{"-kernel", "kernel"}); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := NewMachineStoreFromPath(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var found MachineConfig
+	if err := store.LookupMachineConfig(mid, &found); err != nil {
+		t.Fatal(err)
+	}
+
+	if found.Name != mcfg.Name {
+		t.Errorf("unexpected migrated name: %s", found.Name)
+	}
+
+	state, err := store.LookupMachineState(mid)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if state != MachineStateExited {
+		t.Errorf("unexpected migrated state: %s", state)
+	}
+
+	var dcfg []string
+	if err := store.LookupDriverConfig(mid, &dcfg); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(dcfg) != 2 || dcfg[1] != "kernel" {
+		t.Errorf("unexpected migrated driver config: %v", dcfg)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "machinestore")); !os.IsNotExist(err) {
+		t.Errorf("expected legacy store to be moved aside")
+	}
+}
@@ -0,0 +1,276 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+// Package symbolize resolves instruction addresses found in the console output
+// of a crashed unikernel to functions and source locations using the symbol
+// table and DWARF debug information of its kernel image.
+package symbolize
+
+import (
+	"debug/dwarf"
+	"debug/elf"
+	"fmt"
+	"sort"
+	"strings"
+
+	"kraftkit.sh/machine"
+)
+
+// Location is the resolved source location of an instruction address.
+type Location struct {
+	Address  uint64
+	Function string
+	Offset   uint64
+	File     string
+	Line     int
+}
+
+// String returns the location in the format `function+0xoffset (file:line)`.
+func (l Location) String() string {
+	var ret strings.Builder
+
+	if len(l.Function) > 0 {
+		ret.WriteString(fmt.Sprintf("%s+0x%x", l.Function, l.Offset))
+	} else {
+		ret.WriteString("??")
+	}
+
+	if len(l.File) > 0 {
+		ret.WriteString(fmt.Sprintf(" (%s:%d)", l.File, l.Line))
+	}
+
+	return ret.String()
+}
+
+type function struct {
+	name string
+	low  uint64
+	high uint64
+}
+
+type lineEntry struct {
+	address uint64
+	file    string
+	line    int
+	end     bool
+}
+
+// Symbolizer resolves addresses against a kernel image.  Kernels are expected
+// to be statically linked such that no relocation of addresses is necessary.
+type Symbolizer struct {
+	text      [][2]uint64
+	functions []function
+	lines     []lineEntry
+}
+
+// New loads the symbols and, if available, the DWARF line information of the
+// ELF kernel image at `path`.
+func New(path string) (*Symbolizer, error) {
+	f, err := elf.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open kernel: %v", err)
+	}
+
+	defer f.Close()
+
+	s := &Symbolizer{}
+
+	for _, section := range f.Sections {
+		if section.Type == elf.SHT_PROGBITS && section.Flags&elf.SHF_EXECINSTR != 0 {
+			s.text = append(s.text, [2]uint64{section.Addr, section.Addr + section.Size})
+		}
+	}
+
+	if symbols, err := f.Symbols(); err == nil {
+		for _, symbol := range symbols {
+			if elf.ST_TYPE(symbol.Info) != elf.STT_FUNC || symbol.Value == 0 {
+				continue
+			}
+
+			s.functions = append(s.functions, function{
+				name: symbol.Name,
+				low:  symbol.Value,
+				high: symbol.Value + symbol.Size,
+			})
+		}
+	}
+
+	// Functions described by DWARF are loaded last such that they sort after
+	// symbols at the same address, which lets them take precedence
+	if data, err := f.DWARF(); err == nil {
+		if err := s.loadDWARF(data); err != nil {
+			return nil, fmt.Errorf("could not read debug information: %v", err)
+		}
+	}
+
+	if len(s.functions) == 0 && len(s.lines) == 0 {
+		return nil, fmt.Errorf("%s contains neither symbols nor debug information", path)
+	}
+
+	sort.SliceStable(s.functions, func(i, j int) bool {
+		return s.functions[i].low < s.functions[j].low
+	})
+
+	// End of sequence markers sort before entries at the same address such that
+	// the start of an adjacent sequence is found
+	sort.SliceStable(s.lines, func(i, j int) bool {
+		if s.lines[i].address == s.lines[j].address {
+			return s.lines[i].end && !s.lines[j].end
+		}
+		return s.lines[i].address < s.lines[j].address
+	})
+
+	return s, nil
+}
+
+// loadDWARF collects the address ranges of all functions and the line table
+// of every compilation unit.
+func (s *Symbolizer) loadDWARF(data *dwarf.Data) error {
+	reader := data.Reader()
+
+	for {
+		entry, err := reader.Next()
+		if err != nil {
+			return err
+		}
+		if entry == nil {
+			break
+		}
+
+		switch entry.Tag {
+		case dwarf.TagCompileUnit:
+			lr, err := data.LineReader(entry)
+			if err != nil {
+				return err
+			}
+			if lr == nil {
+				continue
+			}
+
+			var le dwarf.LineEntry
+			for {
+				if err := lr.Next(&le); err != nil {
+					break
+				}
+
+				var file string
+				if le.File != nil {
+					file = le.File.Name
+				}
+
+				s.lines = append(s.lines, lineEntry{
+					address: le.Address,
+					file:    file,
+					line:    le.Line,
+					end:     le.EndSequence,
+				})
+			}
+
+		case dwarf.TagSubprogram:
+			name, ok := entry.Val(dwarf.AttrName).(string)
+			if !ok {
+				continue
+			}
+
+			ranges, err := data.Ranges(entry)
+			if err != nil {
+				continue
+			}
+
+			for _, r := range ranges {
+				s.functions = append(s.functions, function{
+					name: name,
+					low:  r[0],
+					high: r[1],
+				})
+			}
+		}
+	}
+
+	return nil
+}
+
+// IsText returns whether the address lies within an executable section of the
+// kernel.
+func (s *Symbolizer) IsText(addr uint64) bool {
+	for _, r := range s.text {
+		if addr >= r[0] && addr < r[1] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Resolve returns the function and source location of the address.  The
+// boolean is false if neither could be determined.
+func (s *Symbolizer) Resolve(addr uint64) (Location, bool) {
+	loc := Location{Address: addr}
+
+	// Find the last function starting at or before the address and then the
+	// closest one which contains it, as functions may overlap.  Of a function
+	// which is both described by DWARF and a symbol, the former sorts last.
+	i := sort.Search(len(s.functions), func(i int) bool {
+		return s.functions[i].low > addr
+	}) - 1
+
+	for j := i; j >= 0 && j > i-16; j-- {
+		fn := s.functions[j]
+		if addr < fn.high || (fn.high == fn.low && j == i) {
+			loc.Function = fn.name
+			loc.Offset = addr - fn.low
+			break
+		}
+	}
+
+	i = sort.Search(len(s.lines), func(i int) bool {
+		return s.lines[i].address > addr
+	}) - 1
+
+	if i >= 0 && !s.lines[i].end {
+		loc.File = s.lines[i].file
+		loc.Line = s.lines[i].line
+	}
+
+	return loc, len(loc.Function) > 0 || len(loc.File) > 0
+}
+
+// NewFromMachineConfig loads the unstripped kernel of the machine, falling back
+// to the kernel it was booted with should it contain symbols itself.
+func NewFromMachineConfig(mcfg machine.MachineConfig) (*Symbolizer, error) {
+	if len(mcfg.KernelDbgPath) > 0 {
+		if s, err := New(mcfg.KernelDbgPath); err == nil {
+			return s, nil
+		}
+	}
+
+	return New(mcfg.KernelPath)
+}
@@ -0,0 +1,221 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package symbolize
+
+import (
+	"bytes"
+	"debug/elf"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testProgram = `
+int crash(int x)
+{
+	return *(volatile int *)0 + x;
+}
+
+int main(void)
+{
+	return crash(1);
+}
+`
+
+// buildKernel compiles a small program with debug information to stand in for
+// a kernel image and returns its path and the address of `crash`.
+func buildKernel(t *testing.T) (string, uint64) {
+	t.Helper()
+
+	return buildProgram(t, testProgram, "crash")
+}
+
+// buildProgram compiles the C `program` with debug information and returns the
+// path of the executable and the address of the symbol `name`.
+func buildProgram(t *testing.T, program, name string) (string, uint64) {
+	t.Helper()
+
+	cc, err := exec.LookPath("cc")
+	if err != nil {
+		t.Skip("no C compiler available")
+	}
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "prog.c")
+	if err := os.WriteFile(src, []byte(program), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	kernel := filepath.Join(dir, "kernel")
+	if out, err := exec.Command(cc, "-g", "-O0", "-no-pie", "-o", kernel, src).CombinedOutput(); err != nil {
+		t.Skipf("could not compile test program: %v: %s", err, out)
+	}
+
+	f, err := elf.Open(kernel)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer f.Close()
+
+	symbols, err := f.Symbols()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, symbol := range symbols {
+		if symbol.Name == name {
+			return kernel, symbol.Value
+		}
+	}
+
+	t.Fatalf("could not find %s symbol", name)
+	return "", 0
+}
+
+func TestResolve(t *testing.T) {
+	kernel, addr := buildKernel(t)
+
+	s, err := New(kernel)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loc, ok := s.Resolve(addr + 4)
+	if !ok {
+		t.Fatalf("could not resolve 0x%x", addr+4)
+	}
+
+	if loc.Function != "crash" || loc.Offset != 4 {
+		t.Errorf("unexpected function: %s+0x%x", loc.Function, loc.Offset)
+	}
+
+	if filepath.Base(loc.File) != "prog.c" || loc.Line < 2 || loc.Line > 5 {
+		t.Errorf("unexpected source location: %s:%d", loc.File, loc.Line)
+	}
+
+	if !s.IsText(addr) || s.IsText(0) {
+		t.Errorf("unexpected text section bounds")
+	}
+}
+
+func TestResolvePrefersDWARF(t *testing.T) {
+	// The symbol of the function is renamed such that it differs from the name
+	// of the function in its debug information
+	kernel, addr := buildProgram(t, `
+int crash(int x) __asm__("crash_symbol");
+
+int crash(int x)
+{
+	return *(volatile int *)0 + x;
+}
+
+int main(void)
+{
+	return crash(1);
+}
+`, "crash_symbol")
+
+	s, err := New(kernel)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loc, ok := s.Resolve(addr + 4)
+	if !ok {
+		t.Fatalf("could not resolve 0x%x", addr+4)
+	}
+
+	if loc.Function != "crash" {
+		t.Errorf("expected function described by DWARF, got %s", loc.Function)
+	}
+}
+
+func TestNewInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "kernel")
+	if err := os.WriteFile(path, []byte("not an ELF"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := New(path); err == nil {
+		t.Errorf("expected error for invalid kernel")
+	}
+}
+
+func TestWriter(t *testing.T) {
+	kernel, addr := buildKernel(t)
+
+	s, err := New(kernel)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	w := NewWriter(&out, s)
+
+	input := fmt.Sprintf("Booting...\r\nCRIT: RIP: 0008:%016x\r\nRAX: 0000000000000000 RBX: 0000000000000001\r\nStack:\n [0x%x]", addr+8, addr+12)
+
+	// Split the output across writes as received from a console
+	for _, chunk := range []string{input[:15], input[15:40], input[40:]} {
+		if n, err := w.Write([]byte(chunk)); err != nil || n != len(chunk) {
+			t.Fatalf("unexpected write: %d, %v", n, err)
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(out.String(), "\n")
+	expected := []string{
+		"Booting...\r",
+		fmt.Sprintf("CRIT: RIP: 0008:%016x\r", addr+8),
+		fmt.Sprintf("    %016x: crash+0x8 ", addr+8),
+		"RAX: 0000000000000000 RBX: 0000000000000001\r",
+		"Stack:",
+		fmt.Sprintf(" [0x%x]", addr+12),
+		fmt.Sprintf("    %016x: crash+0xc ", addr+12),
+	}
+
+	if len(lines) != len(expected)+1 {
+		t.Fatalf("unexpected output:\n%s", out.String())
+	}
+
+	for i, prefix := range expected {
+		if !strings.HasPrefix(lines[i], prefix) {
+			t.Errorf("line %d: expected prefix %q, got %q", i, prefix, lines[i])
+		}
+	}
+}
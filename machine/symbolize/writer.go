@@ -0,0 +1,177 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package symbolize
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"sync"
+)
+
+// address matches a hexadecimal address which is either prefixed with `0x` or
+// zero-padded to at least 8 digits
+const address = `(?:0x[0-9a-f]{1,16}|[0-9a-f]{8,16})`
+
+var (
+	// registerPattern matches the instruction pointer or link register in a
+	// register dump, e.g. `RIP: 0008:000000000010a3b7` or `PC=0x10a3b7`
+	registerPattern = regexp.MustCompile(`(?i)\b(?:rip|eip|ip|pc|lr|elr(?:_el[0-3])?|ra)\s*[:=]\s*(?:[0-9a-f]{4}:)?` + address + `\b`)
+
+	// stackPattern matches a line of a stack dump, e.g.
+	// `000000000041ffa8: 000000000010a3b7 0000000000000000`
+	stackPattern = regexp.MustCompile(`(?i)\b` + address + `:\s+` + address + `\b`)
+
+	// tracePattern matches a frame of a backtrace, e.g. `[0x10a3b7]`
+	tracePattern = regexp.MustCompile(`(?i)\[<?` + address + `>?\]`)
+
+	// addressPattern captures the digits of any address
+	addressPattern = regexp.MustCompile(`(?i)\b(?:0x([0-9a-f]{1,16})|([0-9a-f]{8,16}))\b`)
+)
+
+// Writer passes console output through to an underlying writer unmodified and
+// follows every line of a register dump, stack dump or backtrace with the
+// resolved locations of the instruction addresses it contains.
+type Writer struct {
+	mu   sync.Mutex
+	w    io.Writer
+	s    *Symbolizer
+	line []byte
+}
+
+// NewWriter returns a Writer which symbolizes the output written to `w`.
+func NewWriter(w io.Writer, s *Symbolizer) *Writer {
+	return &Writer{w: w, s: s}
+}
+
+// Write passes `p` to the underlying writer immediately and annotates each
+// line which has been completed.
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	n := len(p)
+
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			if _, err := w.w.Write(p); err != nil {
+				return 0, err
+			}
+
+			w.line = append(w.line, p...)
+			break
+		}
+
+		if _, err := w.w.Write(p[:i+1]); err != nil {
+			return 0, err
+		}
+
+		w.line = append(w.line, p[:i]...)
+		if err := w.annotate(); err != nil {
+			return 0, err
+		}
+
+		p = p[i+1:]
+	}
+
+	return n, nil
+}
+
+// Flush annotates a final line which was not terminated by a newline.
+func (w *Writer) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.line) == 0 || len(Annotate(w.s, w.line)) == 0 {
+		w.line = w.line[:0]
+		return nil
+	}
+
+	if _, err := w.w.Write([]byte("\n")); err != nil {
+		return err
+	}
+
+	return w.annotate()
+}
+
+func (w *Writer) annotate() error {
+	defer func() {
+		w.line = w.line[:0]
+	}()
+
+	for _, annotation := range Annotate(w.s, w.line) {
+		if _, err := fmt.Fprintln(w.w, annotation); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Annotate returns the resolved locations of the instruction addresses within
+// `line` if it is part of a register dump, stack dump or backtrace.
+func Annotate(s *Symbolizer, line []byte) []string {
+	line = bytes.TrimRight(line, "\r")
+
+	if !registerPattern.Match(line) && !stackPattern.Match(line) && !tracePattern.Match(line) {
+		return nil
+	}
+
+	var annotations []string
+	seen := make(map[uint64]bool)
+
+	for _, match := range addressPattern.FindAllSubmatch(line, -1) {
+		digits := match[1]
+		if len(digits) == 0 {
+			digits = match[2]
+		}
+
+		addr, err := strconv.ParseUint(string(digits), 16, 64)
+		if err != nil || seen[addr] || !s.IsText(addr) {
+			continue
+		}
+
+		seen[addr] = true
+
+		loc, ok := s.Resolve(addr)
+		if !ok {
+			continue
+		}
+
+		annotations = append(annotations, fmt.Sprintf("    %016x: %s", addr, loc))
+	}
+
+	return annotations
+}
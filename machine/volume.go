@@ -0,0 +1,96 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package machine
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// MachineVolume describes a directory of the host which is shared with the
+// guest.
+type MachineVolume struct {
+	// Source is the path of the directory on the host.
+	Source string `json:"source"`
+
+	// Destination is the path within the guest where the directory is mounted.
+	Destination string `json:"destination"`
+
+	// ReadOnly indicates whether the guest is prevented from modifying the
+	// directory.
+	ReadOnly bool `json:"read_only,omitempty"`
+}
+
+// ParseMachineVolume parses a volume of the form SOURCE:DESTINATION[:ro|rw].
+func ParseMachineVolume(volume string) (MachineVolume, error) {
+	parts := strings.Split(volume, ":")
+	if len(parts) < 2 || len(parts) > 3 || len(parts[0]) == 0 {
+		return MachineVolume{}, fmt.Errorf("invalid volume, expected SOURCE:DESTINATION[:ro|rw]: %s", volume)
+	}
+
+	mv := MachineVolume{
+		Source:      parts[0],
+		Destination: parts[1],
+	}
+
+	if !path.IsAbs(mv.Destination) {
+		return mv, fmt.Errorf("volume destination must be an absolute path: %s", volume)
+	}
+
+	if len(parts) == 3 {
+		switch parts[2] {
+		case "ro":
+			mv.ReadOnly = true
+		case "rw":
+		default:
+			return mv, fmt.Errorf("invalid volume mode, expected ro or rw: %s", volume)
+		}
+	}
+
+	return mv, nil
+}
+
+// String returns the volume in the form accepted by ParseMachineVolume.
+func (mv MachineVolume) String() string {
+	if mv.ReadOnly {
+		return mv.Source + ":" + mv.Destination + ":ro"
+	}
+
+	return mv.Source + ":" + mv.Destination
+}
+
+// VolumeTag returns the tag which identifies the volume at index `i` of the
+// machine's volumes to the guest.
+func VolumeTag(i int) string {
+	return fmt.Sprintf("fs%d", i)
+}
@@ -0,0 +1,87 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package machine
+
+import (
+	"testing"
+)
+
+func TestParseMachineVolume(t *testing.T) {
+	tests := []struct {
+		volume   string
+		expected MachineVolume
+		err      bool
+	}{
+		{volume: "./data:/data", expected: MachineVolume{Source: "./data", Destination: "/data"}},
+		{volume: "/srv:/srv:ro", expected: MachineVolume{Source: "/srv", Destination: "/srv", ReadOnly: true}},
+		{volume: "/srv:/srv:rw", expected: MachineVolume{Source: "/srv", Destination: "/srv"}},
+		{volume: "/srv", err: true},
+		{volume: ":/srv", err: true},
+		{volume: "/srv:srv", err: true},
+		{volume: "/srv:/srv:rx", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.volume, func(t *testing.T) {
+			volume, err := ParseMachineVolume(tt.volume)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", volume)
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			if volume != tt.expected {
+				t.Errorf("expected %+v, got %+v", tt.expected, volume)
+			}
+		})
+	}
+}
+
+func TestWithVolumes(t *testing.T) {
+	dir := t.TempDir()
+
+	mcfg, err := NewMachineConfig(WithVolumes([]MachineVolume{{Source: dir, Destination: "/data"}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cmdline := mcfg.Cmdline(); cmdline != "vfs.fstab=[ fs0:/data:9pfs ]" {
+		t.Errorf("unexpected command-line: %s", cmdline)
+	}
+
+	if _, err := NewMachineConfig(WithVolumes([]MachineVolume{{Source: dir + "/missing", Destination: "/data"}})); err == nil {
+		t.Errorf("expected missing volume source to be rejected")
+	}
+}
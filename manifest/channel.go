@@ -39,4 +39,8 @@ type ManifestChannel struct {
 	Resource string `yaml:"resource"`
 	Sha256   string `yaml:"sha256,omitempty"`
 	Local    string `yaml:"-"`
+
+	// Dependencies are the components which this channel requires in addition
+	// to the dependencies of the manifest
+	Dependencies []ManifestDependency `yaml:"dependencies,omitempty"`
 }
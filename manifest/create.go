@@ -0,0 +1,417 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package manifest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-git/v5"
+	gitplumbing "github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/storage/memory"
+
+	"kraftkit.sh/internal/ghrepo"
+	"kraftkit.sh/internal/version"
+	"kraftkit.sh/log"
+	"kraftkit.sh/unikraft"
+)
+
+// CreateOption is an option of the creation of a manifest from a repository
+type CreateOption func(*createOptions)
+
+type createOptions struct {
+	ctype      unikraft.ComponentType
+	name       string
+	archiveURL string
+	noChecksum bool
+	log        log.Logger
+}
+
+// WithCreateType sets the type of the component instead of determining it
+// from the repository
+func WithCreateType(t unikraft.ComponentType) CreateOption {
+	return func(copts *createOptions) {
+		copts.ctype = t
+	}
+}
+
+// WithCreateName sets the name of the component instead of determining it
+// from the repository
+func WithCreateName(name string) CreateOption {
+	return func(copts *createOptions) {
+		copts.name = name
+	}
+}
+
+// WithCreateArchiveURL sets the URL of the archives of the repository, where
+// `{ref}` is replaced by the branch, tag or commit of each channel and
+// version.  Archives of repositories hosted on GitHub are used by default.
+func WithCreateArchiveURL(archiveURL string) CreateOption {
+	return func(copts *createOptions) {
+		copts.archiveURL = archiveURL
+	}
+}
+
+// WithCreateNoChecksum skips downloading the archive of each version to
+// compute its checksum
+func WithCreateNoChecksum(noChecksum bool) CreateOption {
+	return func(copts *createOptions) {
+		copts.noChecksum = noChecksum
+	}
+}
+
+// WithCreateLogger sets the logger which reports the progress
+func WithCreateLogger(l log.Logger) CreateOption {
+	return func(copts *createOptions) {
+		copts.log = l
+	}
+}
+
+var (
+	// Makefile.uk registers libraries, including applications, and platforms
+	// with Unikraft's build system, e.g. `$(eval $(call addlib_s,libmusl,...))`
+	makefileLibRegex  = regexp.MustCompile(`\$\(call\s+addlib(?:_s)?\s*,\s*(app|lib)?([A-Za-z0-9_]+)`)
+	makefilePlatRegex = regexp.MustCompile(`\$\(call\s+addplat(?:_s)?\s*,\s*([A-Za-z0-9_]+)`)
+
+	// Config.uk declares the KConfig menu of the component, e.g.
+	// `menuconfig LIBMUSL` followed by its prompt
+	configSymbolRegex = regexp.MustCompile(`(?m)^\s*(?:menu)?config\s+(APP|LIB)?([A-Za-z0-9_]+)\s*$`)
+	configPromptRegex = regexp.MustCompile(`(?m)^\s*(?:menu)?config\s+[A-Za-z0-9_]+\s*\n\s*bool\s+"([^"]*)"`)
+	configMainRegex   = regexp.MustCompile(`(?m)^\s*mainmenu\s`)
+)
+
+// NewManifestFromRepository creates a manifest of the Unikraft component
+// within a local directory or a remote Git repository.  Branches become
+// channels and tags become versions of the manifest, whose resources are the
+// archives of the repository with their checksums.  The type and name of the
+// component are determined from its `Makefile.uk` and `Config.uk`.
+func NewManifestFromRepository(source string, copts ...CreateOption) (*Manifest, error) {
+	opts := createOptions{}
+	for _, o := range copts {
+		o(&opts)
+	}
+
+	var provider GitProvider
+	var readFile func(string) ([]byte, error)
+
+	if f, err := os.Stat(source); err == nil && f.IsDir() {
+		repo, err := git.PlainOpen(source)
+		if err != nil {
+			return nil, fmt.Errorf("could not open Git repository: %v", err)
+		}
+
+		iter, err := repo.References()
+		if err != nil {
+			return nil, fmt.Errorf("could not list references of %s: %v", source, err)
+		}
+
+		var refs []*gitplumbing.Reference
+		if err := iter.ForEach(func(ref *gitplumbing.Reference) error {
+			refs = append(refs, ref)
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+
+		// The repository is published via its origin
+		origin := source
+		if remote, err := repo.Remote("origin"); err == nil && len(remote.Config().URLs) > 0 {
+			origin = remote.Config().URLs[0]
+		} else if len(opts.archiveURL) == 0 {
+			return nil, fmt.Errorf("could not determine origin of %s: set the URL of its archives", source)
+		}
+
+		provider = GitProvider{
+			repo: origin,
+			refs: refs,
+		}
+
+		readFile = func(name string) ([]byte, error) {
+			return os.ReadFile(filepath.Join(source, name))
+		}
+	} else {
+		p, err := NewGitProvider(source)
+		if err != nil {
+			return nil, err
+		}
+
+		provider = p.(GitProvider)
+
+		// Retrieve the tip of the default branch to read the metadata of the
+		// component
+		fs := memfs.New()
+		if _, err := git.Clone(memory.NewStorage(), fs, &git.CloneOptions{
+			URL:          provider.repo,
+			Depth:        1,
+			SingleBranch: true,
+		}); err != nil {
+			return nil, fmt.Errorf("could not clone %s: %v", provider.repo, err)
+		}
+
+		readFile = func(name string) ([]byte, error) {
+			f, err := fs.Open(name)
+			if err != nil {
+				return nil, err
+			}
+
+			defer f.Close()
+
+			return io.ReadAll(f)
+		}
+	}
+
+	t, name, description := guessComponent(provider.repo, readFile)
+	if len(opts.ctype) > 0 {
+		t = opts.ctype
+	}
+	if len(opts.name) > 0 {
+		name = opts.name
+	}
+
+	if t == unikraft.ComponentTypeUnknown || len(name) == 0 {
+		return nil, fmt.Errorf("could not determine type and name of component in %s", source)
+	}
+
+	manifest := &Manifest{
+		Name:        name,
+		Type:        t,
+		Description: description,
+		Origin:      provider.repo,
+		log:         opts.log,
+	}
+
+	channels, err := provider.probeChannels()
+	if err != nil {
+		return nil, err
+	}
+
+	versions, err := provider.probeVersions()
+	if err != nil {
+		return nil, err
+	}
+
+	// Without the staging and stable convention, the branch which the
+	// repository checks out by default is the default channel
+	if head := headBranch(provider.refs); len(head) > 0 {
+		haveDefault := false
+		for _, channel := range channels {
+			haveDefault = haveDefault || channel.Default
+		}
+
+		for i, channel := range channels {
+			if !haveDefault && channel.Name == head {
+				channels[i].Default = true
+			}
+		}
+	}
+
+	archive := archiveFunc(provider.repo, opts.archiveURL)
+
+	for i, channel := range channels {
+		if archive != nil {
+			channels[i].Resource = archive(channel.Name, "heads")
+		}
+	}
+
+	for i, ver := range versions {
+		if archive == nil {
+			continue
+		}
+
+		if ver.Type == ManifestVersionGitSha {
+			versions[i].Resource = archive(ver.Version, "")
+		} else {
+			versions[i].Resource = archive(ver.Version, "tags")
+		}
+
+		// Branches move, so only the archives of versions have a checksum
+		if opts.noChecksum {
+			continue
+		}
+
+		if opts.log != nil {
+			opts.log.Infof("computing checksum of %s", versions[i].Resource)
+		}
+
+		versions[i].Sha256, err = sha256URL(versions[i].Resource)
+		if err != nil {
+			return nil, fmt.Errorf("could not compute checksum of %s: %v", ver.Version, err)
+		}
+	}
+
+	if archive == nil && opts.log != nil {
+		opts.log.Warnf("resources of %s are Git repositories which have no checksum", provider.repo)
+	}
+
+	manifest.Channels = channels
+	manifest.Versions = versions
+
+	return manifest, nil
+}
+
+// guessComponent determines the type, name and description of the component
+// from its `Makefile.uk` and `Config.uk`, falling back to the name of its
+// repository
+func guessComponent(repo string, readFile func(string) ([]byte, error)) (unikraft.ComponentType, string, string) {
+	base := strings.TrimSuffix(filepath.Base(repo), ".git")
+	t, name, _, _ := unikraft.GuessTypeNameVersion(base)
+
+	var description string
+	var symbol string
+	var prefix string
+
+	if config, err := readFile(unikraft.Config_uk); err == nil {
+		if configMainRegex.Match(config) {
+			return unikraft.ComponentTypeCore, "unikraft", ""
+		}
+
+		if match := configPromptRegex.FindSubmatch(config); match != nil {
+			description = string(match[1])
+		}
+
+		if match := configSymbolRegex.FindSubmatch(config); match != nil {
+			prefix, symbol = strings.ToLower(string(match[1])), strings.ToLower(string(match[2]))
+		}
+	}
+
+	if makefile, err := readFile(unikraft.Makefile_uk); err == nil {
+		if match := makefileLibRegex.FindSubmatch(makefile); match != nil {
+			prefix, symbol = string(match[1]), string(match[2])
+		} else if match := makefilePlatRegex.FindSubmatch(makefile); match != nil {
+			prefix, symbol = "plat", string(match[1])
+		}
+	}
+
+	switch prefix {
+	case "app":
+		t = unikraft.ComponentTypeApp
+	case "lib":
+		t = unikraft.ComponentTypeLib
+	case "plat":
+		t = unikraft.ComponentTypePlat
+	}
+
+	// The name of the repository retains the dashes which are replaced in the
+	// symbol of the component
+	if len(symbol) > 0 && normalizeName(symbol) != normalizeName(name) {
+		name = strings.ReplaceAll(strings.ToLower(symbol), "_", "-")
+	}
+
+	return t, name, description
+}
+
+func normalizeName(name string) string {
+	return strings.NewReplacer("-", "", "_", "").Replace(strings.ToLower(name))
+}
+
+// headBranch returns the branch which HEAD refers to within the references
+func headBranch(refs []*gitplumbing.Reference) string {
+	for _, ref := range refs {
+		if ref.Name() == gitplumbing.HEAD && ref.Type() == gitplumbing.SymbolicReference {
+			return ref.Target().Short()
+		}
+	}
+
+	return ""
+}
+
+// archiveFunc returns the function which determines the URL of the archive of
+// a branch (`heads`), tag (`tags`) or commit of the repository, or nil if the
+// archives of the repository are not known
+func archiveFunc(repo, archiveURL string) func(ref, kind string) string {
+	if len(archiveURL) > 0 {
+		return func(ref, _ string) string {
+			return strings.ReplaceAll(archiveURL, "{ref}", ref)
+		}
+	}
+
+	// Use the HTTPS equivalent of the SSH remote of GitHub
+	if strings.HasPrefix(repo, "git@github.com:") {
+		repo = "https://github.com/" + strings.TrimPrefix(repo, "git@github.com:")
+	}
+
+	if !strings.HasPrefix(repo, "https://github.com/") {
+		return nil
+	}
+
+	ghr, err := ghrepo.NewFromURL(repo)
+	if err != nil {
+		return nil
+	}
+
+	return func(ref, kind string) string {
+		switch kind {
+		case "heads":
+			return ghrepo.BranchArchive(ghr, ref)
+		case "tags":
+			return ghrepo.TagArchive(ghr, ref)
+		default:
+			return ghrepo.SHAArchive(ghr, ref)
+		}
+	}
+}
+
+// sha256URL returns the hex-encoded SHA-256 checksum of the remote resource
+func sha256URL(resource string) (string, error) {
+	get, err := http.NewRequest("GET", resource, nil)
+	if err != nil {
+		return "", err
+	}
+
+	get.Header.Set("User-Agent", "kraftkit/"+version.Version())
+
+	resp, err := http.DefaultClient.Do(get)
+	if err != nil {
+		return "", err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("received %d error when retrieving: %s", resp.StatusCode, resource)
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(h, resp.Body); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,248 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package manifest
+
+import (
+	"os"
+	"testing"
+
+	gitplumbing "github.com/go-git/go-git/v5/plumbing"
+
+	"kraftkit.sh/unikraft"
+)
+
+func TestGuessComponent(t *testing.T) {
+	tests := []struct {
+		name        string
+		repo        string
+		configUk    string
+		makefileUk  string
+		ctype       unikraft.ComponentType
+		cname       string
+		description string
+	}{
+		{
+			name:     "core",
+			repo:     "https://github.com/unikraft/unikraft.git",
+			configUk: "mainmenu \"Unikraft/$(UK_FULLVERSION) Configuration\"\n\nconfig UK_FULLVERSION\n\tstring\n",
+			ctype:    unikraft.ComponentTypeCore,
+			cname:    "unikraft",
+		},
+		{
+			name:        "library",
+			repo:        "https://github.com/unikraft/lib-musl.git",
+			configUk:    "menuconfig LIBMUSL\n\tbool \"musl: A C standard library\"\n\tdefault n\n",
+			makefileUk:  "$(eval $(call addlib_s,libmusl,$(CONFIG_LIBMUSL)))\n",
+			ctype:       unikraft.ComponentTypeLib,
+			cname:       "musl",
+			description: "musl: A C standard library",
+		},
+		{
+			name:       "library with dashes",
+			repo:       "git@github.com:unikraft/lib-intel-intrinsics.git",
+			makefileUk: "$(eval $(call addlib_s,libintel_intrinsics,$(CONFIG_LIBINTEL_INTRINSICS)))\n",
+			ctype:      unikraft.ComponentTypeLib,
+			cname:      "intel-intrinsics",
+		},
+		{
+			name:       "library named differently than its repository",
+			repo:       "https://github.com/example/fork.git",
+			makefileUk: "$(eval $(call addlib_s,libnginx_extra,$(CONFIG_LIBNGINX_EXTRA)))\n",
+			ctype:      unikraft.ComponentTypeLib,
+			cname:      "nginx-extra",
+		},
+		{
+			name:        "library without type in repository",
+			repo:        "https://example.com/lwip.git",
+			configUk:    "menuconfig LIBLWIP\n\tbool \"lwIP - Lightweight TCP/IP stack\"\n",
+			ctype:       unikraft.ComponentTypeLib,
+			cname:       "lwip",
+			description: "lwIP - Lightweight TCP/IP stack",
+		},
+		{
+			name:        "application",
+			repo:        "https://github.com/unikraft/app-helloworld",
+			configUk:    "config APPHELLOWORLD\n\tbool \"Hello world\"\n\tdefault y\n",
+			makefileUk:  "$(eval $(call addlib,apphelloworld))\n",
+			ctype:       unikraft.ComponentTypeApp,
+			cname:       "helloworld",
+			description: "Hello world",
+		},
+		{
+			name:        "platform",
+			repo:        "https://github.com/unikraft/plat-raspi.git",
+			configUk:    "menuconfig PLAT_RASPI\n\tbool \"Raspberry Pi 3B\"\n\tdepends on ARCH_ARM_64\n",
+			makefileUk:  "$(eval $(call addplat_s,raspi,$(CONFIG_PLAT_RASPI)))\n",
+			ctype:       unikraft.ComponentTypePlat,
+			cname:       "raspi",
+			description: "Raspberry Pi 3B",
+		},
+		{
+			name:  "repository only",
+			repo:  "https://github.com/unikraft/lib-newlib.git",
+			ctype: unikraft.ComponentTypeLib,
+			cname: "newlib",
+		},
+		{
+			name:  "unknown",
+			repo:  "https://example.com/something.git",
+			ctype: unikraft.ComponentTypeUnknown,
+			cname: "something",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := map[string]string{}
+			if len(tt.configUk) > 0 {
+				files[unikraft.Config_uk] = tt.configUk
+			}
+			if len(tt.makefileUk) > 0 {
+				files[unikraft.Makefile_uk] = tt.makefileUk
+			}
+
+			ctype, cname, description := guessComponent(tt.repo, func(name string) ([]byte, error) {
+				if content, ok := files[name]; ok {
+					return []byte(content), nil
+				}
+
+				return nil, os.ErrNotExist
+			})
+
+			if ctype != tt.ctype || cname != tt.cname || description != tt.description {
+				t.Errorf("expected %s %q (%q), got %s %q (%q)", tt.ctype, tt.cname, tt.description, ctype, cname, description)
+			}
+		})
+	}
+}
+
+func TestArchiveFunc(t *testing.T) {
+	tests := []struct {
+		repo       string
+		archiveURL string
+		heads      string
+		tags       string
+		sha        string
+	}{
+		{
+			repo:  "https://github.com/unikraft/lib-musl.git",
+			heads: "https://github.com/unikraft/lib-musl/archive/refs/heads/stable.tar.gz",
+			tags:  "https://github.com/unikraft/lib-musl/archive/refs/tags/v0.11.0.tar.gz",
+			sha:   "https://github.com/unikraft/lib-musl/archive/1a2b3c.tar.gz",
+		},
+		{
+			repo:  "https://github.com/unikraft/lib-musl",
+			heads: "https://github.com/unikraft/lib-musl/archive/refs/heads/stable.tar.gz",
+			tags:  "https://github.com/unikraft/lib-musl/archive/refs/tags/v0.11.0.tar.gz",
+			sha:   "https://github.com/unikraft/lib-musl/archive/1a2b3c.tar.gz",
+		},
+		{
+			repo:  "git@github.com:unikraft/lib-musl.git",
+			heads: "https://github.com/unikraft/lib-musl/archive/refs/heads/stable.tar.gz",
+			tags:  "https://github.com/unikraft/lib-musl/archive/refs/tags/v0.11.0.tar.gz",
+			sha:   "https://github.com/unikraft/lib-musl/archive/1a2b3c.tar.gz",
+		},
+		{
+			repo:       "git@github.com:unikraft/lib-musl.git",
+			archiveURL: "https://mirror.example.com/lib-musl/{ref}.tar.gz",
+			heads:      "https://mirror.example.com/lib-musl/stable.tar.gz",
+			tags:       "https://mirror.example.com/lib-musl/v0.11.0.tar.gz",
+			sha:        "https://mirror.example.com/lib-musl/1a2b3c.tar.gz",
+		},
+		{
+			repo: "https://gitlab.com/unikraft/lib-musl.git",
+		},
+		{
+			repo: "ssh://git@github.com/unikraft/lib-musl.git",
+		},
+		{
+			repo: "https://github.com/unikraft",
+		},
+	}
+
+	for _, tt := range tests {
+		archive := archiveFunc(tt.repo, tt.archiveURL)
+		if archive == nil {
+			if len(tt.heads) > 0 {
+				t.Errorf("%s: expected archives", tt.repo)
+			}
+			continue
+		} else if len(tt.heads) == 0 {
+			t.Errorf("%s: expected no archives", tt.repo)
+			continue
+		}
+
+		for _, ref := range []struct {
+			ref, kind, want string
+		}{
+			{"stable", "heads", tt.heads},
+			{"v0.11.0", "tags", tt.tags},
+			{"1a2b3c", "", tt.sha},
+		} {
+			if got := archive(ref.ref, ref.kind); got != ref.want {
+				t.Errorf("%s: expected archive of %s to be %s, got %s", tt.repo, ref.ref, ref.want, got)
+			}
+		}
+	}
+}
+
+func TestHeadBranch(t *testing.T) {
+	hash := gitplumbing.NewHash("0123456789abcdef0123456789abcdef01234567")
+	main := gitplumbing.NewHashReference("refs/heads/main", hash)
+	tag := gitplumbing.NewHashReference("refs/tags/v0.1.0", hash)
+
+	tests := []struct {
+		name string
+		refs []*gitplumbing.Reference
+		want string
+	}{
+		{
+			name: "symbolic",
+			refs: []*gitplumbing.Reference{main, tag, gitplumbing.NewSymbolicReference(gitplumbing.HEAD, "refs/heads/main")},
+			want: "main",
+		},
+		{
+			name: "detached",
+			refs: []*gitplumbing.Reference{main, tag, gitplumbing.NewHashReference(gitplumbing.HEAD, hash)},
+		},
+		{
+			name: "missing",
+			refs: []*gitplumbing.Reference{main, tag},
+		},
+	}
+
+	for _, tt := range tests {
+		if got := headBranch(tt.refs); got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package manifest
+
+import (
+	"kraftkit.sh/pack"
+	"kraftkit.sh/unikraft"
+)
+
+// ManifestDependency is a component which is required by the component of a
+// manifest, for example:
+//
+//	dependencies:
+//	  - name: musl
+//	    version: ^0.11
+//	  - name: compiler-rt
+//	    version: stable
+type ManifestDependency struct {
+	// Name of the required component
+	Name string `yaml:"name"`
+
+	// Type of the required component, which is a library if unset
+	Type unikraft.ComponentType `yaml:"type,omitempty"`
+
+	// Version is the exact version, channel or semantic version constraint
+	// which the required component must satisfy.  The default channel of the
+	// required component is used if unset.
+	Version string `yaml:"version,omitempty"`
+}
+
+// packageDependencies returns the dependencies of the manifest and of its
+// channels and versions as package dependencies
+func packageDependencies(manifest *Manifest, channels []ManifestChannel, versions []ManifestVersion) []pack.Dependency {
+	dependencies := append([]ManifestDependency{}, manifest.Dependencies...)
+
+	for _, channel := range channels {
+		dependencies = append(dependencies, channel.Dependencies...)
+	}
+
+	for _, version := range versions {
+		dependencies = append(dependencies, version.Dependencies...)
+	}
+
+	var deps []pack.Dependency
+	for _, dep := range dependencies {
+		t := dep.Type
+		if len(t) == 0 {
+			t = unikraft.ComponentTypeLib
+		}
+
+		deps = append(deps, pack.Dependency{
+			Type:    t,
+			Name:    dep.Name,
+			Version: dep.Version,
+		})
+	}
+
+	return deps
+}
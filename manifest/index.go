@@ -146,6 +146,10 @@ func NewManifestIndexFromFile(path string, mopts ...ManifestOption) (*ManifestIn
 		return nil, err
 	}
 
+	if err := verifyIndex(path, contents, mopts); err != nil {
+		return nil, err
+	}
+
 	return NewManifestIndexFromBytes(contents, mopts...)
 }
 
@@ -204,6 +208,10 @@ func NewManifestIndexFromURL(path string, mopts ...ManifestOption) (*ManifestInd
 	}
 	providerRequestCache = contents
 
+	if err := verifyIndex(path, contents, mopts); err != nil {
+		return nil, err
+	}
+
 	index, err := NewManifestIndexFromBytes(contents, mopts...)
 	if err != nil {
 		return nil, err
@@ -221,11 +229,30 @@ func (mi *ManifestIndex) WriteToFile(path string) error {
 
 	defer f.Close()
 
-	contents, err := yaml.Marshal(mi)
+	contents, err := mi.marshal()
+	if err != nil {
+		return err
+	}
+
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+
+	_, err = f.Write(contents)
 	if err != nil {
 		return err
 	}
 
+	return nil
+}
+
+// marshal returns the YAML representation of the manifest index
+func (mi *ManifestIndex) marshal() ([]byte, error) {
+	contents, err := yaml.Marshal(mi)
+	if err != nil {
+		return nil, err
+	}
+
 	// TODO: This serialization mechanism is used to encode the provider into the
 	// resulting manifest file and feels a bit of a hack since we are running
 	// `yaml.Marshal` twice.  The library exposes `yaml.Marshler` and
@@ -236,24 +263,10 @@ func (mi *ManifestIndex) WriteToFile(path string) error {
 	// implemented, this code is duplicated also inside of manifest.go
 	var iface map[string]interface{}
 	if err := yaml.Unmarshal(contents, &iface); err != nil {
-		return err
+		return nil, err
 	}
 
 	delete(iface, "provider")
 
-	contents, err = yaml.Marshal(iface)
-	if err != nil {
-		return err
-	}
-
-	if err := f.Truncate(0); err != nil {
-		return err
-	}
-
-	_, err = f.Write(contents)
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return yaml.Marshal(iface)
 }
@@ -36,14 +36,17 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/gobwas/glob"
 
 	"kraftkit.sh/config"
 	"kraftkit.sh/internal/cmdutil"
+	"kraftkit.sh/internal/semver"
 	"kraftkit.sh/pack"
 	"kraftkit.sh/packmanager"
+	"kraftkit.sh/signature"
 	"kraftkit.sh/unikraft"
 )
 
@@ -121,10 +124,16 @@ func (mm ManifestManager) update() (*ManifestIndex, error) {
 		LastUpdated: time.Now(),
 	}
 
+	verifier, err := signature.NewVerifierFromConfig(mm.Options().ConfigManager.Config)
+	if err != nil {
+		return nil, err
+	}
+
 	mopts := []ManifestOption{
 		WithAuthConfig(mm.Options().ConfigManager.Config.Auth),
 		WithSourcesRootDir(mm.Options().ConfigManager.Config.Paths.Sources),
 		WithLogger(mm.Options().Log),
+		WithVerifier(verifier),
 	}
 
 	for _, manipath := range cfm.Config.Unikraft.Manifests {
@@ -165,12 +174,7 @@ func (mm ManifestManager) Update() error {
 
 	// Create a file for each manifest
 	for i, manifest := range localIndex.Manifests {
-		filename := manifest.Name + ".yaml"
-
-		if manifest.Type != unikraft.ComponentTypeCore {
-			filename = manifest.Type.Plural() + "/" + filename
-		}
-
+		filename := manifestFilename(manifest)
 		fileloc := filepath.Join(mm.LocalManifestsDir(), filename)
 		if err := os.MkdirAll(filepath.Dir(fileloc), 0o771); err != nil {
 			return err
@@ -224,6 +228,12 @@ func (mm ManifestManager) RemoveSource(source string) error {
 	return cfm.Write(false)
 }
 
+// Delete is not applicable as manifest packages are not kept in the local
+// package store.
+func (mm ManifestManager) Delete(ref string) error {
+	return fmt.Errorf("method not applicable to manifest manager")
+}
+
 // Push the resulting package to the supported registry of the implementation.
 func (mm ManifestManager) Push(path string) error {
 	return fmt.Errorf("not implemented pack.ManifestManager.Pushh")
@@ -243,14 +253,25 @@ func (um ManifestManager) From(sub string) (packmanager.PackageManager, error) {
 }
 
 func (mm ManifestManager) Catalog(query packmanager.CatalogQuery, popts ...pack.PackageOption) ([]pack.Package, error) {
+	// Manifest packages are not kept in the local package store
+	if query.Local {
+		return nil, nil
+	}
+
 	var err error
 	var index *ManifestIndex
 	var allManifests []*Manifest
 
+	verifier, err := signature.NewVerifierFromConfig(mm.Options().ConfigManager.Config)
+	if err != nil {
+		return nil, err
+	}
+
 	mopts := []ManifestOption{
 		WithAuthConfig(mm.Options().ConfigManager.Config.Auth),
 		WithSourcesRootDir(mm.Options().ConfigManager.Config.Paths.Sources),
 		WithLogger(mm.Options().Log),
+		WithVerifier(verifier),
 	}
 
 	if len(query.Source) > 0 && query.NoCache {
@@ -289,6 +310,7 @@ func (mm ManifestManager) Catalog(query packmanager.CatalogQuery, popts ...pack.
 	}
 
 	var packages []pack.Package
+	var unsatisfied []string
 	var g glob.Glob
 
 	if len(query.Name) > 0 {
@@ -297,7 +319,9 @@ func (mm ManifestManager) Catalog(query packmanager.CatalogQuery, popts ...pack.
 		// Overwrite additional attributes if pattern-matchable
 		if err == nil {
 			query.Name = n
-			query.Version = v
+			if len(v) > 0 {
+				query.Version = v
+			}
 			if t != unikraft.ComponentTypeUnknown {
 				query.Types = append(query.Types, t)
 			}
@@ -345,8 +369,20 @@ func (mm ManifestManager) Catalog(query packmanager.CatalogQuery, popts ...pack.
 				}
 			}
 
+			// Resolve a range to the highest matching semantic version
+			if len(versions) == 0 && semver.IsConstraint(query.Version) {
+				version, err := manifest.ResolveConstraint(query.Version)
+				if err != nil {
+					unsatisfied = append(unsatisfied, err.Error())
+					continue
+				}
+
+				mm.opts.Log.Debugf("resolved %s/%s:%s to %s", manifest.Type, manifest.Name, query.Version, version)
+				versions = append(versions, version)
+			}
+
 			if len(versions) == 0 {
-				break
+				continue
 			}
 		}
 
@@ -375,6 +411,10 @@ func (mm ManifestManager) Catalog(query packmanager.CatalogQuery, popts ...pack.
 		}
 	}
 
+	if len(packages) == 0 && len(unsatisfied) > 0 {
+		return nil, fmt.Errorf("%s", strings.Join(unsatisfied, "; "))
+	}
+
 	for i := range packages {
 		packages[i].ApplyOptions(
 			pack.WithLogger(mm.Options().Log),
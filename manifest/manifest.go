@@ -39,11 +39,14 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"kraftkit.sh/config"
+	"kraftkit.sh/internal/semver"
 	"kraftkit.sh/internal/version"
 	"kraftkit.sh/log"
 	"kraftkit.sh/pack"
+	"kraftkit.sh/signature"
 	"kraftkit.sh/unikraft"
 
 	"gopkg.in/yaml.v2"
@@ -78,11 +81,19 @@ type Manifest struct {
 	// Versions
 	Versions []ManifestVersion `yaml:"versions,omitempty"`
 
+	// Dependencies are the components which all channels and versions of this
+	// manifest require
+	Dependencies []ManifestDependency `yaml:"dependencies,omitempty"`
+
 	// auth is an internal property set by a ManifestOption which is used by the
 	// Manifest to access information a bout itself aswell as downloading a given
 	// resource
 	auths map[string]config.AuthConfig
 
+	// verifier is an internal property set by a ManifestOption which is used to
+	// check the signatures of the resources of the manifest when pulling them
+	verifier *signature.Verifier
+
 	// log is an internal property used to perform logging within the context of
 	// the manifest
 	log log.Logger
@@ -362,11 +373,30 @@ func (m Manifest) WriteToFile(path string) error {
 
 	defer f.Close()
 
-	contents, err := yaml.Marshal(m)
+	contents, err := m.marshal()
+	if err != nil {
+		return err
+	}
+
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+
+	_, err = f.Write(contents)
 	if err != nil {
 		return err
 	}
 
+	return nil
+}
+
+// marshal returns the YAML representation of the manifest
+func (m Manifest) marshal() ([]byte, error) {
+	contents, err := yaml.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+
 	// TODO: This serialization mechanism is used to encode the provider into the
 	// resulting manifest file and feels a bit of a hack since we are running
 	// `yaml.Marshal` twice.  The library exposes `yaml.Marshler` and
@@ -377,7 +407,7 @@ func (m Manifest) WriteToFile(path string) error {
 	// implemented, this code is duplicated also inside of index.go
 	var iface map[string]interface{}
 	if err := yaml.Unmarshal(contents, &iface); err != nil {
-		return err
+		return nil, err
 	}
 
 	if m.Provider != nil {
@@ -386,21 +416,7 @@ func (m Manifest) WriteToFile(path string) error {
 		delete(iface, "provider")
 	}
 
-	contents, err = yaml.Marshal(iface)
-	if err != nil {
-		return err
-	}
-
-	if err := f.Truncate(0); err != nil {
-		return err
-	}
-
-	_, err = f.Write(contents)
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return yaml.Marshal(iface)
 }
 
 // DefaultChannel returns the default channel of the Manifest
@@ -421,6 +437,33 @@ func (m Manifest) DefaultChannel() (*ManifestChannel, error) {
 	return nil, fmt.Errorf("manifest does not have a default channel: %s", m.Origin)
 }
 
+// ResolveConstraint returns the highest semantic version of the Manifest which
+// satisfies the version constraint, e.g. `^0.11` or `>=0.10 <0.12`
+func (m Manifest) ResolveConstraint(constraint string) (string, error) {
+	c, err := semver.ParseConstraint(constraint)
+	if err != nil {
+		return "", err
+	}
+
+	var available []string
+	for _, version := range m.Versions {
+		if version.Type == ManifestVersionSemver {
+			available = append(available, version.Version)
+		}
+	}
+
+	if len(available) == 0 {
+		return "", fmt.Errorf("%s/%s has no semantic versions to satisfy %s", m.Type, m.Name, constraint)
+	}
+
+	version, ok := c.Highest(available)
+	if !ok {
+		return "", fmt.Errorf("no version of %s/%s satisfies %s (available: %s)", m.Type, m.Name, constraint, strings.Join(available, ", "))
+	}
+
+	return version, nil
+}
+
 // Auths returns the map of provided authentication configuration passed as an
 // option to the Manifest
 func (m Manifest) Auths() map[string]config.AuthConfig {
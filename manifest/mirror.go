@@ -0,0 +1,506 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package manifest
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"kraftkit.sh/internal/version"
+	"kraftkit.sh/signature"
+	"kraftkit.sh/unikraft"
+)
+
+const (
+	// MirrorIndexFileName is the name of the manifest index of a mirror
+	MirrorIndexFileName = "index.yaml"
+
+	// MirrorSourcesDir is the directory of a mirror which contains the archives
+	// of the channels and versions of its manifests
+	MirrorSourcesDir = "sources"
+)
+
+// manifestFilename returns the path of the manifest relative to a directory of
+// manifests
+func manifestFilename(m *Manifest) string {
+	filename := m.Name + ".yaml"
+
+	if m.Type != unikraft.ComponentTypeCore {
+		filename = m.Type.Plural() + "/" + filename
+	}
+
+	return filename
+}
+
+// Mirror downloads the archives of the channels and versions of the manifests
+// into the directory, such that they can be served without access to their
+// origin.  The manifests are saved alongside the archives together with an
+// index of all manifests within the directory.  Channels and versions whose
+// archive cannot be retrieved are left out of the mirror.  A signature of a
+// previous index is removed, since the index must be signed again.
+func Mirror(dir string, manifests []*Manifest) error {
+	sources := filepath.Join(dir, MirrorSourcesDir)
+
+	for _, m := range manifests {
+		mirrored := *m
+		mirrored.Provider = nil
+		mirrored.Channels = nil
+		mirrored.Versions = nil
+
+		// Branches move, so the archives of channels are always retrieved again
+		for _, channel := range m.Channels {
+			if err := mirrorResource(m, channel.Resource, channel.Sha256, sourcesPath(sources, m, channel.Name, channel.Resource), true); err != nil {
+				mirrorWarn(m, "skipping %s/%s:%s: %v", m.Type, m.Name, channel.Name, err)
+				continue
+			}
+
+			mirrored.Channels = append(mirrored.Channels, channel)
+		}
+
+		for _, ver := range m.Versions {
+			if err := mirrorResource(m, ver.Resource, ver.Sha256, sourcesPath(sources, m, ver.Version, ver.Resource), false); err != nil {
+				mirrorWarn(m, "skipping %s/%s:%s: %v", m.Type, m.Name, ver.Version, err)
+				continue
+			}
+
+			mirrored.Versions = append(mirrored.Versions, ver)
+		}
+
+		if len(mirrored.Channels) == 0 && len(mirrored.Versions) == 0 {
+			mirrorWarn(m, "skipping %s/%s: nothing could be mirrored", m.Type, m.Name)
+			continue
+		}
+
+		fileloc := filepath.Join(dir, manifestFilename(m))
+		if err := os.MkdirAll(filepath.Dir(fileloc), 0o771); err != nil {
+			return err
+		}
+
+		if err := mirrored.WriteToFile(fileloc); err != nil {
+			return fmt.Errorf("could not save manifest: %v", err)
+		}
+	}
+
+	index, err := NewManifestIndexFromMirror(dir)
+	if err != nil {
+		return err
+	}
+
+	indexPath := filepath.Join(dir, MirrorIndexFileName)
+	if err := os.Remove(indexPath + signature.Extension); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return index.WriteToFile(indexPath)
+}
+
+func mirrorWarn(m *Manifest, format string, args ...interface{}) {
+	if m.log != nil {
+		m.log.Warnf(format, args...)
+	}
+}
+
+// mirrorResource retrieves the archive of the resource to the path unless an
+// archive with the checksum was previously retrieved.  The detached signature
+// of the resource, if any, is retrieved alongside so that clients of the
+// mirror are able to verify the archive.
+func mirrorResource(m *Manifest, resource, checksum, path string, refresh bool) error {
+	u, err := url.Parse(resource)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf("unsupported resource: %s", resource)
+	} else if ext := filepath.Ext(u.Path); ext == "" || ext == ".git" {
+		return fmt.Errorf("resource is not an archive: %s", resource)
+	}
+
+	if f, err := os.Stat(path); err == nil && f.Size() > 0 && !refresh {
+		if len(checksum) == 0 {
+			return nil
+		}
+
+		if sum, err := sha256File(path); err == nil && sum == checksum {
+			return nil
+		}
+	}
+
+	if m.log != nil {
+		m.log.Infof("mirroring %s", resource)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	tmp := path + ".part"
+	defer os.Remove(tmp)
+
+	if err := download(resource, tmp); err != nil {
+		return err
+	}
+
+	sum, err := sha256File(tmp)
+	if err != nil {
+		return fmt.Errorf("could not perform checksum: %v", err)
+	}
+
+	if len(checksum) > 0 && sum != checksum {
+		return fmt.Errorf("checksum of %s does not match: expected %s but got %s", resource, checksum, sum)
+	}
+
+	if err := verifyFile(m, resource, tmp); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+
+	sig, err := readSignature(resource)
+	if err != nil || sig == nil {
+		return nil
+	}
+
+	raw, err := sig.Encode()
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path+signature.Extension, raw, 0o644)
+}
+
+// download saves the remote resource to the path
+func download(resource, path string) error {
+	get, err := http.NewRequest("GET", resource, nil)
+	if err != nil {
+		return err
+	}
+
+	get.Header.Set("User-Agent", "kraftkit/"+version.Version())
+
+	resp, err := http.DefaultClient.Do(get)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("received %d error when retrieving: %s", resp.StatusCode, resource)
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	_, err = io.Copy(f, resp.Body)
+	return err
+}
+
+// ManifestsFromMirror returns the manifests within the directory of a mirror
+func ManifestsFromMirror(dir string, mopts ...ManifestOption) ([]*Manifest, error) {
+	var manifests []*Manifest
+
+	err := filepath.WalkDir(dir, func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			if file == filepath.Join(dir, MirrorSourcesDir) {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if ext := filepath.Ext(file); (ext != ".yaml" && ext != ".yml") || file == filepath.Join(dir, MirrorIndexFileName) {
+			return nil
+		}
+
+		manifest, err := NewManifestFromFile(file, mopts...)
+		if err != nil {
+			return fmt.Errorf("could not read manifest %s: %v", file, err)
+		}
+
+		manifests = append(manifests, manifest)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return manifests, nil
+}
+
+// NewManifestIndexFromMirror returns the index of the manifests within the
+// directory of a mirror
+func NewManifestIndexFromMirror(dir string) (*ManifestIndex, error) {
+	manifests, err := ManifestsFromMirror(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	index := &ManifestIndex{
+		Name:        filepath.Base(dir),
+		LastUpdated: time.Now(),
+	}
+
+	for _, manifest := range manifests {
+		index.Manifests = append(index.Manifests, &Manifest{
+			Name:     manifest.Name,
+			Type:     manifest.Type,
+			Manifest: "./" + manifestFilename(manifest),
+		})
+	}
+
+	return index, nil
+}
+
+// MirrorHandlerOption is an option of the HTTP handler of a mirror
+type MirrorHandlerOption func(*mirrorHandler)
+
+// WithMirrorBaseURL sets the URL at which the mirror is reachable by its
+// clients.  By default, it is derived from each request.
+func WithMirrorBaseURL(baseURL string) MirrorHandlerOption {
+	return func(mh *mirrorHandler) {
+		mh.baseURL = strings.TrimSuffix(baseURL, "/")
+	}
+}
+
+// WithMirrorSourcesDirs adds directories, such as the cache of sources, which
+// are searched for archives after the sources directory of the mirror
+func WithMirrorSourcesDirs(dirs ...string) MirrorHandlerOption {
+	return func(mh *mirrorHandler) {
+		mh.sources = append(mh.sources, dirs...)
+	}
+}
+
+// WithMirrorManifestOptions sets the options which are applied to the
+// manifests of the mirror when they are read
+func WithMirrorManifestOptions(mopts ...ManifestOption) MirrorHandlerOption {
+	return func(mh *mirrorHandler) {
+		mh.mopts = mopts
+	}
+}
+
+type mirrorHandler struct {
+	dir     string
+	baseURL string
+	sources []string
+	mopts   []ManifestOption
+}
+
+// NewMirrorHandler returns the HTTP handler which serves the index of the
+// manifests within the directory of a mirror, the manifests themselves and the
+// archives of their channels and versions.  The index written by Mirror is
+// served as-is together with its detached signature, if any.  The resources of
+// the served manifests refer to the archives served by the handler, whereas
+// channels and versions without an archive are left out.
+func NewMirrorHandler(dir string, opts ...MirrorHandlerOption) http.Handler {
+	mh := &mirrorHandler{
+		dir:     dir,
+		sources: []string{filepath.Join(dir, MirrorSourcesDir)},
+	}
+
+	for _, o := range opts {
+		o(mh)
+	}
+
+	return mh
+}
+
+func (mh *mirrorHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Cleaning the rooted path removes any traversal outside of the mirror
+	p := path.Clean("/" + r.URL.Path)
+
+	switch {
+	case p == "/" || p == "/"+MirrorIndexFileName:
+		mh.serveIndex(w, r)
+	case p == "/"+MirrorIndexFileName+signature.Extension:
+		mh.serveFile(w, r, filepath.Join(mh.dir, MirrorIndexFileName+signature.Extension))
+	case strings.HasPrefix(p, "/"+MirrorSourcesDir+"/"):
+		mh.serveSource(w, r, strings.TrimPrefix(p, "/"+MirrorSourcesDir+"/"))
+	case strings.HasSuffix(p, ".yaml"):
+		mh.serveManifest(w, r, strings.TrimPrefix(p, "/"))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (mh *mirrorHandler) serveIndex(w http.ResponseWriter, r *http.Request) {
+	// Serve the index as it was written, such that its signature is valid
+	if index := filepath.Join(mh.dir, MirrorIndexFileName); isRegularFile(index) {
+		w.Header().Set("Content-Type", "application/yaml")
+		mh.serveFile(w, r, index)
+		return
+	}
+
+	// A directory of manifests which was not populated by Mirror has no index,
+	// so one is generated which cannot be signed
+	index, err := NewManifestIndexFromMirror(mh.dir)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	contents, err := index.marshal()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/yaml")
+	w.Write(contents)
+}
+
+func (mh *mirrorHandler) serveManifest(w http.ResponseWriter, r *http.Request, filename string) {
+	manifests, err := ManifestsFromMirror(mh.dir, mh.mopts...)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var manifest *Manifest
+	for _, m := range manifests {
+		if manifestFilename(m) == filename {
+			manifest = m
+			break
+		}
+	}
+
+	if manifest == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	baseURL := mh.baseURL
+	if len(baseURL) == 0 {
+		scheme := "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+
+		baseURL = scheme + "://" + r.Host
+	}
+
+	var channels []ManifestChannel
+	for _, channel := range manifest.Channels {
+		if source, ok := mh.locate(manifest, channel.Name, channel.Resource); ok {
+			channel.Resource = baseURL + source
+			channels = append(channels, channel)
+		}
+	}
+
+	var versions []ManifestVersion
+	for _, ver := range manifest.Versions {
+		if source, ok := mh.locate(manifest, ver.Version, ver.Resource); ok {
+			ver.Resource = baseURL + source
+			versions = append(versions, ver)
+		}
+	}
+
+	manifest.Channels = channels
+	manifest.Versions = versions
+	manifest.Provider = nil
+
+	contents, err := manifest.marshal()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/yaml")
+	w.Write(contents)
+}
+
+// locate returns the path at which the handler serves the archive of the
+// channel or version of the manifest
+func (mh *mirrorHandler) locate(m *Manifest, name, resource string) (string, bool) {
+	for _, dir := range mh.sources {
+		archive := sourcesPath(dir, m, name, resource)
+		if !isRegularFile(archive) {
+			continue
+		}
+
+		rel, err := filepath.Rel(dir, archive)
+		if err != nil {
+			continue
+		}
+
+		return "/" + MirrorSourcesDir + "/" + filepath.ToSlash(rel), true
+	}
+
+	return "", false
+}
+
+func (mh *mirrorHandler) serveSource(w http.ResponseWriter, r *http.Request, rel string) {
+	for _, dir := range mh.sources {
+		archive := filepath.Join(dir, filepath.FromSlash(rel))
+		if isRegularFile(archive) {
+			http.ServeFile(w, r, archive)
+			return
+		}
+	}
+
+	http.NotFound(w, r)
+}
+
+// serveFile serves the file if it exists
+func (mh *mirrorHandler) serveFile(w http.ResponseWriter, r *http.Request, file string) {
+	if !isRegularFile(file) {
+		http.NotFound(w, r)
+		return
+	}
+
+	http.ServeFile(w, r, file)
+}
+
+func isRegularFile(file string) bool {
+	f, err := os.Stat(file)
+	return err == nil && f.Mode().IsRegular()
+}
@@ -0,0 +1,297 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package manifest
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"kraftkit.sh/internal/logger"
+	"kraftkit.sh/iostreams"
+	"kraftkit.sh/signature"
+	"kraftkit.sh/unikraft"
+)
+
+// newTestMirror populates a mirror with the manifest of musl, whose stable
+// channel is within the sources of the mirror, whose version 0.11.0 is within
+// the directory `cache` and whose version 0.10.0 is not available.
+func newTestMirror(t *testing.T, dir, cache string) *Manifest {
+	t.Helper()
+
+	musl := &Manifest{
+		Name: "musl",
+		Type: unikraft.ComponentTypeLib,
+		Channels: []ManifestChannel{
+			{Name: "stable", Default: true, Resource: "https://github.com/unikraft/lib-musl/archive/refs/heads/stable.tar.gz"},
+		},
+		Versions: []ManifestVersion{
+			{Version: "0.11.0", Resource: "https://github.com/unikraft/lib-musl/archive/refs/tags/v0.11.0.tar.gz"},
+			{Version: "0.10.0", Resource: "https://github.com/unikraft/lib-musl/archive/refs/tags/v0.10.0.tar.gz"},
+		},
+	}
+
+	for _, f := range []struct {
+		path     string
+		contents string
+	}{
+		{sourcesPath(filepath.Join(dir, MirrorSourcesDir), musl, "stable", musl.Channels[0].Resource), "stable"},
+		{sourcesPath(cache, musl, "0.11.0", musl.Versions[0].Resource), "0.11.0"},
+	} {
+		if err := os.MkdirAll(filepath.Dir(f.path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(f.path, []byte(f.contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fileloc := filepath.Join(dir, manifestFilename(musl))
+	if err := os.MkdirAll(filepath.Dir(fileloc), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := musl.WriteToFile(fileloc); err != nil {
+		t.Fatal(err)
+	}
+
+	return musl
+}
+
+func get(t *testing.T, url string) (int, []byte) {
+	t.Helper()
+
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return resp.StatusCode, body
+}
+
+func TestMirrorHandlerIndex(t *testing.T) {
+	dir := t.TempDir()
+	newTestMirror(t, dir, t.TempDir())
+
+	srv := httptest.NewServer(NewMirrorHandler(dir))
+	defer srv.Close()
+
+	// Without an index written by Mirror, one is generated from the manifests
+	status, body := get(t, srv.URL+"/"+MirrorIndexFileName)
+	if status != http.StatusOK {
+		t.Fatalf("expected generated index, got %d", status)
+	}
+
+	index, err := NewManifestIndexFromBytes(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(index.Manifests) != 1 || index.Manifests[0].Manifest != "./libs/musl.yaml" {
+		t.Errorf("unexpected manifests within generated index: %+v", index.Manifests)
+	}
+
+	if status, _ := get(t, srv.URL+"/"+MirrorIndexFileName+signature.Extension); status != http.StatusNotFound {
+		t.Errorf("expected missing signature, got %d", status)
+	}
+
+	// The written index is served as-is, together with its signature
+	indexPath := filepath.Join(dir, MirrorIndexFileName)
+	written, err := NewManifestIndexFromMirror(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := written.WriteToFile(indexPath); err != nil {
+		t.Fatal(err)
+	}
+
+	contents, err := os.ReadFile(indexPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, p := range []string{"/", "/" + MirrorIndexFileName} {
+		if status, body := get(t, srv.URL+p); status != http.StatusOK || string(body) != string(contents) {
+			t.Errorf("%s: expected written index, got %d: %s", p, status, body)
+		}
+	}
+
+	keyPath := filepath.Join(t.TempDir(), "signing.key")
+	pub, err := signature.GenerateKey(keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := signature.ReadPrivateKey(keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verifier, err := signature.NewVerifier(signature.PolicyEnforce, []string{signature.EncodePublicKey(pub)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mopts := []ManifestOption{
+		WithLogger(logger.NewLogger(io.Discard, iostreams.NewColorScheme(false, false, false))),
+		WithVerifier(verifier),
+	}
+
+	if _, err := NewManifestIndexFromURL(srv.URL+"/"+MirrorIndexFileName, mopts...); err == nil {
+		t.Errorf("expected unsigned index to be refused")
+	}
+
+	if _, err := signature.SignFile(key, indexPath); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewManifestIndexFromURL(srv.URL+"/"+MirrorIndexFileName, mopts...); err != nil {
+		t.Errorf("expected signed index to be accepted: %v", err)
+	}
+}
+
+func TestMirrorHandlerManifest(t *testing.T) {
+	dir := t.TempDir()
+	cache := t.TempDir()
+	newTestMirror(t, dir, cache)
+
+	tests := []struct {
+		name    string
+		opts    []MirrorHandlerOption
+		baseURL string
+		version bool
+	}{
+		{
+			name: "without sources",
+		},
+		{
+			name:    "with sources",
+			opts:    []MirrorHandlerOption{WithMirrorSourcesDirs(cache)},
+			version: true,
+		},
+		{
+			name:    "with base URL",
+			opts:    []MirrorHandlerOption{WithMirrorSourcesDirs(cache), WithMirrorBaseURL("https://mirror.example.com/")},
+			baseURL: "https://mirror.example.com",
+			version: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(NewMirrorHandler(dir, tt.opts...))
+			defer srv.Close()
+
+			baseURL := tt.baseURL
+			if len(baseURL) == 0 {
+				baseURL = srv.URL
+			}
+
+			status, body := get(t, srv.URL+"/libs/musl.yaml")
+			if status != http.StatusOK {
+				t.Fatalf("expected manifest, got %d", status)
+			}
+
+			m, err := NewManifestFromBytes(body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(m.Channels) != 1 || m.Channels[0].Resource != baseURL+"/sources/libs/musl-stable.tar.gz" {
+				t.Errorf("unexpected channels: %+v", m.Channels)
+			}
+
+			var want []ManifestVersion
+			if tt.version {
+				want = []ManifestVersion{{Version: "0.11.0", Resource: baseURL + "/sources/libs/musl-0.11.0.tar.gz"}}
+			}
+
+			if len(m.Versions) != len(want) || (len(want) > 0 && m.Versions[0].Resource != want[0].Resource) {
+				t.Errorf("expected versions %+v, got %+v", want, m.Versions)
+			}
+		})
+	}
+}
+
+func TestMirrorHandlerSources(t *testing.T) {
+	parent := t.TempDir()
+	dir := filepath.Join(parent, "mirror")
+	cache := t.TempDir()
+	newTestMirror(t, dir, cache)
+
+	if err := os.WriteFile(filepath.Join(parent, "secret.yaml"), []byte("name: secret\ntype: lib\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	handler := NewMirrorHandler(dir, WithMirrorSourcesDirs(cache))
+
+	tests := []struct {
+		method string
+		path   string
+		status int
+		body   string
+	}{
+		{method: http.MethodGet, path: "/sources/libs/musl-stable.tar.gz", status: http.StatusOK, body: "stable"},
+		{method: http.MethodGet, path: "/sources/libs/musl-0.11.0.tar.gz", status: http.StatusOK, body: "0.11.0"},
+		{method: http.MethodGet, path: "/sources/libs/musl-0.10.0.tar.gz", status: http.StatusNotFound},
+		{method: http.MethodGet, path: "/sources/libs", status: http.StatusNotFound},
+		{method: http.MethodGet, path: "/sources/../../secret.yaml", status: http.StatusNotFound},
+		{method: http.MethodGet, path: "/sources/../../../" + filepath.Base(parent) + "/secret.yaml", status: http.StatusNotFound},
+		{method: http.MethodGet, path: "/../secret.yaml", status: http.StatusNotFound},
+		{method: http.MethodGet, path: "/libs/unknown.yaml", status: http.StatusNotFound},
+		{method: http.MethodGet, path: "/README.md", status: http.StatusNotFound},
+		{method: http.MethodPost, path: "/" + MirrorIndexFileName, status: http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+
+		if rec.Code != tt.status {
+			t.Errorf("%s %s: expected %d, got %d", tt.method, tt.path, tt.status, rec.Code)
+		} else if len(tt.body) > 0 && rec.Body.String() != tt.body {
+			t.Errorf("%s %s: expected %q, got %q", tt.method, tt.path, tt.body, rec.Body.String())
+		}
+	}
+}
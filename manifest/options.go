@@ -36,6 +36,7 @@ import (
 
 	"kraftkit.sh/config"
 	"kraftkit.sh/log"
+	"kraftkit.sh/signature"
 	"kraftkit.sh/unikraft"
 )
 
@@ -55,41 +56,44 @@ func WithLogger(l log.Logger) ManifestOption {
 	}
 }
 
+// WithVerifier sets the verifier which checks the signatures of manifest
+// indexes and of the resources of the manifest when they are pulled
+func WithVerifier(v *signature.Verifier) ManifestOption {
+	return func(m *Manifest) error {
+		m.verifier = v
+		return nil
+	}
+}
+
 // WithSourcesRootDir is an option which helps find cached Manifest Channel or
 // Version resources.  When set to a directory, the fixed structure of this
 // directory should allow us to look up (and also store) resources here for
 // later use.
 func WithSourcesRootDir(dir string) ManifestOption {
 	return func(m *Manifest) error {
-		// See: https://github.com/golang/go/wiki/CommonMistakes#using-reference-to-loop-iterator-variable
-		dir := dir
-
-		if m.Type != unikraft.ComponentTypeCore {
-			dir = filepath.Join(dir, m.Type.Plural())
-		}
-
 		for i, channel := range m.Channels {
-			ext := filepath.Ext(channel.Resource)
-			if ext == ".gz" {
-				ext = ".tar.gz"
-			}
-
-			m.Channels[i].Local = filepath.Join(
-				dir, m.Name+"-"+channel.Name+ext,
-			)
+			m.Channels[i].Local = sourcesPath(dir, m, channel.Name, channel.Resource)
 		}
 
 		for i, version := range m.Versions {
-			ext := filepath.Ext(version.Resource)
-			if ext == ".gz" {
-				ext = ".tar.gz"
-			}
-
-			m.Versions[i].Local = filepath.Join(
-				dir, m.Name+"-"+version.Version+ext,
-			)
+			m.Versions[i].Local = sourcesPath(dir, m, version.Version, version.Resource)
 		}
 
 		return nil
 	}
 }
+
+// sourcesPath returns the path of the archive of the resource of a channel or
+// version of the manifest within the directory of sources
+func sourcesPath(dir string, m *Manifest, name, resource string) string {
+	if m.Type != unikraft.ComponentTypeCore {
+		dir = filepath.Join(dir, m.Type.Plural())
+	}
+
+	ext := filepath.Ext(resource)
+	if ext == ".gz" {
+		ext = ".tar.gz"
+	}
+
+	return filepath.Join(dir, m.Name+"-"+name+ext)
+}
@@ -101,6 +101,7 @@ func NewPackageWithVersion(ctx context.Context, manifest *Manifest, version stri
 		pack.WithRemoteLocation(resource),
 		pack.WithType(manifest.Type),
 		pack.WithVersion(version),
+		pack.WithDependencies(packageDependencies(manifest, channels, versions)),
 	)
 
 	pkgOpts, err := pack.NewPackageOptions(popts...)
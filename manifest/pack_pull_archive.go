@@ -33,6 +33,7 @@ package manifest
 
 import (
 	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"net/http"
@@ -68,6 +69,11 @@ func pullArchive(manifest *Manifest, popts *pack.PackageOptions, ppopts *pack.Pu
 		return err
 	}
 
+	// A locked resource replaces that of the manifest
+	if len(ppopts.Resource()) > 0 {
+		resource = ppopts.Resource()
+	}
+
 	pp := &pullProgressArchive{
 		onProgress: ppopts.OnProgress,
 		total:      0,
@@ -137,7 +143,7 @@ func pullArchive(manifest *Manifest, popts *pack.PackageOptions, ppopts *pack.Pu
 					return fmt.Errorf("could not perform checksum: %v", err)
 				}
 
-				if checksum != string(h.Sum(nil)) {
+				if checksum != hex.EncodeToString(h.Sum(nil)) {
 					return fmt.Errorf("checksum of package does not match")
 				}
 
@@ -145,12 +151,34 @@ func pullArchive(manifest *Manifest, popts *pack.PackageOptions, ppopts *pack.Pu
 			}
 		}
 
+		if err := verifyFile(manifest, resource, tmpCache); err != nil {
+			return err
+		}
+
 		// Copy the completed download to the local cache path
 		if err := os.Rename(tmpCache, cache); err != nil {
 			return fmt.Errorf("could not move downloaded package '%s' to destination '%s': %v", tmpCache, cache, err)
 		}
+	} else if err := verifyFile(manifest, resource, cache); err != nil {
+		// A previously cached resource is subject to the same signing policy as
+		// one which has just been downloaded
+		return err
+	}
+
+	sum, err := sha256File(cache)
+	if err != nil {
+		return fmt.Errorf("could not perform checksum: %v", err)
+	}
+
+	if len(ppopts.Sha256()) > 0 && sum != ppopts.Sha256() {
+		// Do not keep content which does not match for subsequent pulls
+		os.Remove(cache)
+		return fmt.Errorf("checksum of %s does not match: expected %s but got %s", resource, ppopts.Sha256(), sum)
 	}
 
+	popts.RemoteLocation = resource
+	popts.Sha256 = sum
+
 	local := cache
 	if len(ppopts.Workdir()) > 0 {
 		local, err = unikraft.PlaceComponent(
@@ -176,3 +204,20 @@ func pullArchive(manifest *Manifest, popts *pack.PackageOptions, ppopts *pack.Pu
 
 	return nil
 }
+
+// sha256File returns the hex-encoded SHA-256 checksum of the file at the path
+func sha256File(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -47,6 +47,11 @@ func pullGit(manifest *Manifest, popts *pack.PackageOptions, ppopts *pack.PullPa
 		return fmt.Errorf("cannot Git clone manifest package without working directory")
 	}
 
+	// Git repositories cannot carry a detached signature
+	if err := manifest.verifier.Enforce(ppopts.Log(), manifest.Name, "", nil); err != nil {
+		return err
+	}
+
 	ppopts.Log().Infof("using git to pull manifest package %s", manifest.Name)
 
 	if len(manifest.Origin) == 0 {
@@ -92,12 +97,53 @@ func pullGit(manifest *Manifest, popts *pack.PackageOptions, ppopts *pack.PullPa
 
 	ppopts.Log().Infof("cloning %s into %s", manifest.Origin, local)
 
-	_, err = git.Clone(manifest.Origin, local, copts)
+	repo, err := git.Clone(manifest.Origin, local, copts)
 	if err != nil {
 		return fmt.Errorf("could not clone repository: %v", err)
 	}
 
+	// Check out the locked commit instead of the tip of the branch
+	if len(ppopts.Commit()) > 0 {
+		if err := checkoutCommit(repo, ppopts.Commit()); err != nil {
+			return fmt.Errorf("could not check out %s: %v", ppopts.Commit(), err)
+		}
+	}
+
+	head, err := repo.Head()
+	if err != nil {
+		return fmt.Errorf("could not determine commit of repository: %v", err)
+	}
+
+	popts.Commit = head.Target().String()
+
 	ppopts.Log().Infof("successfulyl cloned %s into %s", manifest.Origin, local)
 
 	return nil
 }
+
+// checkoutCommit detaches the head of the repository at the commit and checks
+// out its tree
+func checkoutCommit(repo *git.Repository, commit string) error {
+	oid, err := git.NewOid(commit)
+	if err != nil {
+		return err
+	}
+
+	c, err := repo.LookupCommit(oid)
+	if err != nil {
+		return err
+	}
+
+	tree, err := c.Tree()
+	if err != nil {
+		return err
+	}
+
+	if err := repo.CheckoutTree(tree, &git.CheckoutOptions{
+		Strategy: git.CheckoutForce,
+	}); err != nil {
+		return err
+	}
+
+	return repo.SetHeadDetached(oid)
+}
@@ -0,0 +1,121 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package manifest
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"kraftkit.sh/internal/version"
+	"kraftkit.sh/signature"
+)
+
+// readSignature returns the detached signature of a resource, which is either
+// a path or a URL.  If the resource has no signature, nil is returned.
+func readSignature(resource string) (*signature.Signature, error) {
+	u, err := url.Parse(resource)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return signature.ReadFile(resource)
+	}
+
+	get, err := http.NewRequest("GET", resource+signature.Extension, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	get.Header.Set("User-Agent", "kraftkit/"+version.Version())
+
+	resp, err := http.DefaultClient.Do(get)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	} else if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("received %d error when retreiving: %s", resp.StatusCode, resource+signature.Extension)
+	}
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	return signature.Parse(raw)
+}
+
+// verifyResource applies the policy of the verifier of the manifest to the
+// detached signature of the resource whose content has the digest.
+func verifyResource(manifest *Manifest, resource, digest string) error {
+	if manifest.verifier == nil || manifest.verifier.Policy() == signature.PolicyNone {
+		return nil
+	}
+
+	sig, err := readSignature(resource)
+	if err != nil {
+		return fmt.Errorf("could not read signature of %s: %v", resource, err)
+	}
+
+	return manifest.verifier.Enforce(manifest.log, resource, digest, sig)
+}
+
+// verifyIndex applies the verifier, if provided within the options, to the
+// content of the manifest index at the path.
+func verifyIndex(path string, contents []byte, mopts []ManifestOption) error {
+	manifest := &Manifest{}
+	for _, o := range mopts {
+		if err := o(manifest); err != nil {
+			return err
+		}
+	}
+
+	return verifyResource(manifest, path, signature.Digest(contents))
+}
+
+// verifyFile applies the verifier of the manifest to the file which has been
+// retrieved from the resource.
+func verifyFile(manifest *Manifest, resource, path string) error {
+	if manifest.verifier == nil || manifest.verifier.Policy() == signature.PolicyNone {
+		return nil
+	}
+
+	digest, err := signature.DigestFile(path)
+	if err != nil {
+		return err
+	}
+
+	return verifyResource(manifest, resource, digest)
+}
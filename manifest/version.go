@@ -49,6 +49,10 @@ type ManifestVersion struct {
 	Type     ManifestVersionType `yaml:"type,omitempty"`
 	Unikraft string              `yaml:"unikraft,omitempty"`
 	Local    string              `yaml:"-"`
+
+	// Dependencies are the components which this version requires in addition
+	// to the dependencies of the manifest
+	Dependencies []ManifestDependency `yaml:"dependencies,omitempty"`
 }
 
 func (mv *ManifestVersion) ShortGitSha() (string, error) {
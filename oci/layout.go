@@ -0,0 +1,687 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package oci
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	layoutFile    = "oci-layout"
+	layoutIndex   = "index.json"
+	layoutBlobs   = "blobs"
+	layoutVersion = "1.0.0"
+)
+
+// layoutMu serializes updates of the index of layouts by packages which are
+// packed in parallel.
+var layoutMu sync.Mutex
+
+// Layout is an OCI image layout on disk.  Each reference within the layout
+// points to an image index which holds the manifests of the image for each
+// platform.
+type Layout struct {
+	root string
+}
+
+type layoutHeader struct {
+	ImageLayoutVersion string `json:"imageLayoutVersion"`
+}
+
+// IsLayout checks whether the provided path is an OCI image layout.
+func IsLayout(path string) bool {
+	f, err := os.Stat(filepath.Join(path, layoutFile))
+	return err == nil && !f.IsDir()
+}
+
+// NewLayout opens the OCI image layout at the provided path, creating it if it
+// does not yet exist.
+func NewLayout(root string) (*Layout, error) {
+	l := &Layout{root: root}
+
+	if IsLayout(root) {
+		return l, nil
+	}
+
+	if err := os.MkdirAll(filepath.Join(root, layoutBlobs, "sha256"), 0o755); err != nil {
+		return nil, fmt.Errorf("could not create image layout: %v", err)
+	}
+
+	if err := l.writeFileJSON(layoutIndex, Index{
+		SchemaVersion: 2,
+		MediaType:     MediaTypeImageIndex,
+		Manifests:     []Descriptor{},
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := l.writeFileJSON(layoutFile, layoutHeader{
+		ImageLayoutVersion: layoutVersion,
+	}); err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
+
+// OpenLayout opens an existing OCI image layout.
+func OpenLayout(root string) (*Layout, error) {
+	if !IsLayout(root) {
+		return nil, fmt.Errorf("not an OCI image layout: %s", root)
+	}
+
+	return &Layout{root: root}, nil
+}
+
+// Root returns the path to the layout
+func (l *Layout) Root() string {
+	return l.root
+}
+
+// writeFileJSON atomically writes the JSON encoding of `v` to the file `name`
+// within the layout.
+func (l *Layout) writeFileJSON(name string, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("could not encode %s: %v", name, err)
+	}
+
+	tmp, err := os.CreateTemp(l.root, "."+name+"-*")
+	if err != nil {
+		return fmt.Errorf("could not write %s: %v", name, err)
+	}
+
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return fmt.Errorf("could not write %s: %v", name, err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("could not write %s: %v", name, err)
+	}
+
+	if err := os.Rename(tmp.Name(), filepath.Join(l.root, name)); err != nil {
+		return fmt.Errorf("could not write %s: %v", name, err)
+	}
+
+	return nil
+}
+
+// blobPath returns the path of the blob with the provided digest
+func (l *Layout) blobPath(digest string) (string, error) {
+	algorithm, hash, ok := strings.Cut(digest, ":")
+	if !ok || algorithm != "sha256" || len(hash) != sha256.Size*2 {
+		return "", fmt.Errorf("unsupported digest: %s", digest)
+	}
+
+	if _, err := hex.DecodeString(hash); err != nil {
+		return "", fmt.Errorf("invalid digest: %s", digest)
+	}
+
+	return filepath.Join(l.root, layoutBlobs, algorithm, hash), nil
+}
+
+// HasBlob checks whether the blob with the provided digest exists
+func (l *Layout) HasBlob(digest string) bool {
+	path, err := l.blobPath(digest)
+	if err != nil {
+		return false
+	}
+
+	_, err = os.Stat(path)
+	return err == nil
+}
+
+// WriteBlob stores the content of the reader as a blob and returns its
+// descriptor.
+func (l *Layout) WriteBlob(mediaType string, r io.Reader) (Descriptor, error) {
+	return l.writeBlob(mediaType, r, "")
+}
+
+// WriteDescriptor stores the content of the reader as the blob of the
+// descriptor.  The blob is discarded if its content does not match the digest
+// of the descriptor.
+func (l *Layout) WriteDescriptor(desc Descriptor, r io.Reader) error {
+	if _, err := l.blobPath(desc.Digest); err != nil {
+		return err
+	}
+
+	_, err := l.writeBlob(desc.MediaType, r, desc.Digest)
+	return err
+}
+
+func (l *Layout) writeBlob(mediaType string, r io.Reader, expected string) (Descriptor, error) {
+	dir := filepath.Join(l.root, layoutBlobs, "sha256")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return Descriptor{}, fmt.Errorf("could not create blob directory: %v", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, ".blob-*")
+	if err != nil {
+		return Descriptor{}, fmt.Errorf("could not create blob: %v", err)
+	}
+
+	defer os.Remove(tmp.Name())
+
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, h), r)
+	if err != nil {
+		tmp.Close()
+		return Descriptor{}, fmt.Errorf("could not write blob: %v", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return Descriptor{}, fmt.Errorf("could not write blob: %v", err)
+	}
+
+	desc := Descriptor{
+		MediaType: mediaType,
+		Digest:    "sha256:" + hex.EncodeToString(h.Sum(nil)),
+		Size:      size,
+	}
+
+	if len(expected) > 0 && desc.Digest != expected {
+		return Descriptor{}, fmt.Errorf("blob does not match its digest: %s", expected)
+	}
+
+	path, err := l.blobPath(desc.Digest)
+	if err != nil {
+		return Descriptor{}, err
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return Descriptor{}, fmt.Errorf("could not write blob: %v", err)
+	}
+
+	return desc, nil
+}
+
+// WriteBlobJSON stores the JSON encoding of `v` as a blob and returns its
+// descriptor.
+func (l *Layout) WriteBlobJSON(mediaType string, v any) (Descriptor, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return Descriptor{}, fmt.Errorf("could not encode %s: %v", mediaType, err)
+	}
+
+	return l.WriteBlob(mediaType, strings.NewReader(string(b)))
+}
+
+// OpenBlob opens the blob with the provided digest for reading.
+func (l *Layout) OpenBlob(digest string) (io.ReadCloser, error) {
+	path, err := l.blobPath(digest)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open blob: %v", err)
+	}
+
+	return f, nil
+}
+
+// ReadBlob returns the content of the blob of the descriptor after verifying
+// its digest.
+func (l *Layout) ReadBlob(desc Descriptor) ([]byte, error) {
+	f, err := l.OpenBlob(desc.Digest)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	b, err := io.ReadAll(f)
+	if err != nil {
+		return nil, fmt.Errorf("could not read blob: %v", err)
+	}
+
+	sum := sha256.Sum256(b)
+	if "sha256:"+hex.EncodeToString(sum[:]) != desc.Digest {
+		return nil, fmt.Errorf("blob does not match its digest: %s", desc.Digest)
+	}
+
+	return b, nil
+}
+
+// ReadBlobJSON decodes the blob of the descriptor into `v` after verifying its
+// digest.
+func (l *Layout) ReadBlobJSON(desc Descriptor, v any) error {
+	b, err := l.ReadBlob(desc)
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("could not decode %s: %v", desc.MediaType, err)
+	}
+
+	return nil
+}
+
+// Index returns the top-level index of the layout which references the images
+// by their name.
+func (l *Layout) Index() (Index, error) {
+	var index Index
+
+	b, err := os.ReadFile(filepath.Join(l.root, layoutIndex))
+	if err != nil {
+		return index, fmt.Errorf("could not read image layout index: %v", err)
+	}
+
+	if err := json.Unmarshal(b, &index); err != nil {
+		return index, fmt.Errorf("could not decode image layout index: %v", err)
+	}
+
+	return index, nil
+}
+
+// Refs returns the sorted list of references within the layout.
+func (l *Layout) Refs() ([]string, error) {
+	index, err := l.Index()
+	if err != nil {
+		return nil, err
+	}
+
+	var refs []string
+	for _, desc := range index.Manifests {
+		if ref, ok := desc.Annotations[AnnotationRefName]; ok {
+			refs = append(refs, ref)
+		}
+	}
+
+	sort.Strings(refs)
+
+	return refs, nil
+}
+
+// Resolve returns the descriptor which the reference points to.
+func (l *Layout) Resolve(ref string) (Descriptor, error) {
+	index, err := l.Index()
+	if err != nil {
+		return Descriptor{}, err
+	}
+
+	for _, desc := range index.Manifests {
+		if desc.Annotations[AnnotationRefName] == ref {
+			return desc, nil
+		}
+	}
+
+	return Descriptor{}, fmt.Errorf("could not find %s: %w", ref, os.ErrNotExist)
+}
+
+// Tag points the reference to the descriptor, replacing any previous
+// descriptor of the same reference.
+func (l *Layout) Tag(ref string, desc Descriptor) error {
+	layoutMu.Lock()
+	defer layoutMu.Unlock()
+
+	return l.tag(ref, desc)
+}
+
+func (l *Layout) tag(ref string, desc Descriptor) error {
+	index, err := l.Index()
+	if err != nil {
+		return err
+	}
+
+	annotations := map[string]string{}
+	for k, v := range desc.Annotations {
+		annotations[k] = v
+	}
+	annotations[AnnotationRefName] = ref
+	desc.Annotations = annotations
+
+	manifests := []Descriptor{}
+	for _, existing := range index.Manifests {
+		if existing.Annotations[AnnotationRefName] != ref {
+			manifests = append(manifests, existing)
+		}
+	}
+
+	index.Manifests = append(manifests, desc)
+
+	return l.writeFileJSON(layoutIndex, index)
+}
+
+// Untag removes the reference from the layout.  The blobs of the reference are
+// retained.
+func (l *Layout) Untag(ref string) error {
+	layoutMu.Lock()
+	defer layoutMu.Unlock()
+
+	index, err := l.Index()
+	if err != nil {
+		return err
+	}
+
+	manifests := []Descriptor{}
+	for _, existing := range index.Manifests {
+		if existing.Annotations[AnnotationRefName] != ref {
+			manifests = append(manifests, existing)
+		}
+	}
+
+	if len(manifests) == len(index.Manifests) {
+		return fmt.Errorf("could not find %s: %w", ref, os.ErrNotExist)
+	}
+
+	index.Manifests = manifests
+
+	return l.writeFileJSON(layoutIndex, index)
+}
+
+// ImageIndex returns the image index the reference points to.
+func (l *Layout) ImageIndex(ref string) (Index, error) {
+	desc, err := l.Resolve(ref)
+	if err != nil {
+		return Index{}, err
+	}
+
+	if desc.MediaType != MediaTypeImageIndex {
+		return Index{}, fmt.Errorf("%s is not an image index: %s", ref, desc.MediaType)
+	}
+
+	var index Index
+	if err := l.ReadBlobJSON(desc, &index); err != nil {
+		return Index{}, err
+	}
+
+	return index, nil
+}
+
+// AddManifest adds the manifest of an image for a platform to the image index
+// of the reference.  A manifest of the same platform is replaced.
+func (l *Layout) AddManifest(ref string, desc Descriptor) error {
+	if desc.Platform == nil {
+		return fmt.Errorf("cannot add manifest without platform to %s", ref)
+	}
+
+	layoutMu.Lock()
+	defer layoutMu.Unlock()
+
+	index, err := l.ImageIndex(ref)
+	if errors.Is(err, os.ErrNotExist) {
+		index = Index{
+			SchemaVersion: 2,
+			MediaType:     MediaTypeImageIndex,
+		}
+	} else if err != nil {
+		return err
+	}
+
+	manifests := []Descriptor{}
+	for _, existing := range index.Manifests {
+		if existing.Platform == nil || *existing.Platform != *desc.Platform {
+			manifests = append(manifests, existing)
+		}
+	}
+
+	index.Manifests = append(manifests, desc)
+
+	indexDesc, err := l.WriteBlobJSON(MediaTypeImageIndex, index)
+	if err != nil {
+		return err
+	}
+
+	return l.tag(ref, indexDesc)
+}
+
+// Manifest returns the manifest of the image for the platform which the
+// reference points to.
+func (l *Layout) Manifest(ref string, platform Platform) (Manifest, error) {
+	index, err := l.ImageIndex(ref)
+	if err != nil {
+		return Manifest{}, err
+	}
+
+	for _, desc := range index.Manifests {
+		if desc.Platform == nil || *desc.Platform != platform {
+			continue
+		}
+
+		var manifest Manifest
+		if err := l.ReadBlobJSON(desc, &manifest); err != nil {
+			return Manifest{}, err
+		}
+
+		return manifest, nil
+	}
+
+	return Manifest{}, fmt.Errorf("could not find %s for %s: %w", ref, platform, os.ErrNotExist)
+}
+
+// Image is the image of a reference for a single platform which has been
+// unpacked from a layout.
+type Image struct {
+	// Kernel is the path to the unpacked kernel
+	Kernel string
+
+	// Initrd is the path to the unpacked initial ramdisk, if any
+	Initrd string
+
+	// Command is the list of default arguments passed to the application
+	Command []string
+
+	// Platform is the platform of the image
+	Platform Platform
+}
+
+// Unpack writes the kernel and the optional initial ramdisk of the image of the
+// reference for the platform into the directory.
+func (l *Layout) Unpack(ref string, platform Platform, dir string) (*Image, error) {
+	manifest, err := l.Manifest(ref, platform)
+	if err != nil {
+		return nil, err
+	}
+
+	var config ImageConfig
+	if err := l.ReadBlobJSON(manifest.Config, &config); err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("could not create directory: %v", err)
+	}
+
+	image := &Image{
+		Command:  config.Config.Cmd,
+		Platform: platform,
+	}
+
+	kernel, ok := manifest.Layer(MediaTypeKernel)
+	if !ok {
+		return nil, fmt.Errorf("%s for %s does not contain a kernel", ref, platform)
+	}
+
+	if image.Kernel, err = l.unpackLayer(kernel, dir, "kernel"); err != nil {
+		return nil, err
+	}
+
+	if initrd, ok := manifest.Layer(MediaTypeInitrd); ok {
+		if image.Initrd, err = l.unpackLayer(initrd, dir, "initrd"); err != nil {
+			return nil, err
+		}
+	}
+
+	return image, nil
+}
+
+// unpackLayer copies the blob of the layer into the directory using its title
+// as the name of the file, or the fallback if it has none.
+func (l *Layout) unpackLayer(layer Descriptor, dir, fallback string) (string, error) {
+	name := filepath.Base(layer.Annotations[AnnotationTitle])
+	if name == "." || name == "/" || name == ".." {
+		name = fallback
+	}
+
+	src, err := l.OpenBlob(layer.Digest)
+	if err != nil {
+		return "", err
+	}
+
+	defer src.Close()
+
+	path := filepath.Join(dir, name)
+
+	dst, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o755)
+	if err != nil {
+		return "", fmt.Errorf("could not unpack %s: %v", name, err)
+	}
+
+	defer dst.Close()
+
+	if _, err := io.Copy(dst, src); err != nil {
+		return "", fmt.Errorf("could not unpack %s: %v", name, err)
+	}
+
+	return path, nil
+}
+
+// Size returns the size in bytes of the manifest of the descriptor including
+// its configuration and its layers.
+func (l *Layout) Size(desc Descriptor) (int64, error) {
+	var manifest Manifest
+	if err := l.ReadBlobJSON(desc, &manifest); err != nil {
+		return 0, err
+	}
+
+	size := desc.Size + manifest.Config.Size
+	for _, layer := range manifest.Layers {
+		size += layer.Size
+	}
+
+	return size, nil
+}
+
+// Delete removes the reference from the layout together with all blobs which
+// are no longer referenced.
+func (l *Layout) Delete(ref string) error {
+	if err := l.Untag(ref); err != nil {
+		return err
+	}
+
+	return l.GC()
+}
+
+// GC removes all blobs which are not reachable from the references of the
+// layout.
+func (l *Layout) GC() error {
+	layoutMu.Lock()
+	defer layoutMu.Unlock()
+
+	index, err := l.Index()
+	if err != nil {
+		return err
+	}
+
+	reachable := make(map[string]bool)
+
+	var mark func(desc Descriptor) error
+	mark = func(desc Descriptor) error {
+		if reachable[desc.Digest] {
+			return nil
+		}
+
+		reachable[desc.Digest] = true
+
+		switch desc.MediaType {
+		case MediaTypeImageIndex:
+			var index Index
+			if err := l.ReadBlobJSON(desc, &index); err != nil {
+				return err
+			}
+
+			for _, m := range index.Manifests {
+				if err := mark(m); err != nil {
+					return err
+				}
+			}
+
+		case MediaTypeImageManifest:
+			var manifest Manifest
+			if err := l.ReadBlobJSON(desc, &manifest); err != nil {
+				return err
+			}
+
+			reachable[manifest.Config.Digest] = true
+			for _, layer := range manifest.Layers {
+				reachable[layer.Digest] = true
+			}
+		}
+
+		return nil
+	}
+
+	for _, desc := range index.Manifests {
+		if err := mark(desc); err != nil {
+			return err
+		}
+	}
+
+	dir := filepath.Join(l.root, layoutBlobs, "sha256")
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("could not list blobs: %v", err)
+	}
+
+	for _, entry := range entries {
+		// Skip blobs which are still being written
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+
+		if reachable["sha256:"+entry.Name()] {
+			continue
+		}
+
+		if err := os.Remove(filepath.Join(dir, entry.Name())); err != nil {
+			return fmt.Errorf("could not remove blob: %v", err)
+		}
+	}
+
+	return nil
+}
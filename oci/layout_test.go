@@ -0,0 +1,392 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package oci
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"kraftkit.sh/config"
+	"kraftkit.sh/initrd"
+	"kraftkit.sh/internal/logger"
+	"kraftkit.sh/iostreams"
+	"kraftkit.sh/pack"
+	"kraftkit.sh/packmanager"
+	"kraftkit.sh/unikraft"
+)
+
+func testPackage(t *testing.T, layout, kernel, plat, arch string, popts ...pack.PackageOption) {
+	t.Helper()
+
+	opts, err := pack.NewPackageOptions(append([]pack.PackageOption{
+		pack.WithName("helloworld"),
+		pack.WithVersion("0.1.0"),
+		pack.WithType(unikraft.ComponentTypeApp),
+		pack.WithPlatform(plat),
+		pack.WithArchitecture(arch),
+		pack.WithKernel(kernel),
+		pack.WithCommand([]string{"-v"}),
+		pack.WithLocalLocation(layout, true),
+		pack.WithLogger(logger.NewLogger(io.Discard, iostreams.NewColorScheme(false, false, false))),
+	}, popts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := NewPackageFromOptions(context.TODO(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.Pack(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLayoutPlatforms(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "oci")
+
+	kernel := filepath.Join(dir, "helloworld_kvm-x86_64")
+	if err := os.WriteFile(kernel, []byte("kernel"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	rootfs := filepath.Join(dir, "rootfs")
+	if err := os.MkdirAll(filepath.Join(rootfs, "etc"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(rootfs, "etc", "hostname"), []byte("unikraft"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	testPackage(t, root, kernel, "kvm", "x86_64", pack.WithInitrdConfig(&initrd.InitrdConfig{
+		Input:  []string{rootfs},
+		Format: initrd.NEWC,
+	}))
+	testPackage(t, root, kernel, "kvm", "arm64")
+
+	// Packaging the same platform again replaces its manifest
+	testPackage(t, root, kernel, "kvm", "arm64")
+
+	layout, err := OpenLayout(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	refs, err := layout.Refs()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(refs) != 1 || refs[0] != "helloworld:0.1.0" {
+		t.Fatalf("unexpected refs: %v", refs)
+	}
+
+	index, err := layout.ImageIndex("helloworld:0.1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(index.Manifests) != 2 {
+		t.Fatalf("expected 2 manifests, got %d", len(index.Manifests))
+	}
+
+	manifest, err := layout.Manifest("helloworld:0.1.0", NewPlatform("kvm", "x86_64"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := manifest.Layer(MediaTypeKernel); !ok {
+		t.Errorf("expected kernel layer")
+	}
+
+	desc, ok := manifest.Layer(MediaTypeInitrd)
+	if !ok {
+		t.Fatalf("expected initrd layer")
+	}
+
+	if !layout.HasBlob(desc.Digest) {
+		t.Errorf("missing initrd blob %s", desc.Digest)
+	}
+
+	var config ImageConfig
+	if err := layout.ReadBlobJSON(manifest.Config, &config); err != nil {
+		t.Fatal(err)
+	}
+
+	if config.Architecture != "amd64" || config.OS != "kvm" {
+		t.Errorf("unexpected config platform: %s/%s", config.OS, config.Architecture)
+	}
+
+	if len(config.Config.Cmd) != 1 || config.Config.Cmd[0] != "-v" {
+		t.Errorf("unexpected config command: %v", config.Config.Cmd)
+	}
+
+	manifest, err = layout.Manifest("helloworld:0.1.0", NewPlatform("kvm", "arm64"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := manifest.Layer(MediaTypeInitrd); ok {
+		t.Errorf("unexpected initrd layer for arm64")
+	}
+
+	if _, err := layout.Manifest("helloworld:0.1.0", NewPlatform("xen", "x86_64")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected missing platform")
+	}
+}
+
+func TestLayoutPlatformAlias(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "oci")
+
+	kernel := filepath.Join(dir, "kernel")
+	if err := os.WriteFile(kernel, []byte("kernel"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Aliases of the platform and architecture are recorded by their canonical
+	// names such that the image matches queries for either
+	testPackage(t, root, kernel, "qemu", "amd64")
+
+	layout, err := OpenLayout(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manifest, err := layout.Manifest("helloworld:0.1.0", NewPlatform("kvm", "x86_64"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var config ImageConfig
+	if err := layout.ReadBlobJSON(manifest.Config, &config); err != nil {
+		t.Fatal(err)
+	}
+
+	if config.Architecture != "amd64" || config.OS != "kvm" {
+		t.Errorf("unexpected config platform: %s/%s", config.OS, config.Architecture)
+	}
+
+	for _, plats := range [][]string{{"kvm"}, {"qemu"}, {"firecracker"}} {
+		if !matchPlatform(Platform{Architecture: "amd64", OS: "kvm"}, []string{"x86_64"}, plats) {
+			t.Errorf("expected kvm/amd64 to match %v", plats)
+		}
+	}
+
+	if matchPlatform(Platform{Architecture: "amd64", OS: "kvm"}, nil, []string{"linuxu"}) {
+		t.Errorf("expected kvm/amd64 not to match linuxu")
+	}
+
+	if matchPlatform(Platform{Architecture: "arm64", OS: "kvm"}, []string{"x86_64"}, nil) {
+		t.Errorf("expected kvm/arm64 not to match x86_64")
+	}
+}
+
+func TestManagerCatalog(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "oci")
+
+	kernel := filepath.Join(dir, "kernel")
+	if err := os.WriteFile(kernel, []byte("kernel"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	testPackage(t, root, kernel, "kvm", "x86_64")
+	testPackage(t, root, kernel, "xen", "x86_64")
+
+	options, err := packmanager.NewPackageManagerOptions(context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pm, err := NewOCIPackageManagerFromOptions(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := pm.IsCompatible(root); err != nil {
+		t.Errorf("expected layout to be compatible: %v", err)
+	}
+
+	packages, err := pm.Catalog(packmanager.CatalogQuery{
+		Source: root,
+		Name:   "hello*",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(packages) != 2 {
+		t.Fatalf("expected 2 packages, got %d", len(packages))
+	}
+
+	for _, p := range packages {
+		if p.Options().ArchPlatString() != "kvm/x86_64" && p.Options().ArchPlatString() != "xen/x86_64" {
+			t.Errorf("unexpected package platform: %s", p.Options().ArchPlatString())
+		}
+	}
+
+	packages, err = pm.Catalog(packmanager.CatalogQuery{
+		Source:  root,
+		Version: "0.2.0",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(packages) != 0 {
+		t.Errorf("expected no packages, got %d", len(packages))
+	}
+}
+
+func TestManagerLocal(t *testing.T) {
+	dir := t.TempDir()
+	store := filepath.Join(dir, "packages")
+
+	kernel := filepath.Join(dir, "kernel")
+	if err := os.WriteFile(kernel, []byte("kernel"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Both versions share the same kernel blob
+	testPackage(t, store, kernel, "kvm", "x86_64", pack.WithWorkdir(dir))
+	testPackage(t, store, kernel, "kvm", "x86_64", pack.WithVersion("0.2.0"))
+
+	cfgm := &config.ConfigManager{Config: &config.Config{}}
+	cfgm.Config.Paths.Packages = store
+
+	options, err := packmanager.NewPackageManagerOptions(context.TODO(),
+		packmanager.WithConfigManager(cfgm),
+		packmanager.WithLogger(logger.NewLogger(io.Discard, iostreams.NewColorScheme(false, false, false))),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pm, err := NewOCIPackageManagerFromOptions(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	packages, err := pm.Catalog(packmanager.CatalogQuery{Local: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(packages) != 2 {
+		t.Fatalf("expected 2 packages, got %d", len(packages))
+	}
+
+	packages, err = pm.Catalog(packmanager.CatalogQuery{
+		Source: "helloworld:0.1.0",
+		Local:  true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(packages) != 1 {
+		t.Fatalf("expected 1 package, got %d", len(packages))
+	}
+
+	popts := packages[0].Options()
+	if packages[0].CanonicalName() != "helloworld:0.1.0" {
+		t.Errorf("unexpected reference: %s", packages[0].CanonicalName())
+	}
+
+	if popts.Size <= int64(len("kernel")) {
+		t.Errorf("unexpected size: %d", popts.Size)
+	}
+
+	if popts.Created.IsZero() {
+		t.Errorf("expected creation time")
+	}
+
+	if popts.Source != dir {
+		t.Errorf("unexpected source: %s", popts.Source)
+	}
+
+	if len(popts.Digest) == 0 {
+		t.Errorf("expected digest")
+	}
+
+	layout, err := OpenLayout(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manifest, err := layout.Manifest("helloworld:0.1.0", NewPlatform("kvm", "x86_64"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	desc, ok := manifest.Layer(MediaTypeKernel)
+	if !ok {
+		t.Fatalf("expected kernel layer")
+	}
+
+	if err := pm.Delete("helloworld:0.1.0"); err != nil {
+		t.Fatal(err)
+	}
+
+	if !layout.HasBlob(desc.Digest) {
+		t.Errorf("expected shared kernel blob to be retained")
+	}
+
+	if err := pm.Delete("helloworld:0.2.0"); err != nil {
+		t.Fatal(err)
+	}
+
+	if layout.HasBlob(desc.Digest) {
+		t.Errorf("expected kernel blob to be removed")
+	}
+
+	if err := pm.Delete("helloworld:0.2.0"); err == nil {
+		t.Errorf("expected error when deleting a missing package")
+	}
+
+	packages, err = pm.Catalog(packmanager.CatalogQuery{Local: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(packages) != 0 {
+		t.Errorf("expected no packages, got %d", len(packages))
+	}
+}
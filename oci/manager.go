@@ -0,0 +1,530 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package oci
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gobwas/glob"
+
+	"kraftkit.sh/config"
+	"kraftkit.sh/pack"
+	"kraftkit.sh/packmanager"
+	"kraftkit.sh/signature"
+	"kraftkit.sh/unikraft"
+)
+
+type OCIManager struct {
+	opts *packmanager.PackageManagerOptions
+}
+
+func init() {
+	options, err := packmanager.NewPackageManagerOptions(
+		context.TODO(),
+	)
+	if err != nil {
+		panic(fmt.Sprintf("could not register package manager options: %s", err))
+	}
+
+	manager, err := NewOCIPackageManagerFromOptions(options)
+	if err != nil {
+		panic(fmt.Sprintf("could not register package manager: %s", err))
+	}
+
+	// Register a new pack.Package type
+	packmanager.RegisterPackageManager(OCIContext, manager)
+}
+
+func NewOCIPackageManagerFromOptions(opts *packmanager.PackageManagerOptions) (packmanager.PackageManager, error) {
+	return OCIManager{
+		opts: opts,
+	}, nil
+}
+
+// NewPackageFromOptions initializes a new package which is stored in the local
+// package store unless a location is provided
+func (om OCIManager) NewPackageFromOptions(ctx context.Context, opts *pack.PackageOptions) ([]pack.Package, error) {
+	if len(opts.LocalLocation) == 0 {
+		opts.LocalLocation = om.layoutPath()
+	}
+
+	p, err := NewPackageFromOptions(ctx, opts)
+	return []pack.Package{p}, err
+}
+
+// layoutPath returns the path to the image layout of the local package store
+func (om OCIManager) layoutPath() string {
+	if om.opts.ConfigManager != nil && len(om.opts.ConfigManager.Config.Paths.Packages) > 0 {
+		return om.opts.ConfigManager.Config.Paths.Packages
+	}
+
+	return filepath.Join(config.DataDir(), "packages")
+}
+
+// auths returns the configured credentials of registries
+func (om OCIManager) auths() map[string]config.AuthConfig {
+	if om.opts.ConfigManager == nil {
+		return nil
+	}
+
+	return om.opts.ConfigManager.Config.Auth
+}
+
+// Options allows you to view the current options.
+func (om OCIManager) Options() *packmanager.PackageManagerOptions {
+	return om.opts
+}
+
+func (om OCIManager) ApplyOptions(pmopts ...packmanager.PackageManagerOption) error {
+	for _, opt := range pmopts {
+		if err := opt(om.opts); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Update is a no-op as image layouts are not cached
+func (om OCIManager) Update() error {
+	return nil
+}
+
+// AddSource is a no-op as image layouts are provided as the source of a query
+func (om OCIManager) AddSource(source string) error {
+	return nil
+}
+
+// RemoveSource is a no-op as image layouts are provided as the source of a
+// query
+func (om OCIManager) RemoveSource(source string) error {
+	return nil
+}
+
+// verifier returns the verifier of signatures of the configuration
+func (om OCIManager) verifier() (*signature.Verifier, error) {
+	if om.opts.ConfigManager == nil {
+		return nil, nil
+	}
+
+	return signature.NewVerifierFromConfig(om.opts.ConfigManager.Config)
+}
+
+// Delete removes the image of the reference from the local package store
+// together with all of its content which is no longer used by other images.
+func (om OCIManager) Delete(ref string) error {
+	store, err := OpenLayout(om.layoutPath())
+	if err != nil {
+		return err
+	}
+
+	ref, err = store.Find(ref)
+	if err != nil {
+		return err
+	}
+
+	om.opts.Log.Infof("removing %s", ref)
+
+	return store.Delete(ref)
+}
+
+// Push the image of the reference from the local package store to its
+// registry.  The image is found in the store either by the full reference or by
+// the last component of its repository and its tag, e.g.: pushing
+// `unikraft.io/library/nginx:1.21` pushes the local `nginx:1.21`.
+func (om OCIManager) Push(source string) error {
+	ref, err := ParseReference(source)
+	if err != nil {
+		return err
+	}
+
+	layout, err := OpenLayout(om.layoutPath())
+	if err != nil {
+		return err
+	}
+
+	local := ref.String()
+	if _, err := layout.Resolve(local); err != nil {
+		local = ref.Name() + ":" + ref.Object()
+		if _, err := layout.Resolve(local); err != nil {
+			return fmt.Errorf("could not find %s in %s", source, layout.Root())
+		}
+	}
+
+	registry, err := NewRegistry(ref.Registry, om.auths())
+	if err != nil {
+		return err
+	}
+
+	om.opts.Log.Infof("pushing %s to %s", local, ref)
+
+	return Push(om.opts.Context(), registry, layout, local, ref, nil)
+}
+
+// Pull the image of the reference from its registry into the local package
+// store for the requested architectures and platforms, or for all if none are
+// requested.
+func (om OCIManager) Pull(source string, opts *pack.PullPackageOptions) ([]pack.Package, error) {
+	ref, err := ParseReference(source)
+	if err != nil {
+		return nil, fmt.Errorf("incompatible source: %v", err)
+	}
+
+	registry, err := NewRegistry(ref.Registry, om.auths())
+	if err != nil {
+		return nil, err
+	}
+
+	layout, err := NewLayout(om.layoutPath())
+	if err != nil {
+		return nil, err
+	}
+
+	var archs []string
+	var plats []string
+	var onProgress func(float64)
+
+	if opts != nil {
+		archs = opts.Architectures()
+		plats = opts.Platforms()
+		onProgress = opts.OnProgress
+	}
+
+	verifier, err := om.verifier()
+	if err != nil {
+		return nil, err
+	}
+
+	descs, err := Pull(om.opts.Context(), registry, ref, layout, func(p Platform) bool {
+		return matchPlatform(p, archs, plats)
+	}, verifyDescriptor(verifier, om.opts.Log, ref), onProgress)
+	if err != nil {
+		return nil, err
+	}
+
+	var packages []pack.Package
+	for _, desc := range descs {
+		p, err := om.newPackage(desc, ref.String(), layout.Root(), ref.Name(), ref.Tag)
+		if err != nil {
+			return nil, err
+		}
+
+		packages = append(packages, p)
+	}
+
+	return packages, nil
+}
+
+// matchPlatform checks whether the platform is one of the requested Unikraft
+// architectures and platforms.
+func matchPlatform(p Platform, archs, plats []string) bool {
+	if len(archs) > 0 {
+		found := false
+		for _, arch := range archs {
+			if ArchitectureFromUnikraft(arch) == p.Architecture {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if len(plats) > 0 {
+		found := false
+		for _, plat := range plats {
+			if PlatformFromUnikraft(plat) == PlatformFromUnikraft(p.OS) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (om OCIManager) From(sub string) (packmanager.PackageManager, error) {
+	return nil, fmt.Errorf("method not applicable to oci manager")
+}
+
+// Catalog returns the images of the source of the query, which is either an
+// image layout or the reference of an image within a registry.  Every platform
+// of an image is returned as a separate package.  Unless the cache is bypassed,
+// an image of a registry which has been pulled before is served from the local
+// package store.  A local query lists the local package store instead.
+func (om OCIManager) Catalog(query packmanager.CatalogQuery, popts ...pack.PackageOption) ([]pack.Package, error) {
+	if len(query.Source) == 0 && !query.Local {
+		return nil, nil
+	}
+
+	if len(query.Types) > 0 {
+		found := false
+		for _, t := range query.Types {
+			if t == unikraft.ComponentTypeApp {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, nil
+		}
+	}
+
+	var g glob.Glob
+	if len(query.Name) > 0 {
+		g = glob.MustCompile(query.Name)
+	}
+
+	var packages []pack.Package
+
+	add := func(desc Descriptor, ref string, layout *Layout) {
+		name, version := splitRef(ref)
+
+		p, err := om.newPackage(desc, ref, layout.Root(), name, version, popts...)
+		if err != nil {
+			om.opts.Log.Warnf("%v", err)
+			return
+		}
+
+		if g != nil && !g.Match(p.Name()) {
+			return
+		}
+
+		if len(query.Version) > 0 && p.Options().Version != query.Version && version != query.Version {
+			return
+		}
+
+		if size, err := layout.Size(desc); err == nil {
+			p.Options().Size = size
+		}
+
+		packages = append(packages, p)
+	}
+
+	// addLayout adds the packages of all references of the layout accepted by
+	// `match`
+	addLayout := func(layout *Layout, match func(ref string) bool) error {
+		refs, err := layout.Refs()
+		if err != nil {
+			return err
+		}
+
+		for _, ref := range refs {
+			if !match(ref) {
+				continue
+			}
+
+			index, err := layout.ImageIndex(ref)
+			if err != nil {
+				om.opts.Log.Warnf("%v", err)
+				continue
+			}
+
+			for _, desc := range index.Manifests {
+				add(desc, ref, layout)
+			}
+		}
+
+		return nil
+	}
+
+	if query.Local {
+		store, err := OpenLayout(om.layoutPath())
+		if err != nil {
+			// The local package store does not exist until something is stored
+			return nil, nil
+		}
+
+		if err := addLayout(store, func(ref string) bool {
+			if len(query.Source) == 0 || ref == query.Source {
+				return true
+			}
+
+			name, version := splitRef(ref)
+			return name+":"+version == query.Source
+		}); err != nil {
+			return nil, err
+		}
+
+		return packages, nil
+	}
+
+	if IsLayout(query.Source) {
+		layout, err := OpenLayout(query.Source)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := addLayout(layout, func(string) bool { return true }); err != nil {
+			return nil, err
+		}
+
+		return packages, nil
+	}
+
+	ref, err := ParseReference(query.Source)
+	if err != nil {
+		return nil, nil
+	}
+
+	// Prefer the image previously pulled into the local package store
+	if !query.NoCache {
+		if store, err := OpenLayout(om.layoutPath()); err == nil {
+			if _, err := store.ImageIndex(ref.String()); err == nil {
+				if err := addLayout(store, func(r string) bool { return r == ref.String() }); err != nil {
+					return nil, err
+				}
+
+				return packages, nil
+			}
+		}
+	}
+
+	registry, err := NewRegistry(ref.Registry, om.auths())
+	if err != nil {
+		return nil, err
+	}
+
+	descs, err := Platforms(om.opts.Context(), registry, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, desc := range descs {
+		p, err := om.newPackage(desc, ref.String(), om.layoutPath(), ref.Name(), ref.Tag, popts...)
+		if err != nil {
+			om.opts.Log.Warnf("%v", err)
+			continue
+		}
+
+		if g != nil && !g.Match(p.Name()) {
+			continue
+		}
+
+		packages = append(packages, p)
+	}
+
+	return packages, nil
+}
+
+// splitRef returns the name and the version of a reference within a layout
+func splitRef(ref string) (string, string) {
+	if r, err := ParseReference(ref); err == nil {
+		return r.Name(), r.Object()
+	}
+
+	name, version, _ := strings.Cut(ref, ":")
+	return name, version
+}
+
+// newPackage returns the package of the manifest of a single platform.  The
+// name and the version of the package are taken from the annotations of the
+// manifest, falling back to the provided values.  The package is stored within
+// the layout at the location.
+func (om OCIManager) newPackage(desc Descriptor, ref, location, name, version string, popts ...pack.PackageOption) (pack.Package, error) {
+	if desc.Platform == nil {
+		return nil, fmt.Errorf("manifest %s of %s does not specify its platform", desc.Digest, ref)
+	}
+
+	if n, ok := desc.Annotations[AnnotationName]; ok {
+		name = n
+	}
+
+	if v, ok := desc.Annotations[AnnotationVersion]; ok {
+		version = v
+	}
+
+	arch, ok := desc.Annotations[AnnotationArchitecture]
+	if !ok {
+		arch = ArchitectureToUnikraft(desc.Platform.Architecture)
+	}
+
+	plat, ok := desc.Annotations[AnnotationPlatform]
+	if !ok {
+		plat = desc.Platform.OS
+	}
+
+	pkgOpts, err := pack.NewPackageOptions(append(popts,
+		pack.WithName(name),
+		pack.WithVersion(version),
+		pack.WithType(unikraft.ComponentTypeApp),
+		pack.WithArchitecture(arch),
+		pack.WithPlatform(plat),
+		pack.WithRemoteLocation(ref),
+		pack.WithLocalLocation(location, true),
+		pack.WithLogger(om.opts.Log),
+	)...)
+	if err != nil {
+		return nil, err
+	}
+
+	verifier, err := om.verifier()
+	if err != nil {
+		return nil, err
+	}
+
+	pkgOpts.Digest = desc.Digest
+	pkgOpts.Source = desc.Annotations[AnnotationSource]
+	if created, err := time.Parse(time.RFC3339, desc.Annotations[AnnotationCreated]); err == nil {
+		pkgOpts.Created = created
+	}
+
+	return OCIPackage{
+		PackageOptions: pkgOpts,
+		ref:            ref,
+		verifier:       verifier,
+		auths:          om.auths(),
+		ctx:            om.opts.Context(),
+	}, nil
+}
+
+// IsCompatible checks whether the source is an OCI image layout or the
+// reference of an image within a registry
+func (om OCIManager) IsCompatible(source string) (packmanager.PackageManager, error) {
+	if !IsLayout(source) && !IsReference(source) {
+		return nil, fmt.Errorf("incompatible source")
+	}
+
+	return om, nil
+}
+
+func (om OCIManager) Format() string {
+	return string(OCIContext)
+}
@@ -0,0 +1,322 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package oci
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"kraftkit.sh/config"
+	"kraftkit.sh/initrd"
+	"kraftkit.sh/machine"
+	"kraftkit.sh/pack"
+	"kraftkit.sh/signature"
+	"kraftkit.sh/unikraft/app"
+)
+
+type OCIPackage struct {
+	*pack.PackageOptions
+
+	// ref is the reference of the image within its registry or layout
+	ref      string
+	auths    map[string]config.AuthConfig
+	verifier *signature.Verifier
+	ctx      context.Context
+}
+
+const (
+	OCIContext pack.ContextKey = "oci"
+
+	// DefaultLayoutDir is the directory within the output directory of a
+	// project where images are stored when no output is provided.
+	DefaultLayoutDir = "oci"
+)
+
+// NewPackageFromOptions generates an OCI implementation of the pack.Package
+// construct based on the input options
+func NewPackageFromOptions(ctx context.Context, opts *pack.PackageOptions) (pack.Package, error) {
+	if ctx == nil {
+		return nil, fmt.Errorf("cannot create NewPackageFromOptions without context")
+	}
+
+	return OCIPackage{
+		PackageOptions: opts,
+		ctx:            ctx,
+	}, nil
+}
+
+func (op OCIPackage) ApplyOptions(opts ...pack.PackageOption) error {
+	for _, o := range opts {
+		if err := o(op.PackageOptions); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (op OCIPackage) Options() *pack.PackageOptions {
+	return op.PackageOptions
+}
+
+func (op OCIPackage) Name() string {
+	return op.PackageOptions.Name
+}
+
+// CanonicalName returns the reference of the image within its registry or
+// layout, or the name and version of the package when it has not been stored
+// yet.
+func (op OCIPackage) CanonicalName() string {
+	if len(op.ref) > 0 {
+		return op.ref
+	}
+
+	return op.PackageOptions.Name + ":" + op.PackageOptions.Version
+}
+
+// platform returns the OCI platform of the package
+func (op OCIPackage) platform() (Platform, error) {
+	if op.Architecture == nil || op.Platform == nil {
+		return Platform{}, fmt.Errorf("cannot determine platform of %s without architecture and platform", op.CanonicalName())
+	}
+
+	return NewPlatform(*op.Platform, *op.Architecture), nil
+}
+
+// layoutPath returns the path to the image layout the package is stored in
+func (op OCIPackage) layoutPath() string {
+	if len(op.LocalLocation) > 0 {
+		return op.LocalLocation
+	}
+
+	return filepath.Join(op.Workdir(), app.DefaultOutputDir, DefaultLayoutDir)
+}
+
+// Pack the kernel, the optional initial ramdisk and the default command of the
+// package as an image within the image layout.
+func (op OCIPackage) Pack() error {
+	platform, err := op.platform()
+	if err != nil {
+		return err
+	}
+
+	if len(op.Kernel) == 0 {
+		return fmt.Errorf("cannot package %s without kernel", op.CanonicalName())
+	}
+
+	layout, err := NewLayout(op.layoutPath())
+	if err != nil {
+		return err
+	}
+
+	created := time.Now().UTC()
+
+	annotations := map[string]string{
+		AnnotationCreated:      created.Format(time.RFC3339),
+		AnnotationName:         op.PackageOptions.Name,
+		AnnotationVersion:      op.PackageOptions.Version,
+		AnnotationArchitecture: machine.ArchitectureName(*op.Architecture),
+		AnnotationPlatform:     PlatformFromUnikraft(*op.Platform),
+	}
+
+	if len(op.Workdir()) > 0 {
+		annotations[AnnotationSource] = op.Workdir()
+	}
+
+	op.Log().Debugf("adding kernel %s", op.Kernel)
+
+	kernel, err := op.writeLayer(layout, MediaTypeKernel, op.Kernel)
+	if err != nil {
+		return err
+	}
+
+	layers := []Descriptor{kernel}
+
+	if op.Initrd != nil {
+		path, err := op.Initrd.Build()
+		if err != nil {
+			return fmt.Errorf("could not build initrd: %v", err)
+		}
+
+		if len(op.Initrd.Output) == 0 {
+			defer os.Remove(path)
+		}
+
+		op.Log().Debugf("adding initrd %s", path)
+
+		initrd, err := op.writeLayer(layout, MediaTypeInitrd, path)
+		if err != nil {
+			return err
+		}
+
+		layers = append(layers, initrd)
+	}
+
+	diffIDs := make([]string, len(layers))
+	for i, layer := range layers {
+		diffIDs[i] = layer.Digest
+	}
+
+	config, err := layout.WriteBlobJSON(MediaTypeImageConfig, ImageConfig{
+		Created:      &created,
+		Architecture: platform.Architecture,
+		OS:           platform.OS,
+		Config: ImageRunConfig{
+			Cmd: op.Command,
+		},
+		RootFS: ImageRootFS{
+			Type:    "layers",
+			DiffIDs: diffIDs,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	manifest, err := layout.WriteBlobJSON(MediaTypeImageManifest, Manifest{
+		SchemaVersion: 2,
+		MediaType:     MediaTypeImageManifest,
+		Config:        config,
+		Layers:        layers,
+		Annotations:   annotations,
+	})
+	if err != nil {
+		return err
+	}
+
+	manifest.Annotations = annotations
+	manifest.Platform = &platform
+
+	if err := layout.AddManifest(op.CanonicalName(), manifest); err != nil {
+		return err
+	}
+
+	op.Log().Infof("packaged %s for %s in %s", op.CanonicalName(), platform, layout.Root())
+
+	return nil
+}
+
+// writeLayer stores the file at the path as a layer of the provided media type
+func (op OCIPackage) writeLayer(layout *Layout, mediaType, path string) (Descriptor, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Descriptor{}, fmt.Errorf("could not open %s: %v", path, err)
+	}
+
+	defer f.Close()
+
+	desc, err := layout.WriteBlob(mediaType, f)
+	if err != nil {
+		return Descriptor{}, err
+	}
+
+	desc.Annotations = map[string]string{
+		AnnotationTitle: filepath.Base(path),
+	}
+
+	return desc, nil
+}
+
+func (op OCIPackage) Compatible(ref string) bool {
+	return IsLayout(ref)
+}
+
+// Pull the image of the package from its registry into the local layout of the
+// package, unless the cache is used and the layout already contains it.  If a
+// working directory is requested, the kernel and the initial ramdisk are
+// unpacked into it and the options of the package are updated to refer to them.
+func (op OCIPackage) Pull(opts ...pack.PullPackageOption) error {
+	popts, err := pack.NewPullPackageOptions(opts...)
+	if err != nil {
+		return err
+	}
+
+	platform, err := op.platform()
+	if err != nil {
+		return err
+	}
+
+	layout, err := NewLayout(op.layoutPath())
+	if err != nil {
+		return err
+	}
+
+	cached := false
+	if popts.UseCache() {
+		_, err := layout.Manifest(op.CanonicalName(), platform)
+		cached = err == nil
+	}
+
+	if ref, err := ParseReference(op.CanonicalName()); err == nil && !cached {
+		op.Log().Infof("pulling %s for %s", ref, platform)
+
+		registry, err := NewRegistry(ref.Registry, op.auths)
+		if err != nil {
+			return err
+		}
+
+		if _, err := Pull(op.ctx, registry, ref, layout, func(p Platform) bool {
+			return p == platform
+		}, verifyDescriptor(op.verifier, op.Log(), ref), popts.OnProgress); err != nil {
+			return err
+		}
+	}
+
+	if len(popts.Workdir()) == 0 {
+		return nil
+	}
+
+	image, err := layout.Unpack(op.CanonicalName(), platform, popts.Workdir())
+	if err != nil {
+		return err
+	}
+
+	op.Log().Infof("unpacked %s to %s", op.CanonicalName(), image.Kernel)
+
+	op.PackageOptions.Kernel = image.Kernel
+	op.PackageOptions.Command = image.Command
+	if len(image.Initrd) > 0 {
+		op.PackageOptions.Initrd = &initrd.InitrdConfig{
+			Output: image.Initrd,
+			Format: initrd.NEWC,
+		}
+	}
+
+	return nil
+}
+
+func (op OCIPackage) Format() string {
+	return string(OCIContext)
+}
@@ -0,0 +1,136 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package oci
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// DefaultTag is the tag of a reference which does not specify one
+const DefaultTag = "latest"
+
+var (
+	repositoryRegexp = regexp.MustCompile(`^[a-z0-9]+(?:(?:[._]|__|[-]*)[a-z0-9]+)*(?:/[a-z0-9]+(?:(?:[._]|__|[-]*)[a-z0-9]+)*)*$`)
+	tagRegexp        = regexp.MustCompile(`^[\w][\w.-]{0,127}$`)
+	digestRegexp     = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+)
+
+// Reference points to an image within a repository of a registry, either by
+// its tag or by its digest.
+type Reference struct {
+	Registry   string
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+// ParseReference parses a reference of the form
+// REGISTRY/REPOSITORY[:TAG][@DIGEST].  The registry is mandatory and is
+// recognised by containing a `.` or a `:`, or by being `localhost`.
+func ParseReference(s string) (Reference, error) {
+	var ref Reference
+
+	name, digest, ok := strings.Cut(s, "@")
+	if ok {
+		if !digestRegexp.MatchString(digest) {
+			return ref, fmt.Errorf("invalid digest in reference: %s", s)
+		}
+
+		ref.Digest = digest
+	}
+
+	registry, repository, ok := strings.Cut(name, "/")
+	if !ok || !(strings.ContainsAny(registry, ".:") || registry == "localhost") {
+		return ref, fmt.Errorf("reference does not contain a registry: %s", s)
+	}
+
+	if i := strings.LastIndex(repository, ":"); i >= 0 {
+		ref.Tag = repository[i+1:]
+		repository = repository[:i]
+
+		if !tagRegexp.MatchString(ref.Tag) {
+			return ref, fmt.Errorf("invalid tag in reference: %s", s)
+		}
+	}
+
+	if !repositoryRegexp.MatchString(repository) {
+		return ref, fmt.Errorf("invalid repository in reference: %s", s)
+	}
+
+	if len(ref.Tag) == 0 && len(ref.Digest) == 0 {
+		ref.Tag = DefaultTag
+	}
+
+	ref.Registry = registry
+	ref.Repository = repository
+
+	return ref, nil
+}
+
+// IsReference checks whether the provided string is a valid reference to an
+// image within a registry.
+func IsReference(s string) bool {
+	_, err := ParseReference(s)
+	return err == nil
+}
+
+// Name returns the last component of the repository, e.g.: the name of
+// `unikraft.io/library/nginx` is `nginx`.
+func (ref Reference) Name() string {
+	return path.Base(ref.Repository)
+}
+
+// Object returns the digest of the reference if set, otherwise the tag
+func (ref Reference) Object() string {
+	if len(ref.Digest) > 0 {
+		return ref.Digest
+	}
+
+	return ref.Tag
+}
+
+// String returns the reference in the form REGISTRY/REPOSITORY[:TAG][@DIGEST]
+func (ref Reference) String() string {
+	s := ref.Registry + "/" + ref.Repository
+	if len(ref.Tag) > 0 {
+		s += ":" + ref.Tag
+	}
+
+	if len(ref.Digest) > 0 {
+		s += "@" + ref.Digest
+	}
+
+	return s
+}
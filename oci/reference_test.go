@@ -0,0 +1,82 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package oci
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseReference(t *testing.T) {
+	for input, expected := range map[string]Reference{
+		"unikraft.io/nginx": {
+			Registry:   "unikraft.io",
+			Repository: "nginx",
+			Tag:        "latest",
+		},
+		"localhost:5000/library/nginx:1.21": {
+			Registry:   "localhost:5000",
+			Repository: "library/nginx",
+			Tag:        "1.21",
+		},
+		"unikraft.io/nginx@sha256:" + strings.Repeat("a", 64): {
+			Registry:   "unikraft.io",
+			Repository: "nginx",
+			Digest:     "sha256:" + strings.Repeat("a", 64),
+		},
+	} {
+		ref, err := ParseReference(input)
+		if err != nil {
+			t.Errorf("could not parse %s: %v", input, err)
+			continue
+		}
+
+		if ref != expected {
+			t.Errorf("unexpected reference of %s: %+v", input, ref)
+		}
+
+		if ref.String() != input && ref.Tag != DefaultTag {
+			t.Errorf("unexpected string of %s: %s", input, ref.String())
+		}
+	}
+
+	for _, input := range []string{
+		"nginx:latest",
+		"app/nginx:1.2",
+		"unikraft.io/NGINX",
+		"unikraft.io/nginx@sha256:abc",
+	} {
+		if _, err := ParseReference(input); err == nil {
+			t.Errorf("expected %s to be invalid", input)
+		}
+	}
+}
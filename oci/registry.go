@@ -0,0 +1,497 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package oci
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"kraftkit.sh/config"
+)
+
+const (
+	scopePull     = "pull"
+	scopePullPush = "pull,push"
+)
+
+// Registry is a client of the distribution API of an OCI registry
+type Registry struct {
+	host   string
+	base   *url.URL
+	auth   *config.AuthConfig
+	client *http.Client
+
+	mu     sync.Mutex
+	basic  bool
+	tokens map[string]string
+}
+
+// NewRegistry prepares a client for the registry at the host.  The
+// authentication entry of the host, if any, provides the credentials, the
+// endpoint and whether the certificate of the registry is verified.  Registries
+// on the local machine are accessed without TLS unless an endpoint is set.
+func NewRegistry(host string, auths map[string]config.AuthConfig) (*Registry, error) {
+	r := &Registry{
+		host:   host,
+		client: http.DefaultClient,
+		tokens: make(map[string]string),
+	}
+
+	endpoint := "https://" + host
+	if isLoopback(host) {
+		endpoint = "http://" + host
+	}
+
+	if auth, ok := auths[host]; ok {
+		r.auth = &auth
+
+		if len(auth.Endpoint) > 0 {
+			endpoint = auth.Endpoint
+			if !strings.Contains(endpoint, "://") {
+				endpoint = "https://" + endpoint
+			}
+		}
+
+		if !auth.VerifySSL {
+			r.client = &http.Client{
+				Transport: &http.Transport{
+					Proxy: http.ProxyFromEnvironment,
+					TLSClientConfig: &tls.Config{
+						InsecureSkipVerify: true,
+					},
+				},
+			}
+		}
+	}
+
+	base, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid registry endpoint: %v", err)
+	}
+
+	r.base = base
+
+	return r, nil
+}
+
+// isLoopback checks whether the host refers to the local machine, in which case
+// the registry is accessed without TLS.
+func isLoopback(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	if host == "localhost" {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// Host returns the host of the registry
+func (r *Registry) Host() string {
+	return r.host
+}
+
+// url returns the absolute URL of the path of the API of the registry
+func (r *Registry) url(path string) string {
+	u := *r.base
+	u.Path = strings.TrimSuffix(u.Path, "/") + path
+	return u.String()
+}
+
+// do performs the request returned by `build` with the credentials of the
+// scope.  If the registry challenges the request, it is authenticated and
+// repeated once.
+func (r *Registry) do(ctx context.Context, repository, actions string, build func() (*http.Request, error)) (*http.Response, error) {
+	scope := "repository:" + repository + ":" + actions
+
+	for attempt := 0; ; attempt++ {
+		req, err := build()
+		if err != nil {
+			return nil, err
+		}
+
+		req = req.WithContext(ctx)
+		r.authorize(req, scope)
+
+		resp, err := r.client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("could not reach registry %s: %v", r.host, err)
+		}
+
+		if resp.StatusCode != http.StatusUnauthorized || attempt > 0 {
+			return resp, nil
+		}
+
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+
+		if err := r.authenticate(ctx, challenge, scope); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// authorize sets the credentials of the scope on the request
+func (r *Registry) authorize(req *http.Request, scope string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if token, ok := r.tokens[scope]; ok {
+		req.Header.Set("Authorization", "Bearer "+token)
+	} else if r.basic && r.auth != nil {
+		req.SetBasicAuth(r.auth.User, r.auth.Token)
+	}
+}
+
+// authenticate responds to the challenge of the registry such that subsequent
+// requests of the scope are authorized.
+func (r *Registry) authenticate(ctx context.Context, challenge, scope string) error {
+	scheme, params := parseChallenge(challenge)
+
+	switch strings.ToLower(scheme) {
+	case "basic":
+		if r.auth == nil {
+			return fmt.Errorf("registry %s requires authentication", r.host)
+		}
+
+		r.mu.Lock()
+		r.basic = true
+		r.mu.Unlock()
+
+		return nil
+
+	case "bearer":
+		realm, ok := params["realm"]
+		if !ok {
+			return fmt.Errorf("registry %s did not provide an authentication realm", r.host)
+		}
+
+		u, err := url.Parse(realm)
+		if err != nil {
+			return fmt.Errorf("invalid authentication realm: %v", err)
+		}
+
+		query := u.Query()
+		if service, ok := params["service"]; ok {
+			query.Set("service", service)
+		}
+		query.Set("scope", scope)
+		u.RawQuery = query.Encode()
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+		if err != nil {
+			return err
+		}
+
+		if r.auth != nil {
+			req.SetBasicAuth(r.auth.User, r.auth.Token)
+		}
+
+		resp, err := r.client.Do(req)
+		if err != nil {
+			return fmt.Errorf("could not authenticate with registry %s: %v", r.host, err)
+		}
+
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("could not authenticate with registry %s: %s", r.host, resp.Status)
+		}
+
+		var token struct {
+			Token       string `json:"token"`
+			AccessToken string `json:"access_token"`
+		}
+
+		if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+			return fmt.Errorf("could not decode authentication token: %v", err)
+		}
+
+		if len(token.Token) == 0 {
+			token.Token = token.AccessToken
+		}
+
+		if len(token.Token) == 0 {
+			return fmt.Errorf("registry %s did not provide an authentication token", r.host)
+		}
+
+		r.mu.Lock()
+		r.tokens[scope] = token.Token
+		r.mu.Unlock()
+
+		return nil
+
+	default:
+		return fmt.Errorf("registry %s requires unsupported authentication: %s", r.host, challenge)
+	}
+}
+
+// parseChallenge returns the scheme and the parameters of a WWW-Authenticate
+// header, e.g.: `Bearer realm="https://auth.docker.io/token",service="..."`.
+func parseChallenge(challenge string) (string, map[string]string) {
+	params := make(map[string]string)
+
+	scheme, rest, _ := strings.Cut(strings.TrimSpace(challenge), " ")
+
+	for len(rest) > 0 {
+		var key, value string
+
+		rest = strings.TrimLeft(rest, " ,")
+		key, rest, _ = strings.Cut(rest, "=")
+
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				value, rest = rest[1:], ""
+			} else {
+				value, rest = rest[1:end+1], rest[end+2:]
+			}
+		} else {
+			value, rest, _ = strings.Cut(rest, ",")
+		}
+
+		if len(key) > 0 {
+			params[strings.ToLower(strings.TrimSpace(key))] = value
+		}
+	}
+
+	return scheme, params
+}
+
+// statusError returns an error describing the unexpected response of the
+// registry.  Responses with status 404 wrap os.ErrNotExist.
+func (r *Registry) statusError(resp *http.Response, action, object string) error {
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("could not find %s on %s: %w", object, r.host, os.ErrNotExist)
+	}
+
+	var body struct {
+		Errors []struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<16)).Decode(&body); err == nil && len(body.Errors) > 0 {
+		return fmt.Errorf("could not %s %s on %s: %s: %s", action, object, r.host, body.Errors[0].Code, body.Errors[0].Message)
+	}
+
+	return fmt.Errorf("could not %s %s on %s: %s", action, object, r.host, resp.Status)
+}
+
+// FetchManifest returns the descriptor and the content of the manifest or the
+// image index of the reference within the repository.
+func (r *Registry) FetchManifest(ctx context.Context, repository, reference string) (Descriptor, []byte, error) {
+	resp, err := r.do(ctx, repository, scopePull, func() (*http.Request, error) {
+		req, err := http.NewRequest(http.MethodGet, r.url("/v2/"+repository+"/manifests/"+reference), nil)
+		if err != nil {
+			return nil, err
+		}
+
+		req.Header.Set("Accept", MediaTypeImageIndex+", "+MediaTypeImageManifest)
+
+		return req, nil
+	})
+	if err != nil {
+		return Descriptor{}, nil, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return Descriptor{}, nil, r.statusError(resp, "fetch", repository+":"+reference)
+	}
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return Descriptor{}, nil, fmt.Errorf("could not read manifest: %v", err)
+	}
+
+	sum := sha256.Sum256(b)
+	desc := Descriptor{
+		MediaType: resp.Header.Get("Content-Type"),
+		Digest:    "sha256:" + hex.EncodeToString(sum[:]),
+		Size:      int64(len(b)),
+	}
+
+	if digestRegexp.MatchString(reference) && desc.Digest != reference {
+		return Descriptor{}, nil, fmt.Errorf("manifest does not match its digest: %s", reference)
+	}
+
+	// Prefer the media type embedded in the manifest over the one reported by
+	// the registry.
+	var embedded struct {
+		MediaType string `json:"mediaType"`
+	}
+	if err := json.Unmarshal(b, &embedded); err == nil && len(embedded.MediaType) > 0 {
+		desc.MediaType = embedded.MediaType
+	}
+
+	return desc, b, nil
+}
+
+// PushManifest uploads the content of a manifest or an image index under the
+// reference within the repository.
+func (r *Registry) PushManifest(ctx context.Context, repository, reference, mediaType string, content []byte) error {
+	resp, err := r.do(ctx, repository, scopePullPush, func() (*http.Request, error) {
+		req, err := http.NewRequest(http.MethodPut, r.url("/v2/"+repository+"/manifests/"+reference), bytes.NewReader(content))
+		if err != nil {
+			return nil, err
+		}
+
+		req.Header.Set("Content-Type", mediaType)
+
+		return req, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return r.statusError(resp, "push", repository+":"+reference)
+	}
+
+	return nil
+}
+
+// HasBlob checks whether the repository contains the blob with the digest
+func (r *Registry) HasBlob(ctx context.Context, repository, digest string) (bool, error) {
+	resp, err := r.do(ctx, repository, scopePull, func() (*http.Request, error) {
+		return http.NewRequest(http.MethodHead, r.url("/v2/"+repository+"/blobs/"+digest), nil)
+	})
+	if err != nil {
+		return false, err
+	}
+
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, r.statusError(resp, "check", digest)
+	}
+}
+
+// FetchBlob opens the blob with the digest within the repository for reading
+func (r *Registry) FetchBlob(ctx context.Context, repository, digest string) (io.ReadCloser, error) {
+	resp, err := r.do(ctx, repository, scopePull, func() (*http.Request, error) {
+		return http.NewRequest(http.MethodGet, r.url("/v2/"+repository+"/blobs/"+digest), nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, r.statusError(resp, "fetch", digest)
+	}
+
+	return resp.Body, nil
+}
+
+// PushBlob uploads the content returned by `open` as the blob of the
+// descriptor within the repository using a monolithic upload.
+func (r *Registry) PushBlob(ctx context.Context, repository string, desc Descriptor, open func() (io.ReadCloser, error)) error {
+	resp, err := r.do(ctx, repository, scopePullPush, func() (*http.Request, error) {
+		return http.NewRequest(http.MethodPost, r.url("/v2/"+repository+"/blobs/uploads/"), nil)
+	})
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusAccepted {
+		defer resp.Body.Close()
+		return r.statusError(resp, "start upload of", desc.Digest)
+	}
+
+	resp.Body.Close()
+
+	location, err := r.base.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		return fmt.Errorf("invalid upload location: %v", err)
+	}
+
+	query := location.Query()
+	query.Set("digest", desc.Digest)
+	location.RawQuery = query.Encode()
+
+	resp, err = r.do(ctx, repository, scopePullPush, func() (*http.Request, error) {
+		body, err := open()
+		if err != nil {
+			return nil, err
+		}
+
+		req, err := http.NewRequest(http.MethodPut, location.String(), body)
+		if err != nil {
+			body.Close()
+			return nil, err
+		}
+
+		req.ContentLength = desc.Size
+		req.Header.Set("Content-Type", "application/octet-stream")
+		req.Header.Set("Content-Length", strconv.FormatInt(desc.Size, 10))
+
+		return req, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return r.statusError(resp, "upload", desc.Digest)
+	}
+
+	return nil
+}
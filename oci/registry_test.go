@@ -0,0 +1,531 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package oci
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"kraftkit.sh/config"
+	"kraftkit.sh/initrd"
+	"kraftkit.sh/internal/logger"
+	"kraftkit.sh/iostreams"
+	"kraftkit.sh/pack"
+	"kraftkit.sh/packmanager"
+	"kraftkit.sh/signature"
+)
+
+const (
+	testRegistryUser  = "unikraft"
+	testRegistryToken = "secret"
+)
+
+type testManifest struct {
+	mediaType string
+	content   []byte
+}
+
+// testRegistry is a minimal in-process implementation of the distribution API
+// which requires bearer token authentication.
+type testRegistry struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	blobs     map[string][]byte
+	manifests map[string]testManifest
+	uploads   int
+	denied    bool
+}
+
+func newTestRegistry(t *testing.T) *testRegistry {
+	r := &testRegistry{
+		blobs:     make(map[string][]byte),
+		manifests: make(map[string]testManifest),
+	}
+
+	r.Server = httptest.NewServer(http.HandlerFunc(r.serve))
+	t.Cleanup(r.Close)
+
+	return r
+}
+
+func (r *testRegistry) host() string {
+	u, _ := url.Parse(r.URL)
+	return u.Host
+}
+
+func (r *testRegistry) serve(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Path == "/token" {
+		if user, pass, ok := req.BasicAuth(); !ok || user != testRegistryUser || pass != testRegistryToken {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		if !strings.HasPrefix(req.URL.Query().Get("scope"), "repository:") {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]string{"token": "token"})
+		return
+	}
+
+	if req.Header.Get("Authorization") != "Bearer token" {
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="test"`, r.URL))
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	path := strings.TrimPrefix(req.URL.Path, "/v2/")
+
+	switch {
+	case strings.Contains(path, "/blobs/uploads/"):
+		repository, id, _ := strings.Cut(path, "/blobs/uploads/")
+
+		if req.Method == http.MethodPost {
+			if r.denied {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusForbidden)
+				w.Write([]byte(`{"errors":[{"code":"DENIED","message":"requested access to the resource is denied"}]}`))
+				return
+			}
+
+			r.uploads++
+			w.Header().Set("Location", fmt.Sprintf("/v2/%s/blobs/uploads/%d", repository, r.uploads))
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+
+		if req.Method != http.MethodPut || len(id) == 0 {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		b, _ := io.ReadAll(req.Body)
+		sum := sha256.Sum256(b)
+		digest := "sha256:" + hex.EncodeToString(sum[:])
+
+		if digest != req.URL.Query().Get("digest") {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		r.blobs[digest] = b
+		w.WriteHeader(http.StatusCreated)
+
+	case strings.Contains(path, "/blobs/"):
+		_, digest, _ := strings.Cut(path, "/blobs/")
+
+		b, ok := r.blobs[digest]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		if req.Method == http.MethodGet {
+			w.Write(b)
+		}
+
+	case strings.Contains(path, "/manifests/"):
+		repository, reference, _ := strings.Cut(path, "/manifests/")
+
+		if req.Method == http.MethodPut {
+			b, _ := io.ReadAll(req.Body)
+			sum := sha256.Sum256(b)
+			manifest := testManifest{
+				mediaType: req.Header.Get("Content-Type"),
+				content:   b,
+			}
+
+			r.manifests[repository+"@"+reference] = manifest
+			r.manifests[repository+"@sha256:"+hex.EncodeToString(sum[:])] = manifest
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+
+		manifest, ok := r.manifests[repository+"@"+reference]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", manifest.mediaType)
+		w.Write(manifest.content)
+
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (r *testRegistry) auths() map[string]config.AuthConfig {
+	return map[string]config.AuthConfig{
+		r.host(): {
+			User:  testRegistryUser,
+			Token: testRegistryToken,
+		},
+	}
+}
+
+func TestRegistryPushPull(t *testing.T) {
+	ctx := context.Background()
+	server := newTestRegistry(t)
+	dir := t.TempDir()
+
+	kernel := filepath.Join(dir, "helloworld_kvm-x86_64")
+	if err := os.WriteFile(kernel, []byte("kernel"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	src := filepath.Join(dir, "src")
+	testPackage(t, src, kernel, "kvm", "x86_64")
+	testPackage(t, src, kernel, "kvm", "arm64")
+
+	srcLayout, err := OpenLayout(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ref, err := ParseReference(server.host() + "/library/helloworld:0.1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	registry, err := NewRegistry(ref.Registry, server.auths())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Push(ctx, registry, srcLayout, "helloworld:0.1.0", ref, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Push another platform from a different layout which is merged into the
+	// remote image index
+	other := filepath.Join(dir, "other")
+	testPackage(t, other, kernel, "xen", "x86_64")
+
+	otherLayout, err := OpenLayout(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Push(ctx, registry, otherLayout, "helloworld:0.1.0", ref, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	platforms, err := Platforms(ctx, registry, ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(platforms) != 3 {
+		t.Fatalf("expected 3 platforms, got %d", len(platforms))
+	}
+
+	dst, err := NewLayout(filepath.Join(dir, "dst"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := NewPlatform("kvm", "x86_64")
+	pulled, err := Pull(ctx, registry, ref, dst, func(p Platform) bool {
+		return p == want
+	}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(pulled) != 1 || *pulled[0].Platform != want {
+		t.Fatalf("unexpected pulled manifests: %+v", pulled)
+	}
+
+	if _, err := dst.Manifest(ref.String(), NewPlatform("kvm", "arm64")); err == nil {
+		t.Errorf("expected arm64 not to be pulled")
+	}
+
+	image, err := dst.Unpack(ref.String(), want, filepath.Join(dir, "unpacked"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(image.Kernel)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(b) != "kernel" || filepath.Base(image.Kernel) != "helloworld_kvm-x86_64" {
+		t.Errorf("unexpected unpacked kernel %s: %q", image.Kernel, b)
+	}
+
+	if len(image.Command) != 1 || image.Command[0] != "-v" {
+		t.Errorf("unexpected command: %v", image.Command)
+	}
+}
+
+func TestRegistryUnauthorized(t *testing.T) {
+	server := newTestRegistry(t)
+
+	ref, err := ParseReference(server.host() + "/helloworld:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	registry, err := NewRegistry(ref.Registry, map[string]config.AuthConfig{
+		server.host(): {
+			User:  testRegistryUser,
+			Token: "invalid",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Platforms(context.Background(), registry, ref); err == nil {
+		t.Fatal("expected authentication to fail")
+	}
+}
+
+func TestRegistryPushDenied(t *testing.T) {
+	server := newTestRegistry(t)
+	server.denied = true
+
+	registry, err := NewRegistry(server.host(), server.auths())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = registry.PushBlob(context.Background(), "helloworld", Descriptor{Digest: "sha256:00"}, func() (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader("")), nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "DENIED: requested access to the resource is denied") {
+		t.Errorf("expected the error of the registry, got %v", err)
+	}
+}
+
+func TestManagerPullCache(t *testing.T) {
+	server := newTestRegistry(t)
+	dir := t.TempDir()
+
+	kernel := filepath.Join(dir, "helloworld_kvm-x86_64")
+	if err := os.WriteFile(kernel, []byte("kernel"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	rootfs := filepath.Join(dir, "rootfs")
+	if err := os.MkdirAll(rootfs, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(rootfs, "hello"), []byte("world"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	src := filepath.Join(dir, "src")
+	testPackage(t, src, kernel, "kvm", "x86_64", pack.WithInitrdConfig(&initrd.InitrdConfig{
+		Input:  []string{rootfs},
+		Format: initrd.NEWC,
+	}))
+
+	srcLayout, err := OpenLayout(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ref, err := ParseReference(server.host() + "/helloworld:0.1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	registry, err := NewRegistry(ref.Registry, server.auths())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Push(context.Background(), registry, srcLayout, "helloworld:0.1.0", ref, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	cfgm := &config.ConfigManager{Config: &config.Config{}}
+	cfgm.Config.Paths.Packages = filepath.Join(dir, "packages")
+	cfgm.Config.Auth = server.auths()
+
+	options, err := packmanager.NewPackageManagerOptions(context.TODO(),
+		packmanager.WithConfigManager(cfgm),
+		packmanager.WithLogger(logger.NewLogger(io.Discard, iostreams.NewColorScheme(false, false, false))),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pm, err := NewOCIPackageManagerFromOptions(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		packages, err := pm.Catalog(packmanager.CatalogQuery{Source: ref.String()})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(packages) != 1 {
+			t.Fatalf("expected 1 package, got %d", len(packages))
+		}
+
+		unpacked := filepath.Join(dir, "unpacked")
+		if err := packages[0].Pull(pack.WithPullWorkdir(unpacked), pack.WithPullCache(true)); err != nil {
+			t.Fatal(err)
+		}
+
+		popts := packages[0].Options()
+		if popts.Kernel != filepath.Join(unpacked, "helloworld_kvm-x86_64") {
+			t.Errorf("unexpected kernel: %s", popts.Kernel)
+		}
+
+		if popts.Initrd == nil || len(popts.Initrd.Output) == 0 {
+			t.Errorf("expected initrd to be unpacked")
+		}
+
+		// The second iteration is served from the local package store
+		if i == 0 {
+			server.Close()
+		}
+	}
+}
+
+func TestManagerPullSignature(t *testing.T) {
+	server := newTestRegistry(t)
+	dir := t.TempDir()
+
+	kernel := filepath.Join(dir, "kernel")
+	if err := os.WriteFile(kernel, []byte("kernel"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	src := filepath.Join(dir, "src")
+	testPackage(t, src, kernel, "kvm", "x86_64")
+
+	srcLayout, err := OpenLayout(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pub, err := signature.GenerateKey(filepath.Join(dir, "signing.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := signature.ReadPrivateKey(filepath.Join(dir, "signing.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	registry, err := NewRegistry(server.host(), server.auths())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unsigned, err := ParseReference(server.host() + "/helloworld:unsigned")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Push(context.Background(), registry, srcLayout, "helloworld:0.1.0", unsigned, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := srcLayout.Sign("helloworld:0.1.0", key); err != nil {
+		t.Fatal(err)
+	}
+
+	signed, err := ParseReference(server.host() + "/helloworld:signed")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Push(context.Background(), registry, srcLayout, "helloworld:0.1.0", signed, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	newManager := func(trusted ...string) packmanager.PackageManager {
+		cfgm := &config.ConfigManager{Config: &config.Config{}}
+		cfgm.Config.Paths.Packages = filepath.Join(t.TempDir(), "packages")
+		cfgm.Config.Auth = server.auths()
+		cfgm.Config.Signing.Policy = string(signature.PolicyEnforce)
+		cfgm.Config.Signing.TrustedKeys = trusted
+
+		options, err := packmanager.NewPackageManagerOptions(context.TODO(),
+			packmanager.WithConfigManager(cfgm),
+			packmanager.WithLogger(logger.NewLogger(io.Discard, iostreams.NewColorScheme(false, false, false))),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		pm, err := NewOCIPackageManagerFromOptions(options)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return pm
+	}
+
+	trusted := newManager(signature.EncodePublicKey(pub))
+
+	if _, err := trusted.Pull(unsigned.String(), nil); !errors.Is(err, signature.ErrUnsigned) {
+		t.Errorf("expected unsigned package to be refused, got: %v", err)
+	}
+
+	if _, err := trusted.Pull(signed.String(), nil); err != nil {
+		t.Errorf("expected signed package to be pulled: %v", err)
+	}
+
+	if _, err := newManager().Pull(signed.String(), nil); !errors.Is(err, signature.ErrUntrusted) {
+		t.Errorf("expected untrusted package to be refused, got: %v", err)
+	}
+}
@@ -0,0 +1,121 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package oci
+
+import (
+	"crypto/ed25519"
+	"fmt"
+
+	"kraftkit.sh/log"
+	"kraftkit.sh/signature"
+)
+
+// DescriptorSignature returns the signature of the manifest of the descriptor,
+// or nil if the manifest is not signed.
+func DescriptorSignature(desc Descriptor) (*signature.Signature, error) {
+	raw, ok := desc.Annotations[AnnotationSignature]
+	if !ok {
+		return nil, nil
+	}
+
+	return signature.Parse([]byte(raw))
+}
+
+// Sign signs the manifests of all platforms of the image of the reference.  The
+// signatures are stored on the descriptors of the manifests within the image
+// index such that they are pushed and pulled together with the image.
+func (l *Layout) Sign(ref string, key ed25519.PrivateKey) ([]Descriptor, error) {
+	index, err := l.ImageIndex(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	var signed []Descriptor
+
+	for _, desc := range index.Manifests {
+		raw, err := signature.Sign(key, desc.Digest).Encode()
+		if err != nil {
+			return nil, err
+		}
+
+		annotations := map[string]string{}
+		for k, v := range desc.Annotations {
+			annotations[k] = v
+		}
+
+		annotations[AnnotationSignature] = string(raw)
+		desc.Annotations = annotations
+
+		if err := l.AddManifest(ref, desc); err != nil {
+			return nil, err
+		}
+
+		signed = append(signed, desc)
+	}
+
+	return signed, nil
+}
+
+// Find returns the reference within the layout which is either equal to the
+// provided reference or whose name and version match it.
+func (l *Layout) Find(ref string) (string, error) {
+	refs, err := l.Refs()
+	if err != nil {
+		return "", err
+	}
+
+	for _, r := range refs {
+		if name, version := splitRef(r); r == ref || name+":"+version == ref {
+			return r, nil
+		}
+	}
+
+	return "", fmt.Errorf("could not find %s in %s", ref, l.Root())
+}
+
+// verifyDescriptor returns the check of the signature of a manifest of the
+// reference, which applies the policy of the verifier.  Without a verifier no
+// check is performed.
+func verifyDescriptor(v *signature.Verifier, l log.Logger, ref Reference) func(Descriptor) error {
+	if v == nil {
+		return nil
+	}
+
+	return func(desc Descriptor) error {
+		sig, err := DescriptorSignature(desc)
+		if err != nil {
+			return err
+		}
+
+		return v.Enforce(l, fmt.Sprintf("%s for %s", ref, desc.Platform), desc.Digest, sig)
+	}
+}
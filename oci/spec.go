@@ -0,0 +1,190 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+// Package oci packages unikernels as OCI images.  The kernel and the optional
+// initial ramdisk of a unikernel are stored as layers of an image whose
+// platform is described by the architecture and the Unikraft platform of the
+// kernel.  Images of the same name and version are grouped in an image index
+// such that the artifact matching a host can be selected.
+package oci
+
+import (
+	"time"
+
+	"kraftkit.sh/machine"
+	"kraftkit.sh/unikraft/plat"
+)
+
+const (
+	MediaTypeImageIndex    = "application/vnd.oci.image.index.v1+json"
+	MediaTypeImageManifest = "application/vnd.oci.image.manifest.v1+json"
+	MediaTypeImageConfig   = "application/vnd.oci.image.config.v1+json"
+	MediaTypeImageLayout   = "application/vnd.oci.layout.header.v1+json"
+
+	// MediaTypeKernel is the media type of the layer holding the kernel image
+	MediaTypeKernel = "application/vnd.unikraft.kernel.v1"
+
+	// MediaTypeInitrd is the media type of the layer holding the initial
+	// ramdisk
+	MediaTypeInitrd = "application/vnd.unikraft.initrd.v1"
+)
+
+const (
+	AnnotationCreated = "org.opencontainers.image.created"
+	AnnotationRefName = "org.opencontainers.image.ref.name"
+	AnnotationTitle   = "org.opencontainers.image.title"
+	AnnotationVersion = "org.opencontainers.image.version"
+
+	// AnnotationName is the name of the package
+	AnnotationName = "sh.kraftkit.name"
+
+	// AnnotationArchitecture is the Unikraft architecture of the kernel
+	AnnotationArchitecture = "sh.kraftkit.architecture"
+
+	// AnnotationPlatform is the Unikraft platform of the kernel
+	AnnotationPlatform = "sh.kraftkit.platform"
+
+	// AnnotationSource is the project the package was built from
+	AnnotationSource = "sh.kraftkit.source"
+
+	// AnnotationSignature is the detached signature of a manifest, which is
+	// set on its descriptor within the image index
+	AnnotationSignature = "sh.kraftkit.signature"
+)
+
+// Descriptor describes the disposition of targeted content
+type Descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Platform    *Platform         `json:"platform,omitempty"`
+}
+
+// Platform describes the platform which the image in the manifest runs on.
+// The operating system of a unikernel is the Unikraft platform it has been
+// built for, e.g.: kvm, xen, linuxu.
+type Platform struct {
+	Architecture string `json:"architecture"`
+	OS           string `json:"os"`
+	Variant      string `json:"variant,omitempty"`
+}
+
+// String returns the platform in the form OS/ARCHITECTURE
+func (p Platform) String() string {
+	return p.OS + "/" + p.Architecture
+}
+
+// Manifest describes the configuration and layers of an image
+type Manifest struct {
+	SchemaVersion int               `json:"schemaVersion"`
+	MediaType     string            `json:"mediaType"`
+	Config        Descriptor        `json:"config"`
+	Layers        []Descriptor      `json:"layers"`
+	Annotations   map[string]string `json:"annotations,omitempty"`
+}
+
+// Layer returns the first layer of the manifest with the provided media type
+func (m Manifest) Layer(mediaType string) (Descriptor, bool) {
+	for _, layer := range m.Layers {
+		if layer.MediaType == mediaType {
+			return layer, true
+		}
+	}
+
+	return Descriptor{}, false
+}
+
+// Index references the manifests of an image for multiple platforms
+type Index struct {
+	SchemaVersion int               `json:"schemaVersion"`
+	MediaType     string            `json:"mediaType"`
+	Manifests     []Descriptor      `json:"manifests"`
+	Annotations   map[string]string `json:"annotations,omitempty"`
+}
+
+// ImageConfig is the configuration of an image
+type ImageConfig struct {
+	Created      *time.Time     `json:"created,omitempty"`
+	Architecture string         `json:"architecture"`
+	OS           string         `json:"os"`
+	Config       ImageRunConfig `json:"config,omitempty"`
+	RootFS       ImageRootFS    `json:"rootfs"`
+}
+
+// ImageRunConfig contains the parameters used when running the image
+type ImageRunConfig struct {
+	Cmd []string `json:"Cmd,omitempty"`
+	Env []string `json:"Env,omitempty"`
+}
+
+// ImageRootFS references the layers of the image by their digest
+type ImageRootFS struct {
+	Type    string   `json:"type"`
+	DiffIDs []string `json:"diff_ids"`
+}
+
+// ArchitectureFromUnikraft returns the OCI architecture of a Unikraft
+// architecture, e.g.: x86_64 becomes amd64.
+func ArchitectureFromUnikraft(arch string) string {
+	arch = machine.ArchitectureName(arch)
+	if arch == "x86_64" {
+		return "amd64"
+	}
+
+	return arch
+}
+
+// ArchitectureToUnikraft returns the Unikraft architecture of an OCI
+// architecture, e.g.: amd64 becomes x86_64.
+func ArchitectureToUnikraft(arch string) string {
+	return machine.ArchitectureName(arch)
+}
+
+// PlatformFromUnikraft returns the OCI operating system of a Unikraft platform,
+// which is its canonical name, e.g.: qemu becomes kvm.  Unknown platforms are
+// returned as-is.
+func PlatformFromUnikraft(platform string) string {
+	if name, err := plat.PlatformByName(platform); err == nil {
+		return name
+	}
+
+	return platform
+}
+
+// NewPlatform returns the platform of a unikernel built for the Unikraft
+// platform and architecture.
+func NewPlatform(platform, arch string) Platform {
+	return Platform{
+		Architecture: ArchitectureFromUnikraft(arch),
+		OS:           PlatformFromUnikraft(platform),
+	}
+}
@@ -0,0 +1,279 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package oci
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Push uploads the image of the local reference within the layout to the
+// reference of the registry.  Platforms which exist in the image index of the
+// registry but not in the layout are retained.
+func Push(ctx context.Context, registry *Registry, layout *Layout, local string, ref Reference, onProgress func(float64)) error {
+	index, err := layout.ImageIndex(local)
+	if err != nil {
+		return err
+	}
+
+	// Gather all blobs of all manifests before uploading such that the progress
+	// can be reported.
+	var blobs []Descriptor
+	var manifests []Descriptor
+
+	for _, desc := range index.Manifests {
+		var manifest Manifest
+		if err := layout.ReadBlobJSON(desc, &manifest); err != nil {
+			return err
+		}
+
+		blobs = append(blobs, manifest.Config)
+		blobs = append(blobs, manifest.Layers...)
+		manifests = append(manifests, desc)
+	}
+
+	for i, blob := range blobs {
+		exists, err := registry.HasBlob(ctx, ref.Repository, blob.Digest)
+		if err != nil {
+			return err
+		}
+
+		if !exists {
+			if err := registry.PushBlob(ctx, ref.Repository, blob, func() (io.ReadCloser, error) {
+				return layout.OpenBlob(blob.Digest)
+			}); err != nil {
+				return err
+			}
+		}
+
+		if onProgress != nil {
+			onProgress(float64(i+1) / float64(len(blobs)+1))
+		}
+	}
+
+	for _, desc := range manifests {
+		content, err := layout.ReadBlob(desc)
+		if err != nil {
+			return err
+		}
+
+		if err := registry.PushManifest(ctx, ref.Repository, desc.Digest, desc.MediaType, content); err != nil {
+			return err
+		}
+	}
+
+	// Retain the platforms of the remote image index which are not provided
+	// locally
+	remote, content, err := registry.FetchManifest(ctx, ref.Repository, ref.Object())
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	} else if err == nil && remote.MediaType == MediaTypeImageIndex {
+		var existing Index
+		if err := json.Unmarshal(content, &existing); err != nil {
+			return fmt.Errorf("could not decode remote image index: %v", err)
+		}
+
+	next:
+		for _, desc := range existing.Manifests {
+			if desc.Platform == nil {
+				continue
+			}
+
+			for _, m := range index.Manifests {
+				if m.Platform != nil && *m.Platform == *desc.Platform {
+					continue next
+				}
+			}
+
+			index.Manifests = append(index.Manifests, desc)
+		}
+	}
+
+	content, err = json.Marshal(index)
+	if err != nil {
+		return fmt.Errorf("could not encode image index: %v", err)
+	}
+
+	if err := registry.PushManifest(ctx, ref.Repository, ref.Object(), MediaTypeImageIndex, content); err != nil {
+		return err
+	}
+
+	if onProgress != nil {
+		onProgress(1)
+	}
+
+	return nil
+}
+
+// Platforms returns the descriptors of the manifests of each platform of the
+// reference within the registry.  The platform of an image which is not part of
+// an image index is determined from its configuration.
+func Platforms(ctx context.Context, registry *Registry, ref Reference) ([]Descriptor, error) {
+	desc, content, err := registry.FetchManifest(ctx, ref.Repository, ref.Object())
+	if err != nil {
+		return nil, err
+	}
+
+	switch desc.MediaType {
+	case MediaTypeImageIndex:
+		var index Index
+		if err := json.Unmarshal(content, &index); err != nil {
+			return nil, fmt.Errorf("could not decode image index: %v", err)
+		}
+
+		var manifests []Descriptor
+		for _, m := range index.Manifests {
+			if m.Platform != nil && m.MediaType == MediaTypeImageManifest {
+				manifests = append(manifests, m)
+			}
+		}
+
+		return manifests, nil
+
+	case MediaTypeImageManifest:
+		var manifest Manifest
+		if err := json.Unmarshal(content, &manifest); err != nil {
+			return nil, fmt.Errorf("could not decode manifest: %v", err)
+		}
+
+		blob, err := registry.FetchBlob(ctx, ref.Repository, manifest.Config.Digest)
+		if err != nil {
+			return nil, err
+		}
+
+		defer blob.Close()
+
+		var config ImageConfig
+		if err := json.NewDecoder(blob).Decode(&config); err != nil {
+			return nil, fmt.Errorf("could not decode image configuration: %v", err)
+		}
+
+		desc.Annotations = manifest.Annotations
+		desc.Platform = &Platform{
+			Architecture: config.Architecture,
+			OS:           config.OS,
+		}
+
+		return []Descriptor{desc}, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported media type of %s: %s", ref, desc.MediaType)
+	}
+}
+
+// Pull downloads the images of the reference within the registry for each
+// platform accepted by `match` into the layout, where they are stored under the
+// reference.  If set, `verify` is called with the descriptor of each manifest
+// before any of its content is downloaded.  The descriptors of the pulled
+// manifests are returned.
+func Pull(ctx context.Context, registry *Registry, ref Reference, layout *Layout, match func(Platform) bool, verify func(Descriptor) error, onProgress func(float64)) ([]Descriptor, error) {
+	platforms, err := Platforms(ctx, registry, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	var pulled []Descriptor
+	for _, desc := range platforms {
+		if match == nil || match(*desc.Platform) {
+			pulled = append(pulled, desc)
+		}
+	}
+
+	if len(pulled) == 0 {
+		return nil, fmt.Errorf("could not find %s for the requested platform", ref)
+	}
+
+	for i, desc := range pulled {
+		if verify != nil {
+			if err := verify(desc); err != nil {
+				return nil, err
+			}
+		}
+
+		_, content, err := registry.FetchManifest(ctx, ref.Repository, desc.Digest)
+		if err != nil {
+			return nil, err
+		}
+
+		var manifest Manifest
+		if err := json.Unmarshal(content, &manifest); err != nil {
+			return nil, fmt.Errorf("could not decode manifest: %v", err)
+		}
+
+		for _, blob := range append([]Descriptor{manifest.Config}, manifest.Layers...) {
+			if layout.HasBlob(blob.Digest) {
+				continue
+			}
+
+			if err := pullBlob(ctx, registry, ref, layout, blob); err != nil {
+				return nil, err
+			}
+		}
+
+		if err := layout.WriteDescriptor(desc, bytes.NewReader(content)); err != nil {
+			return nil, err
+		}
+
+		if len(desc.Annotations) == 0 {
+			desc.Annotations = manifest.Annotations
+		}
+
+		if err := layout.AddManifest(ref.String(), desc); err != nil {
+			return nil, err
+		}
+
+		pulled[i] = desc
+
+		if onProgress != nil {
+			onProgress(float64(i+1) / float64(len(pulled)))
+		}
+	}
+
+	return pulled, nil
+}
+
+// pullBlob downloads the blob of the descriptor into the layout
+func pullBlob(ctx context.Context, registry *Registry, ref Reference, layout *Layout, blob Descriptor) error {
+	r, err := registry.FetchBlob(ctx, ref.Repository, blob.Digest)
+	if err != nil {
+		return err
+	}
+
+	defer r.Close()
+
+	return layout.WriteDescriptor(blob, r)
+}
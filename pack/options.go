@@ -35,6 +35,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"time"
 
 	"kraftkit.sh/initrd"
 	"kraftkit.sh/log"
@@ -65,6 +66,9 @@ type PackageOptions struct {
 	// Initrd contains the configuration for the initramfs file
 	Initrd *initrd.InitrdConfig
 
+	// Command is the list of default arguments passed to the application
+	Command []string
+
 	// Metadata represents other items that did not have appropriate annotations
 	Metadata map[string]interface{}
 
@@ -74,9 +78,28 @@ type PackageOptions struct {
 	// LocalLocation contains the path to save or store the package on the host.
 	LocalLocation string
 
-	// Sha256
+	// Sha256 is the checksum of the archive of the package once it is pulled
 	Sha256 string
 
+	// Commit is the Git commit of the package once it is pulled with Git
+	Commit string
+
+	// Digest uniquely identifies the content of the package in the local
+	// package store
+	Digest string
+
+	// Size is the size of the package in bytes in the local package store
+	Size int64
+
+	// Created is the time at which the package was created
+	Created time.Time
+
+	// Source is the path to the project the package was created from
+	Source string
+
+	// Dependencies are the packages which this package requires
+	Dependencies []Dependency
+
 	// Access to a logger
 	log log.Logger
 
@@ -105,6 +128,29 @@ func (opts *PackageOptions) ArchPlatString() string {
 	return plat + "/" + arch
 }
 
+// Dependency is a package which is required by another package
+type Dependency struct {
+	// Type of the required package
+	Type unikraft.ComponentType
+
+	// Name of the required package
+	Name string
+
+	// Version is the exact version, channel or semantic version constraint
+	// which the required package must satisfy
+	Version string
+}
+
+// String returns the string representation of the type, name and version of
+// the dependency
+func (d Dependency) String() string {
+	if len(d.Version) == 0 {
+		return d.Type.Plural() + "/" + d.Name
+	}
+
+	return d.Type.Plural() + "/" + d.Name + ":" + d.Version
+}
+
 // NameVersion returns the string representation of name and version of this
 // package
 func (opts *PackageOptions) NameVersion() string {
@@ -200,10 +246,10 @@ func WithKernel(kernel string) PackageOption {
 }
 
 // WithInitrdConfig sets the metadata attribute with the interface representing
-// initrd configuration
+// initrd configuration.  The output of the configuration is optional.
 func WithInitrdConfig(initrd *initrd.InitrdConfig) PackageOption {
 	return func(opts *PackageOptions) error {
-		if initrd == nil || len(initrd.Input) == 0 || initrd.Input == nil || len(initrd.Output) == 0 {
+		if initrd == nil || len(initrd.Input) == 0 {
 			return nil
 		}
 
@@ -213,6 +259,22 @@ func WithInitrdConfig(initrd *initrd.InitrdConfig) PackageOption {
 	}
 }
 
+// WithCommand sets the default arguments passed to the application
+func WithCommand(command []string) PackageOption {
+	return func(opts *PackageOptions) error {
+		opts.Command = command
+		return nil
+	}
+}
+
+// WithDependencies sets the packages which the package requires
+func WithDependencies(dependencies []Dependency) PackageOption {
+	return func(opts *PackageOptions) error {
+		opts.Dependencies = dependencies
+		return nil
+	}
+}
+
 // WithRemoteLocation sets the location of the package at its remote registry
 func WithRemoteLocation(location string) PackageOption {
 	return func(opts *PackageOptions) error {
@@ -265,6 +327,9 @@ type PullPackageOptions struct {
 	workdir           string
 	log               log.Logger
 	useCache          bool
+	resource          string
+	sha256            string
+	commit            string
 }
 
 // OnProgress calls (if set) an embedded progress function which can be used to
@@ -275,6 +340,16 @@ func (ppo *PullPackageOptions) OnProgress(progress float64) {
 	}
 }
 
+// Architectures returns the requested architectures
+func (ppo *PullPackageOptions) Architectures() []string {
+	return ppo.architectures
+}
+
+// Platforms returns the requested platforms
+func (ppo *PullPackageOptions) Platforms() []string {
+	return ppo.platforms
+}
+
 // Workdir returns the set working directory as part of the pull request
 func (ppo *PullPackageOptions) Workdir() string {
 	return ppo.workdir
@@ -297,6 +372,21 @@ func (ppo *PullPackageOptions) UseCache() bool {
 	return ppo.useCache
 }
 
+// Resource returns the remote location which overrides that of the package
+func (ppo *PullPackageOptions) Resource() string {
+	return ppo.resource
+}
+
+// Sha256 returns the checksum which the pulled archive must match
+func (ppo *PullPackageOptions) Sha256() string {
+	return ppo.sha256
+}
+
+// Commit returns the Git commit which is checked out after pulling with Git
+func (ppo *PullPackageOptions) Commit() string {
+	return ppo.commit
+}
+
 type PullPackageOption func(opts *PullPackageOptions) error
 
 // NewPullPackageOptions creates PullPackageOptions
@@ -393,3 +483,29 @@ func WithPullCache(cache bool) PullPackageOption {
 		return nil
 	}
 }
+
+// WithPullResource pulls the package from the remote location instead of the
+// one of the package
+func WithPullResource(resource string) PullPackageOption {
+	return func(opts *PullPackageOptions) error {
+		opts.resource = resource
+		return nil
+	}
+}
+
+// WithPullSha256 requires the pulled archive of the package to match the
+// checksum
+func WithPullSha256(sum string) PullPackageOption {
+	return func(opts *PullPackageOptions) error {
+		opts.sha256 = sum
+		return nil
+	}
+}
+
+// WithPullCommit checks out the Git commit when the package is pulled with Git
+func WithPullCommit(commit string) PullPackageOption {
+	return func(opts *PullPackageOptions) error {
+		opts.commit = commit
+		return nil
+	}
+}
@@ -65,6 +65,9 @@ type PackageManager interface {
 	// Remove a source from the package manager
 	RemoveSource(string) error
 
+	// Delete removes the package of the reference from the local package store
+	Delete(string) error
+
 	// IsCompatible checks whether the provided source is compatible with the
 	// package manager
 	IsCompatible(string) (PackageManager, error)
@@ -112,6 +112,10 @@ type CatalogQuery struct {
 	// NoCache forces the package manager to update values in-memory without
 	// interacting with any underlying cache
 	NoCache bool
+
+	// Local restricts the query to packages within the local package store.
+	// The source, if set, is then matched against the reference of the package.
+	Local bool
 }
 
 func NewCatalogQuery(s string) CatalogQuery {
@@ -120,6 +124,10 @@ func NewCatalogQuery(s string) CatalogQuery {
 }
 
 func (cq CatalogQuery) String() string {
+	if len(cq.Source) > 0 && len(cq.Name) == 0 {
+		return cq.Source
+	}
+
 	s := ""
 	if len(cq.Types) == 1 {
 		s += string(cq.Types[0]) + "-"
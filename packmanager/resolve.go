@@ -0,0 +1,347 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package packmanager
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"kraftkit.sh/internal/semver"
+	"kraftkit.sh/pack"
+	"kraftkit.sh/unikraft"
+)
+
+// maxResolveIterations bounds the number of times the selection of the
+// dependencies is revised before the resolution is abandoned
+const maxResolveIterations = 64
+
+// Requirement is a version of a package which is required by another package
+type Requirement struct {
+	// Version is the exact version, channel or semantic version constraint
+	// which the required package must satisfy
+	Version string
+
+	// By is the package which declares the requirement
+	By pack.Package
+}
+
+// String returns the requirement and the package which declares it
+func (r Requirement) String() string {
+	version := r.Version
+	if len(version) == 0 {
+		version = "any version"
+	}
+
+	return fmt.Sprintf("%s (required by %s)", version, r.By.Options().TypeNameVersion())
+}
+
+// dependencyKey uniquely identifies a package within a dependency graph
+type dependencyKey struct {
+	Type unikraft.ComponentType
+	Name string
+}
+
+func (k dependencyKey) String() string {
+	return k.Type.Plural() + "/" + k.Name
+}
+
+func packageKey(p pack.Package) dependencyKey {
+	return dependencyKey{p.Options().Type, p.Name()}
+}
+
+// DependencyGraph contains the packages which are transitively required by a
+// set of root packages, where each package is selected exactly once at a
+// version which satisfies all of its requirements
+type DependencyGraph struct {
+	roots        []pack.Package
+	selected     map[dependencyKey]pack.Package
+	requirements map[dependencyKey][]Requirement
+}
+
+// ResolveOption is an option of the resolution of a dependency graph
+type ResolveOption func(*resolveOptions)
+
+type resolveOptions struct {
+	noCache bool
+	prefer  func(unikraft.ComponentType, string) (string, bool)
+}
+
+// WithResolveNoCache forces the package manager to not use its cache when
+// searching the catalog for dependencies
+func WithResolveNoCache(noCache bool) ResolveOption {
+	return func(ropts *resolveOptions) {
+		ropts.noCache = noCache
+	}
+}
+
+// WithResolvePreference provides a version of a dependency, for example one
+// which was previously recorded, which is selected whenever it satisfies the
+// requirements of the dependency
+func WithResolvePreference(prefer func(t unikraft.ComponentType, name string) (string, bool)) ResolveOption {
+	return func(ropts *resolveOptions) {
+		ropts.prefer = prefer
+	}
+}
+
+// ResolveDependencies selects the packages which are transitively required by
+// the root packages.  The versions of the root packages are fixed, whereas the
+// version of every other package is the one which satisfies the requirements
+// of all packages which depend on it.  An error is returned if the
+// requirements of a package conflict.
+func ResolveDependencies(pm PackageManager, roots []pack.Package, ropts ...ResolveOption) (*DependencyGraph, error) {
+	opts := resolveOptions{}
+	for _, o := range ropts {
+		o(&opts)
+	}
+
+	graph := &DependencyGraph{
+		roots:    roots,
+		selected: make(map[dependencyKey]pack.Package),
+	}
+
+	for _, root := range roots {
+		graph.selected[packageKey(root)] = root
+	}
+
+	for i := 0; i < maxResolveIterations; i++ {
+		requirements := graph.gather()
+		changed := false
+
+		// Drop the packages which are no longer required after a revision
+		for key := range graph.selected {
+			if _, ok := requirements[key]; !ok && !graph.isRoot(key) {
+				delete(graph.selected, key)
+				changed = true
+			}
+		}
+
+		keys := make([]dependencyKey, 0, len(requirements))
+		for key := range requirements {
+			keys = append(keys, key)
+		}
+
+		sort.Slice(keys, func(i, j int) bool {
+			return keys[i].String() < keys[j].String()
+		})
+
+		for _, key := range keys {
+			reqs := requirements[key]
+
+			if p, ok := graph.selected[key]; ok {
+				if satisfiesAll(p.Options().Version, reqs) {
+					continue
+				} else if graph.isRoot(key) {
+					return nil, fmt.Errorf("%s of the project does not satisfy %s", p.Options().TypeNameVersion(), joinRequirements(reqs))
+				}
+			}
+
+			p, err := findDependency(pm, key, reqs, opts)
+			if err != nil {
+				return nil, err
+			}
+
+			graph.selected[key] = p
+			changed = true
+		}
+
+		graph.requirements = requirements
+
+		if !changed {
+			return graph, nil
+		}
+	}
+
+	return nil, fmt.Errorf("could not resolve dependencies within %d iterations", maxResolveIterations)
+}
+
+// gather collects the requirements declared by the selected packages
+func (g *DependencyGraph) gather() map[dependencyKey][]Requirement {
+	requirements := make(map[dependencyKey][]Requirement)
+
+	for _, p := range g.selected {
+		for _, dep := range p.Options().Dependencies {
+			key := dependencyKey{dep.Type, dep.Name}
+			requirements[key] = append(requirements[key], Requirement{
+				Version: dep.Version,
+				By:      p,
+			})
+		}
+	}
+
+	for key := range requirements {
+		sort.Slice(requirements[key], func(i, j int) bool {
+			return requirements[key][i].By.Options().TypeNameVersion() < requirements[key][j].By.Options().TypeNameVersion()
+		})
+	}
+
+	return requirements
+}
+
+func (g *DependencyGraph) isRoot(key dependencyKey) bool {
+	for _, root := range g.roots {
+		if packageKey(root) == key {
+			return true
+		}
+	}
+
+	return false
+}
+
+// findDependency searches the catalog for the single package which satisfies
+// all requirements
+func findDependency(pm PackageManager, key dependencyKey, reqs []Requirement, opts resolveOptions) (pack.Package, error) {
+	var versions []string
+	for _, req := range reqs {
+		if len(req.Version) == 0 {
+			continue
+		}
+
+		found := false
+		for _, version := range versions {
+			if version == req.Version {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			versions = append(versions, req.Version)
+		}
+	}
+
+	// Multiple requirements can only be combined if they are all semantic
+	// versions or constraints, since a channel cannot be compared
+	if len(versions) > 1 {
+		for _, version := range versions {
+			if _, err := semver.Parse(version); err != nil && !semver.IsConstraint(version) {
+				return nil, fmt.Errorf("conflicting requirements for %s: %s", key, joinRequirements(reqs))
+			}
+		}
+	}
+
+	version := strings.Join(versions, " ")
+	if opts.prefer != nil {
+		if preferred, ok := opts.prefer(key.Type, key.Name); ok && satisfiesAll(preferred, reqs) {
+			version = preferred
+		}
+	}
+
+	packages, err := pm.Catalog(CatalogQuery{
+		Name:    key.Name,
+		Types:   []unikraft.ComponentType{key.Type},
+		Version: version,
+		NoCache: opts.noCache,
+	})
+	if err != nil && len(versions) > 1 {
+		return nil, fmt.Errorf("conflicting requirements for %s: %s: %v", key, joinRequirements(reqs), err)
+	} else if err != nil {
+		return nil, fmt.Errorf("could not resolve %s for %s: %v", key, joinRequirements(reqs), err)
+	}
+
+	if len(packages) == 0 {
+		if len(versions) > 1 {
+			return nil, fmt.Errorf("conflicting requirements for %s: %s", key, joinRequirements(reqs))
+		}
+
+		return nil, fmt.Errorf("could not find %s for %s", key, joinRequirements(reqs))
+	} else if len(packages) > 1 {
+		return nil, fmt.Errorf("too many options for %s", key)
+	}
+
+	if !satisfiesAll(packages[0].Options().Version, reqs) {
+		return nil, fmt.Errorf("conflicting requirements for %s: %s", key, joinRequirements(reqs))
+	}
+
+	return packages[0], nil
+}
+
+// satisfiesAll checks whether the version satisfies every requirement
+func satisfiesAll(version string, reqs []Requirement) bool {
+	for _, req := range reqs {
+		if len(req.Version) > 0 && !semver.Satisfies(req.Version, version) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func joinRequirements(reqs []Requirement) string {
+	var s []string
+	for _, req := range reqs {
+		s = append(s, req.String())
+	}
+
+	return strings.Join(s, ", ")
+}
+
+// Roots returns the packages whose dependencies were resolved
+func (g *DependencyGraph) Roots() []pack.Package {
+	return g.roots
+}
+
+// Packages returns the packages which are required in addition to the roots
+func (g *DependencyGraph) Packages() []pack.Package {
+	var packages []pack.Package
+	for key, p := range g.selected {
+		if !g.isRoot(key) {
+			packages = append(packages, p)
+		}
+	}
+
+	sort.Slice(packages, func(i, j int) bool {
+		return packages[i].Options().TypeNameVersion() < packages[j].Options().TypeNameVersion()
+	})
+
+	return packages
+}
+
+// Dependencies returns the packages selected for the dependencies of the
+// package in the order in which they are declared.  Every dependency of a
+// package within the graph is selected.
+func (g *DependencyGraph) Dependencies(p pack.Package) []pack.Package {
+	var packages []pack.Package
+	for _, dep := range p.Options().Dependencies {
+		if selected, ok := g.selected[dependencyKey{dep.Type, dep.Name}]; ok {
+			packages = append(packages, selected)
+		}
+	}
+
+	return packages
+}
+
+// Requirements returns the requirements of the package by the packages which
+// depend on it
+func (g *DependencyGraph) Requirements(p pack.Package) []Requirement {
+	return g.requirements[packageKey(p)]
+}
@@ -0,0 +1,325 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package packmanager
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"kraftkit.sh/internal/semver"
+	"kraftkit.sh/pack"
+	"kraftkit.sh/unikraft"
+)
+
+// fakePackage is a package which only carries its options
+type fakePackage struct {
+	opts *pack.PackageOptions
+}
+
+func (p fakePackage) Options() *pack.PackageOptions                 { return p.opts }
+func (p fakePackage) ApplyOptions(opts ...pack.PackageOption) error { return nil }
+func (p fakePackage) Compatible(string) bool                        { return false }
+func (p fakePackage) Name() string                                  { return p.opts.Name }
+func (p fakePackage) CanonicalName() string                         { return p.opts.TypeNameVersion() }
+func (p fakePackage) Pack() error                                   { return nil }
+func (p fakePackage) Pull(...pack.PullPackageOption) error          { return nil }
+func (p fakePackage) Format() string                                { return "fake" }
+
+func newFakePackage(t unikraft.ComponentType, name, version string, deps ...pack.Dependency) pack.Package {
+	return fakePackage{
+		opts: &pack.PackageOptions{
+			Type:         t,
+			Name:         name,
+			Version:      version,
+			Dependencies: deps,
+		},
+	}
+}
+
+func lib(name, version string) pack.Dependency {
+	return pack.Dependency{Type: unikraft.ComponentTypeLib, Name: name, Version: version}
+}
+
+// fakeManager answers catalog queries like the manifest package manager: an
+// empty version selects the first, default, version of a package, an exact
+// version or channel selects that version and a constraint selects the
+// highest semantic version which satisfies it.
+type fakeManager struct {
+	PackageManager
+	packages []pack.Package
+	queries  []CatalogQuery
+}
+
+func (m *fakeManager) Catalog(query CatalogQuery, _ ...pack.PackageOption) ([]pack.Package, error) {
+	m.queries = append(m.queries, query)
+
+	var versions []string
+	byVersion := map[string]pack.Package{}
+
+	for _, p := range m.packages {
+		if p.Name() != query.Name || p.Options().Type != query.Types[0] {
+			continue
+		}
+
+		versions = append(versions, p.Options().Version)
+		byVersion[p.Options().Version] = p
+	}
+
+	if len(versions) == 0 {
+		return nil, nil
+	}
+
+	if len(query.Version) == 0 {
+		return []pack.Package{byVersion[versions[0]]}, nil
+	}
+
+	if p, ok := byVersion[query.Version]; ok {
+		return []pack.Package{p}, nil
+	}
+
+	if !semver.IsConstraint(query.Version) {
+		return nil, nil
+	}
+
+	c, err := semver.ParseConstraint(query.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	version, ok := c.Highest(versions)
+	if !ok {
+		return nil, fmt.Errorf("no version of %s satisfies %s", query.Name, query.Version)
+	}
+
+	return []pack.Package{byVersion[version]}, nil
+}
+
+func TestResolveDependencies(t *testing.T) {
+	catalog := []pack.Package{
+		// Transitive dependencies
+		newFakePackage(unikraft.ComponentTypeLib, "a", "1.0.0"),
+		newFakePackage(unikraft.ComponentTypeLib, "a", "1.2.0", lib("b", "2.0.0")),
+		newFakePackage(unikraft.ComponentTypeLib, "a", "2.0.0"),
+		newFakePackage(unikraft.ComponentTypeLib, "b", "1.0.0"),
+		newFakePackage(unikraft.ComponentTypeLib, "b", "2.0.0"),
+
+		// Intersecting constraints
+		newFakePackage(unikraft.ComponentTypeLib, "c", "1.2.0"),
+		newFakePackage(unikraft.ComponentTypeLib, "c", "1.0.0"),
+		newFakePackage(unikraft.ComponentTypeLib, "c", "1.1.0"),
+		newFakePackage(unikraft.ComponentTypeLib, "c", "stable"),
+		newFakePackage(unikraft.ComponentTypeLib, "needs-c-1", "1.0.0", lib("c", "^1.0")),
+		newFakePackage(unikraft.ComponentTypeLib, "needs-c-below-1.2", "1.0.0", lib("c", "<1.2")),
+		newFakePackage(unikraft.ComponentTypeLib, "needs-c-stable", "1.0.0", lib("c", "stable")),
+		newFakePackage(unikraft.ComponentTypeLib, "needs-c-1.0.0", "1.0.0", lib("c", "1.0.0")),
+
+		// Requirement of the core
+		newFakePackage(unikraft.ComponentTypeLib, "needs-core", "1.0.0", pack.Dependency{Type: unikraft.ComponentTypeCore, Name: "unikraft", Version: "^0.12"}),
+
+		// A revision of x drops its requirement of z
+		newFakePackage(unikraft.ComponentTypeLib, "x", "1.3.0", lib("z", "")),
+		newFakePackage(unikraft.ComponentTypeLib, "x", "1.1.0"),
+		newFakePackage(unikraft.ComponentTypeLib, "y", "1.0.0", lib("x", "<1.2")),
+		newFakePackage(unikraft.ComponentTypeLib, "z", "1.0.0"),
+
+		// The selections of p and q revise each other indefinitely
+		newFakePackage(unikraft.ComponentTypeLib, "p", "2.0.0", lib("q", "1.0.0")),
+		newFakePackage(unikraft.ComponentTypeLib, "p", "1.0.0", lib("q", "2.0.0")),
+		newFakePackage(unikraft.ComponentTypeLib, "q", "2.0.0", lib("p", "1.0.0")),
+		newFakePackage(unikraft.ComponentTypeLib, "q", "1.0.0", lib("p", "2.0.0")),
+	}
+
+	app := func(deps ...pack.Dependency) pack.Package {
+		return newFakePackage(unikraft.ComponentTypeApp, "app", "latest", deps...)
+	}
+
+	tests := []struct {
+		name    string
+		roots   []pack.Package
+		prefer  map[string]string
+		want    []string
+		wantErr string
+	}{
+		{
+			name:  "no dependencies",
+			roots: []pack.Package{app()},
+		},
+		{
+			name:  "transitive",
+			roots: []pack.Package{app(lib("a", "^1.0"))},
+			want:  []string{"libs/a:1.2.0", "libs/b:2.0.0"},
+		},
+		{
+			name:  "default version",
+			roots: []pack.Package{app(lib("a", ""))},
+			want:  []string{"libs/a:1.0.0"},
+		},
+		{
+			name:   "preference",
+			roots:  []pack.Package{app(lib("a", "^1.0"))},
+			prefer: map[string]string{"a": "1.0.0"},
+			want:   []string{"libs/a:1.0.0"},
+		},
+		{
+			name:   "unsatisfying preference",
+			roots:  []pack.Package{app(lib("a", "^1.0"))},
+			prefer: map[string]string{"a": "2.0.0"},
+			want:   []string{"libs/a:1.2.0", "libs/b:2.0.0"},
+		},
+		{
+			name:  "constraint intersection",
+			roots: []pack.Package{app(lib("needs-c-1", ""), lib("needs-c-below-1.2", ""))},
+			want:  []string{"libs/c:1.1.0", "libs/needs-c-1:1.0.0", "libs/needs-c-below-1.2:1.0.0"},
+		},
+		{
+			name:  "same channel",
+			roots: []pack.Package{app(lib("c", "stable"), lib("needs-c-stable", ""))},
+			want:  []string{"libs/c:stable", "libs/needs-c-stable:1.0.0"},
+		},
+		{
+			name:    "channel and version conflict",
+			roots:   []pack.Package{app(lib("needs-c-stable", ""), lib("needs-c-1.0.0", ""))},
+			wantErr: "conflicting requirements for libs/c",
+		},
+		{
+			name:    "disjoint constraints",
+			roots:   []pack.Package{app(lib("c", ">=1.2"), lib("needs-c-below-1.2", ""))},
+			wantErr: "conflicting requirements for libs/c",
+		},
+		{
+			name:    "missing dependency",
+			roots:   []pack.Package{app(lib("missing", ""))},
+			wantErr: "could not find libs/missing",
+		},
+		{
+			name: "root satisfies requirement",
+			roots: []pack.Package{
+				app(lib("needs-core", "")),
+				newFakePackage(unikraft.ComponentTypeCore, "unikraft", "0.12.1"),
+			},
+			want: []string{"libs/needs-core:1.0.0"},
+		},
+		{
+			name: "root violates requirement",
+			roots: []pack.Package{
+				app(lib("needs-core", "")),
+				newFakePackage(unikraft.ComponentTypeCore, "unikraft", "0.11.0"),
+			},
+			wantErr: "core/unikraft:0.11.0 of the project does not satisfy ^0.12 (required by libs/needs-core:1.0.0)",
+		},
+		{
+			name:  "pruning of deselected packages",
+			roots: []pack.Package{app(lib("x", "^1.0"), lib("y", ""))},
+			want:  []string{"libs/x:1.1.0", "libs/y:1.0.0"},
+		},
+		{
+			name:    "iteration bound",
+			roots:   []pack.Package{app(lib("p", ""), lib("q", ""))},
+			wantErr: fmt.Sprintf("could not resolve dependencies within %d iterations", maxResolveIterations),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pm := &fakeManager{packages: catalog}
+
+			ropts := []ResolveOption{WithResolveNoCache(true)}
+			if tt.prefer != nil {
+				ropts = append(ropts, WithResolvePreference(func(_ unikraft.ComponentType, name string) (string, bool) {
+					version, ok := tt.prefer[name]
+					return version, ok
+				}))
+			}
+
+			graph, err := ResolveDependencies(pm, tt.roots, ropts...)
+			if len(tt.wantErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error %q, got: %v", tt.wantErr, err)
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for _, p := range graph.Packages() {
+				got = append(got, p.Options().TypeNameVersion())
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+
+			for _, query := range pm.queries {
+				if !query.NoCache {
+					t.Errorf("expected query of %s to bypass the cache", query.Name)
+				}
+			}
+		})
+	}
+}
+
+func TestDependencyGraph(t *testing.T) {
+	b := newFakePackage(unikraft.ComponentTypeLib, "b", "1.0.0")
+	a := newFakePackage(unikraft.ComponentTypeLib, "a", "1.0.0", lib("b", "^1.0"))
+	root := newFakePackage(unikraft.ComponentTypeApp, "app", "latest", lib("a", ""), lib("b", "1.0.0"))
+
+	graph, err := ResolveDependencies(&fakeManager{packages: []pack.Package{a, b}}, []pack.Package{root})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if roots := graph.Roots(); len(roots) != 1 || roots[0].Name() != "app" {
+		t.Errorf("unexpected roots: %v", roots)
+	}
+
+	var deps []string
+	for _, p := range graph.Dependencies(root) {
+		deps = append(deps, p.Name())
+	}
+
+	if !reflect.DeepEqual(deps, []string{"a", "b"}) {
+		t.Errorf("expected dependencies in declared order, got %v", deps)
+	}
+
+	var reqs []string
+	for _, req := range graph.Requirements(b) {
+		reqs = append(reqs, req.String())
+	}
+
+	want := []string{"1.0.0 (required by apps/app:latest)", "^1.0 (required by libs/a:1.0.0)"}
+	if !reflect.DeepEqual(reqs, want) {
+		t.Errorf("expected requirements %v, got %v", want, reqs)
+	}
+}
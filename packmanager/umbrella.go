@@ -34,6 +34,8 @@ package packmanager
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strings"
 
 	"kraftkit.sh/pack"
 )
@@ -155,6 +157,28 @@ func (um UmbrellaManager) RemoveSource(source string) error {
 	return nil
 }
 
+// Delete removes the package of the reference from the local package store of
+// every package manager which holds it.
+func (um UmbrellaManager) Delete(ref string) error {
+	var errs []string
+	deleted := false
+	for _, manager := range packageManagers {
+		if err := manager.Delete(ref); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", manager.Format(), err))
+			continue
+		}
+
+		deleted = true
+	}
+
+	if !deleted && len(errs) > 0 {
+		sort.Strings(errs)
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+
+	return nil
+}
+
 // Push the resulting package to the supported registry of the implementation.
 func (um UmbrellaManager) Push(path string) error {
 	return fmt.Errorf("not implemented: pack.UmbrellaManager.Push")
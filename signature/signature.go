@@ -0,0 +1,270 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+// Package signature provides detached ed25519 signatures of artifacts, such as
+// package archives, manifest indexes and OCI image manifests, together with
+// their verification against a set of trusted keys.
+package signature
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// Extension is the extension of the file which holds the detached
+	// signature of an artifact, e.g. `index.yaml.sig` for `index.yaml`.
+	Extension = ".sig"
+
+	// publicKeyPrefix is the prefix of the textual representation of a public
+	// key.
+	publicKeyPrefix = "ed25519:"
+
+	// privateKeyType is the PEM block type of a private key file.
+	privateKeyType = "PRIVATE KEY"
+)
+
+var (
+	// ErrUnsigned is returned when an artifact has no signature.
+	ErrUnsigned = errors.New("artifact is not signed")
+
+	// ErrUntrusted is returned when an artifact is signed with a key which is
+	// not trusted.
+	ErrUntrusted = errors.New("artifact is signed by an untrusted key")
+
+	// ErrInvalid is returned when the signature does not match the artifact.
+	ErrInvalid = errors.New("signature does not match artifact")
+)
+
+// Signature is the detached signature of an artifact.  The digest of the
+// artifact, rather than its content, is signed such that large artifacts do
+// not have to be held in memory and such that the signature can be checked
+// against content-addressed descriptors.
+type Signature struct {
+	// KeyID is the identifier of the public key of the signer.
+	KeyID string `json:"key_id"`
+
+	// Digest of the signed artifact in the form `sha256:<hex>`.
+	Digest string `json:"digest"`
+
+	// Signature over the digest.
+	Signature []byte `json:"signature"`
+}
+
+// Digest returns the digest of the content in the form `sha256:<hex>`.
+func Digest(content []byte) string {
+	sum := sha256.Sum256(content)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// DigestReader returns the digest of everything read from the reader.
+func DigestReader(r io.Reader) (string, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// DigestFile returns the digest of the file at the path.
+func DigestFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+
+	defer f.Close()
+
+	return DigestReader(f)
+}
+
+// KeyID returns the identifier of a public key, which is the hex encoding of
+// the first eight bytes of its SHA-256 fingerprint.
+func KeyID(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+	return hex.EncodeToString(sum[:8])
+}
+
+// EncodePublicKey returns the textual representation of a public key as used
+// within the list of trusted keys, e.g. `ed25519:<base64>`.
+func EncodePublicKey(pub ed25519.PublicKey) string {
+	return publicKeyPrefix + base64.StdEncoding.EncodeToString(pub)
+}
+
+// ParsePublicKey parses the textual representation of a public key.
+func ParsePublicKey(s string) (ed25519.PublicKey, error) {
+	if !strings.HasPrefix(s, publicKeyPrefix) {
+		return nil, fmt.Errorf("unsupported public key: expected prefix %s", publicKeyPrefix)
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(s, publicKeyPrefix))
+	if err != nil {
+		return nil, fmt.Errorf("could not decode public key: %v", err)
+	}
+
+	if len(raw) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid public key size: %d", len(raw))
+	}
+
+	return ed25519.PublicKey(raw), nil
+}
+
+// GenerateKey creates a new private key at the path and returns its public key.
+// An existing key is never overwritten.
+func GenerateKey(path string) (ed25519.PublicKey, error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("could not create key: %v", err)
+	}
+
+	defer f.Close()
+
+	if err := pem.Encode(f, &pem.Block{Type: privateKeyType, Bytes: der}); err != nil {
+		return nil, err
+	}
+
+	return pub, nil
+}
+
+// ReadPrivateKey reads the PEM-encoded private key at the path.
+func ReadPrivateKey(path string) (ed25519.PrivateKey, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(raw)
+	if block == nil || block.Type != privateKeyType {
+		return nil, fmt.Errorf("could not decode private key: %s", path)
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse private key: %v", err)
+	}
+
+	priv, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("private key is not an ed25519 key: %s", path)
+	}
+
+	return priv, nil
+}
+
+// Sign returns the signature of the digest of an artifact.
+func Sign(key ed25519.PrivateKey, digest string) *Signature {
+	return &Signature{
+		KeyID:     KeyID(key.Public().(ed25519.PublicKey)),
+		Digest:    digest,
+		Signature: ed25519.Sign(key, []byte(digest)),
+	}
+}
+
+// SignFile signs the file at the path and writes the detached signature next
+// to it.
+func SignFile(key ed25519.PrivateKey, path string) (*Signature, error) {
+	digest, err := DigestFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	sig := Sign(key, digest)
+
+	raw, err := sig.Encode()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.WriteFile(path+Extension, raw, 0o644); err != nil {
+		return nil, err
+	}
+
+	return sig, nil
+}
+
+// Encode returns the serialized representation of the signature.
+func (sig *Signature) Encode() ([]byte, error) {
+	return json.Marshal(sig)
+}
+
+// Parse decodes a serialized signature.
+func Parse(raw []byte) (*Signature, error) {
+	sig := &Signature{}
+	if err := json.Unmarshal(raw, sig); err != nil {
+		return nil, fmt.Errorf("could not decode signature: %v", err)
+	}
+
+	if len(sig.Signature) == 0 {
+		return nil, fmt.Errorf("could not decode signature: empty signature")
+	}
+
+	return sig, nil
+}
+
+// ReadFile reads the detached signature of the file at the path.  If the file
+// has no signature, nil is returned.
+func ReadFile(path string) (*Signature, error) {
+	raw, err := os.ReadFile(path + Extension)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return Parse(raw)
+}
@@ -0,0 +1,146 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package signature
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"kraftkit.sh/internal/logger"
+	"kraftkit.sh/iostreams"
+)
+
+func TestSignFile(t *testing.T) {
+	dir := t.TempDir()
+	keyPath := filepath.Join(dir, "signing.key")
+
+	pub, err := GenerateKey(keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := GenerateKey(keyPath); err == nil {
+		t.Errorf("expected existing key not to be overwritten")
+	}
+
+	key, err := ReadPrivateKey(keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	artifact := filepath.Join(dir, "index.yaml")
+	if err := os.WriteFile(artifact, []byte("manifests: []"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := SignFile(key, artifact); err != nil {
+		t.Fatal(err)
+	}
+
+	sig, err := ReadFile(artifact)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	digest, err := DigestFile(artifact)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	trusted, err := NewVerifier(PolicyEnforce, []string{EncodePublicKey(pub)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := trusted.Verify(digest, sig); err != nil {
+		t.Errorf("expected valid signature: %v", err)
+	}
+
+	if err := trusted.Verify(Digest([]byte("tampered")), sig); !errors.Is(err, ErrInvalid) {
+		t.Errorf("expected invalid signature, got: %v", err)
+	}
+
+	if err := trusted.Verify(digest, nil); !errors.Is(err, ErrUnsigned) {
+		t.Errorf("expected unsigned artifact, got: %v", err)
+	}
+
+	untrusted, err := NewVerifier(PolicyEnforce, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := untrusted.Verify(digest, sig); !errors.Is(err, ErrUntrusted) {
+		t.Errorf("expected untrusted signature, got: %v", err)
+	}
+}
+
+func TestEnforce(t *testing.T) {
+	l := logger.NewLogger(io.Discard, iostreams.NewColorScheme(false, false, false))
+	digest := Digest([]byte("artifact"))
+
+	tests := []struct {
+		policy  string
+		wantErr bool
+	}{
+		{policy: "", wantErr: false},
+		{policy: "none", wantErr: false},
+		{policy: "warn", wantErr: false},
+		{policy: "enforce", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		policy, err := PolicyFromString(tt.policy)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		v, err := NewVerifier(policy, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := v.Enforce(l, "artifact", digest, nil); (err != nil) != tt.wantErr {
+			t.Errorf("policy %q: unexpected error: %v", tt.policy, err)
+		}
+	}
+
+	if _, err := PolicyFromString("strict"); err == nil {
+		t.Errorf("expected unknown policy")
+	}
+
+	if _, err := NewVerifier(PolicyEnforce, []string{"rsa:AAAA"}); err == nil {
+		t.Errorf("expected unsupported public key")
+	}
+}
@@ -0,0 +1,162 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package signature
+
+import (
+	"crypto/ed25519"
+	"fmt"
+
+	"kraftkit.sh/config"
+	"kraftkit.sh/log"
+)
+
+// Policy determines how signatures are checked when pulling artifacts.
+type Policy string
+
+const (
+	// PolicyNone does not check signatures.
+	PolicyNone = Policy("none")
+
+	// PolicyWarn checks signatures and warns about unsigned or untrusted
+	// artifacts.
+	PolicyWarn = Policy("warn")
+
+	// PolicyEnforce refuses unsigned or untrusted artifacts.
+	PolicyEnforce = Policy("enforce")
+)
+
+// Policies returns the list of supported policies.
+func Policies() []Policy {
+	return []Policy{
+		PolicyNone,
+		PolicyWarn,
+		PolicyEnforce,
+	}
+}
+
+// PolicyFromString returns the policy of its name, where an empty name is the
+// default policy, PolicyNone.
+func PolicyFromString(name string) (Policy, error) {
+	if len(name) == 0 {
+		return PolicyNone, nil
+	}
+
+	for _, policy := range Policies() {
+		if string(policy) == name {
+			return policy, nil
+		}
+	}
+
+	return "", fmt.Errorf("unknown signature policy: %s", name)
+}
+
+// Verifier checks signatures against a set of trusted keys.
+type Verifier struct {
+	policy Policy
+	keys   map[string]ed25519.PublicKey
+}
+
+// NewVerifier returns a verifier which applies the policy and trusts the keys,
+// each in the form `ed25519:<base64>`.
+func NewVerifier(policy Policy, trusted []string) (*Verifier, error) {
+	v := &Verifier{
+		policy: policy,
+		keys:   make(map[string]ed25519.PublicKey),
+	}
+
+	for _, s := range trusted {
+		pub, err := ParsePublicKey(s)
+		if err != nil {
+			return nil, err
+		}
+
+		v.keys[KeyID(pub)] = pub
+	}
+
+	return v, nil
+}
+
+// NewVerifierFromConfig returns the verifier of the signing section of the
+// configuration.
+func NewVerifierFromConfig(cfg *config.Config) (*Verifier, error) {
+	policy, err := PolicyFromString(cfg.Signing.Policy)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewVerifier(policy, cfg.Signing.TrustedKeys)
+}
+
+// Policy returns the policy of the verifier.
+func (v *Verifier) Policy() Policy {
+	return v.policy
+}
+
+// Verify checks that the signature is made by a trusted key over the digest,
+// regardless of the policy.
+func (v *Verifier) Verify(digest string, sig *Signature) error {
+	if sig == nil {
+		return ErrUnsigned
+	}
+
+	pub, ok := v.keys[sig.KeyID]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUntrusted, sig.KeyID)
+	}
+
+	if sig.Digest != digest || !ed25519.Verify(pub, []byte(sig.Digest), sig.Signature) {
+		return ErrInvalid
+	}
+
+	return nil
+}
+
+// Enforce applies the policy to the signature of the named artifact.  Only the
+// enforcing policy returns an error.
+func (v *Verifier) Enforce(l log.Logger, name, digest string, sig *Signature) error {
+	if v == nil || v.policy == PolicyNone {
+		return nil
+	}
+
+	err := v.Verify(digest, sig)
+	if err == nil {
+		l.Debugf("verified signature of %s by %s", name, sig.KeyID)
+		return nil
+	}
+
+	if v.policy == PolicyWarn {
+		l.Warnf("%s: %v", name, err)
+		return nil
+	}
+
+	return fmt.Errorf("refusing %s: %w", name, err)
+}
@@ -245,6 +245,26 @@ func (c *{{ .GoName }}Client) setRpcRequestSetDefaults(face any) error {
 
 	return nil
 }
+
+// readResponse reads the next line which is not an asynchronous event.  QEMU
+// may emit events at any time, including between a command and its return.
+func (c *{{ .GoName }}Client) readResponse() ([]byte, error) {
+	for {
+		b, err := c.recv.ReadBytes('\n')
+		if err != nil {
+			return nil, err
+		}
+
+		var event struct {
+			Event *string ` + "`" + `json:"event"` + "`" + `
+		}
+		if err := json.Unmarshal(b, &event); err == nil && event.Event != nil {
+			continue
+		}
+
+		return b, nil
+	}
+}
 `
 
 	messageTemplate = template.Must(template.New("message").Parse(MessageTemplate))
@@ -353,7 +373,7 @@ func (c *{{ .ServiceGoName }}Client) {{ .GoName }}(
 
 	{{ if $hasRes }}
 	var res {{ if $resAsAny }}any{{ else }}{{ .Output.GoIdent.GoName }}{{ end }}
-	b, err = c.recv.ReadBytes('\n')
+	b, err = c.readResponse()
 	if err != nil {
 		return nil, err
 	}
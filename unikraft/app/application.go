@@ -535,6 +535,36 @@ func (a *ApplicationConfig) LibraryNames() []string {
 	return names
 }
 
+// AddLibrary adds a library, such as one which is required by another library,
+// to the application unless the application already contains the library
+func (a *ApplicationConfig) AddLibrary(name, version string, copts ...component.ComponentOption) error {
+	if _, ok := a.libraries[name]; ok {
+		return nil
+	}
+
+	library := lib.LibraryConfig{
+		ComponentConfig: component.ComponentConfig{
+			Name:    name,
+			Version: version,
+		},
+	}
+
+	if err := library.ApplyOptions(append([]component.ComponentOption{
+		component.WithWorkdir(a.workingDir),
+		component.WithType(unikraft.ComponentTypeLib),
+	}, copts...)...); err != nil {
+		return err
+	}
+
+	if a.libraries == nil {
+		a.libraries = lib.Libraries{}
+	}
+
+	a.libraries[name] = library
+
+	return nil
+}
+
 // TargetNames return names for all targets in this Compose config
 func (a *ApplicationConfig) TargetNames() []string {
 	var names []string
@@ -462,6 +462,18 @@ func LoadTargets(source []interface{}, configDetails config.ConfigDetails, outdi
 
 		if opts.ResolvePaths {
 			target.Kernel = configDetails.RelativePath(target.Kernel)
+
+			if len(target.Runtime.Initrd) > 0 {
+				target.Runtime.Initrd = configDetails.RelativePath(target.Runtime.Initrd)
+			}
+
+			// Only the source of a volume, which precedes the first colon, is a
+			// path on the host
+			for j, volume := range target.Runtime.Volumes {
+				if source, destination, ok := strings.Cut(volume, ":"); ok && len(source) > 0 {
+					target.Runtime.Volumes[j] = configDetails.RelativePath(source) + ":" + destination
+				}
+			}
 		}
 
 		targets[i] = target
@@ -0,0 +1,122 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package app
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"kraftkit.sh/unikraft/config"
+	"kraftkit.sh/unikraft/target"
+)
+
+func TestLoadTargetsRuntime(t *testing.T) {
+	source, err := ParseYAML([]byte(`
+targets:
+  - architecture: x86_64
+    platform: qemu
+    runtime:
+      memory: 64
+      vcpus: 2
+      args: nginx -c /etc/nginx.conf
+      env:
+        - HOME=/root
+        - GREETING=hello world
+      ports:
+        - 8080:80
+        - 443
+      volumes:
+        - ./html:/var/www
+        - /srv/data:/data:ro
+      initrd: ./rootfs.cpio
+      driver: qemu
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := &LoaderOptions{ResolvePaths: true}
+	opts.SetProjectName("helloworld", true)
+
+	targets, err := LoadTargets(
+		getSectionList(source, "targets"),
+		config.ConfigDetails{WorkingDir: "/app"},
+		"/app/.unikraft/build",
+		opts,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(targets) != 1 {
+		t.Fatalf("expected 1 target, got %d", len(targets))
+	}
+
+	want := target.RuntimeConfig{
+		Memory:  "64",
+		VCPUs:   2,
+		Args:    target.Command{"nginx", "-c", "/etc/nginx.conf"},
+		Env:     map[string]string{"HOME": "/root", "GREETING": "hello world"},
+		Ports:   []string{"8080:80", "443"},
+		Volumes: []string{filepath.Join("/app", "html") + ":/var/www", "/srv/data:/data:ro"},
+		Initrd:  filepath.Join("/app", "rootfs.cpio"),
+		Driver:  "qemu",
+	}
+
+	if runtime := targets[0].Runtime; !reflect.DeepEqual(runtime, want) {
+		t.Errorf("expected runtime %+v, got %+v", want, runtime)
+	}
+
+	if size, err := targets[0].Runtime.MemorySize(); err != nil || size != 64 {
+		t.Errorf("expected 64 MiB, got %d (%v)", size, err)
+	}
+}
+
+func TestLoadTargetsInvalidRuntime(t *testing.T) {
+	for _, runtime := range []string{
+		`runtime: 64`,
+		`runtime: {env: 1}`,
+	} {
+		source, err := ParseYAML([]byte("targets:\n  - architecture: x86_64\n    platform: qemu\n    " + runtime + "\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		opts := &LoaderOptions{}
+		opts.SetProjectName("helloworld", true)
+
+		if _, err := LoadTargets(getSectionList(source, "targets"), config.ConfigDetails{}, "", opts); err == nil {
+			t.Errorf("%s: expected error", runtime)
+		}
+	}
+}
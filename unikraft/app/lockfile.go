@@ -0,0 +1,200 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package app
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"gopkg.in/yaml.v2"
+
+	"kraftkit.sh/pack"
+	"kraftkit.sh/unikraft"
+)
+
+// DefaultLockFileName is the name of the lockfile which is kept next to the
+// Kraftfile of a project
+const DefaultLockFileName = "kraft.lock"
+
+// lockFileHeader is written at the top of every lockfile
+const lockFileHeader = "# This file is generated by kraft and pins the components of the project.\n# Refresh it with `kraft pkg update --lock` instead of editing it.\n"
+
+// LockedComponent pins a component of a project to the exact content it was
+// resolved to.
+type LockedComponent struct {
+	// Type of the component
+	Type unikraft.ComponentType `yaml:"type"`
+
+	// Name of the component
+	Name string `yaml:"name"`
+
+	// Version which the component was resolved to
+	Version string `yaml:"version"`
+
+	// Resource is the archive the component was pulled from
+	Resource string `yaml:"resource,omitempty"`
+
+	// Commit is the Git commit the component was pulled at
+	Commit string `yaml:"commit,omitempty"`
+
+	// Sha256 is the checksum of the archive of the component
+	Sha256 string `yaml:"sha256,omitempty"`
+}
+
+// LockFile records the resolution of all components of a project such that
+// subsequent builds are reproducible.
+type LockFile struct {
+	Components []LockedComponent `yaml:"components"`
+
+	path string
+}
+
+// LockFilePath returns the path of the lockfile of the project within the
+// working directory
+func LockFilePath(workdir string) string {
+	return filepath.Join(workdir, DefaultLockFileName)
+}
+
+// NewLockFile returns an empty lockfile which is saved at the path
+func NewLockFile(path string) *LockFile {
+	return &LockFile{
+		path: path,
+	}
+}
+
+// NewLockFileFromPath reads the lockfile at the path.  A missing lockfile
+// results in an empty lockfile.
+func NewLockFileFromPath(path string) (*LockFile, error) {
+	lf := NewLockFile(path)
+
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return lf, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := yaml.Unmarshal(raw, lf); err != nil {
+		return nil, fmt.Errorf("could not parse lockfile %s: %v", path, err)
+	}
+
+	return lf, nil
+}
+
+// Path returns the location of the lockfile
+func (lf *LockFile) Path() string {
+	return lf.path
+}
+
+// Lookup returns the locked component of the type and name
+func (lf *LockFile) Lookup(t unikraft.ComponentType, name string) (LockedComponent, bool) {
+	for _, lc := range lf.Components {
+		if lc.Type == t && lc.Name == name {
+			return lc, true
+		}
+	}
+
+	return LockedComponent{}, false
+}
+
+// Set adds the locked component or replaces the one of the same type and name
+func (lf *LockFile) Set(lc LockedComponent) {
+	for i, existing := range lf.Components {
+		if existing.Type == lc.Type && existing.Name == lc.Name {
+			lf.Components[i] = lc
+			return
+		}
+	}
+
+	lf.Components = append(lf.Components, lc)
+
+	sort.Slice(lf.Components, func(i, j int) bool {
+		if lf.Components[i].Type != lf.Components[j].Type {
+			return lf.Components[i].Type < lf.Components[j].Type
+		}
+
+		return lf.Components[i].Name < lf.Components[j].Name
+	})
+}
+
+// Equal checks whether both lockfiles pin the same components to the same
+// content
+func (lf *LockFile) Equal(other *LockFile) bool {
+	if len(lf.Components) != len(other.Components) {
+		return false
+	}
+
+	for _, lc := range lf.Components {
+		if o, ok := other.Lookup(lc.Type, lc.Name); !ok || o != lc {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Save writes the lockfile to its path
+func (lf *LockFile) Save() error {
+	raw, err := yaml.Marshal(lf)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(lf.path, append([]byte(lockFileHeader), raw...), 0o644)
+}
+
+// LockPackage returns the locked component of a package which has been pulled
+func LockPackage(p pack.Package) LockedComponent {
+	popts := p.Options()
+
+	return LockedComponent{
+		Type:     popts.Type,
+		Name:     p.Name(),
+		Version:  popts.Version,
+		Resource: popts.RemoteLocation,
+		Commit:   popts.Commit,
+		Sha256:   popts.Sha256,
+	}
+}
+
+// PullOptions returns the options which pull the locked content of the
+// component
+func (lc LockedComponent) PullOptions() []pack.PullPackageOption {
+	return []pack.PullPackageOption{
+		pack.WithPullResource(lc.Resource),
+		pack.WithPullCommit(lc.Commit),
+		pack.WithPullSha256(lc.Sha256),
+	}
+}
@@ -0,0 +1,157 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package app
+
+import (
+	"os"
+	"testing"
+
+	"kraftkit.sh/unikraft"
+)
+
+func TestLockFileSetLookup(t *testing.T) {
+	lf := NewLockFile("kraft.lock")
+
+	lf.Set(LockedComponent{Type: unikraft.ComponentTypeLib, Name: "musl", Version: "0.10.0"})
+	lf.Set(LockedComponent{Type: unikraft.ComponentTypeCore, Name: "unikraft", Version: "0.11.0"})
+	lf.Set(LockedComponent{Type: unikraft.ComponentTypeLib, Name: "lwip", Version: "stable"})
+	lf.Set(LockedComponent{Type: unikraft.ComponentTypeLib, Name: "musl", Version: "0.11.0"})
+
+	if len(lf.Components) != 3 {
+		t.Fatalf("expected 3 components, got %d", len(lf.Components))
+	}
+
+	want := []string{"unikraft", "lwip", "musl"}
+	for i, lc := range lf.Components {
+		if lc.Name != want[i] {
+			t.Errorf("component %d: expected %s, got %s", i, want[i], lc.Name)
+		}
+	}
+
+	if lc, ok := lf.Lookup(unikraft.ComponentTypeLib, "musl"); !ok || lc.Version != "0.11.0" {
+		t.Errorf("expected replaced musl at 0.11.0, got %v (%v)", lc, ok)
+	}
+
+	if _, ok := lf.Lookup(unikraft.ComponentTypeApp, "musl"); ok {
+		t.Errorf("expected lookup to match on the type")
+	}
+
+	if _, ok := lf.Lookup(unikraft.ComponentTypeLib, "newlib"); ok {
+		t.Errorf("expected missing component")
+	}
+}
+
+func TestLockFileEqual(t *testing.T) {
+	musl := LockedComponent{Type: unikraft.ComponentTypeLib, Name: "musl", Version: "0.11.0", Sha256: "abc"}
+	core := LockedComponent{Type: unikraft.ComponentTypeCore, Name: "unikraft", Version: "0.11.0", Commit: "deadbeef"}
+
+	newLockFile := func(components ...LockedComponent) *LockFile {
+		lf := NewLockFile("kraft.lock")
+		for _, lc := range components {
+			lf.Set(lc)
+		}
+		return lf
+	}
+
+	changed := musl
+	changed.Sha256 = "def"
+
+	tests := []struct {
+		name  string
+		a, b  *LockFile
+		equal bool
+	}{
+		{name: "empty", a: newLockFile(), b: newLockFile(), equal: true},
+		{name: "same order", a: newLockFile(musl, core), b: newLockFile(musl, core), equal: true},
+		{name: "different order", a: newLockFile(musl, core), b: newLockFile(core, musl), equal: true},
+		{name: "missing component", a: newLockFile(musl, core), b: newLockFile(musl), equal: false},
+		{name: "different content", a: newLockFile(musl, core), b: newLockFile(changed, core), equal: false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.a.Equal(tt.b); got != tt.equal {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.equal, got)
+		}
+		if got := tt.b.Equal(tt.a); got != tt.equal {
+			t.Errorf("%s (reversed): expected %v, got %v", tt.name, tt.equal, got)
+		}
+	}
+}
+
+func TestLockFileSave(t *testing.T) {
+	path := LockFilePath(t.TempDir())
+
+	missing, err := NewLockFileFromPath(path)
+	if err != nil {
+		t.Fatalf("expected missing lockfile to be empty: %v", err)
+	}
+
+	if len(missing.Components) != 0 || missing.Path() != path {
+		t.Errorf("unexpected lockfile: %v", missing)
+	}
+
+	lf := NewLockFile(path)
+	lf.Set(LockedComponent{
+		Type:     unikraft.ComponentTypeLib,
+		Name:     "musl",
+		Version:  "0.11.0",
+		Resource: "https://github.com/unikraft/lib-musl/archive/refs/tags/RELEASE-0.11.0.tar.gz",
+		Sha256:   "abc",
+	})
+	lf.Set(LockedComponent{
+		Type:    unikraft.ComponentTypeCore,
+		Name:    "unikraft",
+		Version: "stable",
+		Commit:  "deadbeef",
+	})
+
+	if err := lf.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := NewLockFileFromPath(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !loaded.Equal(lf) {
+		t.Errorf("expected %v, got %v", lf.Components, loaded.Components)
+	}
+
+	if err := os.WriteFile(path, []byte("components: {"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewLockFileFromPath(path); err == nil {
+		t.Errorf("expected malformed lockfile to fail")
+	}
+}
@@ -31,6 +31,7 @@ import (
 
 	"kraftkit.sh/log"
 	"kraftkit.sh/packmanager"
+	"kraftkit.sh/unikraft"
 	"kraftkit.sh/unikraft/component"
 	"kraftkit.sh/unikraft/config"
 )
@@ -388,6 +389,47 @@ func NewApplicationFromOptions(popts *ProjectOptions, copts ...component.Compone
 	return project, nil
 }
 
+// LoadProject interprets the project within the working directory, with paths
+// resolved relative to it and its components retrieved via the package
+// manager.
+func LoadProject(pm packmanager.PackageManager, l log.Logger, workdir string) (*ApplicationConfig, error) {
+	popts, err := NewProjectOptions(
+		nil,
+		WithLogger(l),
+		WithWorkingDirectory(workdir),
+		WithDefaultConfigPath(),
+		WithPackageManager(&pm),
+		WithResolvedPaths(true),
+		WithDotConfig(false),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewApplicationFromOptions(popts)
+}
+
+// MergeProjectTemplate loads the template of the project within the working
+// directory, which must have been pulled into it, and merges the project into
+// the template.  A project which is not based on a template is returned as-is.
+func MergeProjectTemplate(pm packmanager.PackageManager, l log.Logger, workdir string, project *ApplicationConfig) (*ApplicationConfig, error) {
+	if project.Template().Name() == "" {
+		return project, nil
+	}
+
+	templateWorkdir, err := unikraft.PlaceComponent(workdir, project.Template().Type(), project.Template().Name())
+	if err != nil {
+		return nil, err
+	}
+
+	templateProject, err := LoadProject(pm, l, templateWorkdir)
+	if err != nil {
+		return nil, err
+	}
+
+	return templateProject.MergeTemplate(project), nil
+}
+
 // getConfigPathsFromOptions retrieves the config files for project based on project options
 func getConfigPathsFromOptions(options *ProjectOptions) ([]string, error) {
 	if len(options.ConfigPaths) != 0 {
@@ -89,6 +89,7 @@ func createTransformHook(additionalTransformers ...Transformer) mapstructure.Dec
 		reflect.TypeOf(arch.ArchitectureConfig{}): transformArchitecture,
 		reflect.TypeOf(plat.PlatformConfig{}):     transformPlatform,
 		reflect.TypeOf(initrd.InitrdConfig{}):     transformInitrd,
+		reflect.TypeOf(target.RuntimeConfig{}):    transformRuntime,
 		reflect.TypeOf(lib.LibraryConfig{}):       transformLibrary,
 		reflect.TypeOf(core.UnikraftConfig{}):     transformUnikraft,
 		// Use a map as we need to access the name (which is the key)
@@ -181,6 +182,36 @@ var transformInitrd TransformerFunc = func(data interface{}) (interface{}, error
 	}
 }
 
+var transformRuntime TransformerFunc = func(data interface{}) (interface{}, error) {
+	switch value := data.(type) {
+	case map[string]interface{}:
+		// The memory can be specified as a plain number of MiB
+		if memory, ok := value["memory"]; ok {
+			value["memory"] = toString(memory, false)
+		}
+
+		// Ports can be specified as a plain number of the guest
+		if ports, ok := value["ports"].([]interface{}); ok {
+			for i, port := range ports {
+				ports[i] = toString(port, false)
+			}
+		}
+
+		if env, ok := value["env"]; ok {
+			mapping, err := transformMappingOrList(env, "=", false)
+			if err != nil {
+				return value, err
+			}
+
+			value["env"] = mapping
+		}
+
+		return value, nil
+	default:
+		return data, errors.Errorf("invalid type %T for runtime", value)
+	}
+}
+
 func transformMappingOrList(mappingOrList interface{}, sep string, allowNil bool) (interface{}, error) {
 	switch value := mappingOrList.(type) {
 	case map[string]interface{}:
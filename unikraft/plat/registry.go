@@ -0,0 +1,100 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package plat
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	PlatformKVM    = "kvm"
+	PlatformXen    = "xen"
+	PlatformLinuxu = "linuxu"
+)
+
+// platformArchitectures records the architectures which each platform of the
+// Unikraft core can be built for.
+var platformArchitectures = map[string][]string{
+	PlatformKVM:    {"x86_64", "arm", "arm64"},
+	PlatformXen:    {"x86_64", "arm", "arm64"},
+	PlatformLinuxu: {"x86_64", "arm", "arm64"},
+}
+
+// platformAliases maps alternative names which are commonly used to refer to
+// a platform onto its canonical name.
+var platformAliases = map[string]string{
+	"qemu":        PlatformKVM,
+	"firecracker": PlatformKVM,
+	"fc":          PlatformKVM,
+	"linux":       PlatformLinuxu,
+}
+
+// Platforms returns the canonical names of all known platforms.
+func Platforms() []string {
+	plats := make([]string, 0, len(platformArchitectures))
+	for plat := range platformArchitectures {
+		plats = append(plats, plat)
+	}
+
+	sort.Strings(plats)
+
+	return plats
+}
+
+// PlatformByName returns the canonical name of the platform which is referred
+// to by `name`, resolving any alias.
+func PlatformByName(name string) (string, error) {
+	name = strings.ToLower(name)
+
+	if _, ok := platformArchitectures[name]; ok {
+		return name, nil
+	}
+
+	if plat, ok := platformAliases[name]; ok {
+		return plat, nil
+	}
+
+	return "", fmt.Errorf("unknown platform: %s (expected one of: %s)", name, strings.Join(Platforms(), ", "))
+}
+
+// PlatformArchitectures returns the architectures which the platform can be
+// built for.
+func PlatformArchitectures(name string) ([]string, error) {
+	plat, err := PlatformByName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return platformArchitectures[plat], nil
+}
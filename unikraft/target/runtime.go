@@ -0,0 +1,112 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package target
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// RuntimeConfig contains the default settings which are used when the target
+// is run.
+type RuntimeConfig struct {
+	// Memory is the amount of memory assigned to the unikernel, either as a
+	// number of MiB or with a unit, e.g.: 64Mi, 1G.
+	Memory string `yaml:",omitempty" json:"memory,omitempty"`
+
+	// VCPUs is the number of virtual CPUs assigned to the unikernel.
+	VCPUs uint64 `yaml:",omitempty" json:"vcpus,omitempty"`
+
+	// Args are the arguments passed to the application.
+	Args Command `yaml:",omitempty" json:"args,omitempty"`
+
+	// Env are the environment variables of the application.
+	Env map[string]string `yaml:",omitempty" json:"env,omitempty"`
+
+	// Ports are the ports of the host forwarded to the unikernel in the form
+	// [[HOSTIP:]HOSTPORT:]GUESTPORT[/PROTOCOL].
+	Ports []string `yaml:",omitempty" json:"ports,omitempty"`
+
+	// Volumes are the directories of the host shared with the unikernel in the
+	// form SOURCE:DESTINATION[:ro|rw].
+	Volumes []string `yaml:",omitempty" json:"volumes,omitempty"`
+
+	// Initrd is the path to the initial ramdisk passed to the unikernel.
+	Initrd string `yaml:",omitempty" json:"initrd,omitempty"`
+
+	// Driver is the name of the machine driver used to run the unikernel.
+	Driver string `yaml:",omitempty" json:"driver,omitempty"`
+}
+
+// MemorySize returns the amount of memory in MiB, or 0 if it is unset.
+func (rc RuntimeConfig) MemorySize() (uint64, error) {
+	memory := strings.TrimSuffix(strings.TrimSpace(rc.Memory), "B")
+	if len(memory) == 0 {
+		return 0, nil
+	}
+
+	// Without a unit, the amount is in MiB
+	multiplier := uint64(1 << 20)
+
+	for _, unit := range []struct {
+		suffix     string
+		multiplier uint64
+	}{
+		{"Ki", 1 << 10}, {"K", 1 << 10},
+		{"Mi", 1 << 20}, {"M", 1 << 20},
+		{"Gi", 1 << 30}, {"G", 1 << 30},
+	} {
+		if strings.HasSuffix(memory, unit.suffix) {
+			memory = strings.TrimSuffix(memory, unit.suffix)
+			multiplier = unit.multiplier
+			break
+		}
+	}
+
+	size, err := strconv.ParseUint(strings.TrimSpace(memory), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid memory size: %s", rc.Memory)
+	}
+
+	if size > math.MaxUint64/multiplier {
+		return 0, fmt.Errorf("memory size is too large: %s", rc.Memory)
+	}
+
+	size = size * multiplier >> 20
+	if size == 0 {
+		return 0, fmt.Errorf("memory size must be at least 1 MiB: %s", rc.Memory)
+	}
+
+	return size, nil
+}
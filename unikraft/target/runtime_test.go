@@ -0,0 +1,72 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Authors: Alexander Jung <alex@unikraft.io>
+//
+// Copyright (c) 2022, Unikraft GmbH.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+// 3. Neither the name of the copyright holder nor the names of its
+//    contributors may be used to endorse or promote products derived from
+//    this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package target
+
+import "testing"
+
+func TestMemorySize(t *testing.T) {
+	tests := []struct {
+		memory  string
+		size    uint64
+		wantErr bool
+	}{
+		{memory: "", size: 0},
+		{memory: "64", size: 64},
+		{memory: " 64 ", size: 64},
+		{memory: "64Mi", size: 64},
+		{memory: "64M", size: 64},
+		{memory: "64MB", size: 64},
+		{memory: "2048Ki", size: 2},
+		{memory: "1G", size: 1024},
+		{memory: "1Gi", size: 1024},
+		{memory: "512Ki", wantErr: true},
+		{memory: "0", wantErr: true},
+		{memory: "-1", wantErr: true},
+		{memory: "64Ti", wantErr: true},
+		{memory: "lots", wantErr: true},
+		{memory: "17592186044416Gi", wantErr: true},
+		{memory: "18446744073709551615", wantErr: true},
+		{memory: "17592186044415", size: 17592186044415},
+	}
+
+	for _, tt := range tests {
+		size, err := RuntimeConfig{Memory: tt.memory}.MemorySize()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: unexpected error: %v", tt.memory, err)
+			continue
+		}
+
+		if size != tt.size {
+			t.Errorf("%q: expected %d MiB, got %d MiB", tt.memory, tt.size, size)
+		}
+	}
+}
@@ -53,6 +53,8 @@ type TargetConfig struct {
 	KernelDbg    string                  `yaml:",omitempty" json:"kerneldbg,omitempty"`
 	Initrd       *initrd.InitrdConfig    `yaml:",omitempty" json:"initrd,omitempty"`
 	Command      []string                `yaml:",omitempty" json:"commands"`
+	Machine      string                  `yaml:",omitempty" json:"machine,omitempty"`
+	Runtime      RuntimeConfig           `yaml:",omitempty" json:"runtime,omitempty"`
 
 	Extensions map[string]interface{} `yaml:",inline" json:"-"`
 }